@@ -18,6 +18,30 @@ var (
 	EpoolLRUSampleSize = getEnvAsInt("REDIS_EPOOL_LRU_SAMPLE_SIZE", 5)
 )
 
+// Compact encoding thresholds: a small collection is stored in a listpack until it has more
+// than MaxListpackEntries elements or an element longer than MaxListpackValue bytes
+var (
+	HashMaxListpackEntries = getEnvAsInt("REDIS_HASH_MAX_LISTPACK_ENTRIES", 128)
+	HashMaxListpackValue   = getEnvAsInt("REDIS_HASH_MAX_LISTPACK_VALUE", 64)
+	SetMaxListpackEntries  = getEnvAsInt("REDIS_SET_MAX_LISTPACK_ENTRIES", 128)
+	SetMaxListpackValue    = getEnvAsInt("REDIS_SET_MAX_LISTPACK_VALUE", 64)
+	ZsetMaxListpackEntries = getEnvAsInt("REDIS_ZSET_MAX_LISTPACK_ENTRIES", 128)
+	ZsetMaxListpackValue   = getEnvAsInt("REDIS_ZSET_MAX_LISTPACK_VALUE", 64)
+	// SetMaxIntsetEntries is the limit of the intset encoding of the sets of integers
+	SetMaxIntsetEntries = getEnvAsInt("REDIS_SET_MAX_INTSET_ENTRIES", 512)
+)
+
+// Stream entries are packed in blocks of at most StreamNodeMaxEntries entries and StreamNodeMaxBytes bytes
+var (
+	StreamNodeMaxEntries = getEnvAsInt("REDIS_STREAM_NODE_MAX_ENTRIES", 100)
+	StreamNodeMaxBytes   = getEnvAsInt("REDIS_STREAM_NODE_MAX_BYTES", 4096)
+)
+
+// Sorted sets are indexed by a B+ tree ("btree") or a skip list ("skiplist")
+var (
+	ZsetIndexType = getEnv("REDIS_ZSET_INDEX_TYPE", "btree")
+)
+
 // HTTP Gateway configuration
 var (
 	HTTPPort         = getEnv("HTTP_PORT", ":8080")
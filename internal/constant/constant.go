@@ -3,6 +3,7 @@ package constant
 import "time"
 
 var RespNil = []byte("$-1\r\n")
+var RespNilArray = []byte("*-1\r\n")
 var RespOk = []byte("+OK\r\n")
 var RespZero = []byte(":0\r\n")
 var RespOne = []byte(":1\r\n")
@@ -12,9 +13,46 @@ var ActiveExpireFrequency = 100 * time.Millisecond
 var ActiveExpireSampleSize = 20
 var ActiveExpireThreshold = 0.1
 var DefaultBPlusTreeDegree = 4
+var BlockedClientsTimeoutFrequency = 100 * time.Millisecond
+
+// RandomMembersMaxCount bounds the negative count of HRANDFIELD, ZRANDMEMBER and
+// SRANDMEMBER, which repeats members and so is not bounded by the size of the collection
+const RandomMembersMaxCount = 1 << 20
 
 const BfDefaultInitCapacity = 100
 const BfDefaultErrRate = 0.01
+const BfDefaultExpansion = 2
+const BfMaxCapacity = 1 << 30
+
+const CfDefaultCapacity = 1024
+const CfDefaultBucketSize = 2
+const CfDefaultMaxIterations = 20
+const CfDefaultExpansion = 1
+const CfMaxCapacity = 1 << 30
+
+const TopkDefaultWidth = 8
+const TopkDefaultDepth = 7
+const TopkDefaultDecay = 0.9
+
+const TdigestDefaultCompression = 100
+
+const ScanDumpChunkSize = 64 * 1024
+
+// ReadBufferSize is the number of bytes read from a connection at once
+const ReadBufferSize = 16 * 1024
+
+// MaxQueryBufferSize is the maximum size of a command. A client sending a larger command gets
+// a protocol error and is disconnected.
+const MaxQueryBufferSize = 64 * 1024 * 1024
+
+const FtSearchDefaultLimit = 10
+
+const VsetDefaultM = 16
+const VsetDefaultEfConstruction = 200
+const VsetDefaultEfSearch = 100
+const VsetDefaultCount = 10
+
+const TsDefaultChunkSize = 4096
 
 const ServerStatusIdle int32 = 0
 const ServerStatusShutdown int32 = 1
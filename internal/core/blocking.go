@@ -0,0 +1,246 @@
+package core
+
+import (
+	"container/list"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/spaghetti-lover/multithread-redis/internal/constant"
+	"github.com/spaghetti-lover/multithread-redis/internal/data_structure/quick_list"
+	"github.com/spaghetti-lover/multithread-redis/internal/data_structure/sorted_set"
+	"github.com/spaghetti-lover/multithread-redis/internal/data_structure/stream"
+)
+
+// blockedClient is a client parked by a blocking command (BLPOP, BZPOPMIN, XREAD, ...) until
+// one of its keys receives data or its timeout expires.
+type blockedClient struct {
+	fd        int    // connection fd in the single-threaded server, -1 for workers
+	cmd       string // BLPOP, BRPOP, BLMOVE, BZPOPMIN, BZPOPMAX, XREAD or XREADGROUP
+	keys      []string
+	dest      string                     // BLMOVE only
+	whereFrom string                     // BLMOVE only
+	whereTo   string                     // BLMOVE only
+	streamIDs map[string]stream.StreamID // XREAD only, last ID seen per key
+	count     int                        // XREAD and XREADGROUP, 0 means no limit
+	group     string                     // XREADGROUP only
+	consumer  string                     // XREADGROUP only
+	noAck     bool                       // XREADGROUP only
+	deadline  time.Time
+	reply     func(res []byte)
+	closed    <-chan struct{} // closed when the client connection goes away, may be nil
+	elems     map[string]*list.Element
+}
+
+func (c *blockedClient) isClosed() bool {
+	if c.closed == nil {
+		return false
+	}
+	select {
+	case <-c.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// blockingManager keeps, for one keyspace, the clients blocked on each key in FIFO order
+// and the keys that received data since the last call to handleReadyKeys.
+type blockingManager struct {
+	lists     map[string]*quick_list.QuickList
+	zsets     map[string]*sorted_set.SortedSet
+	streams   map[string]*stream.Stream
+	waiting   map[string]*list.List // key -> FIFO of *blockedClient
+	clients   map[*blockedClient]struct{}
+	byFd      map[int]*blockedClient
+	readyKeys []string
+	readySet  map[string]struct{}
+}
+
+func newBlockingManager(lists map[string]*quick_list.QuickList, zsets map[string]*sorted_set.SortedSet, streams map[string]*stream.Stream) *blockingManager {
+	return &blockingManager{
+		lists:    lists,
+		zsets:    zsets,
+		streams:  streams,
+		waiting:  make(map[string]*list.List),
+		clients:  make(map[*blockedClient]struct{}),
+		byFd:     make(map[int]*blockedClient),
+		readySet: make(map[string]struct{}),
+	}
+}
+
+// parseBlockingTimeout parses a timeout in seconds (decimals allowed). Zero means block forever.
+func parseBlockingTimeout(arg string) (time.Time, error) {
+	timeout, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		return time.Time{}, errors.New("(error) ERR timeout is not a float or out of range")
+	}
+	if timeout < 0 {
+		return time.Time{}, errors.New("(error) ERR timeout is negative")
+	}
+	if timeout == 0 {
+		return time.Time{}, nil
+	}
+	return time.Now().Add(time.Duration(timeout * float64(time.Second))), nil
+}
+
+// block parks the client on all of its keys
+func (b *blockingManager) block(c *blockedClient) {
+	c.elems = make(map[string]*list.Element, len(c.keys))
+	for _, key := range c.keys {
+		if _, dup := c.elems[key]; dup {
+			continue
+		}
+		q, exist := b.waiting[key]
+		if !exist {
+			q = list.New()
+			b.waiting[key] = q
+		}
+		c.elems[key] = q.PushBack(c)
+	}
+	b.clients[c] = struct{}{}
+	if c.fd >= 0 {
+		b.byFd[c.fd] = c
+	}
+}
+
+// unblock removes the client from every key it is waiting on
+func (b *blockingManager) unblock(c *blockedClient) {
+	for key, elem := range c.elems {
+		q := b.waiting[key]
+		q.Remove(elem)
+		if q.Len() == 0 {
+			delete(b.waiting, key)
+		}
+	}
+	c.elems = nil
+	delete(b.clients, c)
+	if c.fd >= 0 && b.byFd[c.fd] == c {
+		delete(b.byFd, c.fd)
+	}
+}
+
+// unblockFd drops the client blocked on a connection that was closed
+func (b *blockingManager) unblockFd(fd int) {
+	if c, exist := b.byFd[fd]; exist {
+		b.unblock(c)
+	}
+}
+
+// signalKeyAsReady is called by every command that adds data to a list, a sorted set or a stream
+func (b *blockingManager) signalKeyAsReady(key string) {
+	if _, waiting := b.waiting[key]; !waiting {
+		return
+	}
+	if _, exist := b.readySet[key]; exist {
+		return
+	}
+	b.readySet[key] = struct{}{}
+	b.readyKeys = append(b.readyKeys, key)
+}
+
+// handleReadyKeys serves the clients blocked on the keys that received data, in FIFO order.
+// Serving a BLMOVE can make its destination ready, so loop until nothing is left.
+func (b *blockingManager) handleReadyKeys() {
+	for len(b.readyKeys) > 0 {
+		keys := b.readyKeys
+		b.readyKeys = nil
+		b.readySet = make(map[string]struct{})
+		for _, key := range keys {
+			b.serveKey(key)
+		}
+	}
+}
+
+func (b *blockingManager) serveKey(key string) {
+	q, exist := b.waiting[key]
+	if !exist {
+		return
+	}
+	for elem := q.Front(); elem != nil; {
+		c := elem.Value.(*blockedClient)
+		elem = elem.Next()
+		if c.isClosed() {
+			b.unblock(c)
+			continue
+		}
+		res := b.serveClient(c, key)
+		if res == nil {
+			if c.cmd == "XREAD" || c.cmd == "XREADGROUP" {
+				// Reading a stream does not consume its entries for other readers and groups,
+				// clients after this one may still be served
+				continue
+			}
+			// No more data for this key
+			return
+		}
+		b.unblock(c)
+		c.reply(res)
+	}
+}
+
+// serveClient pops (or reads, for XREAD) data from key for a blocked client.
+// Returns nil if key has no data for the client.
+func (b *blockingManager) serveClient(c *blockedClient, key string) []byte {
+	switch c.cmd {
+	case "BLPOP", "BRPOP":
+		ql, exist := b.lists[key]
+		if !exist || ql.Len() == 0 {
+			return nil
+		}
+		v := listPop(b.lists, key, c.cmd == "BLPOP")
+		return Encode([]string{key, v}, false)
+	case "BLMOVE":
+		ql, exist := b.lists[key]
+		if !exist || ql.Len() == 0 {
+			return nil
+		}
+		v := listMove(b, key, c.dest, c.whereFrom, c.whereTo)
+		return Encode(v, false)
+	case "BZPOPMIN", "BZPOPMAX":
+		zset, exist := b.zsets[key]
+		if !exist || zset.Len() == 0 {
+			return nil
+		}
+		item := zsetPop(b.zsets, key, c.cmd == "BZPOPMIN", 1)[0]
+		return Encode([]string{key, item.Member, formatScore(item.Score)}, false)
+	case "XREAD":
+		s, exist := b.streams[key]
+		if !exist {
+			return nil
+		}
+		entries := streamReadAfter(s, c.streamIDs[key], c.count)
+		if len(entries) == 0 {
+			return nil
+		}
+		return Encode([]interface{}{[]interface{}{key, streamEntriesReply(entries)}}, false)
+	case "XREADGROUP":
+		s, g, err := lookupGroup(b.streams, key, c.group, "XREADGROUP")
+		if err != nil {
+			// The group was destroyed while the client was blocked
+			return Encode(err, false)
+		}
+		now := time.Now().UnixMilli()
+		consumer, _ := g.CreateConsumer(c.consumer, now)
+		entries := s.ReadGroup(g, consumer, c.count, c.noAck, now)
+		if len(entries) == 0 {
+			return nil
+		}
+		return Encode([]interface{}{[]interface{}{key, streamEntriesReply(entries)}}, false)
+	}
+	return nil
+}
+
+// expireTimeouts replies a null array to the blocked clients whose timeout has passed
+func (b *blockingManager) expireTimeouts(now time.Time) {
+	for c := range b.clients {
+		if c.isClosed() {
+			b.unblock(c)
+			continue
+		}
+		if !c.deadline.IsZero() && now.After(c.deadline) {
+			b.unblock(c)
+			c.reply(constant.RespNilArray)
+		}
+	}
+}
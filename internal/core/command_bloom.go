@@ -0,0 +1,399 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spaghetti-lover/multithread-redis/internal/constant"
+	"github.com/spaghetti-lover/multithread-redis/internal/data_structure/probabilistic"
+)
+
+// bloomFilter is a Bloom filter created by BF.RESERVE, BF.INSERT or the first BF.ADD.
+// A filter holding its capacity grows by adding a sub-filter, unless it is non scaling.
+type bloomFilter struct {
+	filter     *probabilistic.ScalableBloom
+	nonScaling bool
+}
+
+// bloomOptions holds the parameters of a new filter
+type bloomOptions struct {
+	capacity   uint64
+	errorRate  float64
+	expansion  int
+	nonScaling bool
+}
+
+func defaultBloomOptions() *bloomOptions {
+	return &bloomOptions{
+		capacity:  constant.BfDefaultInitCapacity,
+		errorRate: constant.BfDefaultErrRate,
+		expansion: constant.BfDefaultExpansion,
+	}
+}
+
+func newBloomFilter(o *bloomOptions) *bloomFilter {
+	return &bloomFilter{
+		filter:     probabilistic.NewScalableBloomFilter(o.capacity, o.errorRate, o.expansion),
+		nonScaling: o.nonScaling,
+	}
+}
+
+// add adds item and returns 1 if it was not in the filter yet, else 0
+func (bf *bloomFilter) add(item string) interface{} {
+	if bf.nonScaling {
+		if bf.filter.Exist(item) {
+			return 0
+		}
+		if bf.filter.Count() >= bf.filter.Capacity() {
+			return errors.New("(error) ERR non scaling filter is full")
+		}
+	}
+	if bf.filter.TryAdd(item) {
+		return 1
+	}
+	return 0
+}
+
+func (bf *bloomFilter) exists(item string) int {
+	if bf.filter.Exist(item) {
+		return 1
+	}
+	return 0
+}
+
+func parseErrorRate(s string) (float64, error) {
+	errorRate, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, errors.New("(error) ERR bad error rate")
+	}
+	if errorRate <= 0 || errorRate >= 1 {
+		return 0, errors.New("(error) ERR (0 < error rate range < 1)")
+	}
+	return errorRate, nil
+}
+
+// parseCapacity parses the capacity of a new filter, at most limit items
+func parseCapacity(s string, limit uint64) (uint64, error) {
+	capacity, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, errors.New("(error) ERR bad capacity")
+	}
+	if capacity <= 0 {
+		return 0, errors.New("(error) ERR (capacity should be larger than 0)")
+	}
+	if uint64(capacity) > limit {
+		return 0, fmt.Errorf("(error) ERR (capacity should be at most %d)", limit)
+	}
+	return uint64(capacity), nil
+}
+
+// checkSize rejects the options of a filter larger than probabilistic.MaxBloomBits, the
+// number of bits per item growing as the error rate gets smaller
+func (o *bloomOptions) checkSize() error {
+	if o.capacity > probabilistic.MaxBloomEntries(o.errorRate) {
+		return errors.New("(error) ERR (filter is too large for this capacity and error rate)")
+	}
+	return nil
+}
+
+func parseExpansion(s string) (int, error) {
+	expansion, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, errors.New("(error) ERR bad expansion")
+	}
+	if expansion < 1 {
+		return 0, errors.New("(error) ERR expansion should be greater or equal to 1")
+	}
+	return expansion, nil
+}
+
+// cmdBFRESERVE implements BF.RESERVE key error_rate capacity [EXPANSION expansion] [NONSCALING]
+func cmdBFRESERVE(args []string) []byte {
+	if len(args) < 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'BF.RESERVE' command"), false)
+	}
+	o := defaultBloomOptions()
+	var err error
+	if o.errorRate, err = parseErrorRate(args[1]); err != nil {
+		return Encode(err, false)
+	}
+	if o.capacity, err = parseCapacity(args[2], constant.BfMaxCapacity); err != nil {
+		return Encode(err, false)
+	}
+	hasExpansion := false
+	for i := 3; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "EXPANSION":
+			if i+1 >= len(args) {
+				return Encode(errors.New("(error) ERR syntax error"), false)
+			}
+			if o.expansion, err = parseExpansion(args[i+1]); err != nil {
+				return Encode(err, false)
+			}
+			hasExpansion = true
+			i++
+		case "NONSCALING":
+			o.nonScaling = true
+		default:
+			return Encode(errors.New("(error) ERR syntax error"), false)
+		}
+	}
+	if o.nonScaling && hasExpansion {
+		return Encode(errors.New("(error) ERR nonscaling filters cannot expand"), false)
+	}
+	if err := o.checkSize(); err != nil {
+		return Encode(err, false)
+	}
+
+	key := args[0]
+	if _, exist := bfStore[key]; exist {
+		return Encode(errors.New("(error) ERR item exists"), false)
+	}
+	bfStore[key] = newBloomFilter(o)
+	return constant.RespOk
+}
+
+// getOrCreateBloomFilter returns the filter stored at key, creating it with the default
+// parameters if needed
+func getOrCreateBloomFilter(key string) *bloomFilter {
+	bf, exist := bfStore[key]
+	if !exist {
+		bf = newBloomFilter(defaultBloomOptions())
+		bfStore[key] = bf
+	}
+	return bf
+}
+
+func cmdBFADD(args []string) []byte {
+	if len(args) != 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'BF.ADD' command"), false)
+	}
+	return Encode(getOrCreateBloomFilter(args[0]).add(args[1]), false)
+}
+
+func cmdBFMADD(args []string) []byte {
+	if len(args) < 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'BF.MADD' command"), false)
+	}
+	bf := getOrCreateBloomFilter(args[0])
+	res := make([]interface{}, 0, len(args)-1)
+	for _, item := range args[1:] {
+		res = append(res, bf.add(item))
+	}
+	return Encode(res, false)
+}
+
+// cmdBFINSERT implements BF.INSERT key [CAPACITY capacity] [ERROR error] [EXPANSION expansion]
+// [NOCREATE] [NONSCALING] ITEMS item [item ...]
+func cmdBFINSERT(args []string) []byte {
+	if len(args) < 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'BF.INSERT' command"), false)
+	}
+	o := defaultBloomOptions()
+	noCreate := false
+	var items []string
+	var err error
+	hasExpansion := false
+options:
+	for i := 1; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i]); opt {
+		case "ITEMS":
+			items = args[i+1:]
+			break options
+		case "NOCREATE":
+			noCreate = true
+		case "NONSCALING":
+			o.nonScaling = true
+		case "CAPACITY", "ERROR", "EXPANSION":
+			if i+1 >= len(args) {
+				return Encode(errors.New("(error) ERR syntax error"), false)
+			}
+			switch opt {
+			case "CAPACITY":
+				o.capacity, err = parseCapacity(args[i+1], constant.BfMaxCapacity)
+			case "ERROR":
+				o.errorRate, err = parseErrorRate(args[i+1])
+			default:
+				o.expansion, err = parseExpansion(args[i+1])
+				hasExpansion = true
+			}
+			if err != nil {
+				return Encode(err, false)
+			}
+			i++
+		default:
+			return Encode(errors.New("(error) ERR syntax error"), false)
+		}
+	}
+	if o.nonScaling && hasExpansion {
+		return Encode(errors.New("(error) ERR nonscaling filters cannot expand"), false)
+	}
+	if len(items) == 0 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'BF.INSERT' command"), false)
+	}
+
+	key := args[0]
+	bf, exist := bfStore[key]
+	if !exist {
+		if noCreate {
+			return Encode(errors.New("(error) ERR not found"), false)
+		}
+		if err := o.checkSize(); err != nil {
+			return Encode(err, false)
+		}
+		bf = newBloomFilter(o)
+		bfStore[key] = bf
+	}
+	res := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		res = append(res, bf.add(item))
+	}
+	return Encode(res, false)
+}
+
+func cmdBFEXISTS(args []string) []byte {
+	if len(args) != 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'BF.EXISTS' command"), false)
+	}
+	bf, exist := bfStore[args[0]]
+	if !exist {
+		return constant.RespZero
+	}
+	return Encode(bf.exists(args[1]), false)
+}
+
+func cmdBFMEXISTS(args []string) []byte {
+	if len(args) < 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'BF.MEXISTS' command"), false)
+	}
+	bf, exist := bfStore[args[0]]
+	res := make([]interface{}, 0, len(args)-1)
+	for _, item := range args[1:] {
+		if !exist {
+			res = append(res, 0)
+			continue
+		}
+		res = append(res, bf.exists(item))
+	}
+	return Encode(res, false)
+}
+
+func cmdBFCARD(args []string) []byte {
+	if len(args) != 1 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'BF.CARD' command"), false)
+	}
+	bf, exist := bfStore[args[0]]
+	if !exist {
+		return constant.RespZero
+	}
+	return Encode(int64(bf.filter.Count()), false)
+}
+
+// cmdBFINFO implements BF.INFO key [CAPACITY | SIZE | FILTERS | ITEMS | EXPANSION]
+func cmdBFINFO(args []string) []byte {
+	if len(args) < 1 || len(args) > 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'BF.INFO' command"), false)
+	}
+	bf, exist := bfStore[args[0]]
+	if !exist {
+		return Encode(errors.New("(error) ERR not found"), false)
+	}
+	var expansion interface{}
+	if !bf.nonScaling {
+		expansion = bf.filter.Expansion
+	}
+	info := []struct {
+		field string
+		name  string
+		value interface{}
+	}{
+		{"CAPACITY", "Capacity", int64(bf.filter.Capacity())},
+		{"SIZE", "Size", int64(bf.filter.Bytes())},
+		{"FILTERS", "Number of filters", bf.filter.Filters()},
+		{"ITEMS", "Number of items inserted", int64(bf.filter.Count())},
+		{"EXPANSION", "Expansion rate", expansion},
+	}
+
+	if len(args) == 2 {
+		field := strings.ToUpper(args[1])
+		for _, x := range info {
+			if x.field == field {
+				return Encode([]interface{}{x.value}, false)
+			}
+		}
+		return Encode(errors.New("(error) ERR Invalid information value"), false)
+	}
+	res := make([]interface{}, 0, 2*len(info))
+	for _, x := range info {
+		res = append(res, x.name, x.value)
+	}
+	return Encode(res, false)
+}
+
+// header encodes the parameters of the filter for BF.SCANDUMP
+func (bf *bloomFilter) header() []byte {
+	flag := byte(0)
+	if bf.nonScaling {
+		flag = 1
+	}
+	return append([]byte{flag}, bf.filter.MarshalHeader()...)
+}
+
+// newBloomFilterFromHeader creates an empty filter from a header of BF.SCANDUMP
+func newBloomFilterFromHeader(header []byte) (*bloomFilter, error) {
+	if len(header) == 0 || header[0] > 1 {
+		return nil, probabilistic.ErrInvalidEncoding
+	}
+	bf := &bloomFilter{filter: &probabilistic.ScalableBloom{}, nonScaling: header[0] == 1}
+	if err := bf.filter.UnmarshalHeader(header[1:]); err != nil {
+		return nil, err
+	}
+	return bf, nil
+}
+
+// cmdBFSCANDUMP implements BF.SCANDUMP key iterator
+func cmdBFSCANDUMP(args []string) []byte {
+	if len(args) != 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'BF.SCANDUMP' command"), false)
+	}
+	bf, exist := bfStore[args[0]]
+	if !exist {
+		return Encode(errors.New("(error) ERR not found"), false)
+	}
+	iter, err := parseIterator(args[1])
+	if err != nil {
+		return Encode(err, false)
+	}
+	return scanDump(bf.filter, bf.header(), iter)
+}
+
+// cmdBFLOADCHUNK implements BF.LOADCHUNK key iterator data. The header replaces the filter
+// stored at key.
+func cmdBFLOADCHUNK(args []string) []byte {
+	if len(args) != 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'BF.LOADCHUNK' command"), false)
+	}
+	key := args[0]
+	iter, err := parseIterator(args[1])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if iter == 1 {
+		bf, err := newBloomFilterFromHeader([]byte(args[2]))
+		if err != nil {
+			return Encode(errors.New("(error) ERR received bad data"), false)
+		}
+		bfStore[key] = bf
+		return constant.RespOk
+	}
+	bf, exist := bfStore[key]
+	if !exist {
+		return Encode(errors.New("(error) ERR not found"), false)
+	}
+	if err := loadChunk(bf.filter, iter, args[2]); err != nil {
+		return Encode(err, false)
+	}
+	return constant.RespOk
+}
@@ -0,0 +1,95 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// decodeReply decodes a reply, an error being decoded as its message
+func decodeReply(t *testing.T, res []byte) interface{} {
+	value, err := Decode(res)
+	assert.NoError(t, err)
+	return value
+}
+
+func TestBFRESERVEArguments(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected interface{}
+	}{
+		{[]string{"bf:args"}, "(error) ERR wrong number of arguments for 'BF.RESERVE' command"},
+		{[]string{"bf:args", "rate", "100"}, "(error) ERR bad error rate"},
+		{[]string{"bf:args", "0", "100"}, "(error) ERR (0 < error rate range < 1)"},
+		{[]string{"bf:args", "1", "100"}, "(error) ERR (0 < error rate range < 1)"},
+		{[]string{"bf:args", "0.01", "many"}, "(error) ERR bad capacity"},
+		{[]string{"bf:args", "0.01", "0"}, "(error) ERR (capacity should be larger than 0)"},
+		{[]string{"bf:args", "0.01", "-5"}, "(error) ERR (capacity should be larger than 0)"},
+		{[]string{"bf:args", "0.01", "100000000000000"}, "(error) ERR (capacity should be at most 1073741824)"},
+		{[]string{"bf:args", "0.0000001", "1000000000"}, "(error) ERR (filter is too large for this capacity and error rate)"},
+		{[]string{"bf:args", "0.01", "100", "EXPANSION"}, "(error) ERR syntax error"},
+		{[]string{"bf:args", "0.01", "100", "EXPANSION", "x"}, "(error) ERR bad expansion"},
+		{[]string{"bf:args", "0.01", "100", "EXPANSION", "0"}, "(error) ERR expansion should be greater or equal to 1"},
+		{[]string{"bf:args", "0.01", "100", "NONSCALING", "EXPANSION", "2"}, "(error) ERR nonscaling filters cannot expand"},
+		{[]string{"bf:args", "0.01", "100", "SCALING"}, "(error) ERR syntax error"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, decodeReply(t, cmdBFRESERVE(tt.args)), tt.args)
+	}
+	_, exist := bfStore["bf:args"]
+	assert.False(t, exist)
+
+	assert.Equal(t, "OK", decodeReply(t, cmdBFRESERVE([]string{"bf:args", "0.001", "500"})))
+	assert.Equal(t, "(error) ERR item exists", decodeReply(t, cmdBFRESERVE([]string{"bf:args", "0.01", "100"})))
+	bf := bfStore["bf:args"]
+	assert.Equal(t, uint64(500), bf.filter.Capacity())
+	assert.Equal(t, 0.001, bf.filter.Error)
+	assert.False(t, bf.nonScaling)
+}
+
+func TestBFRESERVEOptions(t *testing.T) {
+	assert.Equal(t, "OK", decodeReply(t, cmdBFRESERVE([]string{"bf:expansion", "0.01", "10", "expansion", "4"})))
+	assert.Equal(t, []interface{}{int64(4)}, decodeReply(t, cmdBFINFO([]string{"bf:expansion", "EXPANSION"})))
+
+	assert.Equal(t, "OK", decodeReply(t, cmdBFRESERVE([]string{"bf:default", "0.01", "10"})))
+	assert.Equal(t, []interface{}{int64(2)}, decodeReply(t, cmdBFINFO([]string{"bf:default", "EXPANSION"})))
+
+	// A non scaling filter refuses new items once full instead of growing
+	assert.Equal(t, "OK", decodeReply(t, cmdBFRESERVE([]string{"bf:fixed", "0.01", "3", "NONSCALING"})))
+	assert.Equal(t, []interface{}{"Null value"}, decodeReply(t, cmdBFINFO([]string{"bf:fixed", "EXPANSION"})))
+	res := decodeReply(t, cmdBFMADD([]string{"bf:fixed", "a", "b", "c", "d"}))
+	assert.Equal(t, []interface{}{int64(1), int64(1), int64(1), "(error) ERR non scaling filter is full"}, res)
+	assert.Equal(t, []interface{}{int64(1)}, decodeReply(t, cmdBFINFO([]string{"bf:fixed", "FILTERS"})))
+
+	// A scaling filter adds a sub-filter instead
+	for _, item := range []string{"a", "b", "c", "d"} {
+		assert.Equal(t, int64(1), decodeReply(t, cmdBFADD([]string{"bf:default", item})))
+	}
+	for _, item := range []string{"e", "f", "g", "h", "i", "j", "k"} {
+		cmdBFADD([]string{"bf:default", item})
+	}
+	assert.Equal(t, []interface{}{int64(2)}, decodeReply(t, cmdBFINFO([]string{"bf:default", "FILTERS"})))
+}
+
+func TestBFAddExists(t *testing.T) {
+	assert.Equal(t, int64(1), decodeReply(t, cmdBFADD([]string{"bf:items", "x"})))
+	assert.Equal(t, int64(0), decodeReply(t, cmdBFADD([]string{"bf:items", "x"})))
+	assert.Equal(t, []interface{}{int64(0), int64(1)}, decodeReply(t, cmdBFMADD([]string{"bf:items", "x", "y"})))
+
+	assert.Equal(t, int64(1), decodeReply(t, cmdBFEXISTS([]string{"bf:items", "y"})))
+	assert.Equal(t, int64(0), decodeReply(t, cmdBFEXISTS([]string{"bf:missing", "y"})))
+	assert.Equal(t, []interface{}{int64(1), int64(1), int64(0)}, decodeReply(t, cmdBFMEXISTS([]string{"bf:items", "x", "y", "z"})))
+	assert.Equal(t, []interface{}{int64(0), int64(0)}, decodeReply(t, cmdBFMEXISTS([]string{"bf:missing", "x", "y"})))
+
+	// BF.ADD creates the filter with the default parameters
+	info := decodeReply(t, cmdBFINFO([]string{"bf:items"})).([]interface{})
+	assert.Equal(t, []interface{}{
+		"Capacity", int64(100),
+		"Size", info[3],
+		"Number of filters", int64(1),
+		"Number of items inserted", int64(2),
+		"Expansion rate", int64(2),
+	}, info)
+	assert.Equal(t, "(error) ERR Invalid information value", decodeReply(t, cmdBFINFO([]string{"bf:items", "WIDTH"})))
+	assert.Equal(t, "(error) ERR not found", decodeReply(t, cmdBFINFO([]string{"bf:missing"})))
+}
@@ -3,8 +3,8 @@ package core
 import (
 	"errors"
 	"fmt"
-	"math"
 	"strconv"
+	"strings"
 
 	"github.com/spaghetti-lover/multithread-redis/internal/constant"
 	"github.com/spaghetti-lover/multithread-redis/internal/data_structure/probabilistic"
@@ -76,17 +76,21 @@ func cmdCMSINCRBY(args []string) []byte {
 		return Encode(errors.New("CMS: key does not exist"), false)
 	}
 
-	var res []string
-	for i := 1; i < len(args); i += 2 {
-		item := args[i]
-		value, err := strconv.ParseUint(args[i+1], 10, 64)
+	// All the increments are checked before the sketch is modified
+	values := make([]uint64, 0, len(args)/2)
+	for i := 2; i < len(args); i += 2 {
+		value, err := strconv.ParseUint(args[i], 10, 64)
 		if err != nil {
-			return Encode(fmt.Errorf("increment must be a non negative integer number %s", args[1]), false)
+			return Encode(fmt.Errorf("increment must be a non negative integer number %s", args[i]), false)
 		}
-		count := cms.IncrBy(item, uint64(value))
-		if count == math.MaxUint64 {
-			res = append(res, "CMS: INCRBY overflow")
-			continue
+		values = append(values, value)
+	}
+
+	var res []string
+	for i, value := range values {
+		count, err := cms.IncrBy(args[1+2*i], value)
+		if err != nil {
+			return Encode(err, false)
 		}
 		res = append(res, fmt.Sprintf("%d", count))
 	}
@@ -111,3 +115,104 @@ func cmdCMSQUERY(args []string) []byte {
 	}
 	return Encode(res, false)
 }
+
+// cmdCMSMERGE implements CMS.MERGE destination numKeys source [source ...] [WEIGHTS weight [weight ...]]
+func cmdCMSMERGE(args []string) []byte {
+	if len(args) < 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'CMS.MERGE' command"), false)
+	}
+	dest, exist := cmsStore[args[0]]
+	if !exist {
+		return Encode(errors.New("CMS: key does not exist"), false)
+	}
+	numKeys, err := strconv.Atoi(args[1])
+	if err != nil || numKeys <= 0 || numKeys > len(args)-2 {
+		return Encode(errors.New("CMS: invalid numkeys"), false)
+	}
+
+	sources := make([]probabilistic.FrequencyEstimator, numKeys)
+	for i, key := range args[2 : 2+numKeys] {
+		if sources[i], exist = cmsStore[key]; !exist {
+			return Encode(errors.New("CMS: key does not exist"), false)
+		}
+	}
+	weights := make([]uint64, numKeys)
+	for i := range weights {
+		weights[i] = 1
+	}
+	if rest := args[2+numKeys:]; len(rest) > 0 {
+		if strings.ToUpper(rest[0]) != "WEIGHTS" || len(rest)-1 != numKeys {
+			return Encode(errors.New("(error) ERR syntax error"), false)
+		}
+		for i, w := range rest[1:] {
+			if weights[i], err = strconv.ParseUint(w, 10, 64); err != nil {
+				return Encode(errors.New("CMS: invalid weight value"), false)
+			}
+		}
+	}
+
+	if err := dest.Merge(sources, weights); err != nil {
+		return Encode(err, false)
+	}
+	return constant.RespOk
+}
+
+func cmdCMSINFO(args []string) []byte {
+	if len(args) != 1 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'CMS.INFO' command"), false)
+	}
+	cms, exist := cmsStore[args[0]]
+	if !exist {
+		return Encode(errors.New("CMS: key does not exist"), false)
+	}
+	return Encode([]interface{}{
+		"width", int64(cms.Width()),
+		"depth", int64(cms.Depth()),
+		"count", int64(cms.Total()),
+	}, false)
+}
+
+// cmdCMSSCANDUMP implements CMS.SCANDUMP key iterator, see BF.SCANDUMP
+func cmdCMSSCANDUMP(args []string) []byte {
+	if len(args) != 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'CMS.SCANDUMP' command"), false)
+	}
+	cms, exist := cmsStore[args[0]]
+	if !exist {
+		return Encode(errors.New("CMS: key does not exist"), false)
+	}
+	iter, err := parseIterator(args[1])
+	if err != nil {
+		return Encode(err, false)
+	}
+	s := cms.(probabilistic.ChunkedSketch)
+	return scanDump(s, s.MarshalHeader(), iter)
+}
+
+// cmdCMSLOADCHUNK implements CMS.LOADCHUNK key iterator data, see BF.LOADCHUNK
+func cmdCMSLOADCHUNK(args []string) []byte {
+	if len(args) != 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'CMS.LOADCHUNK' command"), false)
+	}
+	key := args[0]
+	iter, err := parseIterator(args[1])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if iter == 1 {
+		cms := &probabilistic.CMS{}
+		if err := cms.UnmarshalHeader([]byte(args[2])); err != nil {
+			return Encode(errors.New("CMS: received bad data"), false)
+		}
+		cmsStore[key] = cms
+		return constant.RespOk
+	}
+	cms, exist := cmsStore[key]
+	if !exist {
+		return Encode(errors.New("CMS: key does not exist"), false)
+	}
+	if err := loadChunk(cms.(probabilistic.ChunkedSketch), iter, args[2]); err != nil {
+		return Encode(err, false)
+	}
+	return constant.RespOk
+}
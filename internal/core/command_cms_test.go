@@ -0,0 +1,19 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCMSINCRBYValidatesIncrements(t *testing.T) {
+	assert.Equal(t, "OK", decodeReply(t, cmdCMSINITBYDIM([]string{"cms:incr", "100", "5"})))
+
+	// A bad increment leaves the sketch untouched
+	res := decodeReply(t, cmdCMSINCRBY([]string{"cms:incr", "a", "5", "b", "-1"}))
+	assert.Equal(t, "increment must be a non negative integer number -1", res)
+	assert.Equal(t, []interface{}{"0", "0"}, decodeReply(t, cmdCMSQUERY([]string{"cms:incr", "a", "b"})))
+
+	res = decodeReply(t, cmdCMSINCRBY([]string{"cms:incr", "a", "5", "b", "2"}))
+	assert.Equal(t, []interface{}{"5", "2"}, res)
+}
@@ -0,0 +1,210 @@
+package core
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/spaghetti-lover/multithread-redis/internal/constant"
+	"github.com/spaghetti-lover/multithread-redis/internal/data_structure/probabilistic"
+)
+
+func newCuckooFilter(capacity uint64) *probabilistic.CuckooFilter {
+	return probabilistic.NewCuckooFilter(capacity, constant.CfDefaultBucketSize, constant.CfDefaultMaxIterations, constant.CfDefaultExpansion)
+}
+
+// parseBoundedInt parses an integer option of CF.RESERVE in [min, max]
+func parseBoundedInt(s string, min, max int, name string) (int, error) {
+	v, err := strconv.Atoi(s)
+	if err != nil || v < min || v > max {
+		return 0, errors.New("(error) ERR Bad " + name)
+	}
+	return v, nil
+}
+
+// cmdCFRESERVE implements CF.RESERVE key capacity [BUCKETSIZE bucketsize]
+// [MAXITERATIONS maxiterations] [EXPANSION expansion]
+func cmdCFRESERVE(args []string) []byte {
+	if len(args) < 2 || len(args)%2 != 0 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'CF.RESERVE' command"), false)
+	}
+	capacity, err := parseCapacity(args[1], constant.CfMaxCapacity)
+	if err != nil {
+		return Encode(err, false)
+	}
+	bucketSize, maxIterations, expansion := constant.CfDefaultBucketSize, constant.CfDefaultMaxIterations, constant.CfDefaultExpansion
+	for i := 2; i < len(args); i += 2 {
+		switch strings.ToUpper(args[i]) {
+		case "BUCKETSIZE":
+			bucketSize, err = parseBoundedInt(args[i+1], 1, 255, "bucket size")
+		case "MAXITERATIONS":
+			maxIterations, err = parseBoundedInt(args[i+1], 1, 65535, "max iterations")
+		case "EXPANSION":
+			expansion, err = parseBoundedInt(args[i+1], 0, 32768, "expansion")
+		default:
+			err = errors.New("(error) ERR syntax error")
+		}
+		if err != nil {
+			return Encode(err, false)
+		}
+	}
+
+	key := args[0]
+	if _, exist := cfStore[key]; exist {
+		return Encode(errors.New("(error) ERR item exists"), false)
+	}
+	cfStore[key] = probabilistic.NewCuckooFilter(capacity, bucketSize, maxIterations, expansion)
+	return constant.RespOk
+}
+
+// cuckooAdd adds item and returns 1, 0 if nx is set and item may already be in the
+// filter, or -1 if the filter is full
+func cuckooAdd(cf *probabilistic.CuckooFilter, item string, nx bool) int {
+	if nx && cf.Exist(item) {
+		return 0
+	}
+	if !cf.Insert(item) {
+		return -1
+	}
+	return 1
+}
+
+// cfAddGeneric implements CF.ADD and CF.ADDNX key item
+func cfAddGeneric(cmd string, args []string, nx bool) []byte {
+	if len(args) != 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for '"+cmd+"' command"), false)
+	}
+	key := args[0]
+	cf, exist := cfStore[key]
+	if !exist {
+		cf = newCuckooFilter(constant.CfDefaultCapacity)
+		cfStore[key] = cf
+	}
+	res := cuckooAdd(cf, args[1], nx)
+	if res < 0 {
+		return Encode(errors.New("(error) ERR Filter is full"), false)
+	}
+	return Encode(res, false)
+}
+
+// cfInsertGeneric implements CF.INSERT and CF.INSERTNX key [CAPACITY capacity] [NOCREATE]
+// ITEMS item [item ...]
+func cfInsertGeneric(cmd string, args []string, nx bool) []byte {
+	if len(args) < 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for '"+cmd+"' command"), false)
+	}
+	capacity := uint64(constant.CfDefaultCapacity)
+	noCreate := false
+	var items []string
+	var err error
+options:
+	for i := 1; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "ITEMS":
+			items = args[i+1:]
+			break options
+		case "NOCREATE":
+			noCreate = true
+		case "CAPACITY":
+			if i+1 >= len(args) {
+				return Encode(errors.New("(error) ERR syntax error"), false)
+			}
+			if capacity, err = parseCapacity(args[i+1], constant.CfMaxCapacity); err != nil {
+				return Encode(err, false)
+			}
+			i++
+		default:
+			return Encode(errors.New("(error) ERR syntax error"), false)
+		}
+	}
+	if len(items) == 0 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for '"+cmd+"' command"), false)
+	}
+
+	key := args[0]
+	cf, exist := cfStore[key]
+	if !exist {
+		if noCreate {
+			return Encode(errors.New("(error) ERR not found"), false)
+		}
+		cf = newCuckooFilter(capacity)
+		cfStore[key] = cf
+	}
+	res := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		res = append(res, cuckooAdd(cf, item, nx))
+	}
+	return Encode(res, false)
+}
+
+func cmdCFEXISTS(args []string) []byte {
+	if len(args) != 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'CF.EXISTS' command"), false)
+	}
+	cf, exist := cfStore[args[0]]
+	if !exist || !cf.Exist(args[1]) {
+		return constant.RespZero
+	}
+	return constant.RespOne
+}
+
+func cmdCFMEXISTS(args []string) []byte {
+	if len(args) < 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'CF.MEXISTS' command"), false)
+	}
+	cf, exist := cfStore[args[0]]
+	res := make([]interface{}, 0, len(args)-1)
+	for _, item := range args[1:] {
+		if exist && cf.Exist(item) {
+			res = append(res, 1)
+		} else {
+			res = append(res, 0)
+		}
+	}
+	return Encode(res, false)
+}
+
+func cmdCFDEL(args []string) []byte {
+	if len(args) != 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'CF.DEL' command"), false)
+	}
+	cf, exist := cfStore[args[0]]
+	if !exist {
+		return Encode(errors.New("(error) ERR Not found"), false)
+	}
+	if !cf.Delete(args[1]) {
+		return constant.RespZero
+	}
+	return constant.RespOne
+}
+
+func cmdCFCOUNT(args []string) []byte {
+	if len(args) != 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'CF.COUNT' command"), false)
+	}
+	cf, exist := cfStore[args[0]]
+	if !exist {
+		return constant.RespZero
+	}
+	return Encode(cf.Count(args[1]), false)
+}
+
+func cmdCFINFO(args []string) []byte {
+	if len(args) != 1 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'CF.INFO' command"), false)
+	}
+	cf, exist := cfStore[args[0]]
+	if !exist {
+		return Encode(errors.New("(error) ERR not found"), false)
+	}
+	return Encode([]interface{}{
+		"Size", int64(cf.Bytes()),
+		"Number of buckets", int64(cf.Buckets()),
+		"Number of filters", cf.Filters(),
+		"Number of items inserted", int64(cf.Items()),
+		"Number of items deleted", int64(cf.Deletes()),
+		"Bucket size", cf.BucketSize,
+		"Expansion rate", cf.Expansion,
+		"Max iterations", cf.MaxIterations,
+	}, false)
+}
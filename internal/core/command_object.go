@@ -0,0 +1,60 @@
+package core
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/spaghetti-lover/multithread-redis/internal/constant"
+)
+
+// embstrSizeLimit is the length up to which Redis embeds a string in its object header
+const embstrSizeLimit = 44
+
+// cmdOBJECT implements OBJECT ENCODING key
+func cmdOBJECT(args []string) []byte {
+	if len(args) == 0 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'OBJECT' command"), false)
+	}
+	if strings.ToUpper(args[0]) != "ENCODING" {
+		return Encode(errors.New("(error) ERR unknown subcommand '"+args[0]+"'. Try OBJECT HELP."), false)
+	}
+	if len(args) != 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'OBJECT|ENCODING' command"), false)
+	}
+	encoding, exist := objectEncoding(args[1])
+	if !exist {
+		return constant.RespNil
+	}
+	return Encode(encoding, false)
+}
+
+// objectEncoding returns the encoding of the value stored at key
+func objectEncoding(key string) (string, bool) {
+	if set, exist := setStore[key]; exist {
+		return set.Encoding(), true
+	}
+	if zset, exist := zsetStore[key]; exist {
+		return zset.Encoding(), true
+	}
+	if h, exist := lookupHash(key); exist {
+		return h.Encoding(), true
+	}
+	if _, exist := listStore[key]; exist {
+		return "quicklist", true
+	}
+	if _, exist := streamStore[key]; exist {
+		return "stream", true
+	}
+	if obj := dictStore.Get(key); obj != nil && !dictStore.HasExpired(key) {
+		s, _ := obj.Value.(string)
+		if v, err := strconv.ParseInt(s, 10, 64); err == nil && strconv.FormatInt(v, 10) == s {
+			return "int", true
+		}
+		if len(s) <= embstrSizeLimit {
+			return "embstr", true
+		}
+		return "raw", true
+	}
+	return "", false
+}
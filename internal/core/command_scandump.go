@@ -0,0 +1,43 @@
+package core
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/spaghetti-lover/multithread-redis/internal/constant"
+	"github.com/spaghetti-lover/multithread-redis/internal/data_structure/probabilistic"
+)
+
+// A sketch is dumped by SCANDUMP as a header followed by chunks of its data. The iterator
+// is 0 to get the header, which is replied with the iterator 1. A chunk of data is replied
+// with the iterator 1 + the offset of its end, and the dump ends with the iterator 0 and
+// an empty chunk. LOADCHUNK receives the chunks with the iterators replied by SCANDUMP.
+
+func parseIterator(s string) (int64, error) {
+	iter, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || iter < 0 {
+		return 0, errors.New("(error) ERR invalid iterator")
+	}
+	return iter, nil
+}
+
+// scanDump replies with the chunk following iter, header being the encoded header
+func scanDump(s probabilistic.ChunkedSketch, header []byte, iter int64) []byte {
+	if iter == 0 {
+		return Encode([]interface{}{int64(1), string(header)}, false)
+	}
+	chunk := s.DataChunk(uint64(iter-1), constant.ScanDumpChunkSize)
+	if len(chunk) == 0 {
+		return Encode([]interface{}{int64(0), ""}, false)
+	}
+	return Encode([]interface{}{iter + int64(len(chunk)), string(chunk)}, false)
+}
+
+// loadChunk loads a chunk of data replied by SCANDUMP with iter
+func loadChunk(s probabilistic.ChunkedSketch, iter int64, chunk string) error {
+	offset := iter - 1 - int64(len(chunk))
+	if offset < 0 || s.LoadChunk(uint64(offset), []byte(chunk)) != nil {
+		return errors.New("(error) ERR received bad data")
+	}
+	return nil
+}
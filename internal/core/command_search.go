@@ -0,0 +1,356 @@
+package core
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spaghetti-lover/multithread-redis/internal/constant"
+	"github.com/spaghetti-lover/multithread-redis/internal/data_structure/hash_map"
+	"github.com/spaghetti-lover/multithread-redis/internal/data_structure/search"
+)
+
+// hashFields returns the fields of h as a map
+func hashFields(h *hash_map.Hash) map[string]string {
+	all := h.GetAll()
+	fields := make(map[string]string, len(all)/2)
+	for i := 0; i < len(all); i += 2 {
+		fields[all[i]] = all[i+1]
+	}
+	return fields
+}
+
+// indexHash updates the search indexes matching key after the hash stored at key was
+// written, deleted or had fields expire. It must be called after every change of a hash.
+func indexHash(key string) {
+	if len(searchIndexStore) == 0 {
+		return
+	}
+	h, exist := hashStore[key]
+	var fields map[string]string
+	if exist {
+		fields = hashFields(h)
+	}
+	for _, idx := range searchIndexStore {
+		if !idx.Matches(key) {
+			continue
+		}
+		if exist {
+			idx.Add(key, fields)
+		} else {
+			idx.Remove(key)
+		}
+	}
+}
+
+// parseSchemaField parses a field of FT.CREATE: name [AS alias] TEXT [WEIGHT weight] |
+// TAG [SEPARATOR separator] [CASESENSITIVE] | NUMERIC, followed by [SORTABLE].
+// Returns the position of the next field.
+func parseSchemaField(args []string, i int) (*search.Field, int, error) {
+	f := &search.Field{Name: args[i], Alias: args[i], Weight: 1, Separator: ','}
+	i++
+	if i+1 < len(args) && strings.ToUpper(args[i]) == "AS" {
+		f.Alias = args[i+1]
+		i += 2
+	}
+	if i >= len(args) {
+		return nil, 0, errors.New("(error) ERR Field type is missing")
+	}
+	switch t := search.FieldType(strings.ToUpper(args[i])); t {
+	case search.FieldText, search.FieldTag, search.FieldNumeric:
+		f.Type = t
+	default:
+		return nil, 0, errors.New("(error) ERR Invalid field type for field `" + f.Name + "`")
+	}
+	for i++; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i]); {
+		case opt == "SORTABLE":
+			f.Sortable = true
+		case opt == "WEIGHT" && f.Type == search.FieldText && i+1 < len(args):
+			w, err := strconv.ParseFloat(args[i+1], 64)
+			if err != nil || w < 0 {
+				return nil, 0, errors.New("(error) ERR Bad arguments for WEIGHT")
+			}
+			f.Weight = w
+			i++
+		case opt == "SEPARATOR" && f.Type == search.FieldTag && i+1 < len(args):
+			if len(args[i+1]) != 1 {
+				return nil, 0, errors.New("(error) ERR Tag separator must be a single character")
+			}
+			f.Separator = args[i+1][0]
+			i++
+		case opt == "CASESENSITIVE" && f.Type == search.FieldTag:
+			f.CaseSensitive = true
+		default:
+			return f, i, nil
+		}
+	}
+	return f, i, nil
+}
+
+// cmdFTCREATE implements FT.CREATE index [ON HASH] [PREFIX count prefix [prefix ...]]
+// SCHEMA field ... The existing hashes are indexed right away.
+func cmdFTCREATE(args []string) []byte {
+	if len(args) < 4 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'FT.CREATE' command"), false)
+	}
+	name := args[0]
+	var prefixes []string
+	i := 1
+options:
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "ON":
+			if i+1 >= len(args) || strings.ToUpper(args[i+1]) != "HASH" {
+				return Encode(errors.New("(error) ERR Only HASH indexes are supported"), false)
+			}
+			i++
+		case "PREFIX":
+			if i+1 >= len(args) {
+				return Encode(errors.New("(error) ERR syntax error"), false)
+			}
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil || n < 0 || i+2+n > len(args) {
+				return Encode(errors.New("(error) ERR Bad arguments for PREFIX"), false)
+			}
+			prefixes = append(prefixes, args[i+2:i+2+n]...)
+			i += 1 + n
+		case "SCHEMA":
+			break options
+		default:
+			return Encode(errors.New("(error) ERR syntax error"), false)
+		}
+	}
+	if i >= len(args)-1 {
+		return Encode(errors.New("(error) ERR Fields arguments are missing"), false)
+	}
+
+	var fields []*search.Field
+	aliases := make(map[string]bool)
+	for i++; i < len(args); {
+		f, next, err := parseSchemaField(args, i)
+		if err != nil {
+			return Encode(err, false)
+		}
+		if aliases[f.Alias] {
+			return Encode(errors.New("(error) ERR Duplicate field in schema - "+f.Alias), false)
+		}
+		aliases[f.Alias] = true
+		fields = append(fields, f)
+		i = next
+	}
+
+	if _, exist := searchIndexStore[name]; exist {
+		return Encode(errors.New("(error) ERR Index already exists"), false)
+	}
+	idx := search.NewIndex(name, prefixes, fields)
+	for key := range hashStore {
+		if !idx.Matches(key) {
+			continue
+		}
+		if h, exist := lookupHash(key); exist {
+			idx.Add(key, hashFields(h))
+		}
+	}
+	searchIndexStore[name] = idx
+	return constant.RespOk
+}
+
+func lookupSearchIndex(name string) (*search.Index, error) {
+	idx, exist := searchIndexStore[name]
+	if !exist {
+		return nil, errors.New("(error) ERR Unknown index name")
+	}
+	return idx, nil
+}
+
+// cmdFTSEARCH implements FT.SEARCH index query [NOCONTENT] [WITHSCORES] [RETURN count field ...]
+// [SORTBY field [ASC | DESC]] [LIMIT offset num]. Replies with the number of matching
+// documents followed by the keys of the page and their fields.
+func cmdFTSEARCH(args []string) []byte {
+	if len(args) < 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'FT.SEARCH' command"), false)
+	}
+	idx, err := lookupSearchIndex(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+
+	noContent, withScores := false, false
+	var returnFields []string
+	opts := search.SearchOptions{}
+	offset, num := 0, constant.FtSearchDefaultLimit
+	for i := 2; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "NOCONTENT":
+			noContent = true
+		case "WITHSCORES":
+			withScores = true
+		case "RETURN":
+			n := -1
+			if i+1 < len(args) {
+				n, err = strconv.Atoi(args[i+1])
+			}
+			if err != nil || n < 0 || i+2+n > len(args) {
+				return Encode(errors.New("(error) ERR Bad arguments for RETURN"), false)
+			}
+			returnFields = args[i+2 : i+2+n]
+			if n == 0 {
+				noContent = true
+			}
+			i += 1 + n
+		case "SORTBY":
+			if i+1 >= len(args) {
+				return Encode(errors.New("(error) ERR Bad arguments for SORTBY"), false)
+			}
+			opts.SortBy = args[i+1]
+			i++
+			if i+1 < len(args) {
+				switch strings.ToUpper(args[i+1]) {
+				case "ASC":
+					i++
+				case "DESC":
+					opts.Desc = true
+					i++
+				}
+			}
+		case "LIMIT":
+			if i+2 >= len(args) {
+				return Encode(errors.New("(error) ERR Bad arguments for LIMIT"), false)
+			}
+			var err1, err2 error
+			offset, err1 = strconv.Atoi(args[i+1])
+			num, err2 = strconv.Atoi(args[i+2])
+			if err1 != nil || err2 != nil || offset < 0 || num < 0 {
+				return Encode(errors.New("(error) ERR Bad arguments for LIMIT"), false)
+			}
+			i += 2
+		default:
+			return Encode(errors.New("(error) ERR syntax error"), false)
+		}
+	}
+
+	results, err := idx.Search(args[1], opts)
+	if err != nil {
+		return Encode(errors.New("(error) ERR "+err.Error()), false)
+	}
+	res := []interface{}{len(results)}
+	if offset >= len(results) {
+		return Encode(res, false)
+	}
+	results = results[offset:]
+	if num < len(results) {
+		results = results[:num]
+	}
+	for _, r := range results {
+		res = append(res, r.Key)
+		if withScores {
+			res = append(res, formatScore(r.Score))
+		}
+		if noContent {
+			continue
+		}
+		h, exist := lookupHash(r.Key)
+		if !exist {
+			res = append(res, []string{})
+			continue
+		}
+		if returnFields == nil {
+			res = append(res, h.GetAll())
+			continue
+		}
+		content := make([]string, 0, 2*len(returnFields))
+		for _, name := range returnFields {
+			field := name
+			if f, ok := idx.Field(name); ok {
+				field = f.Name
+			}
+			if v, ok := h.Get(field); ok {
+				content = append(content, name, v)
+			}
+		}
+		res = append(res, content)
+	}
+	return Encode(res, false)
+}
+
+// cmdFTINFO implements FT.INFO index
+func cmdFTINFO(args []string) []byte {
+	if len(args) != 1 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'FT.INFO' command"), false)
+	}
+	idx, err := lookupSearchIndex(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	attributes := make([]interface{}, 0, len(idx.Fields))
+	for _, f := range idx.Fields {
+		attr := []interface{}{"identifier", f.Name, "attribute", f.Alias, "type", string(f.Type)}
+		switch f.Type {
+		case search.FieldText:
+			attr = append(attr, "WEIGHT", formatScore(f.Weight))
+		case search.FieldTag:
+			attr = append(attr, "SEPARATOR", string(f.Separator))
+			if f.CaseSensitive {
+				attr = append(attr, "CASESENSITIVE")
+			}
+		}
+		if f.Sortable {
+			attr = append(attr, "SORTABLE")
+		}
+		attributes = append(attributes, attr)
+	}
+	prefixes := idx.Prefixes
+	if len(prefixes) == 0 {
+		prefixes = []string{""}
+	}
+	return Encode([]interface{}{
+		"index_name", idx.Name,
+		"index_definition", []interface{}{"key_type", "HASH", "prefixes", prefixes},
+		"attributes", attributes,
+		"num_docs", idx.NumDocs(),
+		"num_terms", idx.NumTerms(),
+		"num_records", idx.NumRecords(),
+	}, false)
+}
+
+// cmdFTDROPINDEX implements FT.DROPINDEX index [DD], DD deleting the indexed hashes
+func cmdFTDROPINDEX(args []string) []byte {
+	if len(args) < 1 || len(args) > 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'FT.DROPINDEX' command"), false)
+	}
+	deleteDocs := false
+	if len(args) == 2 {
+		if strings.ToUpper(args[1]) != "DD" {
+			return Encode(errors.New("(error) ERR syntax error"), false)
+		}
+		deleteDocs = true
+	}
+	idx, err := lookupSearchIndex(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	delete(searchIndexStore, idx.Name)
+	if deleteDocs {
+		for _, key := range idx.Keys() {
+			if _, exist := hashStore[key]; exist {
+				deleteHash(key)
+			}
+		}
+	}
+	return constant.RespOk
+}
+
+// cmdFTLIST implements FT._LIST
+func cmdFTLIST(args []string) []byte {
+	if len(args) != 0 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'FT._LIST' command"), false)
+	}
+	names := make([]string, 0, len(searchIndexStore))
+	for name := range searchIndexStore {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return Encode(names, false)
+}
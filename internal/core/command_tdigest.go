@@ -0,0 +1,288 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/spaghetti-lover/multithread-redis/internal/constant"
+	"github.com/spaghetti-lover/multithread-redis/internal/data_structure/probabilistic"
+)
+
+// formatDouble formats a reply of the TDIGEST commands, "nan" for an empty sketch
+func formatDouble(v float64) string {
+	if math.IsNaN(v) {
+		return "nan"
+	}
+	return formatScore(v)
+}
+
+func parseCompression(s string) (float64, error) {
+	compression, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(compression) || compression <= 0 || math.IsInf(compression, 1) {
+		return 0, errors.New("(error) ERR T-Digest: error parsing compression parameter")
+	}
+	if compression > probabilistic.MaxTDigestCompression {
+		return 0, fmt.Errorf("(error) ERR T-Digest: compression should be at most %d", probabilistic.MaxTDigestCompression)
+	}
+	return compression, nil
+}
+
+// parseTDigestValues parses the values of TDIGEST.ADD, CDF and RANK
+func parseTDigestValues(args []string) ([]float64, error) {
+	values := make([]float64, len(args))
+	for i, arg := range args {
+		v, err := strconv.ParseFloat(arg, 64)
+		if err != nil || math.IsNaN(v) {
+			return nil, errors.New("(error) ERR T-Digest: error parsing val parameter")
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+func lookupTDigest(key string) (*probabilistic.TDigest, error) {
+	td, exist := tdigestStore[key]
+	if !exist {
+		return nil, errors.New("(error) ERR T-Digest: key does not exist")
+	}
+	return td, nil
+}
+
+// cmdTDIGESTCREATE implements TDIGEST.CREATE key [COMPRESSION compression]
+func cmdTDIGESTCREATE(args []string) []byte {
+	if len(args) != 1 && len(args) != 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'TDIGEST.CREATE' command"), false)
+	}
+	compression := float64(constant.TdigestDefaultCompression)
+	if len(args) == 3 {
+		if strings.ToUpper(args[1]) != "COMPRESSION" {
+			return Encode(errors.New("(error) ERR syntax error"), false)
+		}
+		var err error
+		if compression, err = parseCompression(args[2]); err != nil {
+			return Encode(err, false)
+		}
+	}
+	key := args[0]
+	if _, exist := tdigestStore[key]; exist {
+		return Encode(errors.New("(error) ERR T-Digest: key already exists"), false)
+	}
+	tdigestStore[key] = probabilistic.NewTDigest(compression)
+	return constant.RespOk
+}
+
+func cmdTDIGESTADD(args []string) []byte {
+	if len(args) < 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'TDIGEST.ADD' command"), false)
+	}
+	td, err := lookupTDigest(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	values, err := parseTDigestValues(args[1:])
+	if err != nil {
+		return Encode(err, false)
+	}
+	for _, v := range values {
+		td.Add(v)
+	}
+	return constant.RespOk
+}
+
+func cmdTDIGESTQUANTILE(args []string) []byte {
+	if len(args) < 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'TDIGEST.QUANTILE' command"), false)
+	}
+	td, err := lookupTDigest(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	res := make([]string, 0, len(args)-1)
+	for _, arg := range args[1:] {
+		q, err := strconv.ParseFloat(arg, 64)
+		if err != nil || !(q >= 0 && q <= 1) {
+			return Encode(errors.New("(error) ERR T-Digest: quantile should be in [0,1]"), false)
+		}
+		res = append(res, formatDouble(td.Quantile(q)))
+	}
+	return Encode(res, false)
+}
+
+func cmdTDIGESTCDF(args []string) []byte {
+	if len(args) < 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'TDIGEST.CDF' command"), false)
+	}
+	td, err := lookupTDigest(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	values, err := parseTDigestValues(args[1:])
+	if err != nil {
+		return Encode(err, false)
+	}
+	res := make([]string, 0, len(values))
+	for _, v := range values {
+		res = append(res, formatDouble(td.CDF(v)))
+	}
+	return Encode(res, false)
+}
+
+// cmdTDIGESTRANK replies with the estimated number of values below each value: -1 below
+// the minimum, the number of observations above the maximum and -2 for an empty sketch
+func cmdTDIGESTRANK(args []string) []byte {
+	if len(args) < 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'TDIGEST.RANK' command"), false)
+	}
+	td, err := lookupTDigest(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	values, err := parseTDigestValues(args[1:])
+	if err != nil {
+		return Encode(err, false)
+	}
+	n := int64(td.Observations())
+	res := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		switch {
+		case n == 0:
+			res = append(res, int64(-2))
+		case v < td.Min():
+			res = append(res, int64(-1))
+		case v > td.Max():
+			res = append(res, n)
+		default:
+			res = append(res, int64(math.Round(td.CDF(v)*float64(n))))
+		}
+	}
+	return Encode(res, false)
+}
+
+// cmdTDIGESTTRIMMEDMEAN implements TDIGEST.TRIMMED_MEAN key low_cut_quantile high_cut_quantile
+func cmdTDIGESTTRIMMEDMEAN(args []string) []byte {
+	if len(args) != 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'TDIGEST.TRIMMED_MEAN' command"), false)
+	}
+	td, err := lookupTDigest(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	low, err1 := strconv.ParseFloat(args[1], 64)
+	high, err2 := strconv.ParseFloat(args[2], 64)
+	if err1 != nil || err2 != nil || !(low >= 0 && low <= 1) || !(high >= 0 && high <= 1) {
+		return Encode(errors.New("(error) ERR T-Digest: low_cut_percentile and high_cut_percentile should be in [0,1]"), false)
+	}
+	if low >= high {
+		return Encode(errors.New("(error) ERR T-Digest: low_cut_percentile should be lower than high_cut_percentile"), false)
+	}
+	return Encode(formatDouble(td.TrimmedMean(low, high)), false)
+}
+
+// tdigestMinMax implements TDIGEST.MIN and TDIGEST.MAX
+func tdigestMinMax(cmd string, args []string) []byte {
+	if len(args) != 1 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for '"+cmd+"' command"), false)
+	}
+	td, err := lookupTDigest(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if cmd == "TDIGEST.MIN" {
+		return Encode(formatDouble(td.Min()), false)
+	}
+	return Encode(formatDouble(td.Max()), false)
+}
+
+// cmdTDIGESTMERGE implements TDIGEST.MERGE destination numkeys source [source ...]
+// [COMPRESSION compression] [OVERRIDE]. Without OVERRIDE, the values of an existing
+// destination are kept.
+func cmdTDIGESTMERGE(args []string) []byte {
+	if len(args) < 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'TDIGEST.MERGE' command"), false)
+	}
+	numKeys, err := strconv.Atoi(args[1])
+	if err != nil || numKeys <= 0 || numKeys > len(args)-2 {
+		return Encode(errors.New("(error) ERR T-Digest: error parsing numkeys"), false)
+	}
+	sources := make([]*probabilistic.TDigest, 0, numKeys+1)
+	compression := 0.0
+	for _, key := range args[2 : 2+numKeys] {
+		td, err := lookupTDigest(key)
+		if err != nil {
+			return Encode(err, false)
+		}
+		sources = append(sources, td)
+		compression = math.Max(compression, td.Compression)
+	}
+
+	override := false
+	hasCompression := false
+	for i := 2 + numKeys; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "COMPRESSION":
+			if i+1 >= len(args) {
+				return Encode(errors.New("(error) ERR syntax error"), false)
+			}
+			if compression, err = parseCompression(args[i+1]); err != nil {
+				return Encode(err, false)
+			}
+			hasCompression = true
+			i++
+		case "OVERRIDE":
+			override = true
+		default:
+			return Encode(errors.New("(error) ERR syntax error"), false)
+		}
+	}
+
+	// Without OVERRIDE the destination is merged too, once even if it is also a source
+	dest := args[0]
+	if td, exist := tdigestStore[dest]; exist && !override && !slices.Contains(args[2:2+numKeys], dest) {
+		sources = append(sources, td)
+		if !hasCompression {
+			compression = math.Max(compression, td.Compression)
+		}
+	}
+	merged := probabilistic.NewTDigest(compression)
+	merged.Merge(sources...)
+	tdigestStore[dest] = merged
+	return constant.RespOk
+}
+
+func cmdTDIGESTRESET(args []string) []byte {
+	if len(args) != 1 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'TDIGEST.RESET' command"), false)
+	}
+	td, err := lookupTDigest(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	td.Reset()
+	return constant.RespOk
+}
+
+func cmdTDIGESTINFO(args []string) []byte {
+	if len(args) != 1 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'TDIGEST.INFO' command"), false)
+	}
+	td, err := lookupTDigest(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	info := td.Info()
+	return Encode([]interface{}{
+		"Compression", int64(td.Compression),
+		"Capacity", info.Capacity,
+		"Merged nodes", info.MergedNodes,
+		"Unmerged nodes", info.UnmergedNodes,
+		"Merged weight", int64(info.MergedWeight),
+		"Unmerged weight", int64(info.UnmergedWeight),
+		"Observations", int64(td.Observations()),
+		"Total compressions", int64(info.Compressions),
+		"Memory usage", int64(info.Bytes),
+	}, false)
+}
@@ -0,0 +1,34 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTDIGESTMERGEIntoSource(t *testing.T) {
+	assert.Equal(t, "OK", decodeReply(t, cmdTDIGESTCREATE([]string{"td:dest"})))
+	assert.Equal(t, "OK", decodeReply(t, cmdTDIGESTADD([]string{"td:dest", "1", "2", "3"})))
+	assert.Equal(t, "OK", decodeReply(t, cmdTDIGESTCREATE([]string{"td:other"})))
+	assert.Equal(t, "OK", decodeReply(t, cmdTDIGESTADD([]string{"td:other", "10", "20"})))
+
+	// The destination is merged once, whether it is a source or not
+	assert.Equal(t, "OK", decodeReply(t, cmdTDIGESTMERGE([]string{"td:dest", "2", "td:dest", "td:other"})))
+	assert.EqualValues(t, 5, tdigestStore["td:dest"].Observations())
+	assert.Equal(t, "OK", decodeReply(t, cmdTDIGESTMERGE([]string{"td:dest", "1", "td:other"})))
+	assert.EqualValues(t, 7, tdigestStore["td:dest"].Observations())
+
+	assert.Equal(t, "OK", decodeReply(t, cmdTDIGESTMERGE([]string{"td:dest", "1", "td:other", "OVERRIDE"})))
+	assert.EqualValues(t, 2, tdigestStore["td:dest"].Observations())
+	assert.Equal(t, "OK", decodeReply(t, cmdTDIGESTMERGE([]string{"td:dest", "1", "td:dest", "OVERRIDE"})))
+	assert.EqualValues(t, 2, tdigestStore["td:dest"].Observations())
+}
+
+func TestTDIGESTCompressionBound(t *testing.T) {
+	tooLarge := "(error) ERR T-Digest: compression should be at most 100000"
+	assert.Equal(t, tooLarge, decodeReply(t, cmdTDIGESTCREATE([]string{"td:huge", "COMPRESSION", "1e12"})))
+	assert.Equal(t, "OK", decodeReply(t, cmdTDIGESTCREATE([]string{"td:huge", "COMPRESSION", "100000"})))
+	assert.Equal(t, tooLarge, decodeReply(t, cmdTDIGESTMERGE([]string{"td:merged", "1", "td:huge", "COMPRESSION", "100001"})))
+	_, exist := tdigestStore["td:merged"]
+	assert.False(t, exist)
+}
@@ -0,0 +1,619 @@
+package core
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spaghetti-lover/multithread-redis/internal/constant"
+	"github.com/spaghetti-lover/multithread-redis/internal/data_structure/time_series"
+)
+
+// tsOptions holds the options of the commands creating a series
+type tsOptions struct {
+	retention   int64
+	chunkSize   int
+	policy      time_series.DuplicatePolicy
+	onDuplicate time_series.DuplicatePolicy
+	timestamp   string
+	labels      []time_series.Label
+}
+
+// parseTSOptions parses the options in args, allowed listing the accepted keywords.
+// LABELS takes the remaining arguments as label value pairs.
+func parseTSOptions(args []string, allowed ...string) (*tsOptions, error) {
+	opts := &tsOptions{chunkSize: constant.TsDefaultChunkSize, policy: time_series.PolicyBlock, timestamp: "*"}
+	for i := 0; i < len(args); i += 2 {
+		opt := strings.ToUpper(args[i])
+		if !slices.Contains(allowed, opt) {
+			return nil, errors.New("(error) ERR TSDB: unknown option " + args[i])
+		}
+		if opt == "LABELS" {
+			if (len(args)-i-1)%2 != 0 {
+				return nil, errors.New("(error) ERR TSDB: wrong number of labels")
+			}
+			for j := i + 1; j < len(args); j += 2 {
+				opts.labels = append(opts.labels, time_series.Label{Name: args[j], Value: args[j+1]})
+			}
+			break
+		}
+		if i+1 >= len(args) {
+			return nil, errors.New("(error) ERR TSDB: missing value for " + opt)
+		}
+		value := args[i+1]
+		var ok bool
+		switch opt {
+		case "RETENTION":
+			r, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || r < 0 {
+				return nil, errors.New("(error) ERR TSDB: Couldn't parse RETENTION")
+			}
+			opts.retention = r
+		case "CHUNK_SIZE":
+			size, err := strconv.Atoi(value)
+			if err != nil || size < 48 || size > 1048576 {
+				return nil, errors.New("(error) ERR TSDB: CHUNK_SIZE value must be between 48 and 1048576")
+			}
+			opts.chunkSize = size
+		case "DUPLICATE_POLICY":
+			if opts.policy, ok = time_series.ParseDuplicatePolicy(value); !ok {
+				return nil, errors.New("(error) ERR TSDB: Unknown DUPLICATE_POLICY")
+			}
+		case "ON_DUPLICATE":
+			if opts.onDuplicate, ok = time_series.ParseDuplicatePolicy(value); !ok {
+				return nil, errors.New("(error) ERR TSDB: Unknown ON_DUPLICATE policy")
+			}
+		case "TIMESTAMP":
+			opts.timestamp = value
+		}
+	}
+	return opts, nil
+}
+
+func (opts *tsOptions) newSeries() *time_series.Series {
+	return time_series.NewSeries(opts.retention, opts.chunkSize, opts.policy, opts.labels)
+}
+
+// parseTimestamp parses the timestamp of a new sample, * being the current time
+func parseTimestamp(s string) (int64, error) {
+	if s == "*" {
+		return time.Now().UnixMilli(), nil
+	}
+	ts, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || ts < 0 {
+		return 0, errors.New("(error) ERR TSDB: invalid timestamp")
+	}
+	return ts, nil
+}
+
+// parseRangeTimestamp parses a bound of a range, - and + being the oldest and the most
+// recent possible timestamps
+func parseRangeTimestamp(s string) (int64, error) {
+	switch s {
+	case "-":
+		return 0, nil
+	case "+":
+		return math.MaxInt64, nil
+	}
+	return parseTimestamp(s)
+}
+
+func parseSampleValue(s string) (float64, error) {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(v) {
+		return 0, errors.New("(error) ERR TSDB: invalid value")
+	}
+	return v, nil
+}
+
+func lookupSeries(key string) (*time_series.Series, error) {
+	s, exist := tsStore[key]
+	if !exist {
+		return nil, errors.New("(error) ERR TSDB: the key does not exist")
+	}
+	return s, nil
+}
+
+func encodeSample(s time_series.Sample) []interface{} {
+	return []interface{}{s.Timestamp, formatScore(s.Value)}
+}
+
+// tsAdd adds a sample to a series, policy overriding its duplicate policy if set, and
+// updates the destinations of its compaction rules
+func tsAdd(s *time_series.Series, ts int64, value float64, policy time_series.DuplicatePolicy) error {
+	if policy == "" {
+		policy = s.DuplicatePolicy
+	}
+	switch err := s.Add(ts, value, policy); {
+	case errors.Is(err, time_series.ErrDuplicate):
+		return errors.New("(error) ERR TSDB: Error at upsert, update is not supported when DUPLICATE_POLICY is set to BLOCK mode")
+	case errors.Is(err, time_series.ErrTooOld):
+		return errors.New("(error) ERR TSDB: Timestamp is older than retention")
+	}
+	for _, r := range s.Rules {
+		if bucket, ok := r.Update(ts); ok {
+			tsCompact(s, r, bucket, bucket)
+		}
+	}
+	return nil
+}
+
+// tsCompact writes the aggregates of the buckets of the source series between the
+// buckets starting at from and to into the destination of the rule
+func tsCompact(s *time_series.Series, r *time_series.Rule, from, to int64) {
+	dest, exist := tsStore[r.DestKey]
+	if !exist {
+		return
+	}
+	dest.Delete(from, to)
+	samples := s.Range(from, to+r.BucketDuration-1)
+	for _, sample := range time_series.Aggregate(samples, r.Aggregation, r.BucketDuration, r.AlignTimestamp) {
+		dest.Add(sample.Timestamp, sample.Value, time_series.PolicyLast)
+	}
+}
+
+// cmdTSCREATE implements TS.CREATE key [RETENTION retention] [CHUNK_SIZE size]
+// [DUPLICATE_POLICY policy] [LABELS label value ...]
+func cmdTSCREATE(args []string) []byte {
+	if len(args) < 1 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'TS.CREATE' command"), false)
+	}
+	opts, err := parseTSOptions(args[1:], "RETENTION", "CHUNK_SIZE", "DUPLICATE_POLICY", "LABELS")
+	if err != nil {
+		return Encode(err, false)
+	}
+	if _, exist := tsStore[args[0]]; exist {
+		return Encode(errors.New("(error) ERR TSDB: key already exists"), false)
+	}
+	tsStore[args[0]] = opts.newSeries()
+	return constant.RespOk
+}
+
+// cmdTSADD implements TS.ADD key timestamp value [RETENTION retention] [CHUNK_SIZE size]
+// [DUPLICATE_POLICY policy] [ON_DUPLICATE policy] [LABELS label value ...]. The options
+// but ON_DUPLICATE only apply when the key is created.
+func cmdTSADD(args []string) []byte {
+	if len(args) < 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'TS.ADD' command"), false)
+	}
+	ts, err := parseTimestamp(args[1])
+	if err != nil {
+		return Encode(err, false)
+	}
+	value, err := parseSampleValue(args[2])
+	if err != nil {
+		return Encode(err, false)
+	}
+	opts, err := parseTSOptions(args[3:], "RETENTION", "CHUNK_SIZE", "DUPLICATE_POLICY", "ON_DUPLICATE", "LABELS")
+	if err != nil {
+		return Encode(err, false)
+	}
+	s, exist := tsStore[args[0]]
+	if !exist {
+		s = opts.newSeries()
+		tsStore[args[0]] = s
+	}
+	if err := tsAdd(s, ts, value, opts.onDuplicate); err != nil {
+		return Encode(err, false)
+	}
+	return Encode(ts, false)
+}
+
+// cmdTSMADD implements TS.MADD key timestamp value [key timestamp value ...], replying
+// with the timestamp or the error of each sample
+func cmdTSMADD(args []string) []byte {
+	if len(args) < 3 || len(args)%3 != 0 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'TS.MADD' command"), false)
+	}
+	res := make([]interface{}, 0, len(args)/3)
+	for i := 0; i < len(args); i += 3 {
+		s, err := lookupSeries(args[i])
+		var ts int64
+		var value float64
+		if err == nil {
+			ts, err = parseTimestamp(args[i+1])
+		}
+		if err == nil {
+			value, err = parseSampleValue(args[i+2])
+		}
+		if err == nil {
+			err = tsAdd(s, ts, value, "")
+		}
+		if err != nil {
+			res = append(res, err)
+		} else {
+			res = append(res, ts)
+		}
+	}
+	return Encode(res, false)
+}
+
+// cmdTSINCRBY implements TS.INCRBY key addend [TIMESTAMP timestamp] [RETENTION retention]
+// [CHUNK_SIZE size] [DUPLICATE_POLICY policy] [LABELS label value ...], adding a sample
+// whose value is the last one plus addend
+func cmdTSINCRBY(args []string) []byte {
+	if len(args) < 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'TS.INCRBY' command"), false)
+	}
+	addend, err := parseSampleValue(args[1])
+	if err != nil {
+		return Encode(err, false)
+	}
+	opts, err := parseTSOptions(args[2:], "TIMESTAMP", "RETENTION", "CHUNK_SIZE", "DUPLICATE_POLICY", "LABELS")
+	if err != nil {
+		return Encode(err, false)
+	}
+	ts, err := parseTimestamp(opts.timestamp)
+	if err != nil {
+		return Encode(err, false)
+	}
+	s, exist := tsStore[args[0]]
+	if !exist {
+		s = opts.newSeries()
+		tsStore[args[0]] = s
+	}
+	value := addend
+	if last, ok := s.Last(); ok {
+		if ts < last.Timestamp {
+			return Encode(errors.New("(error) ERR TSDB: timestamp must be equal to or higher than the maximum existing timestamp"), false)
+		}
+		value += last.Value
+	}
+	if err := tsAdd(s, ts, value, time_series.PolicyLast); err != nil {
+		return Encode(err, false)
+	}
+	return Encode(ts, false)
+}
+
+// cmdTSGET implements TS.GET key, replying with the last sample
+func cmdTSGET(args []string) []byte {
+	if len(args) != 1 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'TS.GET' command"), false)
+	}
+	s, err := lookupSeries(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	last, ok := s.Last()
+	if !ok {
+		return Encode([]string{}, false)
+	}
+	return Encode(encodeSample(last), false)
+}
+
+// cmdTSDEL implements TS.DEL key from to. The closed buckets of the compaction rules
+// overlapping the range are computed again.
+func cmdTSDEL(args []string) []byte {
+	if len(args) != 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'TS.DEL' command"), false)
+	}
+	s, err := lookupSeries(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	from, err1 := parseRangeTimestamp(args[1])
+	to, err2 := parseRangeTimestamp(args[2])
+	if err1 != nil || err2 != nil {
+		return Encode(errors.New("(error) ERR TSDB: invalid timestamp"), false)
+	}
+	deleted := s.Delete(from, to)
+	if deleted > 0 {
+		for _, r := range s.Rules {
+			open, ok := r.OpenBucket()
+			if !ok {
+				continue
+			}
+			if last := min(to, open-1); from <= last {
+				tsCompact(s, r, r.BucketStart(from), r.BucketStart(last))
+			}
+		}
+	}
+	return Encode(deleted, false)
+}
+
+// tsRangeOptions holds the options of TS.RANGE, TS.REVRANGE and TS.MRANGE
+type tsRangeOptions struct {
+	count       int
+	aggregation time_series.Aggregation
+	duration    int64
+	align       int64
+}
+
+// parseTSRangeOption parses the option at args[i] if it is COUNT, ALIGN or AGGREGATION and
+// returns the position of the next argument
+func parseTSRangeOption(args []string, i int, from, to int64, opts *tsRangeOptions) (int, bool, error) {
+	switch strings.ToUpper(args[i]) {
+	case "COUNT":
+		if i+1 >= len(args) {
+			return 0, true, errors.New("(error) ERR TSDB: wrong COUNT")
+		}
+		n, err := strconv.Atoi(args[i+1])
+		if err != nil || n < 0 {
+			return 0, true, errors.New("(error) ERR TSDB: Couldn't parse COUNT")
+		}
+		opts.count = n
+		return i + 2, true, nil
+	case "ALIGN":
+		if i+1 >= len(args) {
+			return 0, true, errors.New("(error) ERR TSDB: wrong ALIGN")
+		}
+		switch strings.ToLower(args[i+1]) {
+		case "start", "-":
+			opts.align = from
+		case "end", "+":
+			opts.align = to
+		default:
+			align, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil {
+				return 0, true, errors.New("(error) ERR TSDB: unknown ALIGN parameter")
+			}
+			opts.align = align
+		}
+		return i + 2, true, nil
+	case "AGGREGATION":
+		if i+2 >= len(args) {
+			return 0, true, errors.New("(error) ERR TSDB: wrong AGGREGATION")
+		}
+		agg, ok := time_series.ParseAggregation(args[i+1])
+		if !ok {
+			return 0, true, errors.New("(error) ERR TSDB: Unknown aggregation type")
+		}
+		duration, err := strconv.ParseInt(args[i+2], 10, 64)
+		if err != nil || duration <= 0 {
+			return 0, true, errors.New("(error) ERR TSDB: bucketDuration must be greater than zero")
+		}
+		opts.aggregation, opts.duration = agg, duration
+		return i + 3, true, nil
+	}
+	return i, false, nil
+}
+
+// tsRange returns the samples of s between from and to, aggregated and truncated to
+// COUNT samples, the most recent first if reverse is set
+func tsRange(s *time_series.Series, from, to int64, opts *tsRangeOptions, reverse bool) []interface{} {
+	samples := s.Range(from, to)
+	if opts.aggregation != "" {
+		samples = time_series.Aggregate(samples, opts.aggregation, opts.duration, opts.align)
+	}
+	if reverse {
+		slices.Reverse(samples)
+	}
+	if opts.count > 0 && len(samples) > opts.count {
+		samples = samples[:opts.count]
+	}
+	res := make([]interface{}, len(samples))
+	for i, sample := range samples {
+		res[i] = encodeSample(sample)
+	}
+	return res
+}
+
+// tsRangeGeneric implements TS.RANGE and TS.REVRANGE key from to [COUNT count]
+// [ALIGN align] [AGGREGATION aggregator bucketDuration]
+func tsRangeGeneric(cmd string, args []string, reverse bool) []byte {
+	if len(args) < 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for '"+cmd+"' command"), false)
+	}
+	s, err := lookupSeries(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	from, err1 := parseRangeTimestamp(args[1])
+	to, err2 := parseRangeTimestamp(args[2])
+	if err1 != nil || err2 != nil {
+		return Encode(errors.New("(error) ERR TSDB: invalid timestamp"), false)
+	}
+	opts := &tsRangeOptions{}
+	for i := 3; i < len(args); {
+		next, ok, err := parseTSRangeOption(args, i, from, to, opts)
+		if err != nil {
+			return Encode(err, false)
+		}
+		if !ok {
+			return Encode(errors.New("(error) ERR TSDB: unknown option "+args[i]), false)
+		}
+		i = next
+	}
+	return Encode(tsRange(s, from, to, opts, reverse), false)
+}
+
+func cmdTSRANGE(args []string) []byte {
+	return tsRangeGeneric("TS.RANGE", args, false)
+}
+
+func cmdTSREVRANGE(args []string) []byte {
+	return tsRangeGeneric("TS.REVRANGE", args, true)
+}
+
+func encodeLabels(labels []time_series.Label) [][]string {
+	res := make([][]string, len(labels))
+	for i, l := range labels {
+		res[i] = []string{l.Name, l.Value}
+	}
+	return res
+}
+
+// cmdTSMRANGE implements TS.MRANGE from to [WITHLABELS] [COUNT count] [ALIGN align]
+// [AGGREGATION aggregator bucketDuration] FILTER filter [filter ...], replying with the key,
+// the labels and the samples of each matching series, ordered by key
+func cmdTSMRANGE(args []string) []byte {
+	if len(args) < 4 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'TS.MRANGE' command"), false)
+	}
+	from, err1 := parseRangeTimestamp(args[0])
+	to, err2 := parseRangeTimestamp(args[1])
+	if err1 != nil || err2 != nil {
+		return Encode(errors.New("(error) ERR TSDB: invalid timestamp"), false)
+	}
+	opts := &tsRangeOptions{}
+	withLabels := false
+	var filters []*time_series.LabelFilter
+	for i := 2; i < len(args); {
+		if strings.ToUpper(args[i]) == "WITHLABELS" {
+			withLabels = true
+			i++
+			continue
+		}
+		if strings.ToUpper(args[i]) == "FILTER" {
+			for _, text := range args[i+1:] {
+				f, err := time_series.ParseLabelFilter(text)
+				if err != nil {
+					return Encode(errors.New("(error) ERR TSDB: failed parsing labels"), false)
+				}
+				filters = append(filters, f)
+			}
+			break
+		}
+		next, ok, err := parseTSRangeOption(args, i, from, to, opts)
+		if err != nil {
+			return Encode(err, false)
+		}
+		if !ok {
+			return Encode(errors.New("(error) ERR TSDB: unknown option "+args[i]), false)
+		}
+		i = next
+	}
+	if !slices.ContainsFunc(filters, (*time_series.LabelFilter).Positive) {
+		return Encode(errors.New("(error) ERR TSDB: please provide at least one matcher"), false)
+	}
+
+	keys := make([]string, 0)
+	for key, s := range tsStore {
+		matches := true
+		for _, f := range filters {
+			if !f.Match(s) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	res := make([]interface{}, len(keys))
+	for i, key := range keys {
+		s := tsStore[key]
+		labels := [][]string{}
+		if withLabels {
+			labels = encodeLabels(s.Labels)
+		}
+		res[i] = []interface{}{key, labels, tsRange(s, from, to, opts, false)}
+	}
+	return Encode(res, false)
+}
+
+// cmdTSCREATERULE implements TS.CREATERULE sourceKey destKey AGGREGATION aggregator
+// bucketDuration [alignTimestamp]. Compactions can not be chained.
+func cmdTSCREATERULE(args []string) []byte {
+	if len(args) != 5 && len(args) != 6 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'TS.CREATERULE' command"), false)
+	}
+	if strings.ToUpper(args[2]) != "AGGREGATION" {
+		return Encode(errors.New("(error) ERR TSDB: wrong AGGREGATION"), false)
+	}
+	agg, ok := time_series.ParseAggregation(args[3])
+	if !ok {
+		return Encode(errors.New("(error) ERR TSDB: Unknown aggregation type"), false)
+	}
+	duration, err := strconv.ParseInt(args[4], 10, 64)
+	if err != nil || duration <= 0 {
+		return Encode(errors.New("(error) ERR TSDB: bucketDuration must be greater than zero"), false)
+	}
+	var align int64
+	if len(args) == 6 {
+		if align, err = strconv.ParseInt(args[5], 10, 64); err != nil {
+			return Encode(errors.New("(error) ERR TSDB: invalid alignTimestamp"), false)
+		}
+	}
+
+	if args[0] == args[1] {
+		return Encode(errors.New("(error) ERR TSDB: the source key and destination key should be different"), false)
+	}
+	src, err := lookupSeries(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	dest, err := lookupSeries(args[1])
+	if err != nil {
+		return Encode(err, false)
+	}
+	switch {
+	case src.SourceKey != "":
+		return Encode(errors.New("(error) ERR TSDB: the source key is already the destination of a compaction rule"), false)
+	case dest.SourceKey != "":
+		return Encode(errors.New("(error) ERR TSDB: the destination key is already the destination of a compaction rule"), false)
+	case len(dest.Rules) > 0:
+		return Encode(errors.New("(error) ERR TSDB: the destination key is the source of a compaction rule"), false)
+	}
+	src.Rules = append(src.Rules, &time_series.Rule{
+		DestKey:        args[1],
+		Aggregation:    agg,
+		BucketDuration: duration,
+		AlignTimestamp: align,
+	})
+	dest.SourceKey = args[0]
+	return constant.RespOk
+}
+
+// cmdTSDELETERULE implements TS.DELETERULE sourceKey destKey
+func cmdTSDELETERULE(args []string) []byte {
+	if len(args) != 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'TS.DELETERULE' command"), false)
+	}
+	src, err := lookupSeries(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	i := slices.IndexFunc(src.Rules, func(r *time_series.Rule) bool { return r.DestKey == args[1] })
+	if i < 0 {
+		return Encode(errors.New("(error) ERR TSDB: compaction rule does not exist"), false)
+	}
+	src.Rules = slices.Delete(src.Rules, i, i+1)
+	if dest, exist := tsStore[args[1]]; exist {
+		dest.SourceKey = ""
+	}
+	return constant.RespOk
+}
+
+func cmdTSINFO(args []string) []byte {
+	if len(args) != 1 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'TS.INFO' command"), false)
+	}
+	s, err := lookupSeries(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	var first, last int64
+	if sample, ok := s.First(); ok {
+		first = sample.Timestamp
+	}
+	if sample, ok := s.Last(); ok {
+		last = sample.Timestamp
+	}
+	var sourceKey interface{}
+	if s.SourceKey != "" {
+		sourceKey = s.SourceKey
+	}
+	rules := make([]interface{}, len(s.Rules))
+	for i, r := range s.Rules {
+		rules[i] = []interface{}{r.DestKey, r.BucketDuration, strings.ToUpper(string(r.Aggregation)), r.AlignTimestamp}
+	}
+	return Encode([]interface{}{
+		"totalSamples", s.Len(),
+		"memoryUsage", s.MemoryUsage(),
+		"firstTimestamp", first,
+		"lastTimestamp", last,
+		"retentionTime", s.Retention,
+		"chunkCount", s.NumChunks(),
+		"chunkSize", s.ChunkSize,
+		"duplicatePolicy", string(s.DuplicatePolicy),
+		"labels", encodeLabels(s.Labels),
+		"sourceKey", sourceKey,
+		"rules", rules,
+	}, false)
+}
@@ -0,0 +1,179 @@
+package core
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/spaghetti-lover/multithread-redis/internal/constant"
+	"github.com/spaghetti-lover/multithread-redis/internal/data_structure/probabilistic"
+)
+
+// topkMaxIncrement is the largest increment of TOPK.INCRBY, decaying the buckets of other
+// items costs one random draw per unit
+const topkMaxIncrement = 100000
+
+// cmdTOPKRESERVE implements TOPK.RESERVE key topk [width depth decay]
+func cmdTOPKRESERVE(args []string) []byte {
+	if len(args) != 2 && len(args) != 5 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'TOPK.RESERVE' command"), false)
+	}
+	k, err := strconv.Atoi(args[1])
+	if err != nil || k <= 0 || k > probabilistic.MaxTopK {
+		return Encode(errors.New("(error) ERR TopK: invalid k"), false)
+	}
+	width, depth, decay := constant.TopkDefaultWidth, constant.TopkDefaultDepth, constant.TopkDefaultDecay
+	if len(args) == 5 {
+		if width, err = strconv.Atoi(args[2]); err != nil || width <= 0 || width > probabilistic.MaxTopKBuckets {
+			return Encode(errors.New("(error) ERR TopK: invalid width"), false)
+		}
+		if depth, err = strconv.Atoi(args[3]); err != nil || depth <= 0 || depth > probabilistic.MaxTopKBuckets {
+			return Encode(errors.New("(error) ERR TopK: invalid depth"), false)
+		}
+		if decay, err = strconv.ParseFloat(args[4], 64); err != nil || decay <= 0 || decay > 1 {
+			return Encode(errors.New("(error) ERR TopK: invalid decay value. must be '<= 1' & '> 0'"), false)
+		}
+	}
+	if !probabilistic.ValidTopKSize(k, width, depth) {
+		return Encode(errors.New("(error) ERR TopK: width * depth is too large"), false)
+	}
+
+	key := args[0]
+	if _, exist := topkStore[key]; exist {
+		return Encode(errors.New("(error) ERR TopK: key already exists"), false)
+	}
+	topkStore[key] = probabilistic.NewTopK(k, width, depth, decay)
+	return constant.RespOk
+}
+
+func lookupTopK(key string) (*probabilistic.TopK, error) {
+	topk, exist := topkStore[key]
+	if !exist {
+		return nil, errors.New("(error) ERR TopK: key does not exist")
+	}
+	return topk, nil
+}
+
+// expelledReply is the reply for an item added by TOPK.ADD or TOPK.INCRBY: the item it
+// expelled from the list, or nil
+func expelledReply(item string, expelled bool) interface{} {
+	if !expelled {
+		return nil
+	}
+	return item
+}
+
+func cmdTOPKADD(args []string) []byte {
+	if len(args) < 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'TOPK.ADD' command"), false)
+	}
+	topk, err := lookupTopK(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	res := make([]interface{}, 0, len(args)-1)
+	for _, item := range args[1:] {
+		res = append(res, expelledReply(topk.IncrBy(item, 1)))
+	}
+	return Encode(res, false)
+}
+
+// cmdTOPKINCRBY implements TOPK.INCRBY key item increment [item increment ...]
+func cmdTOPKINCRBY(args []string) []byte {
+	if len(args) < 3 || len(args)%2 == 0 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'TOPK.INCRBY' command"), false)
+	}
+	topk, err := lookupTopK(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	increments := make([]uint64, 0, len(args)/2)
+	for i := 2; i < len(args); i += 2 {
+		incr, err := strconv.ParseUint(args[i], 10, 64)
+		if err != nil || incr < 1 || incr > topkMaxIncrement {
+			return Encode(errors.New("(error) ERR TopK: increment must be an integer greater or equal to 1 and less than or equal to 100000"), false)
+		}
+		increments = append(increments, incr)
+	}
+	res := make([]interface{}, 0, len(increments))
+	for i, incr := range increments {
+		res = append(res, expelledReply(topk.IncrBy(args[1+2*i], incr)))
+	}
+	return Encode(res, false)
+}
+
+func cmdTOPKQUERY(args []string) []byte {
+	if len(args) < 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'TOPK.QUERY' command"), false)
+	}
+	topk, err := lookupTopK(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	res := make([]interface{}, 0, len(args)-1)
+	for _, item := range args[1:] {
+		if topk.Query(item) {
+			res = append(res, 1)
+		} else {
+			res = append(res, 0)
+		}
+	}
+	return Encode(res, false)
+}
+
+func cmdTOPKCOUNT(args []string) []byte {
+	if len(args) < 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'TOPK.COUNT' command"), false)
+	}
+	topk, err := lookupTopK(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	res := make([]interface{}, 0, len(args)-1)
+	for _, item := range args[1:] {
+		res = append(res, int64(topk.Count(item)))
+	}
+	return Encode(res, false)
+}
+
+// cmdTOPKLIST implements TOPK.LIST key [WITHCOUNT]
+func cmdTOPKLIST(args []string) []byte {
+	if len(args) < 1 || len(args) > 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'TOPK.LIST' command"), false)
+	}
+	withCount := false
+	if len(args) == 2 {
+		if strings.ToUpper(args[1]) != "WITHCOUNT" {
+			return Encode(errors.New("(error) ERR syntax error"), false)
+		}
+		withCount = true
+	}
+	topk, err := lookupTopK(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	res := make([]interface{}, 0)
+	for _, x := range topk.List() {
+		res = append(res, x.Item)
+		if withCount {
+			res = append(res, int64(x.Count))
+		}
+	}
+	return Encode(res, false)
+}
+
+func cmdTOPKINFO(args []string) []byte {
+	if len(args) != 1 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'TOPK.INFO' command"), false)
+	}
+	topk, err := lookupTopK(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	return Encode([]interface{}{
+		"k", topk.K,
+		"width", topk.Width,
+		"depth", topk.Depth,
+		"decay", strconv.FormatFloat(topk.Decay, 'f', -1, 64),
+	}, false)
+}
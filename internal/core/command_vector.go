@@ -0,0 +1,361 @@
+package core
+
+import (
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/spaghetti-lover/multithread-redis/internal/constant"
+	"github.com/spaghetti-lover/multithread-redis/internal/data_structure/vector_set"
+)
+
+var errInvalidVector = errors.New("(error) ERR invalid vector specification")
+
+// parseVector parses FP32 blob, a little-endian float32 array, or VALUES num value [value ...]
+// starting at args[i]. Returns the vector and the position of the next argument.
+func parseVector(args []string, i int) ([]float32, int, error) {
+	if i+1 >= len(args) {
+		return nil, 0, errInvalidVector
+	}
+	switch strings.ToUpper(args[i]) {
+	case "FP32":
+		blob := args[i+1]
+		if len(blob) == 0 || len(blob)%4 != 0 {
+			return nil, 0, errInvalidVector
+		}
+		v := make([]float32, len(blob)/4)
+		for j := range v {
+			v[j] = math.Float32frombits(binary.LittleEndian.Uint32([]byte(blob[4*j : 4*j+4])))
+			if f := float64(v[j]); math.IsNaN(f) || math.IsInf(f, 0) {
+				return nil, 0, errInvalidVector
+			}
+		}
+		return v, i + 2, nil
+	case "VALUES":
+		n, err := strconv.Atoi(args[i+1])
+		if err != nil || n <= 0 || i+2+n > len(args) {
+			return nil, 0, errInvalidVector
+		}
+		v := make([]float32, n)
+		for j := range v {
+			f, err := strconv.ParseFloat(args[i+2+j], 32)
+			if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+				return nil, 0, errInvalidVector
+			}
+			v[j] = float32(f)
+		}
+		return v, i + 2 + n, nil
+	}
+	return nil, 0, errInvalidVector
+}
+
+func parsePositiveInt(s string, limit int) (int, bool) {
+	n, err := strconv.Atoi(s)
+	return n, err == nil && n > 0 && n <= limit
+}
+
+func formatFloat32(f float32) string {
+	return strconv.FormatFloat(float64(f), 'g', -1, 32)
+}
+
+// cmdVADD implements VADD key (FP32 blob | VALUES num value [value ...]) element
+// [SETATTR attributes] [M numlinks] [EF build-exploration-factor] [METRIC COSINE | L2 | IP]
+// [FLAT | HNSW]. The metric, the index and its parameters are set when the key is created.
+func cmdVADD(args []string) []byte {
+	if len(args) < 4 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'VADD' command"), false)
+	}
+	key := args[0]
+	vector, i, err := parseVector(args, 1)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if i >= len(args) {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'VADD' command"), false)
+	}
+	element := args[i]
+
+	attributes, hasAttributes := "", false
+	m, ef := 0, 0
+	var metric vector_set.Metric
+	var index vector_set.IndexType
+	for i++; i < len(args); i++ {
+		opt := strings.ToUpper(args[i])
+		switch {
+		case opt == "FLAT" || opt == "HNSW":
+			index = vector_set.IndexType(opt)
+			continue
+		case i+1 >= len(args):
+			return Encode(errors.New("(error) ERR syntax error"), false)
+		}
+		i++
+		var ok bool
+		switch opt {
+		case "SETATTR":
+			attributes, hasAttributes = args[i], true
+			if attributes != "" {
+				if _, err := vector_set.ParseAttributes(attributes); err != nil {
+					return Encode(errors.New("(error) ERR invalid JSON attributes"), false)
+				}
+			}
+		case "M":
+			if m, ok = parsePositiveInt(args[i], 4096); !ok || m < 2 {
+				return Encode(errors.New("(error) ERR M must be between 2 and 4096"), false)
+			}
+		case "EF":
+			if ef, ok = parsePositiveInt(args[i], 1000000); !ok {
+				return Encode(errors.New("(error) ERR EF must be between 1 and 1000000"), false)
+			}
+		case "METRIC":
+			metric = vector_set.Metric(strings.ToUpper(args[i]))
+			if metric != vector_set.MetricCosine && metric != vector_set.MetricL2 && metric != vector_set.MetricIP {
+				return Encode(errors.New("(error) ERR METRIC must be COSINE, L2 or IP"), false)
+			}
+		default:
+			return Encode(errors.New("(error) ERR syntax error"), false)
+		}
+	}
+
+	s, exist := vsetStore[key]
+	if exist {
+		switch {
+		case len(vector) != s.Dim:
+			return Encode(fmt.Errorf("(error) ERR Vector dimension mismatch - got %d but set has %d", len(vector), s.Dim), false)
+		case m != 0 && m != s.M:
+			return Encode(errors.New("(error) ERR asked M value mismatch with existing vector set"), false)
+		case metric != "" && metric != s.Metric:
+			return Encode(errors.New("(error) ERR asked METRIC mismatch with existing vector set"), false)
+		case index != "" && index != s.Index:
+			return Encode(errors.New("(error) ERR asked index type mismatch with existing vector set"), false)
+		}
+	} else {
+		s = vector_set.New(len(vector), cmp.Or(metric, vector_set.MetricCosine), cmp.Or(index, vector_set.IndexHNSW),
+			cmp.Or(m, constant.VsetDefaultM), cmp.Or(ef, constant.VsetDefaultEfConstruction))
+		vsetStore[key] = s
+	}
+	added := s.Add(element, vector)
+	if hasAttributes {
+		s.SetAttributes(element, attributes)
+	}
+	if added {
+		return constant.RespOne
+	}
+	return constant.RespZero
+}
+
+// cmdVSIM implements VSIM key (ELE element | FP32 blob | VALUES num value [value ...])
+// [WITHSCORES] [WITHATTRIBS] [COUNT num] [EF search-exploration-factor] [FILTER expression]
+// [FILTER-EF max-filtering-effort] [TRUTH]. TRUTH runs an exact search on an HNSW set.
+func cmdVSIM(args []string) []byte {
+	if len(args) < 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'VSIM' command"), false)
+	}
+	s, exist := vsetStore[args[0]]
+
+	var query []float32
+	i := 1
+	if strings.ToUpper(args[1]) == "ELE" {
+		if exist {
+			var ok bool
+			if query, ok = s.Vector(args[2]); !ok {
+				return Encode(errors.New("(error) ERR element not found in set"), false)
+			}
+		}
+		i = 3
+	} else {
+		var err error
+		if query, i, err = parseVector(args, 1); err != nil {
+			return Encode(err, false)
+		}
+	}
+
+	withScores, withAttribs := false, false
+	opts := vector_set.SearchOptions{Count: constant.VsetDefaultCount, Ef: constant.VsetDefaultEfSearch}
+	for ; i < len(args); i++ {
+		opt := strings.ToUpper(args[i])
+		switch opt {
+		case "WITHSCORES":
+			withScores = true
+			continue
+		case "WITHATTRIBS":
+			withAttribs = true
+			continue
+		case "TRUTH":
+			opts.Exact = true
+			continue
+		}
+		if i+1 >= len(args) {
+			return Encode(errors.New("(error) ERR syntax error"), false)
+		}
+		i++
+		var ok bool
+		switch opt {
+		case "COUNT":
+			if opts.Count, ok = parsePositiveInt(args[i], math.MaxInt32); !ok {
+				return Encode(errors.New("(error) ERR invalid COUNT"), false)
+			}
+		case "EF":
+			if opts.Ef, ok = parsePositiveInt(args[i], 1000000); !ok {
+				return Encode(errors.New("(error) ERR invalid EF"), false)
+			}
+		case "FILTER-EF":
+			if opts.FilterEf, ok = parsePositiveInt(args[i], math.MaxInt32); !ok {
+				return Encode(errors.New("(error) ERR invalid FILTER-EF"), false)
+			}
+		case "FILTER":
+			f, err := vector_set.ParseFilter(args[i])
+			if err != nil {
+				return Encode(errors.New("(error) ERR "+err.Error()), false)
+			}
+			opts.Filter = f
+		default:
+			return Encode(errors.New("(error) ERR syntax error"), false)
+		}
+	}
+
+	if !exist {
+		return Encode([]string{}, false)
+	}
+	if len(query) != s.Dim {
+		return Encode(fmt.Errorf("(error) ERR Vector dimension mismatch - got %d but set has %d", len(query), s.Dim), false)
+	}
+	res := []interface{}{}
+	for _, r := range s.Search(query, opts) {
+		res = append(res, r.Name)
+		if withScores {
+			res = append(res, formatScore(r.Score))
+		}
+		if withAttribs {
+			if attributes, _ := s.Attributes(r.Name); attributes != "" {
+				res = append(res, attributes)
+			} else {
+				res = append(res, nil)
+			}
+		}
+	}
+	return Encode(res, false)
+}
+
+// cmdVREM implements VREM key element, deleting the key when its last element is removed
+func cmdVREM(args []string) []byte {
+	if len(args) != 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'VREM' command"), false)
+	}
+	s, exist := vsetStore[args[0]]
+	if !exist || !s.Remove(args[1]) {
+		return constant.RespZero
+	}
+	if s.Len() == 0 {
+		delete(vsetStore, args[0])
+	}
+	return constant.RespOne
+}
+
+func cmdVCARD(args []string) []byte {
+	if len(args) != 1 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'VCARD' command"), false)
+	}
+	s, exist := vsetStore[args[0]]
+	if !exist {
+		return constant.RespZero
+	}
+	return Encode(s.Len(), false)
+}
+
+func cmdVDIM(args []string) []byte {
+	if len(args) != 1 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'VDIM' command"), false)
+	}
+	s, exist := vsetStore[args[0]]
+	if !exist {
+		return Encode(errors.New("(error) ERR key does not exist"), false)
+	}
+	return Encode(s.Dim, false)
+}
+
+func cmdVISMEMBER(args []string) []byte {
+	if len(args) != 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'VISMEMBER' command"), false)
+	}
+	if s, exist := vsetStore[args[0]]; exist && s.Exists(args[1]) {
+		return constant.RespOne
+	}
+	return constant.RespZero
+}
+
+// cmdVEMB implements VEMB key element, replying with the vector of the element
+func cmdVEMB(args []string) []byte {
+	if len(args) != 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'VEMB' command"), false)
+	}
+	s, exist := vsetStore[args[0]]
+	if !exist {
+		return constant.RespNil
+	}
+	v, ok := s.Vector(args[1])
+	if !ok {
+		return constant.RespNil
+	}
+	res := make([]string, len(v))
+	for i, x := range v {
+		res[i] = formatFloat32(x)
+	}
+	return Encode(res, false)
+}
+
+func cmdVGETATTR(args []string) []byte {
+	if len(args) != 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'VGETATTR' command"), false)
+	}
+	s, exist := vsetStore[args[0]]
+	if !exist {
+		return constant.RespNil
+	}
+	attributes, _ := s.Attributes(args[1])
+	if attributes == "" {
+		return constant.RespNil
+	}
+	return Encode(attributes, false)
+}
+
+// cmdVSETATTR implements VSETATTR key element attributes, an empty string removing the attributes
+func cmdVSETATTR(args []string) []byte {
+	if len(args) != 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'VSETATTR' command"), false)
+	}
+	s, exist := vsetStore[args[0]]
+	if !exist {
+		return constant.RespZero
+	}
+	ok, err := s.SetAttributes(args[1], args[2])
+	if err != nil {
+		return Encode(errors.New("(error) ERR invalid JSON attributes"), false)
+	}
+	if !ok {
+		return constant.RespZero
+	}
+	return constant.RespOne
+}
+
+func cmdVINFO(args []string) []byte {
+	if len(args) != 1 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'VINFO' command"), false)
+	}
+	s, exist := vsetStore[args[0]]
+	if !exist {
+		return constant.RespNil
+	}
+	return Encode([]interface{}{
+		"metric", string(s.Metric),
+		"index-type", string(s.Index),
+		"vector-dim", s.Dim,
+		"size", s.Len(),
+		"max-level", s.MaxLevel(),
+		"hnsw-m", s.M,
+		"ef-construction", s.EfConstruction,
+	}, false)
+}
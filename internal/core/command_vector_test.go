@@ -0,0 +1,44 @@
+package core
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fp32Blob encodes values as the blob of FP32
+func fp32Blob(values ...float32) string {
+	blob := make([]byte, 0, 4*len(values))
+	for _, v := range values {
+		blob = binary.LittleEndian.AppendUint32(blob, math.Float32bits(v))
+	}
+	return string(blob)
+}
+
+func TestParseVector(t *testing.T) {
+	v, next, err := parseVector([]string{"FP32", fp32Blob(1, -2.5), "elem"}, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []float32{1, -2.5}, v)
+	assert.Equal(t, 2, next)
+
+	v, next, err = parseVector([]string{"key", "VALUES", "2", "1", "-2.5"}, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []float32{1, -2.5}, v)
+	assert.Equal(t, 5, next)
+
+	// Both forms reject NaN and infinities
+	for _, args := range [][]string{
+		{"FP32", fp32Blob(1, float32(math.NaN()))},
+		{"FP32", fp32Blob(float32(math.Inf(1)))},
+		{"FP32", fp32Blob(1, float32(math.Inf(-1)))},
+		{"FP32", "abc"},
+		{"VALUES", "1", "nan"},
+		{"VALUES", "2", "1", "-inf"},
+		{"VALUES", "2", "1"},
+	} {
+		_, _, err := parseVector(args, 0)
+		assert.Equal(t, errInvalidVector, err, args)
+	}
+}
@@ -0,0 +1,470 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spaghetti-lover/multithread-redis/internal/constant"
+	"github.com/spaghetti-lover/multithread-redis/internal/data_structure/geo"
+	"github.com/spaghetti-lover/multithread-redis/internal/data_structure/sorted_set"
+)
+
+// geoUnits converts a distance unit to meters
+var geoUnits = map[string]float64{
+	"m":  1,
+	"km": 1000,
+	"ft": 0.3048,
+	"mi": 1609.34,
+}
+
+func parseGeoUnit(arg string) (float64, error) {
+	unit, exist := geoUnits[strings.ToLower(arg)]
+	if !exist {
+		return 0, errors.New("(error) ERR unsupported unit provided. please use M, KM, FT, MI")
+	}
+	return unit, nil
+}
+
+func parseGeoFloat(arg string) (float64, error) {
+	v, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		return 0, errors.New("(error) ERR value is not a valid float")
+	}
+	return v, nil
+}
+
+func formatGeoDistance(meters, unit float64) string {
+	return fmt.Sprintf("%.4f", meters/unit)
+}
+
+func formatGeoCoord(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// GEOADD key [NX | XX] [CH] longitude latitude member [longitude latitude member ...]
+func cmdGEOADD(args []string) []byte {
+	if len(args) < 4 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'GEOADD' command"), false)
+	}
+	key := args[0]
+	nx, xx, ch := false, false, false
+	i := 1
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "NX":
+			nx = true
+			continue
+		case "XX":
+			xx = true
+			continue
+		case "CH":
+			ch = true
+			continue
+		}
+		break
+	}
+	if nx && xx {
+		return Encode(errors.New("(error) ERR XX and NX options at the same time are not compatible"), false)
+	}
+	rest := args[i:]
+	if len(rest) == 0 || len(rest)%3 != 0 {
+		return Encode(errors.New("(error) ERR syntax error. Try GEOADD key [x1] [y1] [name1] [x2] [y2] [name2] ... "), false)
+	}
+
+	scores := make([]float64, 0, len(rest)/3)
+	for j := 0; j < len(rest); j += 3 {
+		lon, err := parseGeoFloat(rest[j])
+		if err != nil {
+			return Encode(err, false)
+		}
+		lat, err := parseGeoFloat(rest[j+1])
+		if err != nil {
+			return Encode(err, false)
+		}
+		if !geo.ValidCoordinates(lon, lat) {
+			return Encode(fmt.Errorf("(error) ERR invalid longitude,latitude pair %f,%f", lon, lat), false)
+		}
+		scores = append(scores, geo.Score(lon, lat))
+	}
+
+	zset, exist := zsetStore[key]
+	if !exist {
+		if xx {
+			return constant.RespZero
+		}
+		var err error
+		zset, err = newSortedSet()
+		if err != nil {
+			return Encode(errors.New("(error) Can not initialize sorted set: "+err.Error()), false)
+		}
+	}
+
+	added, changed := 0, 0
+	for j, score := range scores {
+		member := rest[3*j+2]
+		old, exist := zset.GetScore(member)
+		if (nx && exist) || (xx && !exist) {
+			continue
+		}
+		if !exist {
+			added++
+		} else if old != score {
+			changed++
+		}
+		zset.Add(score, member)
+	}
+	if zset.Len() > 0 {
+		zsetStore[key] = zset
+		blocking.signalKeyAsReady(key)
+	}
+	if ch {
+		return Encode(added+changed, false)
+	}
+	return Encode(added, false)
+}
+
+// GEOPOS key [member [member ...]]
+func cmdGEOPOS(args []string) []byte {
+	if len(args) < 1 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'GEOPOS' command"), false)
+	}
+	zset := zsetStore[args[0]]
+	res := make([]interface{}, 0, len(args)-1)
+	for _, member := range args[1:] {
+		if zset == nil {
+			res = append(res, nil)
+			continue
+		}
+		score, exist := zset.GetScore(member)
+		if !exist {
+			res = append(res, nil)
+			continue
+		}
+		lon, lat := geo.DecodeScore(score)
+		res = append(res, []string{formatGeoCoord(lon), formatGeoCoord(lat)})
+	}
+	return Encode(res, false)
+}
+
+// GEODIST key member1 member2 [M | KM | FT | MI]
+func cmdGEODIST(args []string) []byte {
+	if len(args) != 3 && len(args) != 4 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'GEODIST' command"), false)
+	}
+	unit := 1.0
+	if len(args) == 4 {
+		var err error
+		if unit, err = parseGeoUnit(args[3]); err != nil {
+			return Encode(err, false)
+		}
+	}
+	zset, exist := zsetStore[args[0]]
+	if !exist {
+		return constant.RespNil
+	}
+	score1, exist1 := zset.GetScore(args[1])
+	score2, exist2 := zset.GetScore(args[2])
+	if !exist1 || !exist2 {
+		return constant.RespNil
+	}
+	lon1, lat1 := geo.DecodeScore(score1)
+	lon2, lat2 := geo.DecodeScore(score2)
+	return Encode(formatGeoDistance(geo.Distance(lon1, lat1, lon2, lat2), unit), false)
+}
+
+// GEOHASH key [member [member ...]]
+func cmdGEOHASH(args []string) []byte {
+	if len(args) < 1 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'GEOHASH' command"), false)
+	}
+	zset := zsetStore[args[0]]
+	res := make([]interface{}, 0, len(args)-1)
+	for _, member := range args[1:] {
+		if zset == nil {
+			res = append(res, nil)
+			continue
+		}
+		score, exist := zset.GetScore(member)
+		if !exist {
+			res = append(res, nil)
+			continue
+		}
+		res = append(res, geo.HashString(score))
+	}
+	return Encode(res, false)
+}
+
+// geoSearchArgs holds the options of GEOSEARCH and GEOSEARCHSTORE
+type geoSearchArgs struct {
+	fromMember string
+	hasMember  bool
+	lon, lat   float64
+	hasLonLat  bool
+	shape      geo.Shape
+	byRadius   bool
+	byBox      bool
+	unit       float64
+	order      int // 0 unsorted, 1 ascending, -1 descending distance
+	count      int // 0 means no limit
+	any        bool
+	withDist   bool
+	withCoord  bool
+	withHash   bool
+	storeDist  bool
+}
+
+// geoPoint is a member found by a search
+type geoPoint struct {
+	member   string
+	score    float64
+	dist     float64 // meters
+	lon, lat float64
+}
+
+// parseGeoSearchArgs parses
+// FROMMEMBER member | FROMLONLAT longitude latitude
+// BYRADIUS radius unit | BYBOX width height unit
+// [ASC | DESC] [COUNT count [ANY]] [WITHCOORD] [WITHDIST] [WITHHASH] (GEOSEARCH)
+// [ASC | DESC] [COUNT count [ANY]] [STOREDIST] (GEOSEARCHSTORE)
+func parseGeoSearchArgs(cmd string, args []string, store bool) (*geoSearchArgs, error) {
+	a := &geoSearchArgs{}
+	syntaxErr := errors.New("(error) ERR syntax error")
+	for i := 0; i < len(args); i++ {
+		opt := strings.ToUpper(args[i])
+		remaining := len(args) - i - 1
+		var err error
+		switch {
+		case opt == "FROMMEMBER" && remaining >= 1:
+			if a.hasMember || a.hasLonLat {
+				return nil, errors.New("(error) ERR exactly one of FROMMEMBER or FROMLONLAT can be specified for " + cmd)
+			}
+			a.fromMember = args[i+1]
+			a.hasMember = true
+			i++
+		case opt == "FROMLONLAT" && remaining >= 2:
+			if a.hasMember || a.hasLonLat {
+				return nil, errors.New("(error) ERR exactly one of FROMMEMBER or FROMLONLAT can be specified for " + cmd)
+			}
+			if a.lon, err = parseGeoFloat(args[i+1]); err != nil {
+				return nil, err
+			}
+			if a.lat, err = parseGeoFloat(args[i+2]); err != nil {
+				return nil, err
+			}
+			if !geo.ValidCoordinates(a.lon, a.lat) {
+				return nil, fmt.Errorf("(error) ERR invalid longitude,latitude pair %f,%f", a.lon, a.lat)
+			}
+			a.hasLonLat = true
+			i += 2
+		case opt == "BYRADIUS" && remaining >= 2:
+			if a.byRadius || a.byBox {
+				return nil, errors.New("(error) ERR exactly one of BYRADIUS and BYBOX can be specified for " + cmd)
+			}
+			radius, err := parseGeoFloat(args[i+1])
+			if err != nil {
+				return nil, err
+			}
+			if radius < 0 {
+				return nil, errors.New("(error) ERR radius cannot be negative")
+			}
+			if a.unit, err = parseGeoUnit(args[i+2]); err != nil {
+				return nil, err
+			}
+			a.shape.Radius = radius * a.unit
+			a.byRadius = true
+			i += 2
+		case opt == "BYBOX" && remaining >= 3:
+			if a.byRadius || a.byBox {
+				return nil, errors.New("(error) ERR exactly one of BYRADIUS and BYBOX can be specified for " + cmd)
+			}
+			width, err := parseGeoFloat(args[i+1])
+			if err != nil {
+				return nil, err
+			}
+			height, err := parseGeoFloat(args[i+2])
+			if err != nil {
+				return nil, err
+			}
+			if width < 0 || height < 0 {
+				return nil, errors.New("(error) ERR height or width cannot be negative")
+			}
+			if a.unit, err = parseGeoUnit(args[i+3]); err != nil {
+				return nil, err
+			}
+			a.shape.Width = width * a.unit
+			a.shape.Height = height * a.unit
+			a.byBox = true
+			i += 3
+		case opt == "ASC":
+			a.order = 1
+		case opt == "DESC":
+			a.order = -1
+		case opt == "COUNT" && remaining >= 1:
+			count, err := strconv.Atoi(args[i+1])
+			if err != nil || count <= 0 {
+				return nil, errors.New("(error) ERR COUNT must be > 0")
+			}
+			a.count = count
+			i++
+		case opt == "ANY":
+			a.any = true
+		case opt == "WITHDIST" && !store:
+			a.withDist = true
+		case opt == "WITHCOORD" && !store:
+			a.withCoord = true
+		case opt == "WITHHASH" && !store:
+			a.withHash = true
+		case opt == "STOREDIST" && store:
+			a.storeDist = true
+		default:
+			return nil, syntaxErr
+		}
+	}
+
+	if !a.hasMember && !a.hasLonLat {
+		return nil, errors.New("(error) ERR exactly one of FROMMEMBER or FROMLONLAT can be specified for " + cmd)
+	}
+	if !a.byRadius && !a.byBox {
+		return nil, errors.New("(error) ERR exactly one of BYRADIUS and BYBOX can be specified for " + cmd)
+	}
+	if a.any && a.count == 0 {
+		return nil, errors.New("(error) ERR the ANY argument requires COUNT argument")
+	}
+	// COUNT without ANY returns the closest members
+	if a.count > 0 && !a.any && a.order == 0 {
+		a.order = 1
+	}
+	return a, nil
+}
+
+// geoSearch returns the members of zset inside the search shape. Each geohash box covering
+// the shape is a range of scores, scanned with the ordered index of the sorted set.
+func geoSearch(zset *sorted_set.SortedSet, a *geoSearchArgs) ([]geoPoint, error) {
+	shape := a.shape
+	if a.hasMember {
+		score, exist := zset.GetScore(a.fromMember)
+		if !exist {
+			return nil, errors.New("(error) ERR could not decode requested zset member")
+		}
+		shape.Lon, shape.Lat = geo.DecodeScore(score)
+	} else {
+		shape.Lon, shape.Lat = a.lon, a.lat
+	}
+
+	points := make([]geoPoint, 0)
+	for _, r := range shape.SearchRanges() {
+		// Scores are integers, the upper bound of the range is exclusive
+		for _, item := range zset.Index.GetRange(r.Min, r.Max-1) {
+			lon, lat := geo.DecodeScore(item.Score)
+			dist, ok := shape.Contains(lon, lat)
+			if !ok {
+				continue
+			}
+			points = append(points, geoPoint{member: item.Member, score: item.Score, dist: dist, lon: lon, lat: lat})
+			if a.any && len(points) == a.count {
+				break
+			}
+		}
+		if a.any && len(points) == a.count {
+			break
+		}
+	}
+
+	if a.order != 0 {
+		sort.SliceStable(points, func(i, j int) bool {
+			if a.order > 0 {
+				return points[i].dist < points[j].dist
+			}
+			return points[i].dist > points[j].dist
+		})
+	}
+	if a.count > 0 && len(points) > a.count {
+		points = points[:a.count]
+	}
+	return points, nil
+}
+
+// GEOSEARCH key FROMMEMBER member | FROMLONLAT longitude latitude BYRADIUS radius unit | BYBOX width height unit
+// [ASC | DESC] [COUNT count [ANY]] [WITHCOORD] [WITHDIST] [WITHHASH]
+func cmdGEOSEARCH(args []string) []byte {
+	if len(args) < 5 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'GEOSEARCH' command"), false)
+	}
+	a, err := parseGeoSearchArgs("GEOSEARCH", args[1:], false)
+	if err != nil {
+		return Encode(err, false)
+	}
+	zset, exist := zsetStore[args[0]]
+	if !exist {
+		return Encode(make([]string, 0), false)
+	}
+	points, err := geoSearch(zset, a)
+	if err != nil {
+		return Encode(err, false)
+	}
+
+	if !a.withDist && !a.withCoord && !a.withHash {
+		res := make([]string, 0, len(points))
+		for _, p := range points {
+			res = append(res, p.member)
+		}
+		return Encode(res, false)
+	}
+	res := make([]interface{}, 0, len(points))
+	for _, p := range points {
+		item := []interface{}{p.member}
+		if a.withDist {
+			item = append(item, formatGeoDistance(p.dist, a.unit))
+		}
+		if a.withHash {
+			item = append(item, int64(p.score))
+		}
+		if a.withCoord {
+			item = append(item, []string{formatGeoCoord(p.lon), formatGeoCoord(p.lat)})
+		}
+		res = append(res, item)
+	}
+	return Encode(res, false)
+}
+
+// GEOSEARCHSTORE destination source FROMMEMBER member | FROMLONLAT longitude latitude
+// BYRADIUS radius unit | BYBOX width height unit [ASC | DESC] [COUNT count [ANY]] [STOREDIST]
+func cmdGEOSEARCHSTORE(args []string) []byte {
+	if len(args) < 6 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'GEOSEARCHSTORE' command"), false)
+	}
+	dest := args[0]
+	a, err := parseGeoSearchArgs("GEOSEARCHSTORE", args[2:], true)
+	if err != nil {
+		return Encode(err, false)
+	}
+	var points []geoPoint
+	if zset, exist := zsetStore[args[1]]; exist {
+		if points, err = geoSearch(zset, a); err != nil {
+			return Encode(err, false)
+		}
+	}
+
+	if len(points) == 0 {
+		delete(zsetStore, dest)
+		return constant.RespZero
+	}
+	zset, err := newSortedSet()
+	if err != nil {
+		return Encode(errors.New("(error) Can not initialize sorted set: "+err.Error()), false)
+	}
+	for _, p := range points {
+		if a.storeDist {
+			// Stored in the unit of the search, like Redis
+			zset.Add(p.dist/a.unit, p.member)
+		} else {
+			zset.Add(p.score, p.member)
+		}
+	}
+	zsetStore[dest] = zset
+	blocking.signalKeyAsReady(dest)
+	return Encode(zset.Len(), false)
+}
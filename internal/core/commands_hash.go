@@ -0,0 +1,335 @@
+package core
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spaghetti-lover/multithread-redis/internal/config"
+	"github.com/spaghetti-lover/multithread-redis/internal/constant"
+	"github.com/spaghetti-lover/multithread-redis/internal/data_structure/hash_map"
+)
+
+// lookupHash returns the hash stored at key after deleting its expired fields (passive expiry).
+// A hash whose fields have all expired is deleted.
+func lookupHash(key string) (*hash_map.Hash, bool) {
+	h, exist := hashStore[key]
+	if !exist {
+		return nil, false
+	}
+	if h.DeleteExpired(time.Now().UnixMilli()) > 0 {
+		if h.Len() == 0 {
+			deleteHash(key)
+			return nil, false
+		}
+		indexHash(key)
+	}
+	return h, true
+}
+
+func deleteHash(key string) {
+	delete(hashStore, key)
+	delete(hashFieldExpireKeys, key)
+	indexHash(key)
+}
+
+func getOrCreateHash(key string) *hash_map.Hash {
+	h, exist := lookupHash(key)
+	if !exist {
+		h = hash_map.NewHash(config.HashMaxListpackEntries, config.HashMaxListpackValue)
+		hashStore[key] = h
+	}
+	return h
+}
+
+func cmdHSET(args []string) []byte {
+	if len(args) < 3 || len(args)%2 == 0 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'HSET' command"), false)
+	}
+	h := getOrCreateHash(args[0])
+	count := 0
+	for i := 1; i < len(args); i += 2 {
+		count += h.Set(args[i], args[i+1])
+	}
+	indexHash(args[0])
+	return Encode(count, false)
+}
+
+func cmdHSETNX(args []string) []byte {
+	if len(args) != 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'HSETNX' command"), false)
+	}
+	if h, exist := lookupHash(args[0]); exist && h.Exists(args[1]) {
+		return constant.RespZero
+	}
+	getOrCreateHash(args[0]).Set(args[1], args[2])
+	indexHash(args[0])
+	return constant.RespOne
+}
+
+func cmdHGET(args []string) []byte {
+	if len(args) != 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'HGET' command"), false)
+	}
+	h, exist := lookupHash(args[0])
+	if !exist {
+		return constant.RespNil
+	}
+	v, exist := h.Get(args[1])
+	if !exist {
+		return constant.RespNil
+	}
+	return Encode(v, false)
+}
+
+func cmdHMGET(args []string) []byte {
+	if len(args) < 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'HMGET' command"), false)
+	}
+	h, _ := lookupHash(args[0])
+	res := make([]interface{}, 0, len(args)-1)
+	for _, field := range args[1:] {
+		if h == nil {
+			res = append(res, nil)
+			continue
+		}
+		if v, exist := h.Get(field); exist {
+			res = append(res, v)
+		} else {
+			res = append(res, nil)
+		}
+	}
+	return Encode(res, false)
+}
+
+func cmdHDEL(args []string) []byte {
+	if len(args) < 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'HDEL' command"), false)
+	}
+	key := args[0]
+	h, exist := lookupHash(key)
+	if !exist {
+		return constant.RespZero
+	}
+	count := 0
+	for _, field := range args[1:] {
+		if h.Del(field) {
+			count++
+		}
+	}
+	if h.Len() == 0 {
+		deleteHash(key)
+	} else if count > 0 {
+		indexHash(key)
+	}
+	return Encode(count, false)
+}
+
+func cmdHEXISTS(args []string) []byte {
+	if len(args) != 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'HEXISTS' command"), false)
+	}
+	h, exist := lookupHash(args[0])
+	if !exist || !h.Exists(args[1]) {
+		return constant.RespZero
+	}
+	return constant.RespOne
+}
+
+func cmdHLEN(args []string) []byte {
+	if len(args) != 1 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'HLEN' command"), false)
+	}
+	h, exist := lookupHash(args[0])
+	if !exist {
+		return constant.RespZero
+	}
+	return Encode(h.Len(), false)
+}
+
+func cmdHKEYS(args []string) []byte {
+	if len(args) != 1 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'HKEYS' command"), false)
+	}
+	h, exist := lookupHash(args[0])
+	if !exist {
+		return Encode(make([]string, 0), false)
+	}
+	return Encode(h.Keys(), false)
+}
+
+func cmdHVALS(args []string) []byte {
+	if len(args) != 1 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'HVALS' command"), false)
+	}
+	h, exist := lookupHash(args[0])
+	if !exist {
+		return Encode(make([]string, 0), false)
+	}
+	return Encode(h.Values(), false)
+}
+
+func cmdHGETALL(args []string) []byte {
+	if len(args) != 1 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'HGETALL' command"), false)
+	}
+	h, exist := lookupHash(args[0])
+	if !exist {
+		return Encode(make([]string, 0), false)
+	}
+	return Encode(h.GetAll(), false)
+}
+
+func cmdHINCRBY(args []string) []byte {
+	if len(args) != 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'HINCRBY' command"), false)
+	}
+	incr, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		return Encode(errors.New("(error) ERR value is not an integer or out of range"), false)
+	}
+	var current int64
+	if h, exist := lookupHash(args[0]); exist {
+		if v, exist := h.Get(args[1]); exist {
+			current, err = strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return Encode(errors.New("(error) ERR hash value is not an integer"), false)
+			}
+		}
+	}
+	if (incr < 0 && current < 0 && incr < math.MinInt64-current) ||
+		(incr > 0 && current > 0 && incr > math.MaxInt64-current) {
+		return Encode(errors.New("(error) ERR increment or decrement would overflow"), false)
+	}
+	current += incr
+	getOrCreateHash(args[0]).Set(args[1], strconv.FormatInt(current, 10))
+	indexHash(args[0])
+	return Encode(current, false)
+}
+
+func cmdHINCRBYFLOAT(args []string) []byte {
+	if len(args) != 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'HINCRBYFLOAT' command"), false)
+	}
+	incr, err := strconv.ParseFloat(args[2], 64)
+	if err != nil || math.IsNaN(incr) || math.IsInf(incr, 0) {
+		return Encode(errors.New("(error) ERR value is not a valid float"), false)
+	}
+	var current float64
+	if h, exist := lookupHash(args[0]); exist {
+		if v, exist := h.Get(args[1]); exist {
+			current, err = strconv.ParseFloat(v, 64)
+			if err != nil {
+				return Encode(errors.New("(error) ERR hash value is not a float"), false)
+			}
+		}
+	}
+	current += incr
+	if math.IsNaN(current) || math.IsInf(current, 0) {
+		return Encode(errors.New("(error) ERR increment would produce NaN or Infinity"), false)
+	}
+	value := strconv.FormatFloat(current, 'f', -1, 64)
+	getOrCreateHash(args[0]).Set(args[1], value)
+	indexHash(args[0])
+	return Encode(value, false)
+}
+
+func cmdHSTRLEN(args []string) []byte {
+	if len(args) != 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'HSTRLEN' command"), false)
+	}
+	h, exist := lookupHash(args[0])
+	if !exist {
+		return constant.RespZero
+	}
+	v, _ := h.Get(args[1])
+	return Encode(len(v), false)
+}
+
+func cmdHRANDFIELD(args []string) []byte {
+	if len(args) < 1 || len(args) > 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'HRANDFIELD' command"), false)
+	}
+	h, exist := lookupHash(args[0])
+
+	if len(args) == 1 {
+		if !exist {
+			return constant.RespNil
+		}
+		return Encode(h.RandomFields(1, false)[0], false)
+	}
+
+	count, err := parseRandomCount(args[1])
+	if err != nil {
+		return Encode(err, false)
+	}
+	withValues := false
+	if len(args) == 3 {
+		if strings.ToUpper(args[2]) != "WITHVALUES" {
+			return Encode(errors.New("(error) ERR syntax error"), false)
+		}
+		withValues = true
+	}
+	if !exist {
+		return Encode(make([]string, 0), false)
+	}
+	return Encode(h.RandomFields(count, withValues), false)
+}
+
+// HSCAN key cursor [MATCH pattern] [COUNT count] [NOVALUES]
+func cmdHSCAN(args []string) []byte {
+	if len(args) < 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'HSCAN' command"), false)
+	}
+	cursor, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil {
+		return Encode(errors.New("(error) ERR invalid cursor"), false)
+	}
+
+	pattern := ""
+	count := 10
+	noValues := false
+	for i := 2; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "MATCH":
+			if i+1 >= len(args) {
+				return Encode(errors.New("(error) ERR syntax error"), false)
+			}
+			pattern = args[i+1]
+			i++
+		case "COUNT":
+			if i+1 >= len(args) {
+				return Encode(errors.New("(error) ERR syntax error"), false)
+			}
+			count, err = strconv.Atoi(args[i+1])
+			if err != nil || count < 1 {
+				return Encode(errors.New("(error) ERR value is out of range, must be positive"), false)
+			}
+			i++
+		case "NOVALUES":
+			noValues = true
+		default:
+			return Encode(errors.New("(error) ERR syntax error"), false)
+		}
+	}
+
+	h, exist := lookupHash(args[0])
+	if !exist {
+		return Encode([]interface{}{"0", make([]string, 0)}, false)
+	}
+
+	next, entries := h.Scan(cursor, count)
+	res := make([]string, 0, len(entries))
+	for i := 0; i < len(entries); i += 2 {
+		if pattern != "" && !stringMatch(pattern, entries[i]) {
+			continue
+		}
+		res = append(res, entries[i])
+		if !noValues {
+			res = append(res, entries[i+1])
+		}
+	}
+	return Encode([]interface{}{strconv.FormatUint(next, 10), res}, false)
+}
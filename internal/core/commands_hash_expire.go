@@ -0,0 +1,239 @@
+package core
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spaghetti-lover/multithread-redis/internal/constant"
+)
+
+// Reply codes of the hash field expiration commands, per field
+const (
+	hfeNoField       = -2 // field (or key) does not exist
+	hfeNoTTL         = -1 // field exists but has no TTL (HTTL, HPERSIST)
+	hfeNotSet        = 0  // NX, XX, GT or LT condition not met
+	hfeSet           = 1  // TTL set, or removed by HPERSIST
+	hfeDeletedInPast = 2  // expiration time is in the past, the field was deleted
+)
+
+// parseHashFields parses "FIELDS numfields field [field ...]" at the end of args
+func parseHashFields(args []string) ([]string, error) {
+	if len(args) < 3 || strings.ToUpper(args[0]) != "FIELDS" {
+		return nil, errors.New("(error) ERR mandatory argument FIELDS is missing or not at the right position")
+	}
+	numFields, err := strconv.Atoi(args[1])
+	if err != nil || numFields <= 0 {
+		return nil, errors.New("(error) ERR Parameter `numFields` should be greater than 0")
+	}
+	if numFields != len(args)-2 {
+		return nil, errors.New("(error) ERR The `numfields` parameter must match the number of arguments")
+	}
+	return args[2:], nil
+}
+
+// hexpireGeneric implements HEXPIRE, HPEXPIRE, HEXPIREAT and HPEXPIREAT.
+// unitMs converts the time argument to milliseconds, absolute tells whether it is a unix time.
+// HEXPIRE key seconds [NX | XX | GT | LT] FIELDS numfields field [field ...]
+func hexpireGeneric(cmd string, args []string, unitMs int64, absolute bool) []byte {
+	if len(args) < 5 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for '"+cmd+"' command"), false)
+	}
+	key := args[0]
+	invalidTime := errors.New("(error) ERR invalid expire time in '" + strings.ToLower(cmd) + "' command")
+	t, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil || t < 0 || t > math.MaxInt64/unitMs {
+		return Encode(invalidTime, false)
+	}
+	now := time.Now().UnixMilli()
+	at := t * unitMs
+	if !absolute {
+		if at > math.MaxInt64-now {
+			return Encode(invalidTime, false)
+		}
+		at += now
+	}
+
+	flag := ""
+	fieldsPos := 2
+	switch strings.ToUpper(args[2]) {
+	case "NX", "XX", "GT", "LT":
+		flag = strings.ToUpper(args[2])
+		fieldsPos = 3
+	}
+	fields, err := parseHashFields(args[fieldsPos:])
+	if err != nil {
+		return Encode(err, false)
+	}
+
+	res := make([]interface{}, len(fields))
+	deleted := false
+	h, exist := lookupHash(key)
+	if !exist {
+		for i := range res {
+			res[i] = hfeNoField
+		}
+		return Encode(res, false)
+	}
+
+	for i, field := range fields {
+		if !h.Exists(field) {
+			res[i] = hfeNoField
+			continue
+		}
+		current, hasTTL := h.GetExpire(field)
+		switch {
+		case flag == "NX" && hasTTL,
+			flag == "XX" && !hasTTL,
+			flag == "GT" && (!hasTTL || at <= current),
+			flag == "LT" && hasTTL && at >= current:
+			res[i] = hfeNotSet
+			continue
+		}
+		if at <= now {
+			h.Del(field)
+			deleted = true
+			res[i] = hfeDeletedInPast
+			continue
+		}
+		h.SetExpire(field, at)
+		res[i] = hfeSet
+	}
+
+	if h.Len() == 0 {
+		deleteHash(key)
+		return Encode(res, false)
+	}
+	if h.HasExpires() {
+		hashFieldExpireKeys[key] = struct{}{}
+	}
+	if deleted {
+		indexHash(key)
+	}
+	return Encode(res, false)
+}
+
+// httlGeneric implements HTTL, HPTTL, HEXPIRETIME and HPEXPIRETIME.
+// unitMs converts milliseconds to the reply unit, absolute returns the unix time instead of the TTL.
+func httlGeneric(cmd string, args []string, unitMs int64, absolute bool) []byte {
+	if len(args) < 4 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for '"+cmd+"' command"), false)
+	}
+	fields, err := parseHashFields(args[1:])
+	if err != nil {
+		return Encode(err, false)
+	}
+
+	now := time.Now().UnixMilli()
+	h, exist := lookupHash(args[0])
+	res := make([]interface{}, len(fields))
+	for i, field := range fields {
+		if !exist || !h.Exists(field) {
+			res[i] = hfeNoField
+			continue
+		}
+		at, hasTTL := h.GetExpire(field)
+		if !hasTTL {
+			res[i] = hfeNoTTL
+			continue
+		}
+		if absolute {
+			res[i] = at / unitMs
+		} else {
+			res[i] = (at - now + unitMs/2) / unitMs
+		}
+	}
+	return Encode(res, false)
+}
+
+func cmdHEXPIRE(args []string) []byte {
+	return hexpireGeneric("HEXPIRE", args, 1000, false)
+}
+
+func cmdHPEXPIRE(args []string) []byte {
+	return hexpireGeneric("HPEXPIRE", args, 1, false)
+}
+
+func cmdHEXPIREAT(args []string) []byte {
+	return hexpireGeneric("HEXPIREAT", args, 1000, true)
+}
+
+func cmdHPEXPIREAT(args []string) []byte {
+	return hexpireGeneric("HPEXPIREAT", args, 1, true)
+}
+
+func cmdHTTL(args []string) []byte {
+	return httlGeneric("HTTL", args, 1000, false)
+}
+
+func cmdHPTTL(args []string) []byte {
+	return httlGeneric("HPTTL", args, 1, false)
+}
+
+func cmdHEXPIRETIME(args []string) []byte {
+	return httlGeneric("HEXPIRETIME", args, 1000, true)
+}
+
+func cmdHPEXPIRETIME(args []string) []byte {
+	return httlGeneric("HPEXPIRETIME", args, 1, true)
+}
+
+// HPERSIST key FIELDS numfields field [field ...]
+func cmdHPERSIST(args []string) []byte {
+	if len(args) < 4 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'HPERSIST' command"), false)
+	}
+	fields, err := parseHashFields(args[1:])
+	if err != nil {
+		return Encode(err, false)
+	}
+
+	h, exist := lookupHash(args[0])
+	res := make([]interface{}, len(fields))
+	for i, field := range fields {
+		if !exist || !h.Exists(field) {
+			res[i] = hfeNoField
+			continue
+		}
+		if h.Persist(field) {
+			res[i] = hfeSet
+		} else {
+			res[i] = hfeNoTTL
+		}
+	}
+	if exist && !h.HasExpires() {
+		delete(hashFieldExpireKeys, args[0])
+	}
+	return Encode(res, false)
+}
+
+// activeDeleteExpiredHashFields samples hashes having fields with a TTL and deletes their
+// expired fields, the same way ActiveDeleteExpiredKeys does for keys.
+func activeDeleteExpiredHashFields() {
+	now := time.Now().UnixMilli()
+	sampleCountRemain := constant.ActiveExpireSampleSize
+	for key := range hashFieldExpireKeys {
+		sampleCountRemain--
+		if sampleCountRemain < 0 {
+			break
+		}
+		h, exist := hashStore[key]
+		if !exist {
+			delete(hashFieldExpireKeys, key)
+			continue
+		}
+		expired := h.DeleteExpired(now)
+		if h.Len() == 0 {
+			deleteHash(key)
+			continue
+		}
+		if !h.HasExpires() {
+			delete(hashFieldExpireKeys, key)
+		}
+		if expired > 0 {
+			indexHash(key)
+		}
+	}
+}
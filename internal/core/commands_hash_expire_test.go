@@ -0,0 +1,29 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHEXPIREInvalidTime(t *testing.T) {
+	cmdHSET([]string{"hfe:overflow", "f", "v"})
+	tests := []struct {
+		cmd  string
+		exec func([]string) []byte
+		t    string
+	}{
+		{"hexpire", cmdHEXPIRE, "-1"},
+		{"hexpire", cmdHEXPIRE, "9223372036854775"},
+		{"hpexpire", cmdHPEXPIRE, "9223372036854775807"},
+		{"hexpireat", cmdHEXPIREAT, "9223372036854776"},
+	}
+	for _, tt := range tests {
+		res := decodeReply(t, tt.exec([]string{"hfe:overflow", tt.t, "FIELDS", "1", "f"}))
+		assert.Equal(t, "(error) ERR invalid expire time in '"+tt.cmd+"' command", res, tt.t)
+	}
+	assert.Equal(t, []interface{}{int64(-1)}, decodeReply(t, cmdHTTL([]string{"hfe:overflow", "FIELDS", "1", "f"})))
+
+	// The largest time that does not overflow is accepted
+	assert.Equal(t, []interface{}{int64(1)}, decodeReply(t, cmdHPEXPIREAT([]string{"hfe:overflow", "9223372036854775807", "FIELDS", "1", "f"})))
+}
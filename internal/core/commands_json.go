@@ -0,0 +1,463 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/spaghetti-lover/multithread-redis/internal/constant"
+	"github.com/spaghetti-lover/multithread-redis/internal/data_structure/json_doc"
+)
+
+// jsonTypeError is returned when a value selected by a path does not have the expected type.
+// It is an error reply for a legacy path and a nil result for a JSONPath.
+type jsonTypeError struct {
+	expected string
+	found    any
+}
+
+func (e *jsonTypeError) Error() string {
+	return fmt.Sprintf("(error) ERR wrong type of path value - expected %s but found %s", e.expected, json_doc.TypeOf(e.found))
+}
+
+func parseJSONPath(s string) (*json_doc.Path, error) {
+	path, err := json_doc.ParsePath(s)
+	if err != nil {
+		return nil, fmt.Errorf("(error) ERR invalid JSONPath '%s'", s)
+	}
+	return path, nil
+}
+
+func parseJSONValue(s string) (any, error) {
+	v, err := json_doc.Parse(s)
+	if err != nil {
+		return nil, errors.New("(error) ERR invalid JSON")
+	}
+	return v, nil
+}
+
+// jsonApply calls fn on the values selected by path. For a legacy path it returns the
+// result for the only value, and an error if path selects nothing. For a JSONPath it
+// returns the results for all the values, nil for the values of the wrong type.
+func jsonApply(doc *json_doc.Document, path *json_doc.Path, fn func(m *json_doc.Match) (interface{}, error)) (interface{}, error) {
+	matches := doc.Select(path)
+	if path.Legacy() {
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("(error) ERR Path '%s' does not exist", path)
+		}
+		return fn(&matches[0])
+	}
+	res := make([]interface{}, 0, len(matches))
+	for i := range matches {
+		r, err := fn(&matches[i])
+		if err != nil {
+			var typeErr *jsonTypeError
+			if !errors.As(err, &typeErr) {
+				return nil, err
+			}
+			r = nil
+		}
+		res = append(res, r)
+	}
+	return res, nil
+}
+
+// jsonCommand looks up the document and the path of the JSON.* commands taking key [path],
+// the path defaulting to the root. Replies nil if the key does not exist.
+func jsonCommand(cmd string, args []string, fn func(m *json_doc.Match) (interface{}, error)) []byte {
+	if len(args) < 1 || len(args) > 2 {
+		return Encode(fmt.Errorf("(error) ERR wrong number of arguments for '%s' command", cmd), false)
+	}
+	pathText := "."
+	if len(args) == 2 {
+		pathText = args[1]
+	}
+	path, err := parseJSONPath(pathText)
+	if err != nil {
+		return Encode(err, false)
+	}
+	doc, exist := jsonStore[args[0]]
+	if !exist {
+		return constant.RespNil
+	}
+	res, err := jsonApply(doc, path, fn)
+	if err != nil {
+		return Encode(err, false)
+	}
+	return Encode(res, false)
+}
+
+// cmdJSONSET implements JSON.SET key path value [NX | XX]
+func cmdJSONSET(args []string) []byte {
+	if len(args) != 3 && len(args) != 4 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'JSON.SET' command"), false)
+	}
+	nx, xx := false, false
+	if len(args) == 4 {
+		switch strings.ToUpper(args[3]) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		default:
+			return Encode(errors.New("(error) ERR syntax error"), false)
+		}
+	}
+	path, err := parseJSONPath(args[1])
+	if err != nil {
+		return Encode(err, false)
+	}
+	v, err := parseJSONValue(args[2])
+	if err != nil {
+		return Encode(err, false)
+	}
+
+	key := args[0]
+	doc, exist := jsonStore[key]
+	if !exist {
+		if !path.IsRoot() {
+			return Encode(errors.New("(error) ERR new objects must be created at the root"), false)
+		}
+		if xx {
+			return constant.RespNil
+		}
+		jsonStore[key] = json_doc.NewDocument(v)
+		return constant.RespOk
+	}
+	if !doc.Set(path, v, nx, xx) {
+		return constant.RespNil
+	}
+	return constant.RespOk
+}
+
+// jsonGet returns the JSON text of the values selected by path: the only value for a legacy
+// path, an array of the values for a JSONPath
+func jsonGet(doc *json_doc.Document, path *json_doc.Path, format json_doc.Format) (string, bool) {
+	matches := doc.Select(path)
+	if path.Legacy() {
+		if len(matches) == 0 {
+			return "", false
+		}
+		return format.Marshal(matches[0].Value), true
+	}
+	arr := &json_doc.Array{Items: make([]any, 0, len(matches))}
+	for _, m := range matches {
+		arr.Items = append(arr.Items, m.Value)
+	}
+	return format.Marshal(arr), true
+}
+
+// cmdJSONGET implements JSON.GET key [INDENT indent] [NEWLINE newline] [SPACE space] [path ...].
+// With several paths, replies with an object mapping each path to its values.
+func cmdJSONGET(args []string) []byte {
+	if len(args) < 1 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'JSON.GET' command"), false)
+	}
+	var format json_doc.Format
+	var paths []*json_doc.Path
+	for i := 1; i < len(args); i++ {
+		opt := strings.ToUpper(args[i])
+		if (opt == "INDENT" || opt == "NEWLINE" || opt == "SPACE") && i+1 < len(args) {
+			switch opt {
+			case "INDENT":
+				format.Indent = args[i+1]
+			case "NEWLINE":
+				format.Newline = args[i+1]
+			default:
+				format.Space = args[i+1]
+			}
+			i++
+			continue
+		}
+		path, err := parseJSONPath(args[i])
+		if err != nil {
+			return Encode(err, false)
+		}
+		paths = append(paths, path)
+	}
+	if len(paths) == 0 {
+		root, _ := json_doc.ParsePath(".")
+		paths = append(paths, root)
+	}
+
+	doc, exist := jsonStore[args[0]]
+	if !exist {
+		return constant.RespNil
+	}
+	if len(paths) == 1 {
+		res, ok := jsonGet(doc, paths[0], format)
+		if !ok {
+			return Encode(fmt.Errorf("(error) ERR Path '%s' does not exist", paths[0]), false)
+		}
+		return Encode(res, false)
+	}
+	obj := json_doc.NewObject()
+	for _, path := range paths {
+		matches := doc.Select(path)
+		if path.Legacy() {
+			if len(matches) == 0 {
+				return Encode(fmt.Errorf("(error) ERR Path '%s' does not exist", path), false)
+			}
+			obj.Set(path.String(), matches[0].Value)
+			continue
+		}
+		arr := &json_doc.Array{Items: make([]any, 0, len(matches))}
+		for _, m := range matches {
+			arr.Items = append(arr.Items, m.Value)
+		}
+		obj.Set(path.String(), arr)
+	}
+	return Encode(format.Marshal(obj), false)
+}
+
+// cmdJSONMGET implements JSON.MGET key [key ...] path
+func cmdJSONMGET(args []string) []byte {
+	if len(args) < 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'JSON.MGET' command"), false)
+	}
+	path, err := parseJSONPath(args[len(args)-1])
+	if err != nil {
+		return Encode(err, false)
+	}
+	res := make([]interface{}, 0, len(args)-1)
+	for _, key := range args[:len(args)-1] {
+		doc, exist := jsonStore[key]
+		if !exist {
+			res = append(res, nil)
+			continue
+		}
+		if s, ok := jsonGet(doc, path, json_doc.Format{}); ok {
+			res = append(res, s)
+		} else {
+			res = append(res, nil)
+		}
+	}
+	return Encode(res, false)
+}
+
+// cmdJSONDEL implements JSON.DEL key [path] and JSON.FORGET. Deleting the root deletes the key.
+func cmdJSONDEL(cmd string, args []string) []byte {
+	if len(args) < 1 || len(args) > 2 {
+		return Encode(fmt.Errorf("(error) ERR wrong number of arguments for '%s' command", cmd), false)
+	}
+	pathText := "."
+	if len(args) == 2 {
+		pathText = args[1]
+	}
+	path, err := parseJSONPath(pathText)
+	if err != nil {
+		return Encode(err, false)
+	}
+	key := args[0]
+	doc, exist := jsonStore[key]
+	if !exist {
+		return constant.RespZero
+	}
+	if path.IsRoot() {
+		delete(jsonStore, key)
+		return constant.RespOne
+	}
+	return Encode(doc.Delete(path), false)
+}
+
+// cmdJSONTYPE implements JSON.TYPE key [path]
+func cmdJSONTYPE(args []string) []byte {
+	if len(args) == 2 {
+		if path, err := parseJSONPath(args[1]); err == nil && path.Legacy() {
+			// A missing legacy path has no type rather than being an error
+			if doc, exist := jsonStore[args[0]]; exist && len(doc.Select(path)) == 0 {
+				return constant.RespNil
+			}
+		}
+	}
+	return jsonCommand("JSON.TYPE", args, func(m *json_doc.Match) (interface{}, error) {
+		return json_doc.TypeOf(m.Value), nil
+	})
+}
+
+// cmdJSONNUMINCRBY implements JSON.NUMINCRBY key path value. Replies with the JSON text of
+// the new values. The sum of two integers stays an integer unless it overflows.
+func cmdJSONNUMINCRBY(args []string) []byte {
+	if len(args) != 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'JSON.NUMINCRBY' command"), false)
+	}
+	path, err := parseJSONPath(args[1])
+	if err != nil {
+		return Encode(err, false)
+	}
+	incr, err := parseJSONValue(args[2])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if t := json_doc.TypeOf(incr); t != "integer" && t != "number" {
+		return Encode(errors.New("(error) ERR expected value to be a number"), false)
+	}
+	doc, exist := jsonStore[args[0]]
+	if !exist {
+		return Encode(errors.New("(error) ERR could not perform this operation on a key that doesn't exist"), false)
+	}
+
+	res, err := jsonApply(doc, path, func(m *json_doc.Match) (interface{}, error) {
+		sum, err := jsonAdd(m.Value, incr)
+		if err != nil {
+			return nil, err
+		}
+		m.Replace(sum)
+		return sum, nil
+	})
+	if err != nil {
+		return Encode(err, false)
+	}
+	if values, ok := res.([]interface{}); ok {
+		return Encode(json_doc.Marshal(&json_doc.Array{Items: values}), false)
+	}
+	return Encode(json_doc.Marshal(res), false)
+}
+
+// jsonAdd returns the sum of two numbers
+func jsonAdd(a, b any) (any, error) {
+	x, xIsInt := a.(int64)
+	y, yIsInt := b.(int64)
+	if xIsInt && yIsInt {
+		if sum := x + y; (sum > x) == (y > 0) {
+			return sum, nil
+		}
+	}
+	toFloat := func(v any) (float64, bool) {
+		switch n := v.(type) {
+		case int64:
+			return float64(n), true
+		case float64:
+			return n, true
+		}
+		return 0, false
+	}
+	f, ok := toFloat(a)
+	if !ok {
+		return nil, &jsonTypeError{expected: "number", found: a}
+	}
+	g, _ := toFloat(b)
+	if sum := f + g; !math.IsInf(sum, 0) && !math.IsNaN(sum) {
+		return sum, nil
+	}
+	return nil, errors.New("(error) ERR result is not a number or is out of range")
+}
+
+// cmdJSONSTRAPPEND implements JSON.STRAPPEND key [path] value, value being a JSON string
+func cmdJSONSTRAPPEND(args []string) []byte {
+	if len(args) != 2 && len(args) != 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'JSON.STRAPPEND' command"), false)
+	}
+	v, err := parseJSONValue(args[len(args)-1])
+	if err != nil {
+		return Encode(err, false)
+	}
+	suffix, ok := v.(string)
+	if !ok {
+		return Encode(errors.New("(error) ERR expected value to be a JSON string"), false)
+	}
+	return jsonCommand("JSON.STRAPPEND", args[:len(args)-1], func(m *json_doc.Match) (interface{}, error) {
+		s, ok := m.Value.(string)
+		if !ok {
+			return nil, &jsonTypeError{expected: "string", found: m.Value}
+		}
+		m.Replace(s + suffix)
+		return int64(len(s) + len(suffix)), nil
+	})
+}
+
+// parseJSONValues parses the values of JSON.ARRAPPEND and JSON.ARRINSERT
+func parseJSONValues(args []string) ([]any, error) {
+	values := make([]any, len(args))
+	for i, arg := range args {
+		v, err := parseJSONValue(arg)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+// cloneJSONValues returns copies of values, so that several arrays do not share them
+func cloneJSONValues(values []any) []any {
+	res := make([]any, len(values))
+	for i, v := range values {
+		res[i] = json_doc.Clone(v)
+	}
+	return res
+}
+
+// cmdJSONARRAPPEND implements JSON.ARRAPPEND key path value [value ...]
+func cmdJSONARRAPPEND(args []string) []byte {
+	if len(args) < 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'JSON.ARRAPPEND' command"), false)
+	}
+	values, err := parseJSONValues(args[2:])
+	if err != nil {
+		return Encode(err, false)
+	}
+	return jsonCommand("JSON.ARRAPPEND", args[:2], func(m *json_doc.Match) (interface{}, error) {
+		arr, ok := m.Value.(*json_doc.Array)
+		if !ok {
+			return nil, &jsonTypeError{expected: "array", found: m.Value}
+		}
+		arr.Items = append(arr.Items, cloneJSONValues(values)...)
+		return int64(len(arr.Items)), nil
+	})
+}
+
+// cmdJSONARRINSERT implements JSON.ARRINSERT key path index value [value ...]. The values
+// are inserted before index, a negative index counting from the end.
+func cmdJSONARRINSERT(args []string) []byte {
+	if len(args) < 4 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'JSON.ARRINSERT' command"), false)
+	}
+	index, err := strconv.Atoi(args[2])
+	if err != nil {
+		return Encode(errors.New("(error) ERR value is not an integer or out of range"), false)
+	}
+	values, err := parseJSONValues(args[3:])
+	if err != nil {
+		return Encode(err, false)
+	}
+	return jsonCommand("JSON.ARRINSERT", args[:2], func(m *json_doc.Match) (interface{}, error) {
+		arr, ok := m.Value.(*json_doc.Array)
+		if !ok {
+			return nil, &jsonTypeError{expected: "array", found: m.Value}
+		}
+		i := index
+		if i < 0 {
+			i += len(arr.Items)
+		}
+		if i < 0 || i > len(arr.Items) {
+			return nil, errors.New("(error) ERR index out of bounds")
+		}
+		arr.Insert(i, cloneJSONValues(values)...)
+		return int64(len(arr.Items)), nil
+	})
+}
+
+// cmdJSONARRLEN implements JSON.ARRLEN key [path]
+func cmdJSONARRLEN(args []string) []byte {
+	return jsonCommand("JSON.ARRLEN", args, func(m *json_doc.Match) (interface{}, error) {
+		arr, ok := m.Value.(*json_doc.Array)
+		if !ok {
+			return nil, &jsonTypeError{expected: "array", found: m.Value}
+		}
+		return int64(len(arr.Items)), nil
+	})
+}
+
+// cmdJSONOBJKEYS implements JSON.OBJKEYS key [path]
+func cmdJSONOBJKEYS(args []string) []byte {
+	return jsonCommand("JSON.OBJKEYS", args, func(m *json_doc.Match) (interface{}, error) {
+		obj, ok := m.Value.(*json_doc.Object)
+		if !ok {
+			return nil, &jsonTypeError{expected: "object", found: m.Value}
+		}
+		return obj.Keys(), nil
+	})
+}
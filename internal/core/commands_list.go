@@ -0,0 +1,246 @@
+package core
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spaghetti-lover/multithread-redis/internal/constant"
+	"github.com/spaghetti-lover/multithread-redis/internal/data_structure/quick_list"
+)
+
+// listPush pushes values to the head (left) or the tail of the list stored at key,
+// creating it if needed, and returns the new length
+func listPush(lists map[string]*quick_list.QuickList, b *blockingManager, key string, left bool, values ...string) int {
+	ql, exist := lists[key]
+	if !exist {
+		ql = quick_list.NewQuickList()
+		lists[key] = ql
+	}
+	var length int
+	if left {
+		length = ql.LPush(values...)
+	} else {
+		length = ql.RPush(values...)
+	}
+	b.signalKeyAsReady(key)
+	return length
+}
+
+// listPop pops one element from the list stored at key, which must not be empty.
+// The key is deleted when the list becomes empty.
+func listPop(lists map[string]*quick_list.QuickList, key string, left bool) string {
+	ql := lists[key]
+	var v string
+	if left {
+		v, _ = ql.LPop()
+	} else {
+		v, _ = ql.RPop()
+	}
+	if ql.Len() == 0 {
+		delete(lists, key)
+	}
+	return v
+}
+
+// listMove atomically pops an element from src and pushes it to dst. src must not be empty.
+func listMove(b *blockingManager, src, dst, whereFrom, whereTo string) string {
+	v := listPop(b.lists, src, whereFrom == "LEFT")
+	listPush(b.lists, b, dst, whereTo == "LEFT", v)
+	return v
+}
+
+func parseListDirection(arg string) (string, error) {
+	where := strings.ToUpper(arg)
+	if where != "LEFT" && where != "RIGHT" {
+		return "", errors.New("(error) ERR syntax error")
+	}
+	return where, nil
+}
+
+func pushGenericCommand(lists map[string]*quick_list.QuickList, b *blockingManager, cmd string, args []string) []byte {
+	if len(args) < 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for '"+cmd+"' command"), false)
+	}
+	return Encode(listPush(lists, b, args[0], cmd == "LPUSH", args[1:]...), false)
+}
+
+func popGenericCommand(lists map[string]*quick_list.QuickList, cmd string, args []string) []byte {
+	if len(args) < 1 || len(args) > 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for '"+cmd+"' command"), false)
+	}
+	key := args[0]
+	left := cmd == "LPOP"
+
+	if len(args) == 1 {
+		if _, exist := lists[key]; !exist {
+			return constant.RespNil
+		}
+		return Encode(listPop(lists, key, left), false)
+	}
+
+	count, err := strconv.Atoi(args[1])
+	if err != nil || count < 0 {
+		return Encode(errors.New("(error) ERR value is out of range, must be positive"), false)
+	}
+	ql, exist := lists[key]
+	if !exist {
+		return constant.RespNilArray
+	}
+	if count > ql.Len() {
+		count = ql.Len()
+	}
+	res := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		res = append(res, listPop(lists, key, left))
+	}
+	return Encode(res, false)
+}
+
+func llenCommand(lists map[string]*quick_list.QuickList, args []string) []byte {
+	if len(args) != 1 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'LLEN' command"), false)
+	}
+	ql, exist := lists[args[0]]
+	if !exist {
+		return constant.RespZero
+	}
+	return Encode(ql.Len(), false)
+}
+
+func lrangeCommand(lists map[string]*quick_list.QuickList, args []string) []byte {
+	if len(args) != 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'LRANGE' command"), false)
+	}
+	start, err := strconv.Atoi(args[1])
+	if err != nil {
+		return Encode(errors.New("(error) ERR value is not an integer or out of range"), false)
+	}
+	stop, err := strconv.Atoi(args[2])
+	if err != nil {
+		return Encode(errors.New("(error) ERR value is not an integer or out of range"), false)
+	}
+	ql, exist := lists[args[0]]
+	if !exist {
+		return Encode(make([]string, 0), false)
+	}
+	return Encode(ql.Range(start, stop), false)
+}
+
+func lmoveCommand(b *blockingManager, args []string) []byte {
+	if len(args) != 4 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'LMOVE' command"), false)
+	}
+	whereFrom, err := parseListDirection(args[2])
+	if err != nil {
+		return Encode(err, false)
+	}
+	whereTo, err := parseListDirection(args[3])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if _, exist := b.lists[args[0]]; !exist {
+		return constant.RespNil
+	}
+	return Encode(listMove(b, args[0], args[1], whereFrom, whereTo), false)
+}
+
+// blockingPopGenericCommand implements BLPOP, BRPOP, BLMOVE, BZPOPMIN and BZPOPMAX.
+// If one of the keys has data, the reply is returned immediately like the non-blocking variant.
+// Otherwise the client is parked in b and nil is returned: the reply is sent later through
+// reply, either when another client pushes to one of the keys or when the timeout expires.
+func blockingPopGenericCommand(b *blockingManager, cmd string, args []string, fd int, closed <-chan struct{}, reply func([]byte)) []byte {
+	c := &blockedClient{
+		fd:     fd,
+		cmd:    cmd,
+		reply:  reply,
+		closed: closed,
+	}
+
+	var timeoutArg string
+	if cmd == "BLMOVE" {
+		if len(args) != 5 {
+			return Encode(errors.New("(error) ERR wrong number of arguments for 'BLMOVE' command"), false)
+		}
+		var err error
+		if c.whereFrom, err = parseListDirection(args[2]); err != nil {
+			return Encode(err, false)
+		}
+		if c.whereTo, err = parseListDirection(args[3]); err != nil {
+			return Encode(err, false)
+		}
+		c.keys = []string{args[0]}
+		c.dest = args[1]
+		timeoutArg = args[4]
+	} else {
+		if len(args) < 2 {
+			return Encode(errors.New("(error) ERR wrong number of arguments for '"+cmd+"' command"), false)
+		}
+		c.keys = args[:len(args)-1]
+		timeoutArg = args[len(args)-1]
+	}
+
+	deadline, err := parseBlockingTimeout(timeoutArg)
+	if err != nil {
+		return Encode(err, false)
+	}
+	c.deadline = deadline
+
+	// Serve immediately if any key already has data
+	for _, key := range c.keys {
+		if res := b.serveClient(c, key); res != nil {
+			return res
+		}
+	}
+
+	b.block(c)
+	return nil
+}
+
+func cmdLPUSH(args []string) []byte {
+	return pushGenericCommand(listStore, blocking, "LPUSH", args)
+}
+
+func cmdRPUSH(args []string) []byte {
+	return pushGenericCommand(listStore, blocking, "RPUSH", args)
+}
+
+func cmdLPOP(args []string) []byte {
+	return popGenericCommand(listStore, "LPOP", args)
+}
+
+func cmdRPOP(args []string) []byte {
+	return popGenericCommand(listStore, "RPOP", args)
+}
+
+func cmdLLEN(args []string) []byte {
+	return llenCommand(listStore, args)
+}
+
+func cmdLRANGE(args []string) []byte {
+	return lrangeCommand(listStore, args)
+}
+
+func cmdLMOVE(args []string) []byte {
+	return lmoveCommand(blocking, args)
+}
+
+// cmdBlockingPop runs a blocking command for the client on connFd.
+// It returns nil when the client is blocked, the reply will be written to connFd later.
+func cmdBlockingPop(cmd string, args []string, connFd int) []byte {
+	return blockingPopGenericCommand(blocking, cmd, args, connFd, nil, func(res []byte) {
+		syscall.Write(connFd, res)
+	})
+}
+
+// UnblockClient drops the blocked state of a client whose connection was closed
+func UnblockClient(connFd int) {
+	blocking.unblockFd(connFd)
+}
+
+// ExpireBlockedClients replies to the blocked clients whose timeout has passed
+func ExpireBlockedClients() {
+	blocking.expireTimeouts(time.Now())
+}
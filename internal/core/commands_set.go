@@ -2,10 +2,26 @@ package core
 
 import (
 	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/spaghetti-lover/multithread-redis/internal/config"
+	"github.com/spaghetti-lover/multithread-redis/internal/constant"
 
 	data_structure "github.com/spaghetti-lover/multithread-redis/internal/data_structure/simple_set"
 )
 
+func newSimpleSet(key string) *data_structure.SimpleSet {
+	return data_structure.NewSimpleSet(key, config.SetMaxIntsetEntries, config.SetMaxListpackEntries, config.SetMaxListpackValue)
+}
+
+// deleteSetIfEmpty removes a set from the keyspace once its last member is gone
+func deleteSetIfEmpty(key string) {
+	if set, exist := setStore[key]; exist && set.Card() == 0 {
+		delete(setStore, key)
+	}
+}
+
 func cmdSADD(args []string) []byte {
 	if len(args) < 2 {
 		return Encode(errors.New("(error) ERR wrong number of arguments for 'SADD' command"), false)
@@ -13,7 +29,7 @@ func cmdSADD(args []string) []byte {
 	key := args[0] // TODO: check key is used by other types or not
 	set, exist := setStore[key]
 	if !exist {
-		set = data_structure.NewSimpleSet(key)
+		set = newSimpleSet(key)
 		setStore[key] = set
 	}
 	count := set.Add(args[1:]...)
@@ -22,15 +38,15 @@ func cmdSADD(args []string) []byte {
 
 func cmdSREM(args []string) []byte {
 	if len(args) < 2 {
-		return Encode(errors.New("(error) ERR wrong number of arguments for 'SADD' command"), false)
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'SREM' command"), false)
 	}
 	key := args[0]
 	set, exist := setStore[key]
 	if !exist {
-		set = data_structure.NewSimpleSet(key)
-		setStore[key] = set
+		return constant.RespZero
 	}
 	count := set.Rem(args[1:]...)
+	deleteSetIfEmpty(key)
 	return Encode(count, false)
 }
 
@@ -57,3 +73,219 @@ func cmdSISMEMBER(args []string) []byte {
 	}
 	return Encode(set.IsMember(args[1]), false)
 }
+
+func cmdSMISMEMBER(args []string) []byte {
+	if len(args) < 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'SMISMEMBER' command"), false)
+	}
+	set, exist := setStore[args[0]]
+	res := make([]interface{}, len(args)-1)
+	for i, member := range args[1:] {
+		res[i] = 0
+		if exist {
+			res[i] = set.IsMember(member)
+		}
+	}
+	return Encode(res, false)
+}
+
+func cmdSCARD(args []string) []byte {
+	if len(args) != 1 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'SCARD' command"), false)
+	}
+	set, exist := setStore[args[0]]
+	if !exist {
+		return constant.RespZero
+	}
+	return Encode(set.Card(), false)
+}
+
+// cmdSPOP implements SPOP key [count]
+func cmdSPOP(args []string) []byte {
+	if len(args) < 1 || len(args) > 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'SPOP' command"), false)
+	}
+	key := args[0]
+	set, exist := setStore[key]
+	if len(args) == 1 {
+		if !exist {
+			return constant.RespNil
+		}
+		member := set.Pop(1)[0]
+		deleteSetIfEmpty(key)
+		return Encode(member, false)
+	}
+
+	count, err := strconv.Atoi(args[1])
+	if err != nil || count < 0 {
+		return Encode(errors.New("(error) ERR value is out of range, must be positive"), false)
+	}
+	if !exist {
+		return Encode(make([]string, 0), false)
+	}
+	members := set.Pop(count)
+	deleteSetIfEmpty(key)
+	return Encode(members, false)
+}
+
+// cmdSRANDMEMBER implements SRANDMEMBER key [count]. A negative count allows the same
+// member to be returned several times.
+func cmdSRANDMEMBER(args []string) []byte {
+	if len(args) < 1 || len(args) > 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'SRANDMEMBER' command"), false)
+	}
+	set, exist := setStore[args[0]]
+	if len(args) == 1 {
+		if !exist {
+			return constant.RespNil
+		}
+		return Encode(set.RandomMembers(1)[0], false)
+	}
+
+	count, err := parseRandomCount(args[1])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if !exist || count == 0 {
+		return Encode(make([]string, 0), false)
+	}
+	return Encode(set.RandomMembers(count), false)
+}
+
+// cmdSMOVE implements SMOVE source destination member
+func cmdSMOVE(args []string) []byte {
+	if len(args) != 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'SMOVE' command"), false)
+	}
+	src, dst, member := args[0], args[1], args[2]
+	set, exist := setStore[src]
+	if !exist || set.IsMember(member) == 0 {
+		return constant.RespZero
+	}
+	if src == dst {
+		return constant.RespOne
+	}
+	set.Rem(member)
+	deleteSetIfEmpty(src)
+	dstSet, exist := setStore[dst]
+	if !exist {
+		dstSet = newSimpleSet(dst)
+		setStore[dst] = dstSet
+	}
+	dstSet.Add(member)
+	return constant.RespOne
+}
+
+// setOp computes the union, intersection or difference of the sets stored at keys.
+// Missing keys are empty sets.
+func setOp(op string, keys []string) []string {
+	sets := make([]*data_structure.SimpleSet, len(keys))
+	for i, key := range keys {
+		sets[i] = setStore[key]
+	}
+	isMember := func(set *data_structure.SimpleSet, member string) bool {
+		return set != nil && set.IsMember(member) == 1
+	}
+
+	res := make([]string, 0)
+	switch op {
+	case "UNION":
+		seen := make(map[string]struct{})
+		for _, set := range sets {
+			if set == nil {
+				continue
+			}
+			for _, member := range set.Members() {
+				if _, exist := seen[member]; !exist {
+					seen[member] = struct{}{}
+					res = append(res, member)
+				}
+			}
+		}
+	case "INTER":
+		// Iterate over the smallest set
+		smallest := sets[0]
+		for _, set := range sets {
+			if set == nil {
+				return res
+			}
+			if set.Card() < smallest.Card() {
+				smallest = set
+			}
+		}
+	inter:
+		for _, member := range smallest.Members() {
+			for _, set := range sets {
+				if !isMember(set, member) {
+					continue inter
+				}
+			}
+			res = append(res, member)
+		}
+	case "DIFF":
+		if sets[0] == nil {
+			return res
+		}
+	diff:
+		for _, member := range sets[0].Members() {
+			for _, set := range sets[1:] {
+				if isMember(set, member) {
+					continue diff
+				}
+			}
+			res = append(res, member)
+		}
+	}
+	return res
+}
+
+// cmdSetOp implements SUNION, SINTER, SDIFF and their STORE variants
+func cmdSetOp(cmd string, args []string) []byte {
+	store := strings.HasSuffix(cmd, "STORE")
+	op := strings.TrimSuffix(cmd[1:], "STORE")
+	if len(args) < 1 || (store && len(args) < 2) {
+		return Encode(errors.New("(error) ERR wrong number of arguments for '"+cmd+"' command"), false)
+	}
+	if !store {
+		return Encode(setOp(op, args), false)
+	}
+
+	dest := args[0]
+	members := setOp(op, args[1:])
+	delete(setStore, dest)
+	if len(members) == 0 {
+		return constant.RespZero
+	}
+	set := newSimpleSet(dest)
+	set.Add(members...)
+	setStore[dest] = set
+	return Encode(set.Card(), false)
+}
+
+// cmdSINTERCARD implements SINTERCARD numkeys key [key ...] [LIMIT limit]
+func cmdSINTERCARD(args []string) []byte {
+	keys, err := parseNumKeys("SINTERCARD", args, 0)
+	if err != nil {
+		return Encode(err, false)
+	}
+	limit := 0
+	rest := args[1+len(keys):]
+	if len(rest) > 0 {
+		if len(rest) != 2 || strings.ToUpper(rest[0]) != "LIMIT" {
+			return Encode(errors.New("(error) ERR syntax error"), false)
+		}
+		limit, err = strconv.Atoi(rest[1])
+		if err != nil {
+			return Encode(errors.New("(error) ERR value is not an integer or out of range"), false)
+		}
+		if limit < 0 {
+			return Encode(errors.New("(error) ERR LIMIT can't be negative"), false)
+		}
+	}
+
+	count := len(setOp("INTER", keys))
+	if limit > 0 && count > limit {
+		count = limit
+	}
+	return Encode(count, false)
+}
@@ -2,55 +2,281 @@ package core
 
 import (
 	"errors"
-	"fmt"
+	"math"
+	"math/rand"
 	"strconv"
+	"strings"
 
+	"github.com/spaghetti-lover/multithread-redis/internal/config"
 	"github.com/spaghetti-lover/multithread-redis/internal/constant"
 	"github.com/spaghetti-lover/multithread-redis/internal/data_structure/sorted_set"
 )
 
 func cmdZADD(args []string) []byte {
+	return zaddGeneric(zsetStore, blocking, args)
+}
+
+// newSortedSet creates an empty sorted set with the configured index
+func newSortedSet() (*sorted_set.SortedSet, error) {
+	indexConfig := sorted_set.IndexConfig{
+		Type:     sorted_set.IndexType(config.ZsetIndexType),
+		Degree:   constant.DefaultBPlusTreeDegree,
+		MaxLevel: sorted_set.SkiplistMaxLevel,
+
+		MaxListpackEntries: config.ZsetMaxListpackEntries,
+		MaxListpackValue:   config.ZsetMaxListpackValue,
+	}
+	return sorted_set.NewSortedSet(indexConfig)
+}
+
+// ZADD key [NX | XX] [GT | LT] [CH] [INCR] score member [score member ...]
+func zaddGeneric(zsets map[string]*sorted_set.SortedSet, b *blockingManager, args []string) []byte {
 	if len(args) < 3 {
 		return Encode(errors.New("(error) ERR wrong number of arguments for 'ZADD' command"), false)
 	}
 	key := args[0]
+	nx, xx, gt, lt, ch, incr := false, false, false, false, false, false
 	scoreIndex := 1
+flags:
+	for ; scoreIndex < len(args); scoreIndex++ {
+		switch strings.ToUpper(args[scoreIndex]) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "GT":
+			gt = true
+		case "LT":
+			lt = true
+		case "CH":
+			ch = true
+		case "INCR":
+			incr = true
+		default:
+			break flags
+		}
+	}
 
 	numScoreEleArgs := len(args) - scoreIndex
 	if numScoreEleArgs%2 == 1 || numScoreEleArgs == 0 {
-		return Encode(fmt.Errorf("(error) Wrong number of (score, member) arg: %d", numScoreEleArgs), false)
+		return Encode(errors.New("(error) ERR syntax error"), false)
+	}
+	if nx && xx {
+		return Encode(errors.New("(error) ERR XX and NX options at the same time are not compatible"), false)
+	}
+	if (gt && nx) || (lt && nx) || (gt && lt) {
+		return Encode(errors.New("(error) ERR GT, LT, and/or NX options at the same time are not compatible"), false)
+	}
+	if incr && numScoreEleArgs > 2 {
+		return Encode(errors.New("(error) ERR INCR option supports a single increment-element pair"), false)
 	}
 
-	zset, exist := zsetStore[key]
-	if !exist {
-		config := sorted_set.IndexConfig{
-			Type:   sorted_set.IndexTypeBTree,
-			Degree: constant.DefaultBPlusTreeDegree,
+	// Parse all the scores before touching the sorted set
+	scores := make([]float64, 0, numScoreEleArgs/2)
+	for i := scoreIndex; i < len(args); i += 2 {
+		score, err := parseZsetScore(args[i])
+		if err != nil {
+			return Encode(err, false)
 		}
+		scores = append(scores, score)
+	}
 
+	zset, exist := zsets[key]
+	if !exist {
+		if xx {
+			if incr {
+				return constant.RespNil
+			}
+			return constant.RespZero
+		}
 		var err error
-		zset, err = sorted_set.NewSortedSet(config)
+		zset, err = newSortedSet()
 		if err != nil {
 			return Encode(errors.New("(error) Can not initialize sorted set: "+err.Error()), false)
 		}
+	}
 
-		zsetStore[key] = zset
+	added, updated := 0, 0
+	var newScore float64
+	aborted := false
+	for j, score := range scores {
+		member := args[scoreIndex+2*j+1]
+		oldScore, found := zset.GetScore(member)
+		if found {
+			if nx {
+				aborted = true
+				continue
+			}
+			if incr {
+				score += oldScore
+				if math.IsNaN(score) {
+					return Encode(errors.New("(error) ERR resulting score is not a number (NaN)"), false)
+				}
+			}
+			if (gt && score <= oldScore) || (lt && score >= oldScore) {
+				aborted = true
+				continue
+			}
+			newScore = score
+			if score != oldScore {
+				zset.Add(score, member)
+				updated++
+			}
+		} else {
+			if xx {
+				aborted = true
+				continue
+			}
+			newScore = score
+			added += zset.Add(score, member)
+		}
+	}
+	if zset.Len() > 0 {
+		zsets[key] = zset
+	}
+	if added+updated > 0 {
+		b.signalKeyAsReady(key)
 	}
 
+	if incr {
+		if aborted {
+			return constant.RespNil
+		}
+		return Encode(formatScore(newScore), false)
+	}
+	if ch {
+		return Encode(added+updated, false)
+	}
+	return Encode(added, false)
+}
+
+// parseZsetScore parses a score, -inf and +inf included
+func parseZsetScore(arg string) (float64, error) {
+	score, err := strconv.ParseFloat(arg, 64)
+	if err != nil || math.IsNaN(score) {
+		return 0, errors.New("(error) ERR value is not a valid float")
+	}
+	return score, nil
+}
+
+// ZINCRBY key increment member
+func cmdZINCRBY(args []string) []byte {
+	if len(args) != 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'ZINCRBY' command"), false)
+	}
+	return zaddGeneric(zsetStore, blocking, []string{args[0], "INCR", args[1], args[2]})
+}
+
+// ZREM key member [member ...]
+func cmdZREM(args []string) []byte {
+	if len(args) < 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'ZREM' command"), false)
+	}
+	key := args[0]
+	zset, exist := zsetStore[key]
+	if !exist {
+		return constant.RespZero
+	}
 	count := 0
-	for i := scoreIndex; i < len(args); i += 2 {
-		member := args[i+1]
-		score, err := strconv.ParseFloat(args[i], 64)
-		if err != nil {
-			return Encode(errors.New("(error) Score must be floating point number"), false)
+	for _, member := range args[1:] {
+		count += zset.Remove(member)
+	}
+	if zset.Len() == 0 {
+		delete(zsetStore, key)
+	}
+	return Encode(count, false)
+}
+
+func cmdZCARD(args []string) []byte {
+	if len(args) != 1 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'ZCARD' command"), false)
+	}
+	zset, exist := zsetStore[args[0]]
+	if !exist {
+		return constant.RespZero
+	}
+	return Encode(zset.Len(), false)
+}
+
+// ZMSCORE key member [member ...]
+func cmdZMSCORE(args []string) []byte {
+	if len(args) < 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'ZMSCORE' command"), false)
+	}
+	zset := zsetStore[args[0]]
+	res := make([]interface{}, 0, len(args)-1)
+	for _, member := range args[1:] {
+		if zset == nil {
+			res = append(res, nil)
+			continue
 		}
-		ret := zset.Add(score, member)
-		if ret != 1 {
-			return Encode(errors.New("error when adding element"), false)
+		score, exist := zset.GetScore(member)
+		if !exist {
+			res = append(res, nil)
+			continue
 		}
-		count++
+		res = append(res, formatScore(score))
 	}
-	return Encode(count, false)
+	return Encode(res, false)
+}
+
+// parseRandomCount parses the count of HRANDFIELD, ZRANDMEMBER and SRANDMEMBER. A negative count
+// may repeat members, so its size is bounded to keep the reply from exhausting memory.
+func parseRandomCount(s string) (int, error) {
+	count, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, errors.New("(error) ERR value is not an integer or out of range")
+	}
+	if count < -constant.RandomMembersMaxCount {
+		return 0, errors.New("(error) ERR value is out of range")
+	}
+	return count, nil
+}
+
+// ZRANDMEMBER key [count [WITHSCORES]]
+func cmdZRANDMEMBER(args []string) []byte {
+	if len(args) < 1 || len(args) > 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'ZRANDMEMBER' command"), false)
+	}
+	zset, exist := zsetStore[args[0]]
+	if len(args) == 1 {
+		if !exist {
+			return constant.RespNil
+		}
+		return Encode(zset.Index.GetByRank(rand.Intn(zset.Len())).Member, false)
+	}
+
+	count, err := parseRandomCount(args[1])
+	if err != nil {
+		return Encode(err, false)
+	}
+	withScores := false
+	if len(args) == 3 {
+		if strings.ToUpper(args[2]) != "WITHSCORES" {
+			return Encode(errors.New("(error) ERR syntax error"), false)
+		}
+		withScores = true
+	}
+	if !exist || count == 0 {
+		return Encode(make([]string, 0), false)
+	}
+
+	n := zset.Len()
+	var items []*sorted_set.Item
+	switch {
+	case count < 0:
+		// The same member may be returned several times
+		for i := 0; i < -count; i++ {
+			items = append(items, zset.Index.GetByRank(rand.Intn(n)))
+		}
+	case count >= n:
+		items = zset.Index.GetRangeByRank(0, n-1)
+	default:
+		for _, rank := range rand.Perm(n)[:count] {
+			items = append(items, zset.Index.GetByRank(rank))
+		}
+	}
+	return Encode(zsetItemsReply(items, withScores), false)
 }
 
 func cmdZSCORE(args []string) []byte {
@@ -66,18 +292,403 @@ func cmdZSCORE(args []string) []byte {
 	if !exist {
 		return constant.RespNil
 	}
-	return Encode(fmt.Sprintf("%f", score), false)
+	return Encode(formatScore(score), false)
 }
 
-func cmdZRANK(args []string) []byte {
-	if len(args) != 2 {
-		return Encode(errors.New("(error) ERR wrong number of arguments for 'ZRANK' command"), false)
+// zrankGeneric implements ZRANK and ZREVRANK key member [WITHSCORE]
+func zrankGeneric(cmd string, args []string, reverse bool) []byte {
+	if len(args) != 2 && len(args) != 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for '"+cmd+"' command"), false)
+	}
+	withScore := false
+	if len(args) == 3 {
+		if strings.ToUpper(args[2]) != "WITHSCORE" {
+			return Encode(errors.New("(error) ERR syntax error"), false)
+		}
+		withScore = true
 	}
 	key, member := args[0], args[1]
 	zset, exist := zsetStore[key]
 	if !exist {
+		if withScore {
+			return constant.RespNilArray
+		}
+		return constant.RespNil
+	}
+	score, exist := zset.GetScore(member)
+	if !exist {
+		if withScore {
+			return constant.RespNilArray
+		}
 		return constant.RespNil
 	}
 	rank := zset.GetRank(member)
+	if reverse {
+		rank = zset.GetRevRank(member)
+	}
+	if withScore {
+		return Encode([]interface{}{rank, formatScore(score)}, false)
+	}
 	return Encode(rank, false)
 }
+
+func cmdZRANK(args []string) []byte {
+	return zrankGeneric("ZRANK", args, false)
+}
+
+func cmdZREVRANK(args []string) []byte {
+	return zrankGeneric("ZREVRANK", args, true)
+}
+
+// zrangeSpec holds the arguments of ZRANGE and ZRANGESTORE:
+// start stop [BYSCORE | BYLEX] [REV] [LIMIT offset count] [WITHSCORES]
+type zrangeSpec struct {
+	by         string // "", "BYSCORE" or "BYLEX"
+	start      int
+	stop       int
+	score      *sorted_set.ScoreRange
+	lex        *sorted_set.LexRange
+	reverse    bool
+	offset     int
+	count      int // negative means no limit
+	withScores bool
+}
+
+// parseZrangeSpec parses the arguments following the key(s) of ZRANGE and ZRANGESTORE
+func parseZrangeSpec(args []string, store bool) (*zrangeSpec, error) {
+	spec := &zrangeSpec{count: -1}
+	hasLimit := false
+	for i := 2; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i]); {
+		case opt == "BYSCORE" || opt == "BYLEX":
+			spec.by = opt
+		case opt == "REV":
+			spec.reverse = true
+		case opt == "WITHSCORES" && !store:
+			spec.withScores = true
+		case opt == "LIMIT" && i+2 < len(args):
+			offset, err1 := strconv.Atoi(args[i+1])
+			count, err2 := strconv.Atoi(args[i+2])
+			if err1 != nil || err2 != nil {
+				return nil, errors.New("(error) ERR value is not an integer or out of range")
+			}
+			spec.offset, spec.count = offset, count
+			hasLimit = true
+			i += 2
+		default:
+			return nil, errors.New("(error) ERR syntax error")
+		}
+	}
+	if hasLimit && spec.by == "" {
+		return nil, errors.New("(error) ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX")
+	}
+	if spec.withScores && spec.by == "BYLEX" {
+		return nil, errors.New("(error) ERR syntax error, WITHSCORES not supported in combination with BYLEX")
+	}
+
+	// With REV, the range is given from the highest to the lowest bound
+	min, max := args[0], args[1]
+	if spec.reverse && spec.by != "" {
+		min, max = max, min
+	}
+	var err error
+	switch spec.by {
+	case "BYSCORE":
+		if spec.score, err = sorted_set.ParseScoreRange(min, max); err != nil {
+			return nil, errors.New("(error) ERR " + err.Error())
+		}
+	case "BYLEX":
+		if spec.lex, err = sorted_set.ParseLexRange(min, max); err != nil {
+			return nil, errors.New("(error) ERR " + err.Error())
+		}
+	default:
+		start, err1 := strconv.Atoi(min)
+		stop, err2 := strconv.Atoi(max)
+		if err1 != nil || err2 != nil {
+			return nil, errors.New("(error) ERR value is not an integer or out of range")
+		}
+		spec.start, spec.stop = start, stop
+	}
+	return spec, nil
+}
+
+// apply returns the items of zset selected by the range
+func (spec *zrangeSpec) apply(zset *sorted_set.SortedSet) []*sorted_set.Item {
+	switch spec.by {
+	case "BYSCORE":
+		return zset.RangeByScore(spec.score, spec.reverse, spec.offset, spec.count)
+	case "BYLEX":
+		return zset.RangeByLex(spec.lex, spec.reverse, spec.offset, spec.count)
+	}
+	n := zset.Len()
+	start, stop := spec.start, spec.stop
+	if start < 0 {
+		start += n
+	}
+	if stop < 0 {
+		stop += n
+	}
+	start = max(start, 0)
+	stop = min(stop, n-1)
+	if start > stop {
+		return nil
+	}
+	return zset.RangeByRank(start, stop, spec.reverse)
+}
+
+// zsetItemsReply formats items as a flat array of members, followed by their score with withScores
+func zsetItemsReply(items []*sorted_set.Item, withScores bool) []string {
+	res := make([]string, 0, len(items))
+	for _, item := range items {
+		res = append(res, item.Member)
+		if withScores {
+			res = append(res, formatScore(item.Score))
+		}
+	}
+	return res
+}
+
+// ZRANGE key start stop [BYSCORE | BYLEX] [REV] [LIMIT offset count] [WITHSCORES]
+func cmdZRANGE(args []string) []byte {
+	if len(args) < 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'ZRANGE' command"), false)
+	}
+	spec, err := parseZrangeSpec(args[1:], false)
+	if err != nil {
+		return Encode(err, false)
+	}
+	zset, exist := zsetStore[args[0]]
+	if !exist {
+		return Encode(make([]string, 0), false)
+	}
+	return Encode(zsetItemsReply(spec.apply(zset), spec.withScores), false)
+}
+
+// ZRANGESTORE dst src min max [BYSCORE | BYLEX] [REV] [LIMIT offset count]
+func cmdZRANGESTORE(args []string) []byte {
+	if len(args) < 4 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'ZRANGESTORE' command"), false)
+	}
+	dst := args[0]
+	spec, err := parseZrangeSpec(args[2:], true)
+	if err != nil {
+		return Encode(err, false)
+	}
+	var items []*sorted_set.Item
+	if zset, exist := zsetStore[args[1]]; exist {
+		items = spec.apply(zset)
+	}
+	if len(items) == 0 {
+		delete(zsetStore, dst)
+		return constant.RespZero
+	}
+	zset, err := newSortedSet()
+	if err != nil {
+		return Encode(errors.New("(error) Can not initialize sorted set: "+err.Error()), false)
+	}
+	for _, item := range items {
+		zset.Add(item.Score, item.Member)
+	}
+	zsetStore[dst] = zset
+	blocking.signalKeyAsReady(dst)
+	return Encode(zset.Len(), false)
+}
+
+// zremrangeGeneric implements ZREMRANGEBYRANK, ZREMRANGEBYSCORE and ZREMRANGEBYLEX key min max
+func zremrangeGeneric(cmd string, args []string) []byte {
+	if len(args) != 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for '"+cmd+"' command"), false)
+	}
+	key := args[0]
+	var score *sorted_set.ScoreRange
+	var lex *sorted_set.LexRange
+	var start, stop int
+	var err error
+	switch cmd {
+	case "ZREMRANGEBYSCORE":
+		score, err = sorted_set.ParseScoreRange(args[1], args[2])
+	case "ZREMRANGEBYLEX":
+		lex, err = sorted_set.ParseLexRange(args[1], args[2])
+	default:
+		var err2 error
+		start, err = strconv.Atoi(args[1])
+		stop, err2 = strconv.Atoi(args[2])
+		if err != nil || err2 != nil {
+			return Encode(errors.New("(error) ERR value is not an integer or out of range"), false)
+		}
+	}
+	if err != nil {
+		return Encode(errors.New("(error) ERR "+err.Error()), false)
+	}
+
+	zset, exist := zsetStore[key]
+	if !exist {
+		return constant.RespZero
+	}
+	removed := 0
+	switch cmd {
+	case "ZREMRANGEBYSCORE":
+		removed = zset.RemoveRangeByScore(score)
+	case "ZREMRANGEBYLEX":
+		removed = zset.RemoveRangeByLex(lex)
+	default:
+		n := zset.Len()
+		if start < 0 {
+			start += n
+		}
+		if stop < 0 {
+			stop += n
+		}
+		start = max(start, 0)
+		stop = min(stop, n-1)
+		if start <= stop {
+			removed = zset.RemoveRangeByRank(start, stop)
+		}
+	}
+	if zset.Len() == 0 {
+		delete(zsetStore, key)
+	}
+	return Encode(removed, false)
+}
+
+func cmdZREMRANGEBYRANK(args []string) []byte {
+	return zremrangeGeneric("ZREMRANGEBYRANK", args)
+}
+
+func cmdZREMRANGEBYSCORE(args []string) []byte {
+	return zremrangeGeneric("ZREMRANGEBYSCORE", args)
+}
+
+func cmdZREMRANGEBYLEX(args []string) []byte {
+	return zremrangeGeneric("ZREMRANGEBYLEX", args)
+}
+
+// ZCOUNT key min max
+func cmdZCOUNT(args []string) []byte {
+	if len(args) != 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'ZCOUNT' command"), false)
+	}
+	r, err := sorted_set.ParseScoreRange(args[1], args[2])
+	if err != nil {
+		return Encode(errors.New("(error) ERR "+err.Error()), false)
+	}
+	zset, exist := zsetStore[args[0]]
+	if !exist {
+		return constant.RespZero
+	}
+	return Encode(zset.Count(r), false)
+}
+
+// ZLEXCOUNT key min max
+func cmdZLEXCOUNT(args []string) []byte {
+	if len(args) != 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'ZLEXCOUNT' command"), false)
+	}
+	r, err := sorted_set.ParseLexRange(args[1], args[2])
+	if err != nil {
+		return Encode(errors.New("(error) ERR "+err.Error()), false)
+	}
+	zset, exist := zsetStore[args[0]]
+	if !exist {
+		return constant.RespZero
+	}
+	return Encode(zset.LexCount(r), false)
+}
+
+// zpopGenericCommand implements ZPOPMIN and ZPOPMAX key [count]
+func zpopGenericCommand(zsets map[string]*sorted_set.SortedSet, cmd string, args []string) []byte {
+	if len(args) != 1 && len(args) != 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for '"+cmd+"' command"), false)
+	}
+	key := args[0]
+	count := 1
+	if len(args) == 2 {
+		var err error
+		count, err = strconv.Atoi(args[1])
+		if err != nil || count < 0 {
+			return Encode(errors.New("(error) ERR value is out of range, must be positive"), false)
+		}
+	}
+	if _, exist := zsets[key]; !exist || count == 0 {
+		return Encode(make([]string, 0), false)
+	}
+	return Encode(zsetItemsReply(zsetPop(zsets, key, cmd == "ZPOPMIN", count), true), false)
+}
+
+// zmpopCommand implements ZMPOP numkeys key [key ...] MIN | MAX [COUNT count]. It pops from
+// the first non-empty sorted set.
+func zmpopCommand(zsets map[string]*sorted_set.SortedSet, args []string) []byte {
+	keys, err := parseNumKeys("ZMPOP", args, 0)
+	if err != nil {
+		return Encode(err, false)
+	}
+	rest := args[1+len(keys):]
+	if len(rest) == 0 {
+		return Encode(errors.New("(error) ERR syntax error"), false)
+	}
+	where := strings.ToUpper(rest[0])
+	if where != "MIN" && where != "MAX" {
+		return Encode(errors.New("(error) ERR syntax error"), false)
+	}
+	count := 1
+	if len(rest) > 1 {
+		if len(rest) != 3 || strings.ToUpper(rest[1]) != "COUNT" {
+			return Encode(errors.New("(error) ERR syntax error"), false)
+		}
+		count, err = strconv.Atoi(rest[2])
+		if err != nil || count <= 0 {
+			return Encode(errors.New("(error) ERR count should be greater than 0"), false)
+		}
+	}
+
+	for _, key := range keys {
+		if _, exist := zsets[key]; !exist {
+			continue
+		}
+		items := zsetPop(zsets, key, where == "MIN", count)
+		res := make([]interface{}, 0, len(items))
+		for _, item := range items {
+			res = append(res, []string{item.Member, formatScore(item.Score)})
+		}
+		return Encode([]interface{}{key, res}, false)
+	}
+	return constant.RespNilArray
+}
+
+func cmdZPOPMIN(args []string) []byte {
+	return zpopGenericCommand(zsetStore, "ZPOPMIN", args)
+}
+
+func cmdZPOPMAX(args []string) []byte {
+	return zpopGenericCommand(zsetStore, "ZPOPMAX", args)
+}
+
+func cmdZMPOP(args []string) []byte {
+	return zmpopCommand(zsetStore, args)
+}
+
+// zsetPop pops up to count members with the lowest (min) or highest scores from the sorted set
+// stored at key, which must exist. The key is deleted when the sorted set becomes empty.
+func zsetPop(zsets map[string]*sorted_set.SortedSet, key string, min bool, count int) []*sorted_set.Item {
+	zset := zsets[key]
+	var items []*sorted_set.Item
+	if min {
+		items = zset.PopMin(count)
+	} else {
+		items = zset.PopMax(count)
+	}
+	if zset.Len() == 0 {
+		delete(zsets, key)
+	}
+	return items
+}
+
+func formatScore(score float64) string {
+	if math.IsInf(score, 1) {
+		return "inf"
+	}
+	if math.IsInf(score, -1) {
+		return "-inf"
+	}
+	return strconv.FormatFloat(score, 'f', -1, 64)
+}
@@ -0,0 +1,346 @@
+package core
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spaghetti-lover/multithread-redis/internal/constant"
+	"github.com/spaghetti-lover/multithread-redis/internal/data_structure/sorted_set"
+)
+
+// zsetSource returns the members and scores of an input key of a set operation.
+// The returned map must not be modified.
+type zsetSource func(key string) (map[string]float64, bool)
+
+// localZsetSource reads the inputs from the stores of the single-threaded server.
+// A plain set is an input with a score of 1 for every member.
+func localZsetSource(key string) (map[string]float64, bool) {
+	if zset, exist := zsetStore[key]; exist {
+		return zset.Scores(), true
+	}
+	if set, exist := setStore[key]; exist {
+		scores := make(map[string]float64)
+		for _, member := range set.Members() {
+			scores[member] = 1
+		}
+		return scores, true
+	}
+	return nil, false
+}
+
+// zsetOpArgs holds the arguments of ZUNION, ZINTER, ZDIFF and their STORE variants:
+// numkeys key [key ...] [WEIGHTS weight [weight ...]] [AGGREGATE SUM | MIN | MAX] [WITHSCORES]
+type zsetOpArgs struct {
+	op         string // "UNION", "INTER" or "DIFF"
+	dest       string
+	store      bool
+	keys       []string
+	weights    []float64
+	aggregate  string
+	withScores bool
+}
+
+// zsetOpCommand splits ZUNIONSTORE-like command names into the operation and whether the
+// result is stored
+func zsetOpCommand(cmd string) (op string, store bool, ok bool) {
+	switch cmd {
+	case "ZUNION", "ZINTER", "ZDIFF":
+		return cmd[1:], false, true
+	case "ZUNIONSTORE", "ZINTERSTORE", "ZDIFFSTORE":
+		return strings.TrimSuffix(cmd[1:], "STORE"), true, true
+	}
+	return "", false, false
+}
+
+// parseNumKeys parses numkeys key [key ...] at args[i] and returns the keys
+func parseNumKeys(cmd string, args []string, i int) ([]string, error) {
+	if i >= len(args) {
+		return nil, errors.New("(error) ERR wrong number of arguments for '" + cmd + "' command")
+	}
+	numKeys, err := strconv.Atoi(args[i])
+	if err != nil {
+		return nil, errors.New("(error) ERR value is not an integer or out of range")
+	}
+	if numKeys <= 0 {
+		return nil, errors.New("(error) ERR at least 1 input key is needed for '" + strings.ToLower(cmd) + "' command")
+	}
+	if numKeys > len(args)-i-1 {
+		return nil, errors.New("(error) ERR syntax error")
+	}
+	return args[i+1 : i+1+numKeys], nil
+}
+
+func parseZsetOpArgs(cmd string, args []string) (*zsetOpArgs, error) {
+	op, store, _ := zsetOpCommand(cmd)
+	a := &zsetOpArgs{op: op, store: store, aggregate: "SUM"}
+	i := 0
+	if store {
+		if len(args) < 3 {
+			return nil, errors.New("(error) ERR wrong number of arguments for '" + cmd + "' command")
+		}
+		a.dest = args[0]
+		i = 1
+	}
+	keys, err := parseNumKeys(cmd, args, i)
+	if err != nil {
+		return nil, err
+	}
+	a.keys = keys
+	i += 1 + len(keys)
+
+	for ; i < len(args); i++ {
+		opt := strings.ToUpper(args[i])
+		switch {
+		case opt == "WEIGHTS" && op != "DIFF" && i+len(keys) < len(args):
+			a.weights = make([]float64, len(keys))
+			for j := range keys {
+				w, err := strconv.ParseFloat(args[i+1+j], 64)
+				if err != nil || math.IsNaN(w) {
+					return nil, errors.New("(error) ERR weight value is not a float")
+				}
+				a.weights[j] = w
+			}
+			i += len(keys)
+		case opt == "AGGREGATE" && op != "DIFF" && i+1 < len(args):
+			a.aggregate = strings.ToUpper(args[i+1])
+			if a.aggregate != "SUM" && a.aggregate != "MIN" && a.aggregate != "MAX" {
+				return nil, errors.New("(error) ERR syntax error")
+			}
+			i++
+		case opt == "WITHSCORES" && !store:
+			a.withScores = true
+		default:
+			return nil, errors.New("(error) ERR syntax error")
+		}
+	}
+	return a, nil
+}
+
+func (a *zsetOpArgs) weight(i int) float64 {
+	if a.weights == nil {
+		return 1
+	}
+	return a.weights[i]
+}
+
+// aggregateScores combines the score acc of a member with the weighted score of another input
+func (a *zsetOpArgs) aggregateScores(acc, score float64) float64 {
+	switch a.aggregate {
+	case "MIN":
+		return math.Min(acc, score)
+	case "MAX":
+		return math.Max(acc, score)
+	}
+	// inf + -inf is 0 like in Redis
+	if sum := acc + score; !math.IsNaN(sum) {
+		return sum
+	}
+	return 0
+}
+
+// weightedScore multiplies a score by a weight, 0 * inf being 0
+func weightedScore(score, weight float64) float64 {
+	if v := score * weight; !math.IsNaN(v) {
+		return v
+	}
+	return 0
+}
+
+// apply computes the result of the operation on the inputs read from source
+func (a *zsetOpArgs) apply(source zsetSource) map[string]float64 {
+	inputs := make([]map[string]float64, len(a.keys))
+	for i, key := range a.keys {
+		inputs[i], _ = source(key)
+	}
+
+	result := make(map[string]float64)
+	switch a.op {
+	case "UNION":
+		for i, input := range inputs {
+			for member, score := range input {
+				score = weightedScore(score, a.weight(i))
+				if acc, exist := result[member]; exist {
+					result[member] = a.aggregateScores(acc, score)
+				} else {
+					result[member] = score
+				}
+			}
+		}
+	case "INTER":
+		// Iterate over the smallest input
+		smallest := 0
+		for i, input := range inputs {
+			if len(input) < len(inputs[smallest]) {
+				smallest = i
+			}
+		}
+	members:
+		for member := range inputs[smallest] {
+			var acc float64
+			for i, input := range inputs {
+				score, exist := input[member]
+				if !exist {
+					continue members
+				}
+				score = weightedScore(score, a.weight(i))
+				if i == 0 {
+					acc = score
+				} else {
+					acc = a.aggregateScores(acc, score)
+				}
+			}
+			result[member] = acc
+		}
+	case "DIFF":
+	diff:
+		for member, score := range inputs[0] {
+			for _, input := range inputs[1:] {
+				if _, exist := input[member]; exist {
+					continue diff
+				}
+			}
+			result[member] = score
+		}
+	}
+	return result
+}
+
+// sortedItems orders the result of an operation by score, then member
+func sortedItems(scores map[string]float64) []*sorted_set.Item {
+	items := make([]*sorted_set.Item, 0, len(scores))
+	for member, score := range scores {
+		items = append(items, &sorted_set.Item{Score: score, Member: member})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].CompareTo(items[j]) < 0
+	})
+	return items
+}
+
+// zsetOpGeneric implements ZUNION, ZINTER, ZDIFF, ZUNIONSTORE, ZINTERSTORE and ZDIFFSTORE.
+// Inputs are read from source and the result is stored in zsets.
+func zsetOpGeneric(zsets map[string]*sorted_set.SortedSet, b *blockingManager, source zsetSource, cmd string, args []string) []byte {
+	a, err := parseZsetOpArgs(cmd, args)
+	if err != nil {
+		return Encode(err, false)
+	}
+	result := a.apply(source)
+
+	if !a.store {
+		return Encode(zsetItemsReply(sortedItems(result), a.withScores), false)
+	}
+	if len(result) == 0 {
+		delete(zsets, a.dest)
+		return constant.RespZero
+	}
+	zset, err := newSortedSet()
+	if err != nil {
+		return Encode(errors.New("(error) Can not initialize sorted set: "+err.Error()), false)
+	}
+	for member, score := range result {
+		zset.Add(score, member)
+	}
+	zsets[a.dest] = zset
+	b.signalKeyAsReady(a.dest)
+	return Encode(zset.Len(), false)
+}
+
+// zintercardGeneric implements ZINTERCARD numkeys key [key ...] [LIMIT limit]
+func zintercardGeneric(source zsetSource, args []string) []byte {
+	if len(args) < 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'ZINTERCARD' command"), false)
+	}
+	numKeys, err := strconv.Atoi(args[0])
+	if err != nil {
+		return Encode(errors.New("(error) ERR value is not an integer or out of range"), false)
+	}
+	if numKeys <= 0 {
+		return Encode(errors.New("(error) ERR numkeys should be greater than 0"), false)
+	}
+	if numKeys > len(args)-1 {
+		return Encode(errors.New("(error) ERR Number of keys can't be greater than number of args"), false)
+	}
+	keys := args[1 : 1+numKeys]
+	limit := 0
+	rest := args[1+len(keys):]
+	if len(rest) > 0 {
+		if len(rest) != 2 || strings.ToUpper(rest[0]) != "LIMIT" {
+			return Encode(errors.New("(error) ERR syntax error"), false)
+		}
+		limit, err = strconv.Atoi(rest[1])
+		if err != nil {
+			return Encode(errors.New("(error) ERR value is not an integer or out of range"), false)
+		}
+		if limit < 0 {
+			return Encode(errors.New("(error) ERR LIMIT can't be negative"), false)
+		}
+	}
+
+	inputs := make([]map[string]float64, len(keys))
+	smallest := 0
+	for i, key := range keys {
+		inputs[i], _ = source(key)
+		if len(inputs[i]) < len(inputs[smallest]) {
+			smallest = i
+		}
+	}
+	count := 0
+members:
+	for member := range inputs[smallest] {
+		for _, input := range inputs {
+			if _, exist := input[member]; !exist {
+				continue members
+			}
+		}
+		count++
+		if limit > 0 && count == limit {
+			break
+		}
+	}
+	return Encode(count, false)
+}
+
+func cmdZsetOp(cmd string, args []string) []byte {
+	return zsetOpGeneric(zsetStore, blocking, localZsetSource, cmd, args)
+}
+
+func cmdZINTERCARD(args []string) []byte {
+	return zintercardGeneric(localZsetSource, args)
+}
+
+// ZsetOpKeys returns the keys of a sorted set operation in the sharded server: the key
+// whose worker runs the command (the destination, or the first input) and the input keys.
+// ok is false for other commands or when the keys can not be parsed.
+func ZsetOpKeys(cmd *Command) (owner string, inputs []string, ok bool) {
+	var err error
+	switch cmd.Cmd {
+	case "ZINTERCARD":
+		inputs, err = parseNumKeys(cmd.Cmd, cmd.Args, 0)
+		if err != nil {
+			return "", nil, false
+		}
+		return inputs[0], inputs, true
+	}
+	_, store, isOp := zsetOpCommand(cmd.Cmd)
+	if !isOp {
+		return "", nil, false
+	}
+	if store {
+		if len(cmd.Args) < 1 {
+			return "", nil, false
+		}
+		inputs, err = parseNumKeys(cmd.Cmd, cmd.Args, 1)
+		if err != nil {
+			return "", nil, false
+		}
+		return cmd.Args[0], inputs, true
+	}
+	inputs, err = parseNumKeys(cmd.Cmd, cmd.Args, 0)
+	if err != nil {
+		return "", nil, false
+	}
+	return inputs[0], inputs, true
+}
@@ -0,0 +1,364 @@
+package core
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spaghetti-lover/multithread-redis/internal/config"
+	"github.com/spaghetti-lover/multithread-redis/internal/constant"
+	"github.com/spaghetti-lover/multithread-redis/internal/data_structure/stream"
+)
+
+// streamTrimArgs holds the trimming options of XADD and XTRIM:
+// MAXLEN | MINID [= | ~] threshold [LIMIT count]
+type streamTrimArgs struct {
+	strategy string // "MAXLEN", "MINID" or "" for no trimming
+	maxLen   int
+	minID    stream.StreamID
+	approx   bool
+	limit    int // negative means no limit
+}
+
+// parseStreamTrimArgs parses the trimming options starting at args[i] if there are any.
+// It returns the index of the first argument after them.
+func parseStreamTrimArgs(args []string, i int) (*streamTrimArgs, int, error) {
+	t := &streamTrimArgs{limit: -1}
+	if i >= len(args) {
+		return t, i, nil
+	}
+	strategy := strings.ToUpper(args[i])
+	if strategy != "MAXLEN" && strategy != "MINID" {
+		return t, i, nil
+	}
+	t.strategy = strategy
+	i++
+	if i < len(args) && (args[i] == "=" || args[i] == "~") {
+		t.approx = args[i] == "~"
+		i++
+	}
+	if i >= len(args) {
+		return nil, i, errors.New("(error) ERR syntax error")
+	}
+
+	if strategy == "MAXLEN" {
+		maxLen, err := strconv.Atoi(args[i])
+		if err != nil {
+			return nil, i, errors.New("(error) ERR value is not an integer or out of range")
+		}
+		if maxLen < 0 {
+			return nil, i, errors.New("(error) ERR The MAXLEN argument must be >= 0.")
+		}
+		t.maxLen = maxLen
+	} else {
+		minID, err := stream.ParseID(args[i], 0)
+		if err != nil {
+			return nil, i, errors.New("(error) ERR " + err.Error())
+		}
+		t.minID = minID
+	}
+	i++
+
+	if i+1 < len(args) && strings.ToUpper(args[i]) == "LIMIT" {
+		limit, err := strconv.Atoi(args[i+1])
+		if err != nil || limit < 0 {
+			return nil, i, errors.New("(error) ERR The LIMIT argument must be >= 0.")
+		}
+		if !t.approx {
+			return nil, i, errors.New("(error) ERR syntax error, LIMIT cannot be used without the special ~ option")
+		}
+		t.limit = limit
+		i += 2
+	} else if t.approx {
+		// Bound the work done by a single approximate trim, like Redis
+		t.limit = 100 * config.StreamNodeMaxEntries
+	}
+	return t, i, nil
+}
+
+// apply trims s and returns the number of removed entries
+func (t *streamTrimArgs) apply(s *stream.Stream) int {
+	switch t.strategy {
+	case "MAXLEN":
+		return s.TrimMaxLen(t.maxLen, t.approx, t.limit)
+	case "MINID":
+		return s.TrimMinID(t.minID, t.approx, t.limit)
+	}
+	return 0
+}
+
+func newStream() *stream.Stream {
+	return stream.NewStream(config.StreamNodeMaxEntries, config.StreamNodeMaxBytes)
+}
+
+// streamEntriesReply formats entries as an array of [id, [field, value, ...]]
+func streamEntriesReply(entries []stream.Entry) []interface{} {
+	res := make([]interface{}, 0, len(entries))
+	for _, e := range entries {
+		res = append(res, []interface{}{e.ID.String(), e.Fields})
+	}
+	return res
+}
+
+// streamReadAfter returns the entries of s with an ID greater than id, at most count of them
+// (count <= 0 means no limit)
+func streamReadAfter(s *stream.Stream, id stream.StreamID, count int) []stream.Entry {
+	start, ok := id.Incr()
+	if !ok {
+		return nil
+	}
+	return s.Range(start, stream.MaxID, count, false)
+}
+
+// XADD key [NOMKSTREAM] [MAXLEN | MINID [= | ~] threshold [LIMIT count]] <* | id> field value [field value ...]
+func cmdXADD(args []string) []byte {
+	if len(args) < 4 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'XADD' command"), false)
+	}
+	key := args[0]
+	i := 1
+	noMkStream := false
+	if strings.ToUpper(args[i]) == "NOMKSTREAM" {
+		noMkStream = true
+		i++
+	}
+	trim, i, err := parseStreamTrimArgs(args, i)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if i >= len(args) {
+		return Encode(errors.New("(error) ERR syntax error"), false)
+	}
+	idArg := args[i]
+	fields := args[i+1:]
+	if len(fields) == 0 || len(fields)%2 != 0 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'XADD' command"), false)
+	}
+
+	s, exist := streamStore[key]
+	if !exist {
+		if noMkStream {
+			return constant.RespNil
+		}
+		s = newStream()
+	}
+	id, err := s.ResolveID(idArg, uint64(time.Now().UnixMilli()))
+	if err != nil {
+		return Encode(errors.New("(error) ERR "+err.Error()), false)
+	}
+	if err := s.Add(id, fields); err != nil {
+		return Encode(errors.New("(error) ERR "+err.Error()), false)
+	}
+	streamStore[key] = s
+	trim.apply(s)
+	blocking.signalKeyAsReady(key)
+	return Encode(id.String(), false)
+}
+
+// xrangeGeneric implements XRANGE key start end [COUNT count] and XREVRANGE key end start [COUNT count]
+func xrangeGeneric(cmd string, args []string, reverse bool) []byte {
+	if len(args) != 3 && len(args) != 5 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for '"+cmd+"' command"), false)
+	}
+	startArg, endArg := args[1], args[2]
+	if reverse {
+		startArg, endArg = endArg, startArg
+	}
+	start, err := stream.ParseRangeID(startArg, true)
+	if err != nil {
+		return Encode(errors.New("(error) ERR "+err.Error()), false)
+	}
+	end, err := stream.ParseRangeID(endArg, false)
+	if err != nil {
+		return Encode(errors.New("(error) ERR "+err.Error()), false)
+	}
+
+	count := 0
+	if len(args) == 5 {
+		if strings.ToUpper(args[3]) != "COUNT" {
+			return Encode(errors.New("(error) ERR syntax error"), false)
+		}
+		count, err = strconv.Atoi(args[4])
+		if err != nil {
+			return Encode(errors.New("(error) ERR value is not an integer or out of range"), false)
+		}
+		if count <= 0 {
+			return Encode(make([]interface{}, 0), false)
+		}
+	}
+
+	s, exist := streamStore[args[0]]
+	if !exist {
+		return Encode(make([]interface{}, 0), false)
+	}
+	return Encode(streamEntriesReply(s.Range(start, end, count, reverse)), false)
+}
+
+func cmdXRANGE(args []string) []byte {
+	return xrangeGeneric("XRANGE", args, false)
+}
+
+func cmdXREVRANGE(args []string) []byte {
+	return xrangeGeneric("XREVRANGE", args, true)
+}
+
+func cmdXLEN(args []string) []byte {
+	if len(args) != 1 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'XLEN' command"), false)
+	}
+	s, exist := streamStore[args[0]]
+	if !exist {
+		return constant.RespZero
+	}
+	return Encode(s.Len(), false)
+}
+
+// XDEL key id [id ...]
+func cmdXDEL(args []string) []byte {
+	if len(args) < 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'XDEL' command"), false)
+	}
+	ids := make([]stream.StreamID, 0, len(args)-1)
+	for _, arg := range args[1:] {
+		id, err := stream.ParseID(arg, 0)
+		if err != nil {
+			return Encode(errors.New("(error) ERR "+err.Error()), false)
+		}
+		ids = append(ids, id)
+	}
+	s, exist := streamStore[args[0]]
+	if !exist {
+		return constant.RespZero
+	}
+	count := 0
+	for _, id := range ids {
+		if s.Delete(id) {
+			count++
+		}
+	}
+	return Encode(count, false)
+}
+
+// XTRIM key MAXLEN | MINID [= | ~] threshold [LIMIT count]
+func cmdXTRIM(args []string) []byte {
+	if len(args) < 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'XTRIM' command"), false)
+	}
+	trim, i, err := parseStreamTrimArgs(args, 1)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if trim.strategy == "" || i != len(args) {
+		return Encode(errors.New("(error) ERR syntax error"), false)
+	}
+	s, exist := streamStore[args[0]]
+	if !exist {
+		return constant.RespZero
+	}
+	return Encode(trim.apply(s), false)
+}
+
+// xreadCommand implements XREAD [COUNT count] [BLOCK milliseconds] STREAMS key [key ...] id [id ...].
+// Like blockingPopGenericCommand, it returns nil when the client is blocked and the reply is
+// sent later through reply.
+func xreadCommand(b *blockingManager, streams map[string]*stream.Stream, args []string, fd int, closed <-chan struct{}, reply func([]byte)) []byte {
+	count := 0
+	block := false
+	var deadline time.Time
+	i := 0
+	for ; i < len(args); i++ {
+		opt := strings.ToUpper(args[i])
+		if opt == "STREAMS" {
+			i++
+			break
+		}
+		if i+1 >= len(args) {
+			return Encode(errors.New("(error) ERR syntax error"), false)
+		}
+		switch opt {
+		case "COUNT":
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil {
+				return Encode(errors.New("(error) ERR value is not an integer or out of range"), false)
+			}
+			count = max(n, 0)
+		case "BLOCK":
+			ms, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil {
+				return Encode(errors.New("(error) ERR timeout is not an integer or out of range"), false)
+			}
+			if ms < 0 {
+				return Encode(errors.New("(error) ERR timeout is negative"), false)
+			}
+			block = true
+			if ms > 0 && ms < math.MaxInt64/int64(time.Millisecond) {
+				deadline = time.Now().Add(time.Duration(ms) * time.Millisecond)
+			}
+		default:
+			return Encode(errors.New("(error) ERR syntax error"), false)
+		}
+		i++
+	}
+
+	rest := args[i:]
+	if len(rest) == 0 || len(rest)%2 != 0 {
+		return Encode(errors.New("(error) ERR Unbalanced 'xread' list of streams: for each stream key an ID or '$' must be specified."), false)
+	}
+	keys := rest[:len(rest)/2]
+	ids := make(map[string]stream.StreamID, len(keys))
+	for j, key := range keys {
+		idArg := rest[len(keys)+j]
+		if idArg == "$" {
+			// Only entries added from now on
+			if s, exist := streams[key]; exist {
+				ids[key] = s.LastID()
+			} else {
+				ids[key] = stream.MinID
+			}
+			continue
+		}
+		id, err := stream.ParseID(idArg, 0)
+		if err != nil {
+			return Encode(errors.New("(error) ERR "+err.Error()), false)
+		}
+		ids[key] = id
+	}
+
+	res := make([]interface{}, 0)
+	for _, key := range keys {
+		s, exist := streams[key]
+		if !exist {
+			continue
+		}
+		if entries := streamReadAfter(s, ids[key], count); len(entries) > 0 {
+			res = append(res, []interface{}{key, streamEntriesReply(entries)})
+		}
+	}
+	if len(res) > 0 {
+		return Encode(res, false)
+	}
+	if !block {
+		return constant.RespNilArray
+	}
+
+	b.block(&blockedClient{
+		fd:        fd,
+		cmd:       "XREAD",
+		keys:      keys,
+		streamIDs: ids,
+		count:     count,
+		deadline:  deadline,
+		reply:     reply,
+		closed:    closed,
+	})
+	return nil
+}
+
+func cmdXREAD(args []string, connFd int) []byte {
+	return xreadCommand(blocking, streamStore, args, connFd, nil, func(res []byte) {
+		syscall.Write(connFd, res)
+	})
+}
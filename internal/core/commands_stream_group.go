@@ -0,0 +1,643 @@
+package core
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spaghetti-lover/multithread-redis/internal/constant"
+	"github.com/spaghetti-lover/multithread-redis/internal/data_structure/stream"
+)
+
+func noGroupError(key, group, cmd string) error {
+	return errors.New("(error) NOGROUP No such key '" + key + "' or consumer group '" + group + "' in " + cmd + " command")
+}
+
+// lookupGroup returns the stream at key and its consumer group
+func lookupGroup(streams map[string]*stream.Stream, key, group, cmd string) (*stream.Stream, *stream.ConsumerGroup, error) {
+	s, exist := streams[key]
+	if !exist {
+		return nil, nil, noGroupError(key, group, cmd)
+	}
+	g, exist := s.Group(group)
+	if !exist {
+		return nil, nil, noGroupError(key, group, cmd)
+	}
+	return s, g, nil
+}
+
+// parseGroupID parses the ID of XGROUP CREATE and SETID, "$" being the last ID of the stream
+func parseGroupID(s *stream.Stream, arg string) (stream.StreamID, error) {
+	if arg == "$" {
+		if s == nil {
+			return stream.MinID, nil
+		}
+		return s.LastID(), nil
+	}
+	id, err := stream.ParseID(arg, 0)
+	if err != nil {
+		return stream.StreamID{}, errors.New("(error) ERR " + err.Error())
+	}
+	return id, nil
+}
+
+// parseEntriesRead parses the optional ENTRIESREAD entries-read at args[i]
+func parseEntriesRead(args []string, i int) (int64, error) {
+	if i == len(args) {
+		return stream.EntriesReadUnknown, nil
+	}
+	if i+2 != len(args) || strings.ToUpper(args[i]) != "ENTRIESREAD" {
+		return 0, errors.New("(error) ERR syntax error")
+	}
+	n, err := strconv.ParseInt(args[i+1], 10, 64)
+	if err != nil || n < stream.EntriesReadUnknown {
+		return 0, errors.New("(error) ERR value for ENTRIESREAD must be positive or -1")
+	}
+	return n, nil
+}
+
+// XGROUP CREATE key group id|$ [MKSTREAM] [ENTRIESREAD entries-read]
+// XGROUP SETID key group id|$ [ENTRIESREAD entries-read]
+// XGROUP DESTROY key group
+// XGROUP CREATECONSUMER key group consumer
+// XGROUP DELCONSUMER key group consumer
+func cmdXGROUP(args []string) []byte {
+	if len(args) < 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'XGROUP' command"), false)
+	}
+	sub := strings.ToUpper(args[0])
+	key, group := args[1], args[2]
+	now := time.Now().UnixMilli()
+
+	switch sub {
+	case "CREATE":
+		if len(args) < 4 {
+			return Encode(errors.New("(error) ERR wrong number of arguments for 'XGROUP CREATE' command"), false)
+		}
+		s, exist := streamStore[key]
+		i := 4
+		mkStream := false
+		if i < len(args) && strings.ToUpper(args[i]) == "MKSTREAM" {
+			mkStream = true
+			i++
+		}
+		entriesRead, err := parseEntriesRead(args, i)
+		if err != nil {
+			return Encode(err, false)
+		}
+		if !exist && !mkStream {
+			return Encode(errors.New("(error) ERR The XGROUP subcommand requires the key to exist. Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically."), false)
+		}
+		id, err := parseGroupID(s, args[3])
+		if err != nil {
+			return Encode(err, false)
+		}
+		if !exist {
+			s = newStream()
+		}
+		if _, err := s.CreateGroup(group, id, entriesRead); err != nil {
+			return Encode(errors.New("(error) BUSYGROUP "+err.Error()), false)
+		}
+		streamStore[key] = s
+		return constant.RespOk
+	case "SETID":
+		if len(args) < 4 {
+			return Encode(errors.New("(error) ERR wrong number of arguments for 'XGROUP SETID' command"), false)
+		}
+		entriesRead, err := parseEntriesRead(args, 4)
+		if err != nil {
+			return Encode(err, false)
+		}
+		s, g, err := lookupGroup(streamStore, key, group, "XGROUP")
+		if err != nil {
+			return Encode(err, false)
+		}
+		id, err := parseGroupID(s, args[3])
+		if err != nil {
+			return Encode(err, false)
+		}
+		s.SetGroupID(g, id, entriesRead)
+		return constant.RespOk
+	case "DESTROY":
+		if len(args) != 3 {
+			return Encode(errors.New("(error) ERR wrong number of arguments for 'XGROUP DESTROY' command"), false)
+		}
+		s, exist := streamStore[key]
+		if !exist {
+			return Encode(errors.New("(error) ERR The XGROUP subcommand requires the key to exist. Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically."), false)
+		}
+		if !s.DestroyGroup(group) {
+			return constant.RespZero
+		}
+		// Clients blocked in XREADGROUP on this group get an error
+		blocking.signalKeyAsReady(key)
+		return constant.RespOne
+	case "CREATECONSUMER", "DELCONSUMER":
+		if len(args) != 4 {
+			return Encode(errors.New("(error) ERR wrong number of arguments for 'XGROUP "+sub+"' command"), false)
+		}
+		_, g, err := lookupGroup(streamStore, key, group, "XGROUP")
+		if err != nil {
+			return Encode(err, false)
+		}
+		if sub == "CREATECONSUMER" {
+			if _, created := g.CreateConsumer(args[3], now); created {
+				return constant.RespOne
+			}
+			return constant.RespZero
+		}
+		pending, _ := g.DeleteConsumer(args[3])
+		return Encode(pending, false)
+	}
+	return Encode(errors.New("(error) ERR unknown subcommand '"+args[0]+"'"), false)
+}
+
+// xreadGroupCommand implements
+// XREADGROUP GROUP group consumer [COUNT count] [BLOCK milliseconds] [NOACK] STREAMS key [key ...] id [id ...].
+// The ID ">" reads the entries never delivered to the group, any other ID reads the history of
+// the consumer. Like xreadCommand, it returns nil when the client is blocked.
+func xreadGroupCommand(b *blockingManager, streams map[string]*stream.Stream, args []string, fd int, closed <-chan struct{}, reply func([]byte)) []byte {
+	if len(args) < 6 || strings.ToUpper(args[0]) != "GROUP" {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'XREADGROUP' command"), false)
+	}
+	group, consumerName := args[1], args[2]
+	count := 0
+	block := false
+	noAck := false
+	var deadline time.Time
+	i := 3
+	for ; i < len(args); i++ {
+		opt := strings.ToUpper(args[i])
+		if opt == "STREAMS" {
+			i++
+			break
+		}
+		if opt == "NOACK" {
+			noAck = true
+			continue
+		}
+		if i+1 >= len(args) {
+			return Encode(errors.New("(error) ERR syntax error"), false)
+		}
+		switch opt {
+		case "COUNT":
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil {
+				return Encode(errors.New("(error) ERR value is not an integer or out of range"), false)
+			}
+			count = max(n, 0)
+		case "BLOCK":
+			ms, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil {
+				return Encode(errors.New("(error) ERR timeout is not an integer or out of range"), false)
+			}
+			if ms < 0 {
+				return Encode(errors.New("(error) ERR timeout is negative"), false)
+			}
+			block = true
+			if ms > 0 && ms < math.MaxInt64/int64(time.Millisecond) {
+				deadline = time.Now().Add(time.Duration(ms) * time.Millisecond)
+			}
+		default:
+			return Encode(errors.New("(error) ERR syntax error"), false)
+		}
+		i++
+	}
+
+	rest := args[i:]
+	if len(rest) == 0 || len(rest)%2 != 0 {
+		return Encode(errors.New("(error) ERR Unbalanced 'xreadgroup' list of streams: for each stream key an ID or '>' must be specified."), false)
+	}
+	keys := rest[:len(rest)/2]
+	ids := rest[len(keys):]
+
+	// Check every key first so that nothing is delivered when one of them fails
+	history := make([]stream.StreamID, len(keys))
+	for j, key := range keys {
+		if _, _, err := lookupGroup(streams, key, group, "XREADGROUP"); err != nil {
+			return Encode(err, false)
+		}
+		if ids[j] == ">" {
+			continue
+		}
+		id, err := stream.ParseID(ids[j], 0)
+		if err != nil {
+			return Encode(errors.New("(error) ERR "+err.Error()), false)
+		}
+		history[j] = id
+	}
+
+	now := time.Now().UnixMilli()
+	res := make([]interface{}, 0)
+	onlyNew := true
+	for j, key := range keys {
+		s, g, _ := lookupGroup(streams, key, group, "XREADGROUP")
+		consumer, _ := g.CreateConsumer(consumerName, now)
+		if ids[j] != ">" {
+			onlyNew = false
+			entries := s.ReadGroupHistory(g, consumer, history[j], count, now)
+			res = append(res, []interface{}{key, streamGroupEntriesReply(entries)})
+			continue
+		}
+		if entries := s.ReadGroup(g, consumer, count, noAck, now); len(entries) > 0 {
+			res = append(res, []interface{}{key, streamEntriesReply(entries)})
+		}
+	}
+	if len(res) > 0 {
+		return Encode(res, false)
+	}
+	// Reading the history never blocks
+	if !block || !onlyNew {
+		return constant.RespNilArray
+	}
+
+	b.block(&blockedClient{
+		fd:       fd,
+		cmd:      "XREADGROUP",
+		keys:     keys,
+		group:    group,
+		consumer: consumerName,
+		noAck:    noAck,
+		count:    count,
+		deadline: deadline,
+		reply:    reply,
+		closed:   closed,
+	})
+	return nil
+}
+
+// streamGroupEntriesReply is streamEntriesReply for the history of a consumer,
+// where the entries deleted from the stream have nil fields
+func streamGroupEntriesReply(entries []stream.Entry) []interface{} {
+	res := make([]interface{}, 0, len(entries))
+	for _, e := range entries {
+		if e.Fields == nil {
+			res = append(res, []interface{}{e.ID.String(), nil})
+			continue
+		}
+		res = append(res, []interface{}{e.ID.String(), e.Fields})
+	}
+	return res
+}
+
+func cmdXREADGROUP(args []string, connFd int) []byte {
+	return xreadGroupCommand(blocking, streamStore, args, connFd, nil, func(res []byte) {
+		syscall.Write(connFd, res)
+	})
+}
+
+// XACK key group id [id ...]
+func cmdXACK(args []string) []byte {
+	if len(args) < 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'XACK' command"), false)
+	}
+	ids := make([]stream.StreamID, 0, len(args)-2)
+	for _, arg := range args[2:] {
+		id, err := stream.ParseID(arg, 0)
+		if err != nil {
+			return Encode(errors.New("(error) ERR "+err.Error()), false)
+		}
+		ids = append(ids, id)
+	}
+	_, g, err := lookupGroup(streamStore, args[0], args[1], "XACK")
+	if err != nil {
+		return constant.RespZero
+	}
+	count := 0
+	for _, id := range ids {
+		if g.Ack(id) {
+			count++
+		}
+	}
+	return Encode(count, false)
+}
+
+// XPENDING key group [[IDLE min-idle-time] start end count [consumer]]
+func cmdXPENDING(args []string) []byte {
+	if len(args) < 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'XPENDING' command"), false)
+	}
+	_, g, err := lookupGroup(streamStore, args[0], args[1], "XPENDING")
+	if err != nil {
+		return Encode(err, false)
+	}
+	now := time.Now().UnixMilli()
+
+	// Summary form
+	if len(args) == 2 {
+		if g.PendingLen() == 0 {
+			return Encode([]interface{}{0, nil, nil, nil}, false)
+		}
+		entries := g.PendingRange(stream.MinID, stream.MaxID, 0, nil, 0, now)
+		consumers := make([]interface{}, 0)
+		for _, c := range g.Consumers() {
+			if c.PendingLen() > 0 {
+				consumers = append(consumers, []string{c.Name, strconv.Itoa(c.PendingLen())})
+			}
+		}
+		return Encode([]interface{}{
+			len(entries),
+			entries[0].ID.String(),
+			entries[len(entries)-1].ID.String(),
+			consumers,
+		}, false)
+	}
+
+	// Extended form
+	rest := args[2:]
+	var minIdle int64
+	if strings.ToUpper(rest[0]) == "IDLE" {
+		if len(rest) < 2 {
+			return Encode(errors.New("(error) ERR syntax error"), false)
+		}
+		minIdle, err = strconv.ParseInt(rest[1], 10, 64)
+		if err != nil {
+			return Encode(errors.New("(error) ERR value is not an integer or out of range"), false)
+		}
+		rest = rest[2:]
+	}
+	if len(rest) != 3 && len(rest) != 4 {
+		return Encode(errors.New("(error) ERR syntax error"), false)
+	}
+	start, err := stream.ParseRangeID(rest[0], true)
+	if err != nil {
+		return Encode(errors.New("(error) ERR "+err.Error()), false)
+	}
+	end, err := stream.ParseRangeID(rest[1], false)
+	if err != nil {
+		return Encode(errors.New("(error) ERR "+err.Error()), false)
+	}
+	count, err := strconv.Atoi(rest[2])
+	if err != nil {
+		return Encode(errors.New("(error) ERR value is not an integer or out of range"), false)
+	}
+	res := make([]interface{}, 0)
+	if count <= 0 {
+		return Encode(res, false)
+	}
+	var consumer *stream.Consumer
+	if len(rest) == 4 {
+		c, exist := g.Consumer(rest[3])
+		if !exist {
+			return Encode(res, false)
+		}
+		consumer = c
+	}
+
+	for _, e := range g.PendingRange(start, end, count, consumer, minIdle, now) {
+		res = append(res, []interface{}{
+			e.ID.String(),
+			e.Consumer.Name,
+			max(now-e.DeliveryTime, 0),
+			int64(e.DeliveryCount),
+		})
+	}
+	return Encode(res, false)
+}
+
+// XCLAIM key group consumer min-idle-time id [id ...] [IDLE ms] [TIME unix-time-milliseconds]
+// [RETRYCOUNT count] [FORCE] [JUSTID] [LASTID lastid]
+func cmdXCLAIM(args []string) []byte {
+	if len(args) < 5 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'XCLAIM' command"), false)
+	}
+	minIdle, err := strconv.ParseInt(args[3], 10, 64)
+	if err != nil {
+		return Encode(errors.New("(error) ERR Invalid min-idle-time argument for XCLAIM"), false)
+	}
+	now := time.Now().UnixMilli()
+
+	ids := make([]stream.StreamID, 0)
+	i := 4
+	for ; i < len(args); i++ {
+		id, err := stream.ParseID(args[i], 0)
+		if err != nil {
+			break
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return Encode(errors.New("(error) ERR "+stream.ErrInvalidID.Error()), false)
+	}
+
+	opts := stream.ClaimOptions{DeliveryTime: now, RetryCount: -1}
+	var lastID *stream.StreamID
+	for ; i < len(args); i++ {
+		opt := strings.ToUpper(args[i])
+		switch opt {
+		case "FORCE":
+			opts.Force = true
+			continue
+		case "JUSTID":
+			opts.JustID = true
+			continue
+		}
+		if i+1 >= len(args) {
+			return Encode(errors.New("(error) ERR syntax error"), false)
+		}
+		switch opt {
+		case "IDLE", "TIME", "RETRYCOUNT":
+			n, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil {
+				return Encode(errors.New("(error) ERR Invalid "+opt+" option argument for XCLAIM"), false)
+			}
+			switch opt {
+			case "IDLE":
+				opts.DeliveryTime = now - n
+			case "TIME":
+				opts.DeliveryTime = n
+			case "RETRYCOUNT":
+				opts.RetryCount = max(n, 0)
+			}
+		case "LASTID":
+			id, err := stream.ParseID(args[i+1], 0)
+			if err != nil {
+				return Encode(errors.New("(error) ERR "+err.Error()), false)
+			}
+			lastID = &id
+		default:
+			return Encode(errors.New("(error) ERR Unrecognized XCLAIM option '"+args[i]+"'"), false)
+		}
+		i++
+	}
+	// A delivery time in the future would make the entry never idle
+	opts.DeliveryTime = min(opts.DeliveryTime, now)
+
+	s, g, err := lookupGroup(streamStore, args[0], args[1], "XCLAIM")
+	if err != nil {
+		return Encode(err, false)
+	}
+	if lastID != nil && g.LastID.Less(*lastID) {
+		g.LastID = *lastID
+	}
+	consumer, _ := g.CreateConsumer(args[2], now)
+	consumer.SeenTime = now
+
+	res := make([]interface{}, 0)
+	for _, id := range ids {
+		e, claimed, _ := s.Claim(g, consumer, id, minIdle, now, opts)
+		if !claimed {
+			continue
+		}
+		if opts.JustID {
+			res = append(res, e.ID.String())
+		} else {
+			res = append(res, []interface{}{e.ID.String(), e.Fields})
+		}
+	}
+	return Encode(res, false)
+}
+
+// XAUTOCLAIM key group consumer min-idle-time start [COUNT count] [JUSTID]
+func cmdXAUTOCLAIM(args []string) []byte {
+	if len(args) < 5 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'XAUTOCLAIM' command"), false)
+	}
+	minIdle, err := strconv.ParseInt(args[3], 10, 64)
+	if err != nil {
+		return Encode(errors.New("(error) ERR Invalid min-idle-time argument for XAUTOCLAIM"), false)
+	}
+	start, err := stream.ParseRangeID(args[4], true)
+	if err != nil {
+		return Encode(errors.New("(error) ERR "+err.Error()), false)
+	}
+	count := 100
+	justID := false
+	for i := 5; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "JUSTID":
+			justID = true
+		case "COUNT":
+			if i+1 >= len(args) {
+				return Encode(errors.New("(error) ERR syntax error"), false)
+			}
+			count, err = strconv.Atoi(args[i+1])
+			if err != nil || count < 1 || count > math.MaxInt32/10 {
+				return Encode(errors.New("(error) ERR COUNT must be > 0"), false)
+			}
+			i++
+		default:
+			return Encode(errors.New("(error) ERR syntax error"), false)
+		}
+	}
+
+	s, g, err := lookupGroup(streamStore, args[0], args[1], "XAUTOCLAIM")
+	if err != nil {
+		return Encode(err, false)
+	}
+	now := time.Now().UnixMilli()
+	consumer, _ := g.CreateConsumer(args[2], now)
+
+	next, claimed, deleted := s.AutoClaim(g, consumer, minIdle, start, count, justID, now)
+	var claimedReply []interface{}
+	if justID {
+		claimedReply = make([]interface{}, 0, len(claimed))
+		for _, e := range claimed {
+			claimedReply = append(claimedReply, e.ID.String())
+		}
+	} else {
+		claimedReply = streamEntriesReply(claimed)
+	}
+	deletedReply := make([]string, 0, len(deleted))
+	for _, id := range deleted {
+		deletedReply = append(deletedReply, id.String())
+	}
+	return Encode([]interface{}{next.String(), claimedReply, deletedReply}, false)
+}
+
+// XINFO STREAM key
+// XINFO GROUPS key
+// XINFO CONSUMERS key group
+func cmdXINFO(args []string) []byte {
+	if len(args) < 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'XINFO' command"), false)
+	}
+	sub := strings.ToUpper(args[0])
+	key := args[1]
+	now := time.Now().UnixMilli()
+
+	switch sub {
+	case "STREAM":
+		if len(args) != 2 {
+			return Encode(errors.New("(error) ERR syntax error"), false)
+		}
+		s, exist := streamStore[key]
+		if !exist {
+			return Encode(errors.New("(error) ERR no such key"), false)
+		}
+		var firstEntry, lastEntry interface{}
+		recordedFirstID := stream.MinID
+		if e, ok := s.First(); ok {
+			firstEntry = []interface{}{e.ID.String(), e.Fields}
+			recordedFirstID = e.ID
+		}
+		if e, ok := s.Last(); ok {
+			lastEntry = []interface{}{e.ID.String(), e.Fields}
+		}
+		return Encode([]interface{}{
+			"length", s.Len(),
+			"radix-tree-keys", s.Blocks(),
+			"radix-tree-nodes", s.TreeNodes(),
+			"last-generated-id", s.LastID().String(),
+			"max-deleted-entry-id", s.MaxDeletedID().String(),
+			"entries-added", int64(s.EntriesAdded()),
+			"recorded-first-entry-id", recordedFirstID.String(),
+			"groups", len(s.Groups()),
+			"first-entry", firstEntry,
+			"last-entry", lastEntry,
+		}, false)
+	case "GROUPS":
+		if len(args) != 2 {
+			return Encode(errors.New("(error) ERR syntax error"), false)
+		}
+		s, exist := streamStore[key]
+		if !exist {
+			return Encode(errors.New("(error) ERR no such key"), false)
+		}
+		res := make([]interface{}, 0)
+		for _, g := range s.Groups() {
+			var entriesRead, lag interface{}
+			if g.EntriesRead != stream.EntriesReadUnknown {
+				entriesRead = g.EntriesRead
+			}
+			if n, ok := s.Lag(g); ok {
+				lag = n
+			}
+			res = append(res, []interface{}{
+				"name", g.Name,
+				"consumers", len(g.Consumers()),
+				"pending", g.PendingLen(),
+				"last-delivered-id", g.LastID.String(),
+				"entries-read", entriesRead,
+				"lag", lag,
+			})
+		}
+		return Encode(res, false)
+	case "CONSUMERS":
+		if len(args) != 3 {
+			return Encode(errors.New("(error) ERR syntax error"), false)
+		}
+		_, g, err := lookupGroup(streamStore, key, args[2], "XINFO")
+		if err != nil {
+			return Encode(err, false)
+		}
+		res := make([]interface{}, 0)
+		for _, c := range g.Consumers() {
+			inactive := int64(-1)
+			if c.ActiveTime >= 0 {
+				inactive = max(now-c.ActiveTime, 0)
+			}
+			res = append(res, []interface{}{
+				"name", c.Name,
+				"pending", c.PendingLen(),
+				"idle", max(now-c.SeenTime, 0),
+				"inactive", inactive,
+			})
+		}
+		return Encode(res, false)
+	}
+	return Encode(errors.New("(error) ERR unknown subcommand '"+args[0]+"'"), false)
+}
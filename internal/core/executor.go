@@ -103,12 +103,236 @@ func ExecuteAndResponse(cmd *Command, connFd int) error {
 		res = cmdGET(cmd.Args)
 	case "TTL":
 		res = cmdTTL(cmd.Args)
+	case "OBJECT":
+		res = cmdOBJECT(cmd.Args)
 	case "ZADD":
 		res = cmdZADD(cmd.Args)
 	case "ZSCORE":
 		res = cmdZSCORE(cmd.Args)
 	case "ZRANK":
 		res = cmdZRANK(cmd.Args)
+	case "ZREVRANK":
+		res = cmdZREVRANK(cmd.Args)
+	case "ZRANGE":
+		res = cmdZRANGE(cmd.Args)
+	case "ZRANGESTORE":
+		res = cmdZRANGESTORE(cmd.Args)
+	case "ZCOUNT":
+		res = cmdZCOUNT(cmd.Args)
+	case "ZLEXCOUNT":
+		res = cmdZLEXCOUNT(cmd.Args)
+	case "ZINCRBY":
+		res = cmdZINCRBY(cmd.Args)
+	case "ZREM":
+		res = cmdZREM(cmd.Args)
+	case "ZCARD":
+		res = cmdZCARD(cmd.Args)
+	case "ZMSCORE":
+		res = cmdZMSCORE(cmd.Args)
+	case "ZRANDMEMBER":
+		res = cmdZRANDMEMBER(cmd.Args)
+	case "ZREMRANGEBYRANK":
+		res = cmdZREMRANGEBYRANK(cmd.Args)
+	case "ZREMRANGEBYSCORE":
+		res = cmdZREMRANGEBYSCORE(cmd.Args)
+	case "ZREMRANGEBYLEX":
+		res = cmdZREMRANGEBYLEX(cmd.Args)
+	case "ZUNION", "ZINTER", "ZDIFF", "ZUNIONSTORE", "ZINTERSTORE", "ZDIFFSTORE":
+		res = cmdZsetOp(cmd.Cmd, cmd.Args)
+	case "ZINTERCARD":
+		res = cmdZINTERCARD(cmd.Args)
+	case "ZPOPMIN":
+		res = cmdZPOPMIN(cmd.Args)
+	case "ZPOPMAX":
+		res = cmdZPOPMAX(cmd.Args)
+	case "ZMPOP":
+		res = cmdZMPOP(cmd.Args)
+	case "BZPOPMIN", "BZPOPMAX":
+		res = cmdBlockingPop(cmd.Cmd, cmd.Args, connFd)
+	// Geospatial
+	case "GEOADD":
+		res = cmdGEOADD(cmd.Args)
+	case "GEOPOS":
+		res = cmdGEOPOS(cmd.Args)
+	case "GEODIST":
+		res = cmdGEODIST(cmd.Args)
+	case "GEOHASH":
+		res = cmdGEOHASH(cmd.Args)
+	case "GEOSEARCH":
+		res = cmdGEOSEARCH(cmd.Args)
+	case "GEOSEARCHSTORE":
+		res = cmdGEOSEARCHSTORE(cmd.Args)
+	// List
+	case "LPUSH":
+		res = cmdLPUSH(cmd.Args)
+	case "RPUSH":
+		res = cmdRPUSH(cmd.Args)
+	case "LPOP":
+		res = cmdLPOP(cmd.Args)
+	case "RPOP":
+		res = cmdRPOP(cmd.Args)
+	case "LLEN":
+		res = cmdLLEN(cmd.Args)
+	case "LRANGE":
+		res = cmdLRANGE(cmd.Args)
+	case "LMOVE":
+		res = cmdLMOVE(cmd.Args)
+	case "BLPOP", "BRPOP", "BLMOVE":
+		res = cmdBlockingPop(cmd.Cmd, cmd.Args, connFd)
+	// Hash
+	case "HSET":
+		res = cmdHSET(cmd.Args)
+	case "HSETNX":
+		res = cmdHSETNX(cmd.Args)
+	case "HGET":
+		res = cmdHGET(cmd.Args)
+	case "HMGET":
+		res = cmdHMGET(cmd.Args)
+	case "HDEL":
+		res = cmdHDEL(cmd.Args)
+	case "HEXISTS":
+		res = cmdHEXISTS(cmd.Args)
+	case "HLEN":
+		res = cmdHLEN(cmd.Args)
+	case "HKEYS":
+		res = cmdHKEYS(cmd.Args)
+	case "HVALS":
+		res = cmdHVALS(cmd.Args)
+	case "HGETALL":
+		res = cmdHGETALL(cmd.Args)
+	case "HINCRBY":
+		res = cmdHINCRBY(cmd.Args)
+	case "HINCRBYFLOAT":
+		res = cmdHINCRBYFLOAT(cmd.Args)
+	case "HSTRLEN":
+		res = cmdHSTRLEN(cmd.Args)
+	case "HRANDFIELD":
+		res = cmdHRANDFIELD(cmd.Args)
+	case "HSCAN":
+		res = cmdHSCAN(cmd.Args)
+	case "HEXPIRE":
+		res = cmdHEXPIRE(cmd.Args)
+	case "HPEXPIRE":
+		res = cmdHPEXPIRE(cmd.Args)
+	case "HEXPIREAT":
+		res = cmdHEXPIREAT(cmd.Args)
+	case "HPEXPIREAT":
+		res = cmdHPEXPIREAT(cmd.Args)
+	case "HTTL":
+		res = cmdHTTL(cmd.Args)
+	case "HPTTL":
+		res = cmdHPTTL(cmd.Args)
+	case "HEXPIRETIME":
+		res = cmdHEXPIRETIME(cmd.Args)
+	case "HPEXPIRETIME":
+		res = cmdHPEXPIRETIME(cmd.Args)
+	case "HPERSIST":
+		res = cmdHPERSIST(cmd.Args)
+	// Stream
+	case "XADD":
+		res = cmdXADD(cmd.Args)
+	case "XRANGE":
+		res = cmdXRANGE(cmd.Args)
+	case "XREVRANGE":
+		res = cmdXREVRANGE(cmd.Args)
+	case "XLEN":
+		res = cmdXLEN(cmd.Args)
+	case "XDEL":
+		res = cmdXDEL(cmd.Args)
+	case "XTRIM":
+		res = cmdXTRIM(cmd.Args)
+	case "XREAD":
+		res = cmdXREAD(cmd.Args, connFd)
+	case "XGROUP":
+		res = cmdXGROUP(cmd.Args)
+	case "XREADGROUP":
+		res = cmdXREADGROUP(cmd.Args, connFd)
+	case "XACK":
+		res = cmdXACK(cmd.Args)
+	case "XPENDING":
+		res = cmdXPENDING(cmd.Args)
+	case "XCLAIM":
+		res = cmdXCLAIM(cmd.Args)
+	case "XAUTOCLAIM":
+		res = cmdXAUTOCLAIM(cmd.Args)
+	case "XINFO":
+		res = cmdXINFO(cmd.Args)
+	case "JSON.SET":
+		res = cmdJSONSET(cmd.Args)
+	case "JSON.GET":
+		res = cmdJSONGET(cmd.Args)
+	case "JSON.MGET":
+		res = cmdJSONMGET(cmd.Args)
+	case "JSON.DEL", "JSON.FORGET":
+		res = cmdJSONDEL(cmd.Cmd, cmd.Args)
+	case "JSON.TYPE":
+		res = cmdJSONTYPE(cmd.Args)
+	case "JSON.NUMINCRBY":
+		res = cmdJSONNUMINCRBY(cmd.Args)
+	case "JSON.STRAPPEND":
+		res = cmdJSONSTRAPPEND(cmd.Args)
+	case "JSON.ARRAPPEND":
+		res = cmdJSONARRAPPEND(cmd.Args)
+	case "JSON.ARRINSERT":
+		res = cmdJSONARRINSERT(cmd.Args)
+	case "JSON.ARRLEN":
+		res = cmdJSONARRLEN(cmd.Args)
+	case "JSON.OBJKEYS":
+		res = cmdJSONOBJKEYS(cmd.Args)
+	case "FT.CREATE":
+		res = cmdFTCREATE(cmd.Args)
+	case "FT.SEARCH":
+		res = cmdFTSEARCH(cmd.Args)
+	case "FT.INFO":
+		res = cmdFTINFO(cmd.Args)
+	case "FT.DROPINDEX":
+		res = cmdFTDROPINDEX(cmd.Args)
+	case "FT._LIST":
+		res = cmdFTLIST(cmd.Args)
+	case "VADD":
+		res = cmdVADD(cmd.Args)
+	case "VREM":
+		res = cmdVREM(cmd.Args)
+	case "VSIM":
+		res = cmdVSIM(cmd.Args)
+	case "VCARD":
+		res = cmdVCARD(cmd.Args)
+	case "VDIM":
+		res = cmdVDIM(cmd.Args)
+	case "VISMEMBER":
+		res = cmdVISMEMBER(cmd.Args)
+	case "VEMB":
+		res = cmdVEMB(cmd.Args)
+	case "VGETATTR":
+		res = cmdVGETATTR(cmd.Args)
+	case "VSETATTR":
+		res = cmdVSETATTR(cmd.Args)
+	case "VINFO":
+		res = cmdVINFO(cmd.Args)
+	case "TS.CREATE":
+		res = cmdTSCREATE(cmd.Args)
+	case "TS.ADD":
+		res = cmdTSADD(cmd.Args)
+	case "TS.MADD":
+		res = cmdTSMADD(cmd.Args)
+	case "TS.INCRBY":
+		res = cmdTSINCRBY(cmd.Args)
+	case "TS.GET":
+		res = cmdTSGET(cmd.Args)
+	case "TS.DEL":
+		res = cmdTSDEL(cmd.Args)
+	case "TS.RANGE":
+		res = cmdTSRANGE(cmd.Args)
+	case "TS.REVRANGE":
+		res = cmdTSREVRANGE(cmd.Args)
+	case "TS.MRANGE":
+		res = cmdTSMRANGE(cmd.Args)
+	case "TS.CREATERULE":
+		res = cmdTSCREATERULE(cmd.Args)
+	case "TS.DELETERULE":
+		res = cmdTSDELETERULE(cmd.Args)
+	case "TS.INFO":
+		res = cmdTSINFO(cmd.Args)
 	case "SADD":
 		res = cmdSADD(cmd.Args)
 	case "SREM":
@@ -117,6 +341,20 @@ func ExecuteAndResponse(cmd *Command, connFd int) error {
 		res = cmdSMEMBERS(cmd.Args)
 	case "SISMEMBER":
 		res = cmdSISMEMBER(cmd.Args)
+	case "SMISMEMBER":
+		res = cmdSMISMEMBER(cmd.Args)
+	case "SCARD":
+		res = cmdSCARD(cmd.Args)
+	case "SPOP":
+		res = cmdSPOP(cmd.Args)
+	case "SRANDMEMBER":
+		res = cmdSRANDMEMBER(cmd.Args)
+	case "SMOVE":
+		res = cmdSMOVE(cmd.Args)
+	case "SUNION", "SINTER", "SDIFF", "SUNIONSTORE", "SINTERSTORE", "SDIFFSTORE":
+		res = cmdSetOp(cmd.Cmd, cmd.Args)
+	case "SINTERCARD":
+		res = cmdSINTERCARD(cmd.Args)
 	// Count-min Sketch
 	case "CMS.INITBYDIM":
 		res = cmdCMSINITBYDIM(cmd.Args)
@@ -126,6 +364,88 @@ func ExecuteAndResponse(cmd *Command, connFd int) error {
 		res = cmdCMSINCRBY(cmd.Args)
 	case "CMS.QUERY":
 		res = cmdCMSQUERY(cmd.Args)
+	case "CMS.MERGE":
+		res = cmdCMSMERGE(cmd.Args)
+	case "CMS.INFO":
+		res = cmdCMSINFO(cmd.Args)
+	case "CMS.SCANDUMP":
+		res = cmdCMSSCANDUMP(cmd.Args)
+	case "CMS.LOADCHUNK":
+		res = cmdCMSLOADCHUNK(cmd.Args)
+	case "BF.RESERVE":
+		res = cmdBFRESERVE(cmd.Args)
+	case "BF.ADD":
+		res = cmdBFADD(cmd.Args)
+	case "BF.MADD":
+		res = cmdBFMADD(cmd.Args)
+	case "BF.INSERT":
+		res = cmdBFINSERT(cmd.Args)
+	case "BF.EXISTS":
+		res = cmdBFEXISTS(cmd.Args)
+	case "BF.MEXISTS":
+		res = cmdBFMEXISTS(cmd.Args)
+	case "BF.CARD":
+		res = cmdBFCARD(cmd.Args)
+	case "BF.INFO":
+		res = cmdBFINFO(cmd.Args)
+	case "BF.SCANDUMP":
+		res = cmdBFSCANDUMP(cmd.Args)
+	case "BF.LOADCHUNK":
+		res = cmdBFLOADCHUNK(cmd.Args)
+	case "CF.RESERVE":
+		res = cmdCFRESERVE(cmd.Args)
+	case "CF.ADD":
+		res = cfAddGeneric(cmd.Cmd, cmd.Args, false)
+	case "CF.ADDNX":
+		res = cfAddGeneric(cmd.Cmd, cmd.Args, true)
+	case "CF.INSERT":
+		res = cfInsertGeneric(cmd.Cmd, cmd.Args, false)
+	case "CF.INSERTNX":
+		res = cfInsertGeneric(cmd.Cmd, cmd.Args, true)
+	case "CF.EXISTS":
+		res = cmdCFEXISTS(cmd.Args)
+	case "CF.MEXISTS":
+		res = cmdCFMEXISTS(cmd.Args)
+	case "CF.DEL":
+		res = cmdCFDEL(cmd.Args)
+	case "CF.COUNT":
+		res = cmdCFCOUNT(cmd.Args)
+	case "CF.INFO":
+		res = cmdCFINFO(cmd.Args)
+	case "TOPK.RESERVE":
+		res = cmdTOPKRESERVE(cmd.Args)
+	case "TOPK.ADD":
+		res = cmdTOPKADD(cmd.Args)
+	case "TOPK.INCRBY":
+		res = cmdTOPKINCRBY(cmd.Args)
+	case "TOPK.QUERY":
+		res = cmdTOPKQUERY(cmd.Args)
+	case "TOPK.COUNT":
+		res = cmdTOPKCOUNT(cmd.Args)
+	case "TOPK.LIST":
+		res = cmdTOPKLIST(cmd.Args)
+	case "TOPK.INFO":
+		res = cmdTOPKINFO(cmd.Args)
+	case "TDIGEST.CREATE":
+		res = cmdTDIGESTCREATE(cmd.Args)
+	case "TDIGEST.ADD":
+		res = cmdTDIGESTADD(cmd.Args)
+	case "TDIGEST.QUANTILE":
+		res = cmdTDIGESTQUANTILE(cmd.Args)
+	case "TDIGEST.CDF":
+		res = cmdTDIGESTCDF(cmd.Args)
+	case "TDIGEST.RANK":
+		res = cmdTDIGESTRANK(cmd.Args)
+	case "TDIGEST.TRIMMED_MEAN":
+		res = cmdTDIGESTTRIMMEDMEAN(cmd.Args)
+	case "TDIGEST.MIN", "TDIGEST.MAX":
+		res = tdigestMinMax(cmd.Cmd, cmd.Args)
+	case "TDIGEST.MERGE":
+		res = cmdTDIGESTMERGE(cmd.Args)
+	case "TDIGEST.RESET":
+		res = cmdTDIGESTRESET(cmd.Args)
+	case "TDIGEST.INFO":
+		res = cmdTDIGESTINFO(cmd.Args)
 	// INFO
 	case "INFO":
 		res = cmdINFO(cmd.Args)
@@ -135,6 +455,12 @@ func ExecuteAndResponse(cmd *Command, connFd int) error {
 		res = []byte("-CMD NOT FOUND\r\n")
 	}
 
+	// A blocked client gets its reply later, when a key is ready or the timeout expires
+	if res == nil {
+		return nil
+	}
+
 	_, err := syscall.Write(connFd, res)
+	blocking.handleReadyKeys()
 	return err
 }
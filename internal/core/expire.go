@@ -26,4 +26,6 @@ func ActiveDeleteExpiredKeys() {
 			break
 		}
 	}
+
+	activeDeleteExpiredHashFields()
 }
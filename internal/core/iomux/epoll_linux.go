@@ -7,6 +7,7 @@ import (
 	"syscall"
 
 	"github.com/spaghetti-lover/multithread-redis/internal/config"
+	"github.com/spaghetti-lover/multithread-redis/internal/constant"
 )
 
 type Epoll struct {
@@ -39,8 +40,10 @@ func (ep *Epoll) Unmonitor(event Event) error {
 	return syscall.EpollCtl(ep.fd, syscall.EPOLL_CTL_DEL, event.Fd, nil)
 }
 
+// Wait returns the ready events. It wakes up at least every BlockedClientsTimeoutFrequency
+// so that the event loop can run its time events (active expiry, blocked clients timeout).
 func (ep *Epoll) Wait() ([]Event, error) {
-	n, err := syscall.EpollWait(ep.fd, ep.epollEvents, -1)
+	n, err := syscall.EpollWait(ep.fd, ep.epollEvents, int(constant.BlockedClientsTimeoutFrequency.Milliseconds()))
 	if err != nil {
 		return nil, err
 	}
@@ -12,6 +12,7 @@ type Event struct {
 
 type IOMultiplexer interface {
 	Monitor(event Event) error
+	Unmonitor(event Event) error
 	Wait() ([]Event, error)
 	Close() error
 }
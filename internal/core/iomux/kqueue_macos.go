@@ -7,6 +7,7 @@ import (
 	"syscall"
 
 	"github.com/spaghetti-lover/multithread-redis/internal/config"
+	"github.com/spaghetti-lover/multithread-redis/internal/constant"
 )
 
 type KQueue struct {
@@ -43,8 +44,11 @@ func (kq *KQueue) Unmonitor(event Event) error {
 	return err
 }
 
+// Wait returns the ready events. It wakes up at least every BlockedClientsTimeoutFrequency
+// so that the event loop can run its time events (active expiry, blocked clients timeout).
 func (kq *KQueue) Wait() ([]Event, error) {
-	n, err := syscall.Kevent(kq.fd, nil, kq.kqEvents, nil)
+	timeout := syscall.NsecToTimespec(constant.BlockedClientsTimeoutFrequency.Nanoseconds())
+	n, err := syscall.Kevent(kq.fd, nil, kq.kqEvents, &timeout)
 	if err != nil {
 		return nil, err
 	}
@@ -4,88 +4,116 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
+
+	"github.com/spaghetti-lover/multithread-redis/internal/constant"
 )
 
 const CRLF string = "\r\n"
 
 var RespNil = []byte("$-1\r\n")
 
+var (
+	// ErrIncomplete is returned when the data ends before the value does, more data is needed
+	ErrIncomplete = errors.New("incomplete RESP data")
+	// ErrProtocol is returned for malformed data, which more data cannot fix
+	ErrProtocol = errors.New("Protocol error")
+)
+
+// readLine returns the line at the start of data without its CRLF, and the position after it
+func readLine(data []byte) ([]byte, int, error) {
+	end := bytes.Index(data, []byte(CRLF))
+	if end == -1 {
+		return nil, 0, ErrIncomplete
+	}
+	return data[:end], end + 2, nil
+}
+
 // +OK\r\n => OK, 5
 func readSimpleString(data []byte) (string, int, error) {
-	pos := 1
-	for data[pos] != '\r' {
-		pos += 1
+	line, pos, err := readLine(data[1:])
+	if err != nil {
+		return "", 0, err
 	}
-	return string(data[1:pos]), pos + 2, nil
+	return string(line), pos + 1, nil
 }
 
 // :123\r\n => 123
 func readInt64(data []byte) (int64, int, error) {
-	var res int64 = 0
-	var sign int64 = 1
-
-	pos := 1
-
-	if data[pos] == '-' {
-		sign = -1
-		pos += 1
-	}
-
-	if data[pos] == '+' {
-		sign = 1
-		pos += 1
+	line, pos, err := readLine(data[1:])
+	if err != nil {
+		return 0, 0, err
 	}
-
-	for data[pos] != '\r' {
-		res = res*10 + int64(data[pos]-'0')
-		pos += 1
+	res, err := strconv.ParseInt(string(line), 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: invalid integer", ErrProtocol)
 	}
-
-	return res * sign, pos + 2, nil
+	return res, pos + 1, nil
 }
 
 func readError(data []byte) (string, int, error) {
 	return readSimpleString(data)
 }
 
-// $5\r\nhello\r\n => 5, 4
-func readLen(data []byte) (int, int) {
-	res, pos, _ := readInt64(data)
-	return int(res), pos
+// $5\r\nhello\r\n => 5, 4. The length is -1 for a null value and cannot exceed the size
+// of a command.
+func readLen(data []byte) (int, int, error) {
+	res, pos, err := readInt64(data)
+	if err != nil {
+		return 0, 0, err
+	}
+	if res < -1 || res > int64(constant.MaxQueryBufferSize) {
+		return 0, 0, fmt.Errorf("%w: invalid length", ErrProtocol)
+	}
+	return int(res), pos, nil
 }
 
 // $5\r\nhello\r\n => "hello"
 func readBulkString(data []byte) (string, int, error) {
-	length, pos := readLen(data)
+	length, pos, err := readLen(data)
+	if err != nil {
+		return "", 0, err
+	}
 	if length == -1 {
-		return "Null value", pos + 2, nil
+		return "Null value", pos, nil
+	}
+	if len(data) < pos+length+2 {
+		return "", 0, ErrIncomplete
+	}
+	if string(data[pos+length:pos+length+2]) != CRLF {
+		return "", 0, fmt.Errorf("%w: invalid bulk string", ErrProtocol)
 	}
 	return string(data[pos:(pos + length)]), pos + length + 2, nil
 }
 
 // *2\r\n$5\r\nhello\r\n$5\r\nworld\r\n => {"hello", "world"}
 func readArray(data []byte) (interface{}, int, error) {
-	length, pos := readLen(data)
-	var res []interface{} = make([]interface{}, length)
-
-	// implement start
-	for i := range res {
+	length, pos, err := readLen(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	if length == -1 {
+		return nil, pos, nil
+	}
+	// Every element takes at least a byte, the length is not trusted further than that
+	res := make([]interface{}, 0, min(length, len(data)))
+	for i := 0; i < length; i++ {
 		elem, delta, err := DecodeOne(data[pos:])
 		if err != nil {
 			return nil, 0, err
 		}
-		res[i] = elem
+		res = append(res, elem)
 		pos += delta
 	}
-
-	// implement end
 	return res, pos, nil
 }
 
+// DecodeOne decodes the value at the start of data and returns the number of bytes it takes.
+// It fails with ErrIncomplete if data ends before the value, or ErrProtocol if it is malformed.
 func DecodeOne(data []byte) (interface{}, int, error) {
 	if len(data) == 0 {
-		return nil, 0, errors.New("no data")
+		return nil, 0, ErrIncomplete
 	}
 	switch data[0] {
 	case '+':
@@ -99,7 +127,7 @@ func DecodeOne(data []byte) (interface{}, int, error) {
 	case '*':
 		return readArray(data)
 	}
-	return fmt.Sprintf("unknown prefix: %c", data[0]), 0, nil
+	return nil, 0, fmt.Errorf("%w: unknown prefix %q", ErrProtocol, data[0])
 }
 
 func Decode(data []byte) (interface{}, error) {
@@ -152,24 +180,34 @@ func Encode(value interface{}, isSimpleString bool) []byte {
 	}
 }
 
-func ParseCmd(data []byte) (*Command, error) {
-	if len(data) == 0 {
-		return nil, fmt.Errorf("empty data")
-	}
-
-	value, err := Decode(data)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode RESP: %v", err)
-	}
-
+// DecodeCmd decodes the command at the start of data and returns the number of bytes it
+// takes, so that the commands pipelined after it can be decoded next. A command is a RESP
+// array, or an inline command: a line of words separated by spaces. It fails with
+// ErrIncomplete if data ends before the command.
+func DecodeCmd(data []byte) (*Command, int, error) {
+	pos := 0
 	var tokens []string
-
-	switch v := value.(type) {
-	case nil:
-		tokens = []string{}
-	case []interface{}:
-		tokens = make([]string, len(v))
-		for i, e := range v {
+	for len(tokens) == 0 {
+		if pos == len(data) {
+			return nil, 0, ErrIncomplete
+		}
+		if data[pos] != '*' {
+			end := bytes.IndexByte(data[pos:], '\n')
+			if end == -1 {
+				return nil, 0, ErrIncomplete
+			}
+			tokens = strings.Fields(string(data[pos : pos+end]))
+			pos += end + 1
+			continue
+		}
+		value, n, err := DecodeOne(data[pos:])
+		if err != nil {
+			return nil, 0, err
+		}
+		pos += n
+		elems, _ := value.([]interface{})
+		tokens = make([]string, len(elems))
+		for i, e := range elems {
 			switch t := e.(type) {
 			case string:
 				tokens[i] = t
@@ -179,16 +217,18 @@ func ParseCmd(data []byte) (*Command, error) {
 				tokens[i] = fmt.Sprint(t)
 			}
 		}
-	case string:
-		tokens = strings.Fields(v)
-	default:
-		tokens = []string{fmt.Sprint(v)}
 	}
+	return &Command{Cmd: strings.ToUpper(tokens[0]), Args: tokens[1:]}, pos, nil
+}
 
-	if len(tokens) == 0 {
-		return nil, fmt.Errorf("no command found")
+// ParseCmd decodes the first command of data
+func ParseCmd(data []byte) (*Command, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty data")
 	}
-
-	res := &Command{Cmd: strings.ToUpper(tokens[0]), Args: tokens[1:]}
-	return res, nil
+	cmd, _, err := DecodeCmd(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode RESP: %w", err)
+	}
+	return cmd, nil
 }
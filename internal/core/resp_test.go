@@ -95,3 +95,28 @@ func TestEncodeString2DArray(t *testing.T) {
 		}
 	}
 }
+
+func TestDecodeIncomplete(t *testing.T) {
+	full := "*3\r\n$3\r\nset\r\n:12\r\n+OK\r\n"
+	for i := 0; i < len(full); i++ {
+		_, err := core.Decode([]byte(full[:i]))
+		assert.ErrorIs(t, err, core.ErrIncomplete, full[:i])
+	}
+	value, n, err := core.DecodeOne([]byte(full + "*1\r\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, len(full), n)
+	assert.Equal(t, []interface{}{"set", int64(12), "OK"}, value)
+}
+
+func TestDecodeCmd(t *testing.T) {
+	data := []byte("*2\r\n$3\r\nget\r\n$1\r\nk\r\n\r\n ping  hello \r\n*1\r\n$4\r\nPI")
+	cmd, n, err := core.DecodeCmd(data)
+	assert.NoError(t, err)
+	assert.Equal(t, &core.Command{Cmd: "GET", Args: []string{"k"}}, cmd)
+	data = data[n:]
+	cmd, n, err = core.DecodeCmd(data)
+	assert.NoError(t, err)
+	assert.Equal(t, &core.Command{Cmd: "PING", Args: []string{"hello"}}, cmd)
+	_, _, err = core.DecodeCmd(data[n:])
+	assert.ErrorIs(t, err, core.ErrIncomplete)
+}
@@ -1,20 +1,59 @@
 package core
 
 import (
+	"github.com/spaghetti-lover/multithread-redis/internal/data_structure/hash_map"
 	"github.com/spaghetti-lover/multithread-redis/internal/data_structure/hash_table"
+	"github.com/spaghetti-lover/multithread-redis/internal/data_structure/json_doc"
 	"github.com/spaghetti-lover/multithread-redis/internal/data_structure/probabilistic"
+	"github.com/spaghetti-lover/multithread-redis/internal/data_structure/quick_list"
+	"github.com/spaghetti-lover/multithread-redis/internal/data_structure/search"
 	"github.com/spaghetti-lover/multithread-redis/internal/data_structure/simple_set"
 	"github.com/spaghetti-lover/multithread-redis/internal/data_structure/sorted_set"
+	"github.com/spaghetti-lover/multithread-redis/internal/data_structure/stream"
+	"github.com/spaghetti-lover/multithread-redis/internal/data_structure/time_series"
+	"github.com/spaghetti-lover/multithread-redis/internal/data_structure/vector_set"
 )
 
 var dictStore *hash_table.Dict
 var zsetStore map[string]*sorted_set.SortedSet
 var setStore map[string]*simple_set.SimpleSet
 var cmsStore map[string]probabilistic.FrequencyEstimator
+var bfStore map[string]*bloomFilter
+var cfStore map[string]*probabilistic.CuckooFilter
+var topkStore map[string]*probabilistic.TopK
+var tdigestStore map[string]*probabilistic.TDigest
+var listStore map[string]*quick_list.QuickList
+var hashStore map[string]*hash_map.Hash
+var streamStore map[string]*stream.Stream
+var jsonStore map[string]*json_doc.Document
+var vsetStore map[string]*vector_set.Set
+var tsStore map[string]*time_series.Series
+
+// searchIndexStore holds the secondary indexes over hashes, by index name
+var searchIndexStore map[string]*search.Index
+
+// hashFieldExpireKeys holds the keys of the hashes having fields with a TTL, for active expiry
+var hashFieldExpireKeys map[string]struct{}
+
+// blocking tracks the clients parked by blocking commands in the single-threaded server
+var blocking *blockingManager
 
 func init() {
 	dictStore = hash_table.CreateDict()
 	zsetStore = make(map[string]*sorted_set.SortedSet)
 	setStore = make(map[string]*simple_set.SimpleSet)
 	cmsStore = make(map[string]probabilistic.FrequencyEstimator)
+	bfStore = make(map[string]*bloomFilter)
+	cfStore = make(map[string]*probabilistic.CuckooFilter)
+	topkStore = make(map[string]*probabilistic.TopK)
+	tdigestStore = make(map[string]*probabilistic.TDigest)
+	listStore = make(map[string]*quick_list.QuickList)
+	hashStore = make(map[string]*hash_map.Hash)
+	streamStore = make(map[string]*stream.Stream)
+	jsonStore = make(map[string]*json_doc.Document)
+	vsetStore = make(map[string]*vector_set.Set)
+	tsStore = make(map[string]*time_series.Series)
+	searchIndexStore = make(map[string]*search.Index)
+	hashFieldExpireKeys = make(map[string]struct{})
+	blocking = newBlockingManager(listStore, zsetStore, streamStore)
 }
@@ -0,0 +1,33 @@
+package core
+
+import "testing"
+
+func TestStringMatch(t *testing.T) {
+	cases := []struct {
+		pattern string
+		str     string
+		match   bool
+	}{
+		{"*", "anything", true},
+		{"user:*", "user:42", true},
+		{"user:*", "order:42", false},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-c]llo", "hbllo", true},
+		{"h[a-c]llo", "hdllo", false},
+		{"h\\*llo", "h*llo", true},
+		{"h\\*llo", "hello", false},
+		{"*:*:end", "a:b:end", true},
+		{"", "", true},
+		{"", "a", false},
+	}
+	for _, c := range cases {
+		if got := stringMatch(c.pattern, c.str); got != c.match {
+			t.Errorf("stringMatch(%q, %q) = %v, want %v", c.pattern, c.str, got, c.match)
+		}
+	}
+}
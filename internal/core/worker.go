@@ -6,19 +6,32 @@ import (
 	"log"
 	"strconv"
 	"sync"
+	"time"
 
 	"github.com/spaghetti-lover/multithread-redis/internal/constant"
 	"github.com/spaghetti-lover/multithread-redis/internal/data_structure/hash_table"
+	"github.com/spaghetti-lover/multithread-redis/internal/data_structure/quick_list"
+	"github.com/spaghetti-lover/multithread-redis/internal/data_structure/sorted_set"
 )
 
 type Task struct {
 	Command *Command
 	ReplyCh chan []byte // Channel to send the result back to the client's handler
+	// Closed when the client's connection goes away, so a blocked client is not served anymore
+	ConnClosed <-chan struct{}
+	// Inputs holds copies of the sorted sets owned by other workers that a sorted set
+	// operation reads (ZUNIONSTORE, ...), gathered by the server before dispatching it
+	Inputs map[string]map[string]float64
+	// exec runs on the worker goroutine in place of a command
+	exec func()
 }
 
 type Worker struct {
 	id        int
 	dictStore *hash_table.Dict
+	listStore map[string]*quick_list.QuickList
+	zsetStore map[string]*sorted_set.SortedSet
+	blocking  *blockingManager   // Clients blocked on keys owned by this worker
 	TaskCh    chan *Task         // Receives tasks from the I/O handler
 	ctx       context.Context    // Use context to manage goroutine
 	cancel    context.CancelFunc // Set `Context` object's internal state to `canceled`. It closes the `Done()` channel of that Context
@@ -29,11 +42,14 @@ func NewWorker(id int, bufferSize int) *Worker {
 	w := &Worker{
 		id:        id,
 		dictStore: hash_table.CreateDict(),
+		listStore: make(map[string]*quick_list.QuickList),
+		zsetStore: make(map[string]*sorted_set.SortedSet),
 		TaskCh:    make(chan *Task, bufferSize),
 		ctx:       context.Background(),
 		cancel:    nil,
 		waitGroup: &sync.WaitGroup{},
 	}
+	w.blocking = newBlockingManager(w.listStore, w.zsetStore, nil)
 	return w
 }
 
@@ -117,16 +133,93 @@ func (w *Worker) ExecuteAndResponse(task *Task) {
 		res = w.cmdGET(task.Command.Args)
 	case "PING":
 		res = w.cmdPING(task.Command.Args)
+	case "LPUSH", "RPUSH":
+		res = pushGenericCommand(w.listStore, w.blocking, task.Command.Cmd, task.Command.Args)
+	case "LPOP", "RPOP":
+		res = popGenericCommand(w.listStore, task.Command.Cmd, task.Command.Args)
+	case "LLEN":
+		res = llenCommand(w.listStore, task.Command.Args)
+	case "LRANGE":
+		res = lrangeCommand(w.listStore, task.Command.Args)
+	case "LMOVE":
+		res = lmoveCommand(w.blocking, task.Command.Args)
+	case "ZADD":
+		res = zaddGeneric(w.zsetStore, w.blocking, task.Command.Args)
+	case "ZPOPMIN", "ZPOPMAX":
+		res = zpopGenericCommand(w.zsetStore, task.Command.Cmd, task.Command.Args)
+	case "ZMPOP":
+		res = zmpopCommand(w.zsetStore, task.Command.Args)
+	case "ZUNION", "ZINTER", "ZDIFF", "ZUNIONSTORE", "ZINTERSTORE", "ZDIFFSTORE":
+		res = zsetOpGeneric(w.zsetStore, w.blocking, w.zsetSource(task.Inputs), task.Command.Cmd, task.Command.Args)
+	case "ZINTERCARD":
+		res = zintercardGeneric(w.zsetSource(task.Inputs), task.Command.Args)
+	case "BLPOP", "BRPOP", "BLMOVE", "BZPOPMIN", "BZPOPMAX":
+		res = w.cmdBlockingPop(task)
 	default:
 		res = []byte("-CMD NOT FOUND\r\n")
 	}
-	task.ReplyCh <- res
+
+	// A blocked task is answered later through its ReplyCh
+	if res != nil {
+		task.ReplyCh <- res
+	}
+	w.blocking.handleReadyKeys()
+}
+
+// cmdBlockingPop parks the task in the worker when none of its keys has data.
+// ReplyCh is buffered so replying from the worker goroutine never blocks it.
+func (w *Worker) cmdBlockingPop(task *Task) []byte {
+	return blockingPopGenericCommand(w.blocking, task.Command.Cmd, task.Command.Args, -1, task.ConnClosed, func(res []byte) {
+		task.ReplyCh <- res
+	})
+}
+
+// zsetSource reads the inputs of a sorted set operation from the copies gathered from
+// other workers, then from the sorted sets of this worker
+func (w *Worker) zsetSource(inputs map[string]map[string]float64) zsetSource {
+	return func(key string) (map[string]float64, bool) {
+		if scores, exist := inputs[key]; exist {
+			return scores, true
+		}
+		if zset, exist := w.zsetStore[key]; exist {
+			return zset.Scores(), true
+		}
+		return nil, false
+	}
+}
+
+// SnapshotZsets requests copies of the members and scores of the sorted sets stored at keys
+// on this worker. The copies are made on the worker goroutine and sent on the returned channel.
+func (w *Worker) SnapshotZsets(keys []string) <-chan map[string]map[string]float64 {
+	done := make(chan map[string]map[string]float64, 1)
+	w.TaskCh <- &Task{exec: func() {
+		res := make(map[string]map[string]float64, len(keys))
+		for _, key := range keys {
+			zset, exist := w.zsetStore[key]
+			if !exist {
+				continue
+			}
+			scores := make(map[string]float64, zset.Len())
+			for member, score := range zset.Scores() {
+				scores[member] = score
+			}
+			res[key] = scores
+		}
+		done <- res
+	}}
+	return done
 }
 
 func (w *Worker) run(ctx context.Context) {
 	defer w.waitGroup.Done()
+	// Check timeouts of blocked clients periodically
+	ticker := time.NewTicker(constant.BlockedClientsTimeoutFrequency)
+	defer ticker.Stop()
 	for {
 		select {
+		case now := <-ticker.C:
+			w.blocking.expireTimeouts(now)
+
 		case <-ctx.Done():
 			log.Printf("Worker %d shutting down gracefully", w.id)
 			return
@@ -136,6 +229,10 @@ func (w *Worker) run(ctx context.Context) {
 				log.Printf("Worker %d channel closed, shutting down", w.id)
 				return
 			}
+			if task.exec != nil {
+				task.exec()
+				continue
+			}
 			w.ExecuteAndResponse(task)
 		}
 
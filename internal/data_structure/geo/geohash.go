@@ -0,0 +1,348 @@
+package geo
+
+import (
+	"math"
+)
+
+// Limits of the Web Mercator projection used by the geohash scores, like in Redis
+const (
+	LatMin = -85.05112878
+	LatMax = 85.05112878
+	LonMin = -180.0
+	LonMax = 180.0
+
+	// StepMax is the number of bits per coordinate of a score: 2 * 26 = 52 bits fit in a float64
+	StepMax = 26
+
+	EarthRadiusMeters = 6372797.560856
+	mercatorMax       = 20037726.37
+)
+
+// Hash is a geohash: the bits of the longitude and latitude interleaved, step bits each
+type Hash struct {
+	Bits uint64
+	Step uint8
+}
+
+// Area is the rectangle covered by a Hash
+type Area struct {
+	Hash   Hash
+	LonMin float64
+	LonMax float64
+	LatMin float64
+	LatMax float64
+}
+
+func ValidCoordinates(lon, lat float64) bool {
+	return lon >= LonMin && lon <= LonMax && lat >= LatMin && lat <= LatMax
+}
+
+// interleave64 spreads the bits of x on the even positions and the bits of y on the odd ones
+func interleave64(x, y uint32) uint64 {
+	spread := func(v uint32) uint64 {
+		r := uint64(v)
+		r = (r | r<<16) & 0x0000FFFF0000FFFF
+		r = (r | r<<8) & 0x00FF00FF00FF00FF
+		r = (r | r<<4) & 0x0F0F0F0F0F0F0F0F
+		r = (r | r<<2) & 0x3333333333333333
+		r = (r | r<<1) & 0x5555555555555555
+		return r
+	}
+	return spread(x) | spread(y)<<1
+}
+
+// deinterleave64 is the reverse of interleave64
+func deinterleave64(v uint64) (x, y uint32) {
+	squash := func(r uint64) uint32 {
+		r &= 0x5555555555555555
+		r = (r | r>>1) & 0x3333333333333333
+		r = (r | r>>2) & 0x0F0F0F0F0F0F0F0F
+		r = (r | r>>4) & 0x00FF00FF00FF00FF
+		r = (r | r>>8) & 0x0000FFFF0000FFFF
+		r = (r | r>>16) & 0x00000000FFFFFFFF
+		return uint32(r)
+	}
+	return squash(v), squash(v >> 1)
+}
+
+// encodeRange encodes a point within the given latitude and longitude ranges
+func encodeRange(lon, lat float64, lonMin, lonMax, latMin, latMax float64, step uint8) Hash {
+	latOffset := (lat - latMin) / (latMax - latMin)
+	lonOffset := (lon - lonMin) / (lonMax - lonMin)
+	scale := float64(uint64(1) << step)
+	// The maximum value would overflow the step bits
+	latBits := min(uint64(latOffset*scale), uint64(1)<<step-1)
+	lonBits := min(uint64(lonOffset*scale), uint64(1)<<step-1)
+	return Hash{Bits: interleave64(uint32(latBits), uint32(lonBits)), Step: step}
+}
+
+// Encode returns the geohash of a point with step bits per coordinate
+func Encode(lon, lat float64, step uint8) Hash {
+	return encodeRange(lon, lat, LonMin, LonMax, LatMin, LatMax, step)
+}
+
+// Decode returns the area covered by a geohash
+func Decode(h Hash) Area {
+	latBits, lonBits := deinterleave64(h.Bits)
+	scale := float64(uint64(1) << h.Step)
+	latScale := LatMax - LatMin
+	lonScale := LonMax - LonMin
+	return Area{
+		Hash:   h,
+		LatMin: LatMin + float64(latBits)/scale*latScale,
+		LatMax: LatMin + float64(latBits+1)/scale*latScale,
+		LonMin: LonMin + float64(lonBits)/scale*lonScale,
+		LonMax: LonMin + float64(lonBits+1)/scale*lonScale,
+	}
+}
+
+// Center returns the center of the area, clamped to the valid coordinates
+func (a Area) Center() (lon, lat float64) {
+	lon = min(max((a.LonMin+a.LonMax)/2, LonMin), LonMax)
+	lat = min(max((a.LatMin+a.LatMax)/2, LatMin), LatMax)
+	return lon, lat
+}
+
+// Score returns the 52 bits score of a point, stored as the score of a sorted set member
+func Score(lon, lat float64) float64 {
+	return float64(Encode(lon, lat, StepMax).Bits)
+}
+
+// DecodeScore returns the coordinates of a point from its score
+func DecodeScore(score float64) (lon, lat float64) {
+	return Decode(Hash{Bits: uint64(score), Step: StepMax}).Center()
+}
+
+const geoAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// HashString returns the standard 11 characters geohash of a score. The score uses the Mercator
+// latitude range, so the point is encoded again with the standard [-90, 90] range.
+func HashString(score float64) string {
+	lon, lat := DecodeScore(score)
+	bits := encodeRange(lon, lat, LonMin, LonMax, -90, 90, StepMax).Bits
+	buf := make([]byte, 11)
+	for i := range buf {
+		idx := 0
+		if i < 10 {
+			idx = int(bits>>(52-uint((i+1)*5))) & 0x1f
+		}
+		buf[i] = geoAlphabet[idx]
+	}
+	return string(buf)
+}
+
+func degRad(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func radDeg(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
+
+// Distance returns the distance in meters between two points with the haversine formula
+func Distance(lon1, lat1, lon2, lat2 float64) float64 {
+	lat1r := degRad(lat1)
+	lat2r := degRad(lat2)
+	u := math.Sin((lat2r - lat1r) / 2)
+	v := math.Sin(degRad(lon2-lon1) / 2)
+	a := u*u + math.Cos(lat1r)*math.Cos(lat2r)*v*v
+	return 2 * EarthRadiusMeters * math.Asin(math.Sqrt(a))
+}
+
+// latDistance returns the distance in meters between two latitudes on the same meridian
+func latDistance(lat1, lat2 float64) float64 {
+	return EarthRadiusMeters * math.Abs(degRad(lat2)-degRad(lat1))
+}
+
+// Shape is the area of a search: a circle of Radius meters, or a box of Width x Height meters
+// when Radius is zero, centered on (Lon, Lat)
+type Shape struct {
+	Lon    float64
+	Lat    float64
+	Radius float64
+	Width  float64
+	Height float64
+}
+
+func (s Shape) isBox() bool {
+	return s.Radius == 0 && (s.Width > 0 || s.Height > 0)
+}
+
+// Contains returns the distance between the center of the shape and the point,
+// and whether the point is inside the shape
+func (s Shape) Contains(lon, lat float64) (float64, bool) {
+	if !s.isBox() {
+		d := Distance(s.Lon, s.Lat, lon, lat)
+		return d, d <= s.Radius
+	}
+	if latDistance(s.Lat, lat) > s.Height/2 {
+		return 0, false
+	}
+	if Distance(s.Lon, lat, lon, lat) > s.Width/2 {
+		return 0, false
+	}
+	return Distance(s.Lon, s.Lat, lon, lat), true
+}
+
+// boundingBox returns the coordinates of the rectangle containing the shape
+func (s Shape) boundingBox() (lonMin, latMin, lonMax, latMax float64) {
+	width, height := s.Radius, s.Radius
+	if s.isBox() {
+		width, height = s.Width/2, s.Height/2
+	}
+	latDelta := radDeg(height / EarthRadiusMeters)
+	lonDeltaTop := radDeg(width / EarthRadiusMeters / math.Cos(degRad(s.Lat+latDelta)))
+	lonDeltaBottom := radDeg(width / EarthRadiusMeters / math.Cos(degRad(s.Lat-latDelta)))
+	// The box is larger on the side closer to the equator
+	lonDelta := lonDeltaTop
+	if s.Lat < 0 {
+		lonDelta = lonDeltaBottom
+	}
+	return s.Lon - lonDelta, s.Lat - latDelta, s.Lon + lonDelta, s.Lat + latDelta
+}
+
+// estimateSteps returns the precision of the geohash boxes to cover a radius in meters
+func estimateSteps(radius, lat float64) uint8 {
+	if radius == 0 {
+		return StepMax
+	}
+	step := 1
+	for radius < mercatorMax {
+		radius *= 2
+		step++
+	}
+	// Make sure the range is included in most of the base cases
+	step -= 2
+	// Boxes are narrower near the poles
+	if lat > 66 || lat < -66 {
+		step--
+		if lat > 80 || lat < -80 {
+			step--
+		}
+	}
+	return uint8(min(max(step, 1), StepMax))
+}
+
+func (h Hash) moveX(d int) Hash {
+	if d == 0 {
+		return h
+	}
+	x := h.Bits & 0xaaaaaaaaaaaaaaaa
+	y := h.Bits & 0x5555555555555555
+	zz := uint64(0x5555555555555555) >> (64 - uint(h.Step)*2)
+	if d > 0 {
+		x = x + (zz + 1)
+	} else {
+		x = x | zz
+		x = x - (zz + 1)
+	}
+	x &= 0xaaaaaaaaaaaaaaaa >> (64 - uint(h.Step)*2)
+	return Hash{Bits: x | y, Step: h.Step}
+}
+
+func (h Hash) moveY(d int) Hash {
+	if d == 0 {
+		return h
+	}
+	x := h.Bits & 0xaaaaaaaaaaaaaaaa
+	y := h.Bits & 0x5555555555555555
+	zz := uint64(0xaaaaaaaaaaaaaaaa) >> (64 - uint(h.Step)*2)
+	if d > 0 {
+		y = y + (zz + 1)
+	} else {
+		y = y | zz
+		y = y - (zz + 1)
+	}
+	y &= 0x5555555555555555 >> (64 - uint(h.Step)*2)
+	return Hash{Bits: x | y, Step: h.Step}
+}
+
+// ScoreRange is a range of scores [Min, Max) of the members inside a geohash box
+type ScoreRange struct {
+	Min float64
+	Max float64
+}
+
+func (h Hash) scoreRange() ScoreRange {
+	shift := 2 * (StepMax - uint(h.Step))
+	return ScoreRange{
+		Min: float64(h.Bits << shift),
+		Max: float64((h.Bits + 1) << shift),
+	}
+}
+
+// SearchRanges returns the score ranges to scan to find every member inside the shape:
+// the geohash box containing its center and the neighbouring boxes that intersect it.
+// The members found in these ranges must still be filtered with Contains.
+func (s Shape) SearchRanges() []ScoreRange {
+	lonMin, latMin, lonMax, latMax := s.boundingBox()
+	radius := s.Radius
+	if s.isBox() {
+		radius = math.Sqrt(s.Width*s.Width/4 + s.Height*s.Height/4)
+	}
+	step := estimateSteps(radius, s.Lat)
+
+	center := Encode(s.Lon, s.Lat, step)
+	neighbors := func(h Hash) map[string]Hash {
+		return map[string]Hash{
+			"n":  h.moveY(1),
+			"s":  h.moveY(-1),
+			"e":  h.moveX(1),
+			"w":  h.moveX(-1),
+			"ne": h.moveX(1).moveY(1),
+			"nw": h.moveX(-1).moveY(1),
+			"se": h.moveX(1).moveY(-1),
+			"sw": h.moveX(-1).moveY(-1),
+		}
+	}
+	around := neighbors(center)
+
+	// The boxes may be too small to cover the shape when the center is close to their edge,
+	// then use one step less
+	if step > 1 {
+		n, so, e, w := Decode(around["n"]), Decode(around["s"]), Decode(around["e"]), Decode(around["w"])
+		if n.LatMax < latMax || so.LatMin > latMin || e.LonMax < lonMax || w.LonMin > lonMin {
+			step--
+			center = Encode(s.Lon, s.Lat, step)
+			around = neighbors(center)
+		}
+	}
+
+	// Exclude the boxes that can not intersect the shape
+	area := Decode(center)
+	if step >= 2 {
+		if area.LatMin < latMin {
+			delete(around, "s")
+			delete(around, "sw")
+			delete(around, "se")
+		}
+		if area.LatMax > latMax {
+			delete(around, "n")
+			delete(around, "nw")
+			delete(around, "ne")
+		}
+		if area.LonMin < lonMin {
+			delete(around, "w")
+			delete(around, "sw")
+			delete(around, "nw")
+		}
+		if area.LonMax > lonMax {
+			delete(around, "e")
+			delete(around, "se")
+			delete(around, "ne")
+		}
+	}
+
+	// At low precision the neighbours may wrap around to the same box
+	ranges := []ScoreRange{center.scoreRange()}
+	seen := map[uint64]bool{center.Bits: true}
+	for _, dir := range []string{"n", "s", "e", "w", "ne", "nw", "se", "sw"} {
+		h, ok := around[dir]
+		if !ok || seen[h.Bits] {
+			continue
+		}
+		seen[h.Bits] = true
+		ranges = append(ranges, h.scoreRange())
+	}
+	return ranges
+}
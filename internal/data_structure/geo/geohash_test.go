@@ -0,0 +1,86 @@
+package geo
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScore(t *testing.T) {
+	// Values from the Redis documentation
+	score := Score(13.361389, 38.115556)
+	assert.Equal(t, float64(3479099956230698), score)
+
+	lon, lat := DecodeScore(score)
+	assert.InDelta(t, 13.361389, lon, 1e-5)
+	assert.InDelta(t, 38.115556, lat, 1e-5)
+
+	assert.Equal(t, "sqc8b49rny0", HashString(score))
+	assert.Equal(t, "sqdtr74hyu0", HashString(Score(15.087269, 37.502669)))
+}
+
+func TestValidCoordinates(t *testing.T) {
+	assert.True(t, ValidCoordinates(180, 85.05112878))
+	assert.False(t, ValidCoordinates(180.1, 0))
+	assert.False(t, ValidCoordinates(0, 86))
+}
+
+func TestDistance(t *testing.T) {
+	d := Distance(13.361389, 38.115556, 15.087269, 37.502669)
+	assert.InDelta(t, 166274.15, d, 1)
+	assert.Equal(t, 0.0, Distance(1, 2, 1, 2))
+}
+
+func TestShape_Contains(t *testing.T) {
+	circle := Shape{Lon: 15, Lat: 37, Radius: 200 * 1000}
+	_, ok := circle.Contains(13.361389, 38.115556)
+	assert.True(t, ok)
+	circle.Radius = 100 * 1000
+	_, ok = circle.Contains(13.361389, 38.115556)
+	assert.False(t, ok)
+
+	box := Shape{Lon: 15, Lat: 37, Width: 400 * 1000, Height: 400 * 1000}
+	d, ok := box.Contains(13.361389, 38.115556)
+	assert.True(t, ok)
+	assert.InDelta(t, 190000, d, 10000)
+	box.Height = 200 * 1000
+	_, ok = box.Contains(13.361389, 38.115556)
+	assert.False(t, ok)
+}
+
+// Every point inside the shape must be found in one of the search ranges
+func TestShape_SearchRanges(t *testing.T) {
+	shapes := []Shape{
+		{Lon: 15, Lat: 37, Radius: 200 * 1000},
+		{Lon: -122.4, Lat: 37.7, Radius: 500},
+		{Lon: 179.99, Lat: 0, Radius: 5000},
+		{Lon: 10, Lat: 75, Width: 300 * 1000, Height: 100 * 1000},
+		{Lon: 0, Lat: 0, Radius: 5000 * 1000},
+	}
+	for i, shape := range shapes {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			ranges := shape.SearchRanges()
+			lonMin, latMin, lonMax, latMax := shape.boundingBox()
+			for lon := lonMin; lon <= lonMax; lon += (lonMax - lonMin) / 50 {
+				for lat := latMin; lat <= latMax; lat += (latMax - latMin) / 50 {
+					if !ValidCoordinates(lon, lat) {
+						continue
+					}
+					score := Score(lon, lat)
+					if _, ok := shape.Contains(DecodeScore(score)); !ok {
+						continue
+					}
+					found := false
+					for _, r := range ranges {
+						if score >= r.Min && score < r.Max {
+							found = true
+							break
+						}
+					}
+					assert.True(t, found, "point %f,%f not covered", lon, lat)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,286 @@
+package hash_map
+
+import (
+	"hash/fnv"
+	"math/rand"
+
+	"github.com/spaghetti-lover/multithread-redis/internal/data_structure/listpack"
+	"github.com/spaghetti-lover/multithread-redis/internal/data_structure/sorted_set"
+)
+
+const (
+	EncodingListpack  = "listpack"
+	EncodingHashTable = "hashtable"
+)
+
+// Hash is a map of fields to values.
+// Small hashes are stored in a listpack (field, value, field, value, ...) and converted
+// to a Go map once they have more than maxListpackEntries fields or a field/value longer
+// than maxListpackValue bytes. The conversion is one way, like in Redis.
+type Hash struct {
+	lp   *listpack.Listpack
+	dict map[string]string
+	// scanOrder holds the fields of dict ordered by scanScore, for HSCAN
+	scanOrder          sorted_set.OrderedIndex
+	maxListpackEntries int
+	maxListpackValue   int
+
+	// expires holds the expiration time in unix ms of the fields that have a TTL
+	expires map[string]int64
+	// nextExpire is the earliest time in expires, so expired fields are only looked for when needed
+	nextExpire int64
+}
+
+func NewHash(maxListpackEntries int, maxListpackValue int) *Hash {
+	return &Hash{
+		lp:                 listpack.New(),
+		maxListpackEntries: maxListpackEntries,
+		maxListpackValue:   maxListpackValue,
+	}
+}
+
+// Encoding returns the current encoding, "listpack" or "hashtable"
+func (h *Hash) Encoding() string {
+	if h.dict != nil {
+		return EncodingHashTable
+	}
+	return EncodingListpack
+}
+
+func (h *Hash) Len() int {
+	if h.dict != nil {
+		return len(h.dict)
+	}
+	return h.lp.Len() / 2
+}
+
+func (h *Hash) convertToHashTable() {
+	h.dict = make(map[string]string, h.lp.Len()/2)
+	h.scanOrder = sorted_set.NewSkipListIndex(sorted_set.SkiplistMaxLevel)
+	entries := h.lp.Entries()
+	for i := 0; i < len(entries); i += 2 {
+		h.dict[entries[i]] = entries[i+1]
+		h.scanOrder.Add(scanScore(entries[i]), entries[i])
+	}
+	h.lp = nil
+}
+
+// Set sets field to value. Returns 1 if field is new, 0 if it was updated.
+func (h *Hash) Set(field, value string) int {
+	// Overwriting a field discards its TTL
+	delete(h.expires, field)
+
+	if h.dict == nil && (len(field) > h.maxListpackValue || len(value) > h.maxListpackValue) {
+		h.convertToHashTable()
+	}
+
+	if h.dict != nil {
+		_, exist := h.dict[field]
+		h.dict[field] = value
+		if exist {
+			return 0
+		}
+		h.scanOrder.Add(scanScore(field), field)
+		return 1
+	}
+
+	if pos := h.lp.Find(0, field, 1); pos >= 0 {
+		h.lp.Replace(h.lp.Next(pos), value)
+		return 0
+	}
+	h.lp.Append(field, value)
+	if h.Len() > h.maxListpackEntries {
+		h.convertToHashTable()
+	}
+	return 1
+}
+
+// Get returns the value of field
+func (h *Hash) Get(field string) (string, bool) {
+	if h.dict != nil {
+		v, exist := h.dict[field]
+		return v, exist
+	}
+	pos := h.lp.Find(0, field, 1)
+	if pos < 0 {
+		return "", false
+	}
+	v, _ := h.lp.Get(h.lp.Next(pos))
+	return v, true
+}
+
+func (h *Hash) Exists(field string) bool {
+	_, exist := h.Get(field)
+	return exist
+}
+
+// Del removes field. Returns true if field existed.
+func (h *Hash) Del(field string) bool {
+	delete(h.expires, field)
+	if h.dict != nil {
+		if _, exist := h.dict[field]; !exist {
+			return false
+		}
+		delete(h.dict, field)
+		h.scanOrder.RemoveByScore(scanScore(field), field)
+		return true
+	}
+	pos := h.lp.Find(0, field, 1)
+	if pos < 0 {
+		return false
+	}
+	h.lp.Delete(pos, 2)
+	return true
+}
+
+// GetAll returns fields and values interleaved: field1, value1, field2, value2, ...
+func (h *Hash) GetAll() []string {
+	if h.dict == nil {
+		return h.lp.Entries()
+	}
+	res := make([]string, 0, 2*len(h.dict))
+	for f, v := range h.dict {
+		res = append(res, f, v)
+	}
+	return res
+}
+
+func (h *Hash) Keys() []string {
+	all := h.GetAll()
+	res := make([]string, 0, len(all)/2)
+	for i := 0; i < len(all); i += 2 {
+		res = append(res, all[i])
+	}
+	return res
+}
+
+func (h *Hash) Values() []string {
+	all := h.GetAll()
+	res := make([]string, 0, len(all)/2)
+	for i := 1; i < len(all); i += 2 {
+		res = append(res, all[i])
+	}
+	return res
+}
+
+// RandomFields returns count fields (with their values if withValues) like HRANDFIELD.
+// A positive count returns distinct fields, a negative count may return the same field several times.
+func (h *Hash) RandomFields(count int, withValues bool) []string {
+	all := h.GetAll()
+	n := len(all) / 2
+	var res []string
+	if n == 0 || count == 0 {
+		return []string{}
+	}
+
+	if count < 0 {
+		// count comes from the client, negating it may overflow
+		for i := 0; i > count; i-- {
+			j := rand.Intn(n)
+			res = append(res, all[2*j])
+			if withValues {
+				res = append(res, all[2*j+1])
+			}
+		}
+		return res
+	}
+
+	if count > n {
+		count = n
+	}
+	for _, j := range rand.Perm(n)[:count] {
+		res = append(res, all[2*j])
+		if withValues {
+			res = append(res, all[2*j+1])
+		}
+	}
+	return res
+}
+
+// scanScore is the position of a field in the scan order: the 53 high bits of its hash,
+// which a float64 holds exactly
+func scanScore(field string) float64 {
+	hasher := fnv.New64a()
+	hasher.Write([]byte(field))
+	return float64(hasher.Sum64() >> 11)
+}
+
+// Scan returns about count fields and values interleaved, starting at cursor, and the cursor
+// to continue with (0 when the iteration is complete).
+// A listpack is returned at once. For a hash table, fields are visited in order of their
+// scan score and the cursor is the next score to visit, so every field present during the
+// whole iteration is returned even if the hash is modified between calls. A call costs
+// O(log N + count).
+func (h *Hash) Scan(cursor uint64, count int) (uint64, []string) {
+	if h.dict == nil {
+		return 0, h.lp.Entries()
+	}
+	if count < 1 {
+		count = 1
+	}
+
+	res := make([]string, 0, 2*count)
+	it := h.scanOrder.IterateFromScore(float64(cursor), false, false)
+	// Fields with the same score are returned together, the cursor cannot split them
+	for n := 0; it.Valid() && (n < count || it.Item().Score == scanScore(res[len(res)-2])); n++ {
+		field := it.Item().Member
+		res = append(res, field, h.dict[field])
+		it.Next()
+	}
+	if !it.Valid() {
+		return 0, res
+	}
+	return uint64(it.Item().Score), res
+}
+
+// GetExpire returns the expiration time (unix ms) of field, if it has one
+func (h *Hash) GetExpire(field string) (int64, bool) {
+	at, exist := h.expires[field]
+	return at, exist
+}
+
+// SetExpire sets the expiration time (unix ms) of an existing field
+func (h *Hash) SetExpire(field string, at int64) {
+	if h.expires == nil {
+		h.expires = make(map[string]int64)
+	}
+	h.expires[field] = at
+	if len(h.expires) == 1 || at < h.nextExpire {
+		h.nextExpire = at
+	}
+}
+
+// Persist removes the TTL of field. Returns false if the field had no TTL.
+func (h *Hash) Persist(field string) bool {
+	if _, exist := h.expires[field]; !exist {
+		return false
+	}
+	delete(h.expires, field)
+	return true
+}
+
+// HasExpires reports whether some fields have a TTL
+func (h *Hash) HasExpires() bool {
+	return len(h.expires) > 0
+}
+
+// DeleteExpired removes the fields whose expiration time is before or at now (unix ms)
+// and returns how many were removed. It is O(1) until the earliest TTL is reached.
+func (h *Hash) DeleteExpired(now int64) int {
+	if len(h.expires) == 0 || now < h.nextExpire {
+		return 0
+	}
+	removed := 0
+	h.nextExpire = 0
+	for field, at := range h.expires {
+		if at <= now {
+			h.Del(field)
+			removed++
+			continue
+		}
+		if h.nextExpire == 0 || at < h.nextExpire {
+			h.nextExpire = at
+		}
+	}
+	return removed
+}
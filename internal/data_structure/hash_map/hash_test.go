@@ -0,0 +1,141 @@
+package hash_map
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHash_SetGetDel(t *testing.T) {
+	h := NewHash(128, 64)
+
+	assert.Equal(t, 1, h.Set("f1", "v1"))
+	assert.Equal(t, 1, h.Set("f2", "v2"))
+	assert.Equal(t, 0, h.Set("f1", "v1-new"))
+	assert.Equal(t, 2, h.Len())
+	assert.Equal(t, EncodingListpack, h.Encoding())
+
+	v, ok := h.Get("f1")
+	assert.True(t, ok)
+	assert.Equal(t, "v1-new", v)
+	_, ok = h.Get("missing")
+	assert.False(t, ok)
+
+	assert.Equal(t, []string{"f1", "v1-new", "f2", "v2"}, h.GetAll())
+	assert.Equal(t, []string{"f1", "f2"}, h.Keys())
+	assert.Equal(t, []string{"v1-new", "v2"}, h.Values())
+
+	assert.True(t, h.Del("f1"))
+	assert.False(t, h.Del("f1"))
+	assert.Equal(t, 1, h.Len())
+}
+
+func TestHash_ConvertToHashTable(t *testing.T) {
+	// Too many entries
+	h := NewHash(4, 64)
+	for i := 0; i < 4; i++ {
+		h.Set(fmt.Sprintf("f%d", i), "v")
+	}
+	assert.Equal(t, EncodingListpack, h.Encoding())
+	h.Set("f4", "v")
+	assert.Equal(t, EncodingHashTable, h.Encoding())
+	assert.Equal(t, 5, h.Len())
+	for i := 0; i < 5; i++ {
+		assert.True(t, h.Exists(fmt.Sprintf("f%d", i)))
+	}
+
+	// Value too long
+	h = NewHash(128, 8)
+	h.Set("f", "short")
+	h.Set("g", strings.Repeat("x", 9))
+	assert.Equal(t, EncodingHashTable, h.Encoding())
+	v, _ := h.Get("f")
+	assert.Equal(t, "short", v)
+}
+
+func TestHash_RandomFields(t *testing.T) {
+	h := NewHash(128, 64)
+	h.Set("a", "1")
+	h.Set("b", "2")
+	h.Set("c", "3")
+
+	fields := h.RandomFields(10, false)
+	sort.Strings(fields)
+	assert.Equal(t, []string{"a", "b", "c"}, fields)
+
+	assert.Len(t, h.RandomFields(-10, false), 10)
+	assert.Len(t, h.RandomFields(2, true), 4)
+	assert.Len(t, h.RandomFields(0, true), 0)
+}
+
+func TestHash_Scan(t *testing.T) {
+	h := NewHash(4, 64)
+	expected := make(map[string]string)
+	for i := 0; i < 100; i++ {
+		f := fmt.Sprintf("field:%d", i)
+		h.Set(f, fmt.Sprintf("%d", i))
+		expected[f] = fmt.Sprintf("%d", i)
+	}
+
+	seen := make(map[string]string)
+	var cursor uint64
+	iterations := 0
+	for {
+		var res []string
+		cursor, res = h.Scan(cursor, 10)
+		for i := 0; i < len(res); i += 2 {
+			seen[res[i]] = res[i+1]
+		}
+		iterations++
+		// fields deleted during the scan must not break the iteration
+		h.Del(fmt.Sprintf("field:%d", 99-iterations))
+		if cursor == 0 {
+			break
+		}
+	}
+	assert.Greater(t, iterations, 5)
+	for f, v := range seen {
+		assert.Equal(t, expected[f], v)
+	}
+	for i := 0; i < 99-iterations; i++ {
+		_, ok := seen[fmt.Sprintf("field:%d", i)]
+		assert.True(t, ok)
+	}
+}
+
+func TestHash_FieldExpire(t *testing.T) {
+	h := NewHash(128, 64)
+	h.Set("a", "1")
+	h.Set("b", "2")
+	h.Set("c", "3")
+
+	h.SetExpire("a", 100)
+	h.SetExpire("b", 200)
+	assert.True(t, h.HasExpires())
+
+	at, ok := h.GetExpire("a")
+	assert.True(t, ok)
+	assert.EqualValues(t, 100, at)
+	_, ok = h.GetExpire("c")
+	assert.False(t, ok)
+
+	// Nothing is expired yet
+	assert.Equal(t, 0, h.DeleteExpired(99))
+	assert.Equal(t, 3, h.Len())
+
+	assert.Equal(t, 1, h.DeleteExpired(150))
+	assert.False(t, h.Exists("a"))
+	assert.Equal(t, 2, h.Len())
+
+	// Persist and overwrite drop the TTL
+	assert.True(t, h.Persist("b"))
+	assert.False(t, h.Persist("b"))
+	h.SetExpire("c", 300)
+	h.Set("c", "new")
+	assert.False(t, h.HasExpires())
+	assert.Equal(t, 0, h.DeleteExpired(1000))
+	assert.Equal(t, 2, h.Len())
+}
@@ -0,0 +1,126 @@
+package intset
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+)
+
+// IntSet is a sorted set of integers stored in one contiguous byte slice.
+// All the values are encoded with the same width, 2, 4 or 8 bytes, which is the smallest
+// one able to hold every value. Adding a value that does not fit upgrades the whole set
+// to a wider encoding; the set is never downgraded.
+type IntSet struct {
+	width    int
+	contents []byte
+}
+
+func New() *IntSet {
+	return &IntSet{width: 2}
+}
+
+// widthFor returns the number of bytes needed to encode v
+func widthFor(v int64) int {
+	switch {
+	case v >= math.MinInt16 && v <= math.MaxInt16:
+		return 2
+	case v >= math.MinInt32 && v <= math.MaxInt32:
+		return 4
+	}
+	return 8
+}
+
+// Len returns the number of values
+func (s *IntSet) Len() int {
+	return len(s.contents) / s.width
+}
+
+// Bytes returns the size of the encoded values in bytes
+func (s *IntSet) Bytes() int {
+	return len(s.contents)
+}
+
+// Get returns the value at index i
+func (s *IntSet) Get(i int) int64 {
+	return s.get(s.contents, s.width, i)
+}
+
+func (s *IntSet) get(contents []byte, width int, i int) int64 {
+	b := contents[i*width:]
+	switch width {
+	case 2:
+		return int64(int16(binary.LittleEndian.Uint16(b)))
+	case 4:
+		return int64(int32(binary.LittleEndian.Uint32(b)))
+	}
+	return int64(binary.LittleEndian.Uint64(b))
+}
+
+func (s *IntSet) set(i int, v int64) {
+	b := s.contents[i*s.width:]
+	switch s.width {
+	case 2:
+		binary.LittleEndian.PutUint16(b, uint16(v))
+	case 4:
+		binary.LittleEndian.PutUint32(b, uint32(v))
+	default:
+		binary.LittleEndian.PutUint64(b, uint64(v))
+	}
+}
+
+// search returns the index of v, or where it would be inserted
+func (s *IntSet) search(v int64) (int, bool) {
+	n := s.Len()
+	i := sort.Search(n, func(i int) bool { return s.Get(i) >= v })
+	return i, i < n && s.Get(i) == v
+}
+
+func (s *IntSet) Contains(v int64) bool {
+	_, found := s.search(v)
+	return found
+}
+
+// upgrade re-encodes the values with a wider encoding
+func (s *IntSet) upgrade(width int) {
+	old, oldWidth := s.contents, s.width
+	n := len(old) / oldWidth
+	s.width = width
+	s.contents = make([]byte, n*width)
+	for i := 0; i < n; i++ {
+		s.set(i, s.get(old, oldWidth, i))
+	}
+}
+
+// Add adds v, returns false if it was already present
+func (s *IntSet) Add(v int64) bool {
+	if w := widthFor(v); w > s.width {
+		s.upgrade(w)
+	}
+	i, found := s.search(v)
+	if found {
+		return false
+	}
+	s.contents = append(s.contents, make([]byte, s.width)...)
+	copy(s.contents[(i+1)*s.width:], s.contents[i*s.width:])
+	s.set(i, v)
+	return true
+}
+
+// Remove removes v, returns false if it was not present
+func (s *IntSet) Remove(v int64) bool {
+	i, found := s.search(v)
+	if !found {
+		return false
+	}
+	s.contents = append(s.contents[:i*s.width], s.contents[(i+1)*s.width:]...)
+	return true
+}
+
+// Values returns the values in ascending order
+func (s *IntSet) Values() []int64 {
+	res := make([]int64, s.Len())
+	for i := range res {
+		res[i] = s.Get(i)
+	}
+	return res
+}
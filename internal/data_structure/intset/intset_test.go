@@ -0,0 +1,66 @@
+package intset
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIntSet_AddRemove(t *testing.T) {
+	s := New()
+	assert.True(t, s.Add(5))
+	assert.True(t, s.Add(-3))
+	assert.True(t, s.Add(10))
+	assert.False(t, s.Add(5))
+	assert.Equal(t, []int64{-3, 5, 10}, s.Values())
+	assert.Equal(t, 6, s.Bytes())
+
+	assert.True(t, s.Contains(10))
+	assert.False(t, s.Contains(11))
+	assert.True(t, s.Remove(5))
+	assert.False(t, s.Remove(5))
+	assert.Equal(t, []int64{-3, 10}, s.Values())
+}
+
+func TestIntSet_Upgrade(t *testing.T) {
+	s := New()
+	s.Add(1)
+	s.Add(-2)
+	s.Add(math.MaxInt32)
+	assert.Equal(t, 12, s.Bytes())
+	s.Add(math.MinInt64)
+	assert.Equal(t, 32, s.Bytes())
+	assert.Equal(t, []int64{math.MinInt64, -2, 1, math.MaxInt32}, s.Values())
+
+	// Removing the wide values keeps the encoding
+	s.Remove(math.MinInt64)
+	assert.Equal(t, 24, s.Bytes())
+	assert.True(t, s.Contains(-2))
+}
+
+func TestIntSet_Random(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	s := New()
+	model := make(map[int64]struct{})
+	for i := 0; i < 3000; i++ {
+		v := rnd.Int63n(1<<uint(rnd.Intn(40)+1)) - 1000
+		if rnd.Intn(3) == 0 {
+			_, exist := model[v]
+			assert.Equal(t, exist, s.Remove(v))
+			delete(model, v)
+		} else {
+			_, exist := model[v]
+			assert.Equal(t, !exist, s.Add(v))
+			model[v] = struct{}{}
+		}
+	}
+	expected := make([]int64, 0, len(model))
+	for v := range model {
+		expected = append(expected, v)
+	}
+	sort.Slice(expected, func(i, j int) bool { return expected[i] < expected[j] })
+	assert.Equal(t, expected, s.Values())
+}
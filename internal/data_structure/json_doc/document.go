@@ -0,0 +1,96 @@
+package json_doc
+
+import "sort"
+
+// Document is a parsed JSON value. Updates modify the tree in place, so changing a field
+// does not re-serialize the whole document.
+type Document struct {
+	Root any
+}
+
+func NewDocument(root any) *Document {
+	return &Document{Root: root}
+}
+
+// Select returns the values selected by path
+func (d *Document) Select(path *Path) []Match {
+	return path.selectFrom(d)
+}
+
+// Set replaces the values selected by path with v. If path selects nothing and ends with a
+// key, the key is added to the objects selected by the rest of the path. With nx, existing
+// values are not replaced, with xx no key is added. Returns false if nothing was set.
+func (d *Document) Set(path *Path, v any, nx, xx bool) bool {
+	if matches := path.selectFrom(d); len(matches) > 0 {
+		if nx {
+			return false
+		}
+		for i := range matches {
+			// Each match gets its own copy, so that they can be updated independently
+			matches[i].Replace(Clone(v))
+		}
+		return true
+	}
+
+	parent, key, ok := path.parent()
+	if !ok || xx {
+		return false
+	}
+	set := false
+	for _, m := range parent.selectFrom(d) {
+		if obj, ok := m.Value.(*Object); ok {
+			obj.Set(key, Clone(v))
+			set = true
+		}
+	}
+	return set
+}
+
+// Delete deletes the values selected by path, except the root, and returns how many were
+// deleted
+func (d *Document) Delete(path *Path) int {
+	deleted := 0
+	indexes := make(map[*Array][]int)
+	for _, m := range path.selectFrom(d) {
+		switch p := m.parent.(type) {
+		case *Object:
+			if p.Delete(m.key) {
+				deleted++
+			}
+		case *Array:
+			indexes[p] = append(indexes[p], m.index)
+		}
+	}
+	// Items are deleted from the last one so that the indexes stay valid
+	for arr, idx := range indexes {
+		sort.Sort(sort.Reverse(sort.IntSlice(idx)))
+		for i, index := range idx {
+			if i > 0 && index == idx[i-1] {
+				continue
+			}
+			arr.Items = append(arr.Items[:index], arr.Items[index+1:]...)
+			deleted++
+		}
+	}
+	return deleted
+}
+
+// Clone returns a deep copy of v
+func Clone(v any) any {
+	switch t := v.(type) {
+	case *Object:
+		obj := NewObject()
+		for _, key := range t.keys {
+			obj.Set(key, Clone(t.values[key]))
+		}
+		return obj
+	case *Array:
+		arr := &Array{Items: make([]any, len(t.Items))}
+		for i, item := range t.Items {
+			arr.Items[i] = Clone(item)
+		}
+		return arr
+	default:
+		return v
+	}
+}
@@ -0,0 +1,129 @@
+package json_doc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustParse(t *testing.T, text string) *Document {
+	v, err := Parse(text)
+	require.NoError(t, err)
+	return NewDocument(v)
+}
+
+func mustPath(t *testing.T, text string) *Path {
+	p, err := ParsePath(text)
+	require.NoError(t, err)
+	return p
+}
+
+// selected returns the compact JSON of the values selected by path
+func selected(t *testing.T, d *Document, path string) []string {
+	res := []string{}
+	for _, m := range d.Select(mustPath(t, path)) {
+		res = append(res, Marshal(m.Value))
+	}
+	return res
+}
+
+func TestParseMarshal(t *testing.T) {
+	for _, text := range []string{
+		`{"b":1,"a":[true,false,null],"c":{"d":"x\"y\n"},"e":-2.5,"f":1.0,"g":[],"h":{}}`,
+		`"é"`,
+		`12`,
+		`1e+100`,
+	} {
+		v, err := Parse(text)
+		require.NoError(t, err, text)
+		assert.Equal(t, text, Marshal(v))
+	}
+
+	for _, text := range []string{``, `{`, `[1,]`, `{"a":1}x`, `{1:2}`, `nul`} {
+		_, err := Parse(text)
+		assert.ErrorIs(t, err, ErrInvalidJSON, text)
+	}
+
+	v, _ := Parse(`{"a":1,"b":2.5,"c":"s","d":true,"e":null,"f":[],"g":{}}`)
+	var types []string
+	for _, key := range v.(*Object).Keys() {
+		child, _ := v.(*Object).Get(key)
+		types = append(types, TypeOf(child))
+	}
+	assert.Equal(t, []string{"integer", "number", "string", "boolean", "null", "array", "object"}, types)
+
+	v, _ = Parse(`{"a":[1,{"b":2}],"c":{}}`)
+	assert.Equal(t, "{\n\t\"a\": [\n\t\t1,\n\t\t{\n\t\t\t\"b\": 2\n\t\t}\n\t],\n\t\"c\": {}\n}",
+		Format{Indent: "\t", Newline: "\n", Space: " "}.Marshal(v))
+}
+
+func TestPathSelect(t *testing.T) {
+	d := mustParse(t, `{"a":{"b":[10,20,30],"c":"x"},"b":{"b":1},"arr":[{"b":5},{"d":6}]}`)
+
+	tests := []struct {
+		path     string
+		expected []string
+	}{
+		{"$", []string{Marshal(d.Root)}},
+		{".", []string{Marshal(d.Root)}},
+		{"$.a.b[0]", []string{"10"}},
+		{"$.a.b[-1]", []string{"30"}},
+		{"$.a.b[0,2,5]", []string{"10", "30"}},
+		{"$.a.b[1:]", []string{"20", "30"}},
+		{"$.a.b[:-1]", []string{"10", "20"}},
+		{"$.a.b[*]", []string{"10", "20", "30"}},
+		{"$['a']['c']", []string{`"x"`}},
+		{`$["a","b"].c`, []string{`"x"`}},
+		{"$.a.*", []string{"[10,20,30]", `"x"`}},
+		{"$..b", []string{`{"b":1}`, "[10,20,30]", "1", "5"}},
+		{"$..[0]", []string{`10`, `{"b":5}`}},
+		{"$.arr[*].b", []string{"5"}},
+		{"$.missing", []string{}},
+		{".a.b", []string{"[10,20,30]"}},
+		{"a.b[1]", []string{"20"}},
+		{"..b", []string{`{"b":1}`}},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, selected(t, d, tt.path), tt.path)
+	}
+
+	for _, path := range []string{"$.", "$..", "$[", "$[a]", "$['a'", "$a", "$.a[1:2:3]"} {
+		_, err := ParsePath(path)
+		assert.ErrorIs(t, err, ErrInvalidPath, path)
+	}
+}
+
+func TestDocumentSet(t *testing.T) {
+	d := mustParse(t, `{"a":{"b":1},"c":[{"b":2},{"x":3}]}`)
+
+	assert.True(t, d.Set(mustPath(t, "$..b"), int64(7), false, false))
+	assert.Equal(t, `{"a":{"b":7},"c":[{"b":7},{"x":3}]}`, Marshal(d.Root))
+
+	// A missing key is added to the objects selected by the parent path
+	assert.True(t, d.Set(mustPath(t, "$.c[*].y"), "new", false, false))
+	assert.Equal(t, `{"a":{"b":7},"c":[{"b":7,"y":"new"},{"x":3,"y":"new"}]}`, Marshal(d.Root))
+
+	assert.False(t, d.Set(mustPath(t, "$.a.b"), int64(1), true, false))
+	assert.False(t, d.Set(mustPath(t, "$.a.z"), int64(1), false, true))
+	assert.False(t, d.Set(mustPath(t, "$.nope.z"), int64(1), false, false))
+	assert.True(t, d.Set(mustPath(t, "$.a.z"), int64(1), true, false))
+
+	// Each match gets its own copy
+	v, _ := Parse(`[1]`)
+	assert.True(t, d.Set(mustPath(t, "$.c[*].y"), v, false, false))
+	d.Select(mustPath(t, "$.c[0].y"))[0].Value.(*Array).Items[0] = int64(2)
+	assert.Equal(t, []string{"[2]", "[1]"}, selected(t, d, "$.c[*].y"))
+
+	assert.True(t, d.Set(mustPath(t, "$"), "root", false, false))
+	assert.Equal(t, `"root"`, Marshal(d.Root))
+}
+
+func TestDocumentDelete(t *testing.T) {
+	d := mustParse(t, `{"a":[0,1,2,3,4],"b":{"a":1,"c":2}}`)
+	assert.Equal(t, 3, d.Delete(mustPath(t, "$.a[0,2,2,-1]")))
+	assert.Equal(t, `{"a":[1,3],"b":{"a":1,"c":2}}`, Marshal(d.Root))
+	assert.Equal(t, 2, d.Delete(mustPath(t, "$..a")))
+	assert.Equal(t, `{"b":{"c":2}}`, Marshal(d.Root))
+	assert.Equal(t, 0, d.Delete(mustPath(t, "$.x")))
+}
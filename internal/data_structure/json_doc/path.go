@@ -0,0 +1,334 @@
+package json_doc
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidPath = errors.New("invalid path")
+
+// Path is a compiled JSONPath. The supported subset is:
+//   - $ for the root, followed by
+//   - .key or ['key'] for a member of an object, .* or [*] for all the members of an object
+//     or all the items of an array
+//   - [i] or [i,j,...] for items of an array, negative indexes counting from the end, and
+//     [start:end] for a slice, either bound being optional
+//   - ..key, ..* or ..[...] to apply a selector to a value and all its descendants
+//
+// A legacy path does not start with $ (".a.b", "a[0]", "." for the root) and selects at
+// most one value.
+type Path struct {
+	text     string
+	legacy   bool
+	segments []segment
+}
+
+type segmentKind int
+
+const (
+	segmentKeys segmentKind = iota
+	segmentWildcard
+	segmentIndexes
+	segmentSlice
+)
+
+// segment is a selector applied to the children of a value, or to the children of a value
+// and all its descendants if recursive
+type segment struct {
+	kind      segmentKind
+	recursive bool
+	keys      []string
+	indexes   []int
+	start     *int
+	end       *int
+}
+
+// Match is a value selected by a path. It can be replaced in its parent.
+type Match struct {
+	Value  any
+	parent any // *Object, *Array or *Document for the root
+	key    string
+	index  int
+}
+
+// Replace replaces the value in its parent
+func (m *Match) Replace(v any) {
+	switch p := m.parent.(type) {
+	case *Object:
+		p.Set(m.key, v)
+	case *Array:
+		p.Items[m.index] = v
+	case *Document:
+		p.Root = v
+	}
+	m.Value = v
+}
+
+func ParsePath(text string) (*Path, error) {
+	p := &Path{text: text}
+	s := text
+	if strings.HasPrefix(s, "$") {
+		s = s[1:]
+	} else {
+		p.legacy = true
+		if s == "." {
+			return p, nil
+		}
+		// The first key of a legacy path may omit the dot
+		if s != "" && s[0] != '.' && s[0] != '[' {
+			s = "." + s
+		}
+	}
+
+	for s != "" {
+		seg := segment{}
+		if strings.HasPrefix(s, "..") {
+			seg.recursive = true
+			s = s[2:]
+			if s != "" && s[0] != '[' && s[0] != '.' {
+				s = "." + s
+			} else if s == "" || s[0] == '.' {
+				return nil, ErrInvalidPath
+			}
+		}
+		var err error
+		switch s[0] {
+		case '.':
+			s, err = parseDotSegment(s[1:], &seg)
+		case '[':
+			s, err = parseBracketSegment(s[1:], &seg)
+		default:
+			err = ErrInvalidPath
+		}
+		if err != nil {
+			return nil, err
+		}
+		p.segments = append(p.segments, seg)
+	}
+	return p, nil
+}
+
+// parseDotSegment parses a .key or .* selector and returns the rest of the path
+func parseDotSegment(s string, seg *segment) (string, error) {
+	end := strings.IndexAny(s, ".[")
+	if end < 0 {
+		end = len(s)
+	}
+	name := s[:end]
+	switch name {
+	case "":
+		return "", ErrInvalidPath
+	case "*":
+		seg.kind = segmentWildcard
+	default:
+		seg.kind = segmentKeys
+		seg.keys = []string{name}
+	}
+	return s[end:], nil
+}
+
+// parseBracketSegment parses a selector between brackets and returns the rest of the path
+func parseBracketSegment(s string, seg *segment) (string, error) {
+	s = strings.TrimLeft(s, " ")
+	if strings.HasPrefix(s, "*") {
+		s = strings.TrimLeft(s[1:], " ")
+		if !strings.HasPrefix(s, "]") {
+			return "", ErrInvalidPath
+		}
+		seg.kind = segmentWildcard
+		return s[1:], nil
+	}
+
+	if s != "" && (s[0] == '\'' || s[0] == '"') {
+		seg.kind = segmentKeys
+		for {
+			key, rest, err := parseQuotedKey(s)
+			if err != nil {
+				return "", err
+			}
+			seg.keys = append(seg.keys, key)
+			rest = strings.TrimLeft(rest, " ")
+			if strings.HasPrefix(rest, "]") {
+				return rest[1:], nil
+			}
+			if !strings.HasPrefix(rest, ",") {
+				return "", ErrInvalidPath
+			}
+			s = strings.TrimLeft(rest[1:], " ")
+		}
+	}
+
+	end := strings.IndexByte(s, ']')
+	if end < 0 {
+		return "", ErrInvalidPath
+	}
+	selector, rest := strings.ReplaceAll(s[:end], " ", ""), s[end+1:]
+	if bounds := strings.Split(selector, ":"); len(bounds) == 2 {
+		seg.kind = segmentSlice
+		for i, b := range bounds {
+			if b == "" {
+				continue
+			}
+			n, err := strconv.Atoi(b)
+			if err != nil {
+				return "", ErrInvalidPath
+			}
+			if i == 0 {
+				seg.start = &n
+			} else {
+				seg.end = &n
+			}
+		}
+		return rest, nil
+	}
+	seg.kind = segmentIndexes
+	for _, index := range strings.Split(selector, ",") {
+		n, err := strconv.Atoi(index)
+		if err != nil {
+			return "", ErrInvalidPath
+		}
+		seg.indexes = append(seg.indexes, n)
+	}
+	return rest, nil
+}
+
+// parseQuotedKey parses a key between single or double quotes, with backslash escapes
+func parseQuotedKey(s string) (string, string, error) {
+	quote := s[0]
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case quote:
+			return b.String(), s[i+1:], nil
+		case '\\':
+			if i+1 == len(s) {
+				return "", "", ErrInvalidPath
+			}
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return "", "", ErrInvalidPath
+}
+
+// String returns the text the path was parsed from
+func (p *Path) String() string {
+	return p.text
+}
+
+// Legacy reports whether the path is a legacy path, selecting at most one value
+func (p *Path) Legacy() bool {
+	return p.legacy
+}
+
+// IsRoot reports whether the path selects the root only
+func (p *Path) IsRoot() bool {
+	return len(p.segments) == 0
+}
+
+// parent returns the path without its last segment and the key that last segment selects,
+// if it is a single key
+func (p *Path) parent() (*Path, string, bool) {
+	if len(p.segments) == 0 {
+		return nil, "", false
+	}
+	last := p.segments[len(p.segments)-1]
+	if last.recursive || last.kind != segmentKeys || len(last.keys) != 1 {
+		return nil, "", false
+	}
+	return &Path{text: p.text, legacy: p.legacy, segments: p.segments[:len(p.segments)-1]}, last.keys[0], true
+}
+
+// selectFrom returns the values of doc selected by the path, at most one for a legacy path
+func (p *Path) selectFrom(doc *Document) []Match {
+	matches := []Match{{Value: doc.Root, parent: doc}}
+	for _, seg := range p.segments {
+		var next []Match
+		for _, m := range matches {
+			if seg.recursive {
+				forEachDescendant(m, func(d Match) {
+					next = seg.apply(d, next)
+				})
+			} else {
+				next = seg.apply(m, next)
+			}
+		}
+		matches = next
+	}
+	if p.legacy && len(matches) > 1 {
+		matches = matches[:1]
+	}
+	return matches
+}
+
+// forEachDescendant calls fn on m and all its descendants, parents first
+func forEachDescendant(m Match, fn func(Match)) {
+	fn(m)
+	switch v := m.Value.(type) {
+	case *Object:
+		for _, key := range v.keys {
+			forEachDescendant(Match{Value: v.values[key], parent: v, key: key}, fn)
+		}
+	case *Array:
+		for i, item := range v.Items {
+			forEachDescendant(Match{Value: item, parent: v, index: i}, fn)
+		}
+	}
+}
+
+// apply appends the children of m selected by the segment to res
+func (seg *segment) apply(m Match, res []Match) []Match {
+	switch v := m.Value.(type) {
+	case *Object:
+		switch seg.kind {
+		case segmentKeys:
+			for _, key := range seg.keys {
+				if child, ok := v.values[key]; ok {
+					res = append(res, Match{Value: child, parent: v, key: key})
+				}
+			}
+		case segmentWildcard:
+			for _, key := range v.keys {
+				res = append(res, Match{Value: v.values[key], parent: v, key: key})
+			}
+		}
+	case *Array:
+		n := len(v.Items)
+		switch seg.kind {
+		case segmentWildcard:
+			for i, item := range v.Items {
+				res = append(res, Match{Value: item, parent: v, index: i})
+			}
+		case segmentIndexes:
+			for _, i := range seg.indexes {
+				if i < 0 {
+					i += n
+				}
+				if i >= 0 && i < n {
+					res = append(res, Match{Value: v.Items[i], parent: v, index: i})
+				}
+			}
+		case segmentSlice:
+			start, end := 0, n
+			if seg.start != nil {
+				start = normalizeBound(*seg.start, n)
+			}
+			if seg.end != nil {
+				end = normalizeBound(*seg.end, n)
+			}
+			for i := start; i < end; i++ {
+				res = append(res, Match{Value: v.Items[i], parent: v, index: i})
+			}
+		}
+	}
+	return res
+}
+
+// normalizeBound maps a slice bound to [0, n], negative bounds counting from the end
+func normalizeBound(i, n int) int {
+	if i < 0 {
+		i += n
+	}
+	return max(0, min(i, n))
+}
@@ -0,0 +1,272 @@
+package json_doc
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// A value of a document is nil, bool, int64, float64, string, *Array or *Object. Arrays and
+// objects are pointers so they can be updated in place.
+
+var ErrInvalidJSON = errors.New("invalid JSON")
+
+// Object keeps its keys in insertion order, like the text it was parsed from
+type Object struct {
+	keys   []string
+	values map[string]any
+}
+
+func NewObject() *Object {
+	return &Object{values: make(map[string]any)}
+}
+
+func (o *Object) Get(key string) (any, bool) {
+	v, ok := o.values[key]
+	return v, ok
+}
+
+// Set adds or replaces key, a new key is added last
+func (o *Object) Set(key string, v any) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = v
+}
+
+func (o *Object) Delete(key string) bool {
+	if _, ok := o.values[key]; !ok {
+		return false
+	}
+	delete(o.values, key)
+	for i, k := range o.keys {
+		if k == key {
+			o.keys = append(o.keys[:i], o.keys[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+func (o *Object) Keys() []string {
+	return append([]string(nil), o.keys...)
+}
+
+func (o *Object) Len() int {
+	return len(o.keys)
+}
+
+type Array struct {
+	Items []any
+}
+
+// Insert inserts values before index, which must be in [0, len]
+func (a *Array) Insert(index int, values ...any) {
+	a.Items = append(a.Items[:index], append(values, a.Items[index:]...)...)
+}
+
+// Parse parses a JSON text
+func Parse(text string) (any, error) {
+	dec := json.NewDecoder(strings.NewReader(text))
+	dec.UseNumber()
+	v, err := parseValue(dec)
+	if err != nil {
+		return nil, ErrInvalidJSON
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, ErrInvalidJSON
+	}
+	return v, nil
+}
+
+func parseValue(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		if t == '{' {
+			obj := NewObject()
+			for dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				v, err := parseValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				obj.Set(key.(string), v)
+			}
+			_, err := dec.Token()
+			return obj, err
+		}
+		if t == '[' {
+			arr := &Array{Items: []any{}}
+			for dec.More() {
+				v, err := parseValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				arr.Items = append(arr.Items, v)
+			}
+			_, err := dec.Token()
+			return arr, err
+		}
+		return nil, ErrInvalidJSON
+	case json.Number:
+		return parseNumber(string(t))
+	default:
+		// string, bool or nil
+		return t, nil
+	}
+}
+
+// parseNumber returns an int64 for the integers that fit, else a float64
+func parseNumber(s string) (any, error) {
+	if !strings.ContainsAny(s, ".eE") {
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n, nil
+		}
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsInf(f, 0) {
+		return nil, ErrInvalidJSON
+	}
+	return f, nil
+}
+
+// TypeOf returns the JSON type of v: "null", "boolean", "integer", "number", "string",
+// "array" or "object"
+func TypeOf(v any) string {
+	switch v.(type) {
+	case bool:
+		return "boolean"
+	case int64:
+		return "integer"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case *Array:
+		return "array"
+	case *Object:
+		return "object"
+	default:
+		return "null"
+	}
+}
+
+// Format controls how Marshal lays out arrays and objects
+type Format struct {
+	Indent  string // repeated for each nesting level
+	Newline string // written after each element
+	Space   string // written between a key and its value
+}
+
+// Marshal returns the compact JSON text of v
+func Marshal(v any) string {
+	return Format{}.Marshal(v)
+}
+
+func (f Format) Marshal(v any) string {
+	var b strings.Builder
+	f.marshal(&b, v, "")
+	return b.String()
+}
+
+func (f Format) marshal(b *strings.Builder, v any, prefix string) {
+	switch t := v.(type) {
+	case nil:
+		b.WriteString("null")
+	case bool:
+		b.WriteString(strconv.FormatBool(t))
+	case int64:
+		b.WriteString(strconv.FormatInt(t, 10))
+	case float64:
+		b.WriteString(FormatFloat(t))
+	case string:
+		writeString(b, t)
+	case *Array:
+		if len(t.Items) == 0 {
+			b.WriteString("[]")
+			return
+		}
+		b.WriteByte('[')
+		for i, item := range t.Items {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(f.Newline + prefix + f.Indent)
+			f.marshal(b, item, prefix+f.Indent)
+		}
+		b.WriteString(f.Newline + prefix + "]")
+	case *Object:
+		if t.Len() == 0 {
+			b.WriteString("{}")
+			return
+		}
+		b.WriteByte('{')
+		for i, key := range t.keys {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(f.Newline + prefix + f.Indent)
+			writeString(b, key)
+			b.WriteString(":" + f.Space)
+			f.marshal(b, t.values[key], prefix+f.Indent)
+		}
+		b.WriteString(f.Newline + prefix + "}")
+	}
+}
+
+// FormatFloat formats a number that is not an integer, keeping a ".0" for integral values
+// so that it reads back as a float
+func FormatFloat(f float64) string {
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eE") {
+		s += ".0"
+	}
+	return s
+}
+
+func writeString(b *strings.Builder, s string) {
+	const hex = "0123456789abcdef"
+	b.WriteByte('"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c == '\n':
+			b.WriteString(`\n`)
+		case c == '\r':
+			b.WriteString(`\r`)
+		case c == '\t':
+			b.WriteString(`\t`)
+		case c < 0x20:
+			b.WriteString(`\u00`)
+			b.WriteByte(hex[c>>4])
+			b.WriteByte(hex[c&0xf])
+		case c < utf8.RuneSelf:
+			b.WriteByte(c)
+		default:
+			r, size := utf8.DecodeRuneInString(s[i:])
+			if r == utf8.RuneError && size == 1 {
+				b.WriteString(`\ufffd`)
+			} else {
+				b.WriteString(s[i : i+size])
+			}
+			i += size
+			continue
+		}
+		i++
+	}
+	b.WriteByte('"')
+}
@@ -0,0 +1,124 @@
+package listpack
+
+import "encoding/binary"
+
+// Listpack stores a sequence of strings in one contiguous byte slice.
+// Each entry is encoded as <uvarint length><bytes>, so a small collection costs a single
+// allocation instead of one map bucket or tree node per element.
+// Entries are addressed by their byte offset: 0 is the first entry and Next gives the
+// offset of the following one. Offsets are invalidated by any modification.
+type Listpack struct {
+	buf   []byte
+	count int
+}
+
+func New() *Listpack {
+	return &Listpack{}
+}
+
+// Len returns the number of entries
+func (lp *Listpack) Len() int {
+	return lp.count
+}
+
+// Bytes returns the size of the encoded entries in bytes
+func (lp *Listpack) Bytes() int {
+	return len(lp.buf)
+}
+
+// End returns the offset right after the last entry
+func (lp *Listpack) End() int {
+	return len(lp.buf)
+}
+
+// Get returns the entry at offset pos and the offset of the next entry
+func (lp *Listpack) Get(pos int) (string, int) {
+	length, n := binary.Uvarint(lp.buf[pos:])
+	start := pos + n
+	end := start + int(length)
+	return string(lp.buf[start:end]), end
+}
+
+// Next returns the offset of the entry following the one at pos
+func (lp *Listpack) Next(pos int) int {
+	length, n := binary.Uvarint(lp.buf[pos:])
+	return pos + n + int(length)
+}
+
+// Seek returns the offset of the entry at index, or End() if index is out of range
+func (lp *Listpack) Seek(index int) int {
+	if index < 0 || index >= lp.count {
+		return lp.End()
+	}
+	pos := 0
+	for i := 0; i < index; i++ {
+		pos = lp.Next(pos)
+	}
+	return pos
+}
+
+// Find returns the offset of the first entry equal to entry, starting at pos and skipping
+// skip entries after each comparison (skip=1 looks at the keys of a key/value listpack).
+// Returns -1 if not found.
+func (lp *Listpack) Find(pos int, entry string, skip int) int {
+	for pos < len(lp.buf) {
+		v, next := lp.Get(pos)
+		if v == entry {
+			return pos
+		}
+		pos = next
+		for i := 0; i < skip && pos < len(lp.buf); i++ {
+			pos = lp.Next(pos)
+		}
+	}
+	return -1
+}
+
+// Append adds entries at the end
+func (lp *Listpack) Append(entries ...string) {
+	for _, e := range entries {
+		lp.buf = binary.AppendUvarint(lp.buf, uint64(len(e)))
+		lp.buf = append(lp.buf, e...)
+		lp.count++
+	}
+}
+
+// Insert adds entries before the entry at offset pos (pos == End() appends)
+func (lp *Listpack) Insert(pos int, entries ...string) {
+	var encoded []byte
+	for _, e := range entries {
+		encoded = binary.AppendUvarint(encoded, uint64(len(e)))
+		encoded = append(encoded, e...)
+	}
+	lp.buf = append(lp.buf[:pos], append(encoded, lp.buf[pos:]...)...)
+	lp.count += len(entries)
+}
+
+// Replace overwrites the entry at offset pos
+func (lp *Listpack) Replace(pos int, entry string) {
+	next := lp.Next(pos)
+	encoded := binary.AppendUvarint(nil, uint64(len(entry)))
+	encoded = append(encoded, entry...)
+	lp.buf = append(lp.buf[:pos], append(encoded, lp.buf[next:]...)...)
+}
+
+// Delete removes count entries starting at offset pos
+func (lp *Listpack) Delete(pos int, count int) {
+	end := pos
+	for i := 0; i < count && end < len(lp.buf); i++ {
+		end = lp.Next(end)
+		lp.count--
+	}
+	lp.buf = append(lp.buf[:pos], lp.buf[end:]...)
+}
+
+// Entries decodes all the entries
+func (lp *Listpack) Entries() []string {
+	res := make([]string, 0, lp.count)
+	for pos := 0; pos < len(lp.buf); {
+		var v string
+		v, pos = lp.Get(pos)
+		res = append(res, v)
+	}
+	return res
+}
@@ -0,0 +1,45 @@
+package listpack
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListpack_AppendGet(t *testing.T) {
+	lp := New()
+	long := strings.Repeat("x", 300) // length needs a 2-byte varint
+	lp.Append("a", "", long, "bc")
+	assert.Equal(t, 4, lp.Len())
+	assert.Equal(t, []string{"a", "", long, "bc"}, lp.Entries())
+
+	pos := lp.Seek(2)
+	v, next := lp.Get(pos)
+	assert.Equal(t, long, v)
+	v, _ = lp.Get(next)
+	assert.Equal(t, "bc", v)
+	assert.Equal(t, lp.End(), lp.Seek(4))
+}
+
+func TestListpack_Modify(t *testing.T) {
+	lp := New()
+	lp.Append("f1", "v1", "f2", "v2", "f3", "v3")
+
+	// Find only looks at keys when skipping values
+	assert.Equal(t, -1, lp.Find(0, "v1", 1))
+	pos := lp.Find(0, "f2", 1)
+	assert.Equal(t, lp.Seek(2), pos)
+
+	lp.Replace(lp.Next(pos), "value-two")
+	assert.Equal(t, []string{"f1", "v1", "f2", "value-two", "f3", "v3"}, lp.Entries())
+
+	lp.Delete(pos, 2)
+	assert.Equal(t, 4, lp.Len())
+	assert.Equal(t, []string{"f1", "v1", "f3", "v3"}, lp.Entries())
+
+	lp.Insert(0, "f0", "v0")
+	lp.Insert(lp.End(), "f4", "v4")
+	assert.Equal(t, []string{"f0", "v0", "f1", "v1", "f3", "v3", "f4", "v4"}, lp.Entries())
+	assert.Equal(t, 8, lp.Len())
+}
@@ -12,6 +12,9 @@ const (
 	ABigSeed  uint32  = 0x9747b28c
 )
 
+// MaxBloomBits bounds the size of a Bloom filter, and of each sub-filter of a scalable one
+const MaxBloomBits uint64 = 1 << 32
+
 type Bloom struct {
 	Hashes      int // number of hash functions
 	Entries     uint64
@@ -32,6 +35,12 @@ func calcBpe(err float64) float64 {
 	return math.Abs(-(num / Ln2Square))
 }
 
+// MaxBloomEntries returns the largest capacity of a filter with errorRate that fits in
+// MaxBloomBits
+func MaxBloomEntries(errorRate float64) uint64 {
+	return uint64(float64(MaxBloomBits) / calcBpe(errorRate))
+}
+
 func NewBloomFilter(entries uint64, errorRate float64) MembershipTester {
 	bloom := &Bloom{
 		Entries: entries,
@@ -54,6 +63,10 @@ func NewBloomFilter(entries uint64, errorRate float64) MembershipTester {
 	return bloom
 }
 
+func (b *Bloom) Bytes() uint64 {
+	return b.words * 8
+}
+
 func (b *Bloom) CalcHash(entry string) HashValue {
 	hasher := murmur3.New128WithSeed(ABigSeed)
 	hasher.Write([]byte(entry))
@@ -105,3 +118,57 @@ func (b *Bloom) ExistHash(initHash HashValue) bool {
 	}
 	return true
 }
+
+var _ ChunkedSketch = (*Bloom)(nil)
+
+func (b *Bloom) MarshalHeader() []byte {
+	e := encoder{}
+	e.uint64(b.Entries)
+	e.float64(b.Error)
+	e.uint64(uint64(b.Hashes))
+	e.uint64(b.bits)
+	return e.buf
+}
+
+func (b *Bloom) UnmarshalHeader(header []byte) error {
+	d := decoder{buf: header}
+	entries, errorRate := d.uint64(), d.float64()
+	hashes, bits := d.uint64(), d.uint64()
+	if err := d.finish(); err != nil {
+		return err
+	}
+	if hashes == 0 || hashes > 64 || bits == 0 || bits%64 != 0 || bits/64 > maxDecodedWords ||
+		!(errorRate > 0 && errorRate < 1) {
+		return ErrInvalidEncoding
+	}
+	*b = Bloom{
+		Hashes:      int(hashes),
+		Entries:     entries,
+		Error:       errorRate,
+		bitPerEntry: calcBpe(errorRate),
+		bf:          make([]uint64, bits/64),
+		bits:        bits,
+		words:       bits / 64,
+	}
+	return nil
+}
+
+func (b *Bloom) DataSize() uint64 {
+	return b.Bytes()
+}
+
+func (b *Bloom) DataChunk(offset uint64, maxSize int) []byte {
+	return wordsChunk(b.bf, offset, maxSize)
+}
+
+func (b *Bloom) LoadChunk(offset uint64, chunk []byte) error {
+	return loadWords(b.bf, offset, chunk)
+}
+
+func (b *Bloom) MarshalBinary() ([]byte, error) {
+	return marshalChunked(b), nil
+}
+
+func (b *Bloom) UnmarshalBinary(data []byte) error {
+	return unmarshalChunked(b, data)
+}
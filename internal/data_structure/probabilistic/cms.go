@@ -1,14 +1,23 @@
 package probabilistic
 
 import (
+	"errors"
 	"math"
+	"math/bits"
 
 	"github.com/spaolacci/murmur3"
 )
 
+var (
+	ErrCMSOverflow      = errors.New("CMS: INCRBY overflow")
+	ErrCMSMergeOverflow = errors.New("CMS: MERGE overflow")
+	ErrCMSDimMismatch   = errors.New("CMS: width/depth is not equal")
+)
+
 type CMS struct {
 	width   uint64
 	depth   uint64
+	total   uint64   // sum of all the increments
 	counter []uint64 // 1D slides: index = i*width + j for better cache locality than 2D array
 }
 
@@ -42,8 +51,33 @@ func (c *CMS) calcHash(item string, seed uint32) uint64 {
 	return hasher.Sum64()
 }
 
-// IncrBy increments an item by value and returns the estimated count.
-func (c *CMS) IncrBy(item string, value uint64) uint64 {
+func (c *CMS) Width() uint64 {
+	return c.width
+}
+
+func (c *CMS) Depth() uint64 {
+	return c.depth
+}
+
+func (c *CMS) Total() uint64 {
+	return c.total
+}
+
+// saturatingAdd returns a + b, or MaxUint64 and false on overflow
+func saturatingAdd(a, b uint64) (uint64, bool) {
+	sum, carry := bits.Add64(a, b, 0)
+	if carry != 0 {
+		return math.MaxUint64, false
+	}
+	return sum, true
+}
+
+// IncrBy increments an item by value and returns the estimated count. ErrCMSOverflow is
+// returned when a counter of the item saturates, the total saturating silently.
+func (c *CMS) IncrBy(item string, value uint64) (uint64, error) {
+	var err error
+	var ok bool
+	c.total, _ = saturatingAdd(c.total, value)
 	var minCount uint64 = math.MaxUint64
 	for i := uint64(0); i < c.depth; i++ {
 		hash := c.calcHash(item, uint32(i))
@@ -51,17 +85,15 @@ func (c *CMS) IncrBy(item string, value uint64) uint64 {
 		idx := c.getIndex(i, j)
 
 		// Prevent overflow
-		if math.MaxUint64-c.counter[idx] < value {
-			c.counter[idx] = math.MaxUint64
-		} else {
-			c.counter[idx] += value
+		if c.counter[idx], ok = saturatingAdd(c.counter[idx], value); !ok {
+			err = ErrCMSOverflow
 		}
 		if c.counter[idx] < minCount {
 			minCount = c.counter[idx]
 		}
 	}
 
-	return minCount
+	return minCount, err
 }
 
 // Count returns the estimated count of an item.
@@ -78,3 +110,78 @@ func (c *CMS) Count(item string) uint64 {
 	}
 	return minCount
 }
+
+// Merge replaces the counters of c with the weighted sum of the counters of sources.
+// c may be one of the sources.
+func (c *CMS) Merge(sources []FrequencyEstimator, weights []uint64) error {
+	counter := make([]uint64, len(c.counter))
+	var total uint64
+	for i, source := range sources {
+		src, ok := source.(*CMS)
+		if !ok || src.width != c.width || src.depth != c.depth {
+			return ErrCMSDimMismatch
+		}
+		for j, v := range src.counter {
+			hi, product := bits.Mul64(v, weights[i])
+			if hi != 0 {
+				return ErrCMSMergeOverflow
+			}
+			if counter[j], ok = saturatingAdd(counter[j], product); !ok {
+				return ErrCMSMergeOverflow
+			}
+		}
+		hi, product := bits.Mul64(src.total, weights[i])
+		if hi != 0 {
+			return ErrCMSMergeOverflow
+		}
+		if total, ok = saturatingAdd(total, product); !ok {
+			return ErrCMSMergeOverflow
+		}
+	}
+	c.counter = counter
+	c.total = total
+	return nil
+}
+
+var _ ChunkedSketch = (*CMS)(nil)
+
+func (c *CMS) MarshalHeader() []byte {
+	e := encoder{}
+	e.uint64(c.width)
+	e.uint64(c.depth)
+	e.uint64(c.total)
+	return e.buf
+}
+
+func (c *CMS) UnmarshalHeader(header []byte) error {
+	d := decoder{buf: header}
+	width, depth, total := d.uint64(), d.uint64(), d.uint64()
+	if err := d.finish(); err != nil {
+		return err
+	}
+	if width == 0 || depth == 0 || width > maxDecodedWords/depth {
+		return ErrInvalidEncoding
+	}
+	*c = CMS{width: width, depth: depth, total: total, counter: make([]uint64, width*depth)}
+	return nil
+}
+
+func (c *CMS) DataSize() uint64 {
+	return uint64(len(c.counter)) * 8
+}
+
+func (c *CMS) DataChunk(offset uint64, maxSize int) []byte {
+	return wordsChunk(c.counter, offset, maxSize)
+}
+
+func (c *CMS) LoadChunk(offset uint64, chunk []byte) error {
+	return loadWords(c.counter, offset, chunk)
+}
+
+func (c *CMS) MarshalBinary() ([]byte, error) {
+	return marshalChunked(c), nil
+}
+
+func (c *CMS) UnmarshalBinary(data []byte) error {
+	return unmarshalChunked(c, data)
+}
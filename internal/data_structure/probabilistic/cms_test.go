@@ -50,11 +50,21 @@ func TestOverflowProtection(t *testing.T) {
 	cms.IncrBy("big", huge)
 
 	// next increment should saturate at MaxUint64
-	cms.IncrBy("big", 100)
+	if _, err := cms.IncrBy("big", 100); err != ErrCMSOverflow {
+		t.Errorf("Expected ErrCMSOverflow, got %v", err)
+	}
 
 	if cms.Count("big") != math.MaxUint64 {
 		t.Errorf("Expected saturated MaxUint64, got %d", cms.Count("big"))
 	}
+
+	// A saturated total alone is not an error
+	if count, err := cms.IncrBy("small", 7); err != nil || count != 7 {
+		t.Errorf("Expected 7 and no error, got %d and %v", count, err)
+	}
+	if cms.Total() != math.MaxUint64 {
+		t.Errorf("Expected saturated total, got %d", cms.Total())
+	}
 }
 
 func TestDifferentItemsIndependence(t *testing.T) {
@@ -75,3 +85,49 @@ func TestDifferentItemsIndependence(t *testing.T) {
 		t.Errorf("Expected x >= y, got x=%d, y=%d", countX, countY)
 	}
 }
+
+func TestDimsAndTotal(t *testing.T) {
+	cms := NewCMS(100, 5)
+	cms.IncrBy("a", 3)
+	cms.IncrBy("b", 4)
+
+	if cms.Width() != 100 || cms.Depth() != 5 {
+		t.Errorf("Expected 100x5, got %dx%d", cms.Width(), cms.Depth())
+	}
+	if cms.Total() != 7 {
+		t.Errorf("Expected total 7, got %d", cms.Total())
+	}
+}
+
+func TestMerge(t *testing.T) {
+	a := NewCMS(100, 5)
+	b := NewCMS(100, 5)
+	a.IncrBy("x", 3)
+	b.IncrBy("x", 2)
+	b.IncrBy("y", 1)
+
+	dest := NewCMS(100, 5)
+	if err := dest.Merge([]FrequencyEstimator{a, b}, []uint64{1, 10}); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if dest.Count("x") < 23 || dest.Count("y") < 10 {
+		t.Errorf("Expected x >= 23 and y >= 10, got x=%d, y=%d", dest.Count("x"), dest.Count("y"))
+	}
+	if dest.Total() != 33 {
+		t.Errorf("Expected total 33, got %d", dest.Total())
+	}
+
+	// The destination can be a source
+	if err := a.Merge([]FrequencyEstimator{a, a}, []uint64{1, 1}); err != nil || a.Count("x") < 6 {
+		t.Errorf("Expected x >= 6, got %d (%v)", a.Count("x"), err)
+	}
+
+	if err := dest.Merge([]FrequencyEstimator{NewCMS(50, 5)}, []uint64{1}); err != ErrCMSDimMismatch {
+		t.Errorf("Expected ErrCMSDimMismatch, got %v", err)
+	}
+	big := NewCMS(100, 5)
+	big.IncrBy("x", math.MaxUint64/2)
+	if err := dest.Merge([]FrequencyEstimator{big}, []uint64{3}); err != ErrCMSMergeOverflow {
+		t.Errorf("Expected ErrCMSMergeOverflow, got %v", err)
+	}
+}
@@ -0,0 +1,318 @@
+package probabilistic
+
+import (
+	"math/bits"
+	"math/rand"
+
+	"github.com/spaolacci/murmur3"
+)
+
+// altHashMultiplier mixes a fingerprint into the alternate bucket index
+const altHashMultiplier = 0x5bd1e995
+
+// CuckooFilter stores an 8-bit fingerprint of each item in one of two candidate buckets.
+// Unlike a Bloom filter, items can be deleted and counted. When an item does not fit after
+// MaxIterations relocations, a new sub-filter Expansion times larger is added. An Expansion
+// of 0 disables the growth and Insert fails once the filter is full.
+type CuckooFilter struct {
+	BucketSize    int
+	MaxIterations int
+	Expansion     int
+	filters       []*cuckooSubFilter
+	items         uint64 // number of items inserted
+	deletes       uint64 // number of items deleted
+}
+
+var _ DeletableMembershipTester = (*CuckooFilter)(nil)
+
+// MaxCuckooBytes bounds the size of each sub-filter of a cuckoo filter
+const MaxCuckooBytes uint64 = 1 << 30
+
+// cuckooSubFilter is a table of numBuckets buckets of bucketSize fingerprints, 0 being empty
+type cuckooSubFilter struct {
+	numBuckets uint64 // a power of 2
+	data       []uint8
+}
+
+// NewCuckooFilter creates a filter able to hold about capacity items.
+// The number of buckets and the expansion are rounded up to powers of 2, the size of a
+// sub-filter being bounded by MaxCuckooBytes.
+func NewCuckooFilter(capacity uint64, bucketSize, maxIterations, expansion int) *CuckooFilter {
+	if bucketSize < 1 {
+		bucketSize = 1
+	}
+	if expansion > 0 {
+		expansion = 1 << bits.Len(uint(expansion-1))
+	}
+	numBuckets := capacity / uint64(bucketSize)
+	if numBuckets == 0 {
+		numBuckets = 1
+	}
+	c := &CuckooFilter{BucketSize: bucketSize, MaxIterations: maxIterations, Expansion: expansion}
+	c.grow(min(1<<bits.Len64(numBuckets-1), c.maxBuckets()))
+	return c
+}
+
+// maxBuckets returns the largest power of 2 number of buckets that fits in MaxCuckooBytes
+func (c *CuckooFilter) maxBuckets() uint64 {
+	return 1 << (bits.Len64(MaxCuckooBytes/uint64(c.BucketSize)) - 1)
+}
+
+func (c *CuckooFilter) grow(numBuckets uint64) {
+	c.filters = append(c.filters, &cuckooSubFilter{
+		numBuckets: numBuckets,
+		data:       make([]uint8, numBuckets*uint64(c.BucketSize)),
+	})
+}
+
+// cuckooHash is the hash of an item: its fingerprint and its first bucket before reduction
+type cuckooHash struct {
+	fp uint8
+	h  uint64
+}
+
+func calcCuckooHash(item string) cuckooHash {
+	h := murmur3.Sum64WithSeed([]byte(item), ABigSeed)
+	return cuckooHash{fp: uint8(h%255 + 1), h: h}
+}
+
+func (h cuckooHash) altIndex(index uint64) uint64 {
+	return index ^ (uint64(h.fp) * altHashMultiplier)
+}
+
+// buckets returns the two candidate buckets of h in f
+func (f *cuckooSubFilter) buckets(h cuckooHash) (uint64, uint64) {
+	mask := f.numBuckets - 1
+	i1 := h.h & mask
+	return i1, h.altIndex(i1) & mask
+}
+
+func (f *cuckooSubFilter) bucket(i uint64, bucketSize int) []uint8 {
+	return f.data[i*uint64(bucketSize) : (i+1)*uint64(bucketSize)]
+}
+
+// addToBucket stores fp in a free slot of bucket i
+func (f *cuckooSubFilter) addToBucket(i uint64, bucketSize int, fp uint8) bool {
+	b := f.bucket(i, bucketSize)
+	for j := range b {
+		if b[j] == 0 {
+			b[j] = fp
+			return true
+		}
+	}
+	return false
+}
+
+func (f *cuckooSubFilter) count(h cuckooHash, bucketSize int) int {
+	i1, i2 := f.buckets(h)
+	n := 0
+	for _, fp := range f.bucket(i1, bucketSize) {
+		if fp == h.fp {
+			n++
+		}
+	}
+	if i2 != i1 {
+		for _, fp := range f.bucket(i2, bucketSize) {
+			if fp == h.fp {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func (f *cuckooSubFilter) delete(h cuckooHash, bucketSize int) bool {
+	i1, i2 := f.buckets(h)
+	for _, i := range []uint64{i1, i2} {
+		b := f.bucket(i, bucketSize)
+		for j := range b {
+			if b[j] == h.fp {
+				b[j] = 0
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// insert stores the fingerprint of h, relocating others at most maxIterations times.
+// On failure the relocations are undone, so no fingerprint is lost.
+func (f *cuckooSubFilter) insert(h cuckooHash, bucketSize, maxIterations int) bool {
+	i1, i2 := f.buckets(h)
+	if f.addToBucket(i1, bucketSize, h.fp) || f.addToBucket(i2, bucketSize, h.fp) {
+		return true
+	}
+
+	type swap struct {
+		i    uint64
+		slot int
+	}
+	var swaps []swap
+	fp, i := h.fp, i1
+	if rand.Intn(2) == 0 {
+		i = i2
+	}
+	for n := 0; n < maxIterations; n++ {
+		slot := rand.Intn(bucketSize)
+		b := f.bucket(i, bucketSize)
+		fp, b[slot] = b[slot], fp
+		swaps = append(swaps, swap{i, slot})
+
+		i = cuckooHash{fp: fp}.altIndex(i) & (f.numBuckets - 1)
+		if f.addToBucket(i, bucketSize, fp) {
+			return true
+		}
+	}
+	for n := len(swaps) - 1; n >= 0; n-- {
+		b := f.bucket(swaps[n].i, bucketSize)
+		fp, b[swaps[n].slot] = b[swaps[n].slot], fp
+	}
+	return false
+}
+
+// Insert adds item, the same item can be added several times. Returns false if the filter
+// is full and can not grow.
+func (c *CuckooFilter) Insert(item string) bool {
+	h := calcCuckooHash(item)
+	// Newer filters are larger and have more free slots
+	for i := len(c.filters) - 1; i >= 0; i-- {
+		if c.filters[i].insert(h, c.BucketSize, c.MaxIterations) {
+			c.items++
+			return true
+		}
+	}
+	if c.Expansion == 0 {
+		return false
+	}
+	c.grow(c.nextBuckets(c.filters[len(c.filters)-1]))
+	if !c.filters[len(c.filters)-1].insert(h, c.BucketSize, c.MaxIterations) {
+		return false
+	}
+	c.items++
+	return true
+}
+
+// nextBuckets returns the number of buckets of the sub-filter added after last: Expansion
+// times more, but no more than maxBuckets
+func (c *CuckooFilter) nextBuckets(last *cuckooSubFilter) uint64 {
+	limit := c.maxBuckets()
+	if last.numBuckets > limit/uint64(c.Expansion) {
+		return limit
+	}
+	return last.numBuckets * uint64(c.Expansion)
+}
+
+func (c *CuckooFilter) Add(item string) {
+	c.Insert(item)
+}
+
+func (c *CuckooFilter) Exist(item string) bool {
+	h := calcCuckooHash(item)
+	for _, f := range c.filters {
+		if f.count(h, c.BucketSize) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Count returns how many times item may have been added
+func (c *CuckooFilter) Count(item string) int {
+	h := calcCuckooHash(item)
+	n := 0
+	for _, f := range c.filters {
+		n += f.count(h, c.BucketSize)
+	}
+	return n
+}
+
+// Delete removes one occurrence of item. Deleting an item that was never added may remove
+// another item with the same fingerprint.
+func (c *CuckooFilter) Delete(item string) bool {
+	h := calcCuckooHash(item)
+	for i := len(c.filters) - 1; i >= 0; i-- {
+		if c.filters[i].delete(h, c.BucketSize) {
+			c.items--
+			c.deletes++
+			return true
+		}
+	}
+	return false
+}
+
+// Items returns the number of items in the filter
+func (c *CuckooFilter) Items() uint64 {
+	return c.items
+}
+
+// Deletes returns the number of items deleted
+func (c *CuckooFilter) Deletes() uint64 {
+	return c.deletes
+}
+
+// Filters returns the number of sub-filters
+func (c *CuckooFilter) Filters() int {
+	return len(c.filters)
+}
+
+// Buckets returns the number of buckets of all the sub-filters
+func (c *CuckooFilter) Buckets() uint64 {
+	var n uint64
+	for _, f := range c.filters {
+		n += f.numBuckets
+	}
+	return n
+}
+
+func (c *CuckooFilter) Bytes() uint64 {
+	var size uint64
+	for _, f := range c.filters {
+		size += uint64(len(f.data))
+	}
+	return size
+}
+
+func (c *CuckooFilter) MarshalBinary() ([]byte, error) {
+	e := encoder{}
+	e.uint64(uint64(c.BucketSize))
+	e.uint64(uint64(c.MaxIterations))
+	e.uint64(uint64(c.Expansion))
+	e.uint64(c.items)
+	e.uint64(c.deletes)
+	e.uint64(uint64(len(c.filters)))
+	for _, f := range c.filters {
+		e.bytes(f.data)
+	}
+	return e.buf, nil
+}
+
+func (c *CuckooFilter) UnmarshalBinary(data []byte) error {
+	d := decoder{buf: data}
+	res := CuckooFilter{
+		BucketSize:    int(d.uint64()),
+		MaxIterations: int(d.uint64()),
+		Expansion:     int(d.uint64()),
+		items:         d.uint64(),
+		deletes:       d.uint64(),
+	}
+	n := d.count(8)
+	for i := 0; i < n && d.err == nil; i++ {
+		b := d.bytes()
+		if res.BucketSize < 1 || len(b)%res.BucketSize != 0 {
+			return ErrInvalidEncoding
+		}
+		numBuckets := uint64(len(b) / res.BucketSize)
+		if numBuckets&(numBuckets-1) != 0 {
+			return ErrInvalidEncoding
+		}
+		res.filters = append(res.filters, &cuckooSubFilter{numBuckets: numBuckets, data: append([]uint8(nil), b...)})
+	}
+	if err := d.finish(); err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrInvalidEncoding
+	}
+	*c = res
+	return nil
+}
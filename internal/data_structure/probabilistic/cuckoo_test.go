@@ -0,0 +1,119 @@
+package probabilistic
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCuckooAddDelete(t *testing.T) {
+	cf := NewCuckooFilter(1000, 2, 20, 1)
+	cf.Add("golang")
+	cf.Add("golang")
+
+	if !cf.Exist("golang") {
+		t.Errorf("expected 'golang' to exist in cuckoo filter")
+	}
+	if cf.Exist("python") {
+		t.Errorf("did not expect 'python' to exist in cuckoo filter")
+	}
+	if cf.Count("golang") != 2 {
+		t.Errorf("expected 'golang' to be counted twice, got %d", cf.Count("golang"))
+	}
+
+	if !cf.Delete("golang") || !cf.Delete("golang") {
+		t.Errorf("expected 'golang' to be deleted twice")
+	}
+	if cf.Delete("golang") || cf.Exist("golang") {
+		t.Errorf("expected 'golang' to be gone")
+	}
+	if cf.Items() != 0 || cf.Deletes() != 2 {
+		t.Errorf("expected 0 items and 2 deletes, got %d and %d", cf.Items(), cf.Deletes())
+	}
+}
+
+func TestCuckooFalsePositiveRate(t *testing.T) {
+	n := 5000
+	cf := NewCuckooFilter(uint64(n), 4, 500, 1)
+	for i := 0; i < n; i++ {
+		if !cf.Insert(fmt.Sprintf("item-%d", i)) {
+			t.Fatalf("failed to insert item-%d", i)
+		}
+	}
+	for i := 0; i < n; i++ {
+		if !cf.Exist(fmt.Sprintf("item-%d", i)) {
+			t.Errorf("expected item-%d to exist", i)
+		}
+	}
+
+	falsePositives := 0
+	trials := 10000
+	for i := 0; i < trials; i++ {
+		if cf.Exist(fmt.Sprintf("other-%d", i)) {
+			falsePositives++
+		}
+	}
+	// 2 buckets of 4 slots are compared to an 8-bit fingerprint
+	fpRate := float64(falsePositives) / float64(trials)
+	t.Logf("false positive rate observed = %.4f with %d filters", fpRate, cf.Filters())
+	if fpRate > 8.0/255 {
+		t.Errorf("false positive rate too high: got %.4f", fpRate)
+	}
+}
+
+func TestCuckooExpansion(t *testing.T) {
+	cf := NewCuckooFilter(64, 2, 20, 2)
+	for i := 0; i < 500; i++ {
+		if !cf.Insert(fmt.Sprintf("item-%d", i)) {
+			t.Fatalf("failed to insert item-%d", i)
+		}
+	}
+	if cf.Filters() < 2 {
+		t.Errorf("expected the filter to grow, got %d filters", cf.Filters())
+	}
+	if cf.Buckets()*2 < 500 {
+		t.Errorf("expected room for 500 items, got %d buckets", cf.Buckets())
+	}
+	for i := 0; i < 500; i++ {
+		if !cf.Exist(fmt.Sprintf("item-%d", i)) {
+			t.Errorf("expected item-%d to exist", i)
+		}
+	}
+
+	// Without expansion the filter ends up full, and failed inserts lose nothing
+	cf = NewCuckooFilter(64, 2, 20, 0)
+	var inserted []string
+	for i := 0; i < 200; i++ {
+		item := fmt.Sprintf("item-%d", i)
+		if cf.Insert(item) {
+			inserted = append(inserted, item)
+		}
+	}
+	if len(inserted) == 200 || cf.Filters() != 1 || cf.Items() != uint64(len(inserted)) {
+		t.Errorf("expected a full filter, inserted %d in %d filters", len(inserted), cf.Filters())
+	}
+	for _, item := range inserted {
+		if !cf.Exist(item) {
+			t.Errorf("expected %s to exist", item)
+		}
+	}
+}
+
+func TestCuckooMaxBuckets(t *testing.T) {
+	cf := NewCuckooFilter(64, 255, 20, 32768)
+	if cf.Expansion != 32768 {
+		t.Errorf("expected expansion 32768, got %d", cf.Expansion)
+	}
+	// The grown sub-filter neither overflows nor exceeds MaxCuckooBytes
+	limit := cf.maxBuckets()
+	if limit*255 > MaxCuckooBytes || 2*limit*255 <= MaxCuckooBytes {
+		t.Errorf("unexpected bucket limit %d", limit)
+	}
+	if got := cf.nextBuckets(cf.filters[0]); got != cf.filters[0].numBuckets*32768 {
+		t.Errorf("unexpected number of buckets %d", got)
+	}
+	for _, numBuckets := range []uint64{limit / 2, limit, 1 << 62} {
+		if got := cf.nextBuckets(&cuckooSubFilter{numBuckets: numBuckets}); got != limit {
+			t.Errorf("expected %d buckets after %d, got %d", limit, numBuckets, got)
+		}
+	}
+}
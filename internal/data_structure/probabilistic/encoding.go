@@ -0,0 +1,160 @@
+package probabilistic
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// maxDecodedWords bounds the size of the sketches created from a header, so that corrupted
+// data can not trigger huge allocations
+const maxDecodedWords = 1 << 28
+
+// ErrInvalidEncoding is returned when decoding malformed data
+var ErrInvalidEncoding = errors.New("invalid encoding")
+
+// ChunkedSketch is a sketch that can be dumped and restored in bounded-size chunks: a
+// header holding its parameters, then its data.
+type ChunkedSketch interface {
+	// MarshalHeader encodes the parameters of the sketch
+	MarshalHeader() []byte
+
+	// UnmarshalHeader resets the sketch to an empty one with the decoded parameters
+	UnmarshalHeader(header []byte) error
+
+	// DataSize returns the size of the data in bytes
+	DataSize() uint64
+
+	// DataChunk returns at most maxSize bytes of data starting at offset, nil past the end
+	DataChunk(offset uint64, maxSize int) []byte
+
+	// LoadChunk copies a chunk returned by DataChunk back at offset
+	LoadChunk(offset uint64, chunk []byte) error
+}
+
+// encoder appends little endian values to buf
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) uint64(v uint64) {
+	e.buf = binary.LittleEndian.AppendUint64(e.buf, v)
+}
+
+func (e *encoder) float64(v float64) {
+	e.uint64(math.Float64bits(v))
+}
+
+// bytes appends b prefixed by its length
+func (e *encoder) bytes(b []byte) {
+	e.uint64(uint64(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+// decoder reads the values written by an encoder. The first error is kept and the
+// following reads return zero values.
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) uint64() uint64 {
+	if d.err != nil || len(d.buf) < 8 {
+		d.err = ErrInvalidEncoding
+		return 0
+	}
+	v := binary.LittleEndian.Uint64(d.buf)
+	d.buf = d.buf[8:]
+	return v
+}
+
+func (d *decoder) float64() float64 {
+	return math.Float64frombits(d.uint64())
+}
+
+func (d *decoder) bytes() []byte {
+	n := d.uint64()
+	if d.err != nil || uint64(len(d.buf)) < n {
+		d.err = ErrInvalidEncoding
+		return nil
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+// count reads a number of elements, each encoded on at least size bytes
+func (d *decoder) count(size int) int {
+	n := d.uint64()
+	if d.err != nil || n > uint64(len(d.buf)/size) {
+		d.err = ErrInvalidEncoding
+		return 0
+	}
+	return int(n)
+}
+
+// finish returns the first error, or ErrInvalidEncoding if some data was not read
+func (d *decoder) finish() error {
+	if d.err == nil && len(d.buf) != 0 {
+		return ErrInvalidEncoding
+	}
+	return d.err
+}
+
+// wordsChunk returns at most maxSize bytes of words in little endian, starting at the byte
+// offset
+func wordsChunk(words []uint64, offset uint64, maxSize int) []byte {
+	size := uint64(len(words)) * 8
+	if offset >= size {
+		return nil
+	}
+	end := min(size, offset+uint64(maxSize))
+	chunk := make([]byte, 0, end-offset)
+	for i := offset; i < end; i++ {
+		chunk = append(chunk, byte(words[i/8]>>(8*(i%8))))
+	}
+	return chunk
+}
+
+// loadWords is the inverse of wordsChunk
+func loadWords(words []uint64, offset uint64, chunk []byte) error {
+	if offset+uint64(len(chunk)) > uint64(len(words))*8 {
+		return ErrInvalidEncoding
+	}
+	for j, b := range chunk {
+		i := offset + uint64(j)
+		shift := 8 * (i % 8)
+		words[i/8] = words[i/8]&^(0xff<<shift) | uint64(b)<<shift
+	}
+	return nil
+}
+
+// marshalChunked encodes the header of s followed by its data
+func marshalChunked(s ChunkedSketch) []byte {
+	e := encoder{}
+	e.bytes(s.MarshalHeader())
+	for offset := uint64(0); ; {
+		chunk := s.DataChunk(offset, math.MaxInt32)
+		if len(chunk) == 0 {
+			return e.buf
+		}
+		e.buf = append(e.buf, chunk...)
+		offset += uint64(len(chunk))
+	}
+}
+
+// unmarshalChunked decodes data written by marshalChunked into s
+func unmarshalChunked(s ChunkedSketch, data []byte) error {
+	d := decoder{buf: data}
+	header := d.bytes()
+	if d.err != nil {
+		return d.err
+	}
+	if err := s.UnmarshalHeader(header); err != nil {
+		return err
+	}
+	if uint64(len(d.buf)) != s.DataSize() {
+		return ErrInvalidEncoding
+	}
+	return s.LoadChunk(0, d.buf)
+}
@@ -0,0 +1,139 @@
+package probabilistic
+
+import (
+	"encoding"
+	"fmt"
+	"testing"
+)
+
+// dumpChunks dumps s in chunks of at most chunkSize bytes and loads them into dst
+func dumpChunks(t *testing.T, s, dst ChunkedSketch, chunkSize int) {
+	if err := dst.UnmarshalHeader(s.MarshalHeader()); err != nil {
+		t.Fatalf("unexpected error loading the header: %v", err)
+	}
+	offset := uint64(0)
+	for {
+		chunk := s.DataChunk(offset, chunkSize)
+		if len(chunk) == 0 {
+			break
+		}
+		if len(chunk) > chunkSize {
+			t.Fatalf("expected chunks of at most %d bytes, got %d", chunkSize, len(chunk))
+		}
+		if err := dst.LoadChunk(offset, chunk); err != nil {
+			t.Fatalf("unexpected error loading a chunk: %v", err)
+		}
+		offset += uint64(len(chunk))
+	}
+	if offset != s.DataSize() {
+		t.Errorf("expected %d bytes of data, got %d", s.DataSize(), offset)
+	}
+}
+
+func TestScalableBloomChunks(t *testing.T) {
+	s := NewScalableBloomFilter(100, 0.01, 2)
+	for i := 0; i < 500; i++ {
+		s.Add(fmt.Sprintf("item%d", i))
+	}
+	if s.Filters() < 2 {
+		t.Fatalf("expected several sub-filters, got %d", s.Filters())
+	}
+
+	var restored ScalableBloom
+	dumpChunks(t, s, &restored, 37)
+	if restored.Filters() != s.Filters() || restored.Count() != s.Count() || restored.Capacity() != s.Capacity() {
+		t.Errorf("expected the same filters, count and capacity")
+	}
+	for i := 0; i < 500; i++ {
+		if !restored.Exist(fmt.Sprintf("item%d", i)) {
+			t.Errorf("expected item%d to exist in the restored filter", i)
+		}
+	}
+}
+
+func TestCMSChunks(t *testing.T) {
+	c := NewCMS(100, 5).(*CMS)
+	c.IncrBy("apple", 10)
+	c.IncrBy("banana", 1<<40)
+
+	var restored CMS
+	dumpChunks(t, c, &restored, 64)
+	if restored.Count("apple") != c.Count("apple") || restored.Count("banana") != c.Count("banana") {
+		t.Errorf("expected the same counts")
+	}
+	if restored.Width() != 100 || restored.Depth() != 5 || restored.Total() != c.Total() {
+		t.Errorf("expected the same dimensions and total")
+	}
+}
+
+func TestMarshalBinary(t *testing.T) {
+	topk := NewTopK(3, 8, 7, 0.9)
+	cuckoo := NewCuckooFilter(64, 2, 20, 1)
+	td := NewTDigest(50)
+	for i := 0; i < 1000; i++ {
+		item := fmt.Sprintf("item%d", i%10)
+		topk.IncrBy(item, uint64(i%10))
+		cuckoo.Insert(item)
+		td.Add(float64(i))
+	}
+
+	var restoredTopK TopK
+	var restoredCuckoo CuckooFilter
+	var restoredTDigest TDigest
+	sketches := []struct {
+		name     string
+		s        encoding.BinaryMarshaler
+		restored encoding.BinaryUnmarshaler
+		check    func() bool
+	}{
+		{"topk", topk, &restoredTopK, func() bool {
+			return fmt.Sprint(restoredTopK.List()) == fmt.Sprint(topk.List()) &&
+				restoredTopK.Count("item9") == topk.Count("item9") && restoredTopK.Query("item9")
+		}},
+		{"cuckoo", cuckoo, &restoredCuckoo, func() bool {
+			return restoredCuckoo.Items() == cuckoo.Items() && restoredCuckoo.Count("item1") == cuckoo.Count("item1")
+		}},
+		{"tdigest", td, &restoredTDigest, func() bool {
+			return restoredTDigest.Quantile(0.5) == td.Quantile(0.5) && restoredTDigest.Observations() == 1000 &&
+				restoredTDigest.Min() == 0 && restoredTDigest.Max() == 999
+		}},
+	}
+
+	for _, x := range sketches {
+		data, err := x.s.MarshalBinary()
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", x.name, err)
+		}
+		if err := x.restored.UnmarshalBinary(data); err != nil {
+			t.Fatalf("%s: unexpected error: %v", x.name, err)
+		}
+		if !x.check() {
+			t.Errorf("%s: expected the restored sketch to match", x.name)
+		}
+		if err := x.restored.UnmarshalBinary(data[:len(data)-1]); err != ErrInvalidEncoding {
+			t.Errorf("%s: expected ErrInvalidEncoding for truncated data, got %v", x.name, err)
+		}
+	}
+}
+
+func TestUnmarshalInvalid(t *testing.T) {
+	data, _ := NewCMS(10, 2).(*CMS).MarshalBinary()
+	var c CMS
+	if err := c.UnmarshalBinary(data[:len(data)-3]); err != ErrInvalidEncoding {
+		t.Errorf("expected ErrInvalidEncoding for truncated data, got %v", err)
+	}
+	if err := c.UnmarshalBinary(append(data, 0)); err != ErrInvalidEncoding {
+		t.Errorf("expected ErrInvalidEncoding for trailing data, got %v", err)
+	}
+	if err := c.UnmarshalHeader(make([]byte, 24)); err != ErrInvalidEncoding {
+		t.Errorf("expected ErrInvalidEncoding for a zero width, got %v", err)
+	}
+	if err := c.LoadChunk(1000, []byte{1}); err != ErrInvalidEncoding {
+		t.Errorf("expected ErrInvalidEncoding for a chunk out of range, got %v", err)
+	}
+
+	var b Bloom
+	if err := b.UnmarshalBinary([]byte{1, 2, 3}); err != ErrInvalidEncoding {
+		t.Errorf("expected ErrInvalidEncoding for a short header, got %v", err)
+	}
+}
@@ -5,15 +5,40 @@ type FrequencyEstimator interface {
 	// IncryBy increases the count of item by increment. Multiple items can be increased with one call.
 	// Return array of updated min-counts of each of the provided item in the sketch
 	// Error if: invalid arguments, missing key, overflow, or wrong key type.
-	IncrBy(item string, value uint64) uint64
+	// On overflow the counters saturate and ErrCMSOverflow is returned.
+	IncrBy(item string, value uint64) (uint64, error)
 
 	// Query returns the count for one or more items in a sketch.
 	// Return the min-counts of each of the provided items in the sketch.
 	// Error if: invalid arguments, missing key, or wrong key type.
 	Count(item string) uint64
+
+	// Width returns the number of counters per row
+	Width() uint64
+
+	// Depth returns the number of rows
+	Depth() uint64
+
+	// Total returns the sum of all the increments
+	Total() uint64
+
+	// Merge replaces the counters with the sum of the counters of sources multiplied by
+	// weights. All the sketches must have the same dimensions.
+	Merge(sources []FrequencyEstimator, weights []uint64) error
 }
 
 type MembershipTester interface {
 	Add(item string)
 	Exist(entry string) bool
+
+	// Bytes returns the memory used by the filter in bytes
+	Bytes() uint64
+}
+
+// DeletableMembershipTester is a MembershipTester whose items can be removed
+type DeletableMembershipTester interface {
+	MembershipTester
+
+	// Delete removes one occurrence of item, returns false if it was not found
+	Delete(item string) bool
 }
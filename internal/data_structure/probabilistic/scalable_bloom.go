@@ -0,0 +1,195 @@
+package probabilistic
+
+// TighteningRatio is the factor applied to the error rate of each new sub-filter
+const TighteningRatio = 0.5
+
+// ScalableBloom is a chain of Bloom filters. Items are added to the last filter and once it
+// holds its capacity, a new filter Expansion times larger and with a tighter error rate is
+// appended. The overall false positive rate stays below Error / (1 - TighteningRatio) no
+// matter how many items are added.
+type ScalableBloom struct {
+	Error     float64
+	Expansion int
+	filters   []*Bloom
+	counts    []uint64 // number of items added to each filter
+}
+
+var _ MembershipTester = (*ScalableBloom)(nil)
+
+// NewScalableBloomFilter creates a filter whose first sub-filter holds capacity items with
+// a false positive rate of errorRate. An expansion of 1 adds filters of the same capacity.
+func NewScalableBloomFilter(capacity uint64, errorRate float64, expansion int) *ScalableBloom {
+	if expansion < 1 {
+		expansion = 1
+	}
+	s := &ScalableBloom{Error: errorRate, Expansion: expansion}
+	s.grow(capacity, errorRate)
+	return s
+}
+
+func (s *ScalableBloom) grow(capacity uint64, errorRate float64) {
+	s.filters = append(s.filters, NewBloomFilter(capacity, errorRate).(*Bloom))
+	s.counts = append(s.counts, 0)
+}
+
+// Filters returns the number of sub-filters
+func (s *ScalableBloom) Filters() int {
+	return len(s.filters)
+}
+
+// Capacity returns the number of items the filters can hold before a new one is added
+func (s *ScalableBloom) Capacity() uint64 {
+	var capacity uint64
+	for _, f := range s.filters {
+		capacity += f.Entries
+	}
+	return capacity
+}
+
+// Count returns the number of items added
+func (s *ScalableBloom) Count() uint64 {
+	var count uint64
+	for _, c := range s.counts {
+		count += c
+	}
+	return count
+}
+
+func (s *ScalableBloom) Bytes() uint64 {
+	var size uint64
+	for _, f := range s.filters {
+		size += f.Bytes()
+	}
+	return size
+}
+
+func (s *ScalableBloom) existHash(h HashValue) bool {
+	// The last filter is the largest, it is the most likely to hold the item
+	for i := len(s.filters) - 1; i >= 0; i-- {
+		if s.filters[i].ExistHash(h) {
+			return true
+		}
+	}
+	return false
+}
+
+// TryAdd adds item and reports whether it was not in the filter yet
+func (s *ScalableBloom) TryAdd(item string) bool {
+	last := s.filters[len(s.filters)-1]
+	h := last.CalcHash(item)
+	if s.existHash(h) {
+		return false
+	}
+	if n := len(s.filters) - 1; s.counts[n] >= last.Entries {
+		s.grow(s.nextCapacity(last), last.Error*TighteningRatio)
+		last = s.filters[n+1]
+	}
+	last.AddHash(h)
+	s.counts[len(s.counts)-1]++
+	return true
+}
+
+// nextCapacity returns the capacity of the filter added after last: Expansion times larger,
+// but no larger than MaxBloomBits allow
+func (s *ScalableBloom) nextCapacity(last *Bloom) uint64 {
+	limit := max(MaxBloomEntries(last.Error*TighteningRatio), 1)
+	if last.Entries > limit/uint64(s.Expansion) {
+		return limit
+	}
+	return last.Entries * uint64(s.Expansion)
+}
+
+func (s *ScalableBloom) Add(item string) {
+	s.TryAdd(item)
+}
+
+func (s *ScalableBloom) Exist(item string) bool {
+	return s.existHash(s.filters[0].CalcHash(item))
+}
+
+var _ ChunkedSketch = (*ScalableBloom)(nil)
+
+// MarshalHeader encodes the parameters and the number of items of each sub-filter
+func (s *ScalableBloom) MarshalHeader() []byte {
+	e := encoder{}
+	e.float64(s.Error)
+	e.uint64(uint64(s.Expansion))
+	e.uint64(uint64(len(s.filters)))
+	for i, f := range s.filters {
+		e.bytes(f.MarshalHeader())
+		e.uint64(s.counts[i])
+	}
+	return e.buf
+}
+
+func (s *ScalableBloom) UnmarshalHeader(header []byte) error {
+	d := decoder{buf: header}
+	res := ScalableBloom{Error: d.float64(), Expansion: int(d.uint64())}
+	n := d.count(16)
+	var words uint64
+	for i := 0; i < n && d.err == nil; i++ {
+		f := &Bloom{}
+		if err := f.UnmarshalHeader(d.bytes()); err != nil {
+			return err
+		}
+		if words += f.words; words > maxDecodedWords {
+			return ErrInvalidEncoding
+		}
+		res.filters = append(res.filters, f)
+		res.counts = append(res.counts, d.uint64())
+	}
+	if err := d.finish(); err != nil {
+		return err
+	}
+	if n == 0 || res.Expansion < 1 {
+		return ErrInvalidEncoding
+	}
+	*s = res
+	return nil
+}
+
+// DataSize returns the size of the bits of all the sub-filters, dumped one after the other
+func (s *ScalableBloom) DataSize() uint64 {
+	return s.Bytes()
+}
+
+// DataChunk returns a chunk of a single sub-filter
+func (s *ScalableBloom) DataChunk(offset uint64, maxSize int) []byte {
+	for _, f := range s.filters {
+		if offset < f.Bytes() {
+			return f.DataChunk(offset, maxSize)
+		}
+		offset -= f.Bytes()
+	}
+	return nil
+}
+
+func (s *ScalableBloom) LoadChunk(offset uint64, chunk []byte) error {
+	for _, f := range s.filters {
+		if len(chunk) == 0 {
+			return nil
+		}
+		if offset >= f.Bytes() {
+			offset -= f.Bytes()
+			continue
+		}
+		n := min(uint64(len(chunk)), f.Bytes()-offset)
+		if err := f.LoadChunk(offset, chunk[:n]); err != nil {
+			return err
+		}
+		chunk = chunk[n:]
+		offset = 0
+	}
+	if len(chunk) != 0 {
+		return ErrInvalidEncoding
+	}
+	return nil
+}
+
+func (s *ScalableBloom) MarshalBinary() ([]byte, error) {
+	return marshalChunked(s), nil
+}
+
+func (s *ScalableBloom) UnmarshalBinary(data []byte) error {
+	return unmarshalChunked(s, data)
+}
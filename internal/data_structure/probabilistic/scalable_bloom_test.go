@@ -0,0 +1,104 @@
+package probabilistic
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestScalableBloomGrows(t *testing.T) {
+	sb := NewScalableBloomFilter(100, 0.01, 2)
+	for i := 0; i < 100; i++ {
+		if !sb.TryAdd(fmt.Sprintf("item-%d", i)) {
+			t.Logf("false positive while adding item-%d", i)
+		}
+	}
+	if sb.Filters() != 1 {
+		t.Errorf("expected 1 filter, got %d", sb.Filters())
+	}
+	if sb.TryAdd("item-0") {
+		t.Errorf("expected item-0 to already exist")
+	}
+
+	for i := 100; i < 1000; i++ {
+		sb.Add(fmt.Sprintf("item-%d", i))
+	}
+	// 100 + 200 + 400 + 800 items
+	if sb.Filters() != 4 || sb.Capacity() != 1500 {
+		t.Errorf("expected 4 filters holding 1500 items, got %d holding %d", sb.Filters(), sb.Capacity())
+	}
+	// False positives are not added
+	if sb.Count() > 1000 || sb.Count() < 970 {
+		t.Errorf("expected about 1000 items, got %d", sb.Count())
+	}
+	for i := 0; i < 1000; i++ {
+		if !sb.Exist(fmt.Sprintf("item-%d", i)) {
+			t.Errorf("expected item-%d to exist", i)
+		}
+	}
+}
+
+func TestScalableBloomFalsePositiveRate(t *testing.T) {
+	errorRate := 0.01
+	sb := NewScalableBloomFilter(1000, errorRate, 2)
+	// Fill the filter far above its initial capacity
+	for i := 0; i < 20000; i++ {
+		sb.Add(fmt.Sprintf("item-%d", i))
+	}
+
+	falsePositives := 0
+	trials := 20000
+	for i := 0; i < trials; i++ {
+		if sb.Exist(fmt.Sprintf("other-%d", i)) {
+			falsePositives++
+		}
+	}
+	fpRate := float64(falsePositives) / float64(trials)
+	bound := errorRate / (1 - TighteningRatio)
+	t.Logf("false positive rate observed = %.4f with %d filters (bound %.2f)", fpRate, sb.Filters(), bound)
+	if fpRate > bound*1.5 {
+		t.Errorf("false positive rate too high: got %.4f, want ≤ %.2f", fpRate, bound)
+	}
+
+	// A fixed size filter saturates
+	bf := NewBloomFilter(1000, errorRate)
+	for i := 0; i < 20000; i++ {
+		bf.Add(fmt.Sprintf("item-%d", i))
+	}
+	saturated := 0
+	for i := 0; i < trials; i++ {
+		if bf.Exist(fmt.Sprintf("other-%d", i)) {
+			saturated++
+		}
+	}
+	if saturated <= falsePositives {
+		t.Errorf("expected the fixed size filter to saturate, got %d false positives vs %d", saturated, falsePositives)
+	}
+}
+
+func TestScalableBloomExpansionOne(t *testing.T) {
+	sb := NewScalableBloomFilter(10, 0.01, 1)
+	for i := 0; i < 35; i++ {
+		sb.Add(fmt.Sprintf("item-%d", i))
+	}
+	if sb.Filters() < 4 || sb.Capacity() != uint64(10*sb.Filters()) {
+		t.Errorf("expected filters of 10 items, got %d holding %d", sb.Filters(), sb.Capacity())
+	}
+}
+
+func TestScalableBloomNextCapacity(t *testing.T) {
+	// The grown capacity neither overflows nor exceeds MaxBloomBits
+	limit := MaxBloomEntries(0.005)
+	sb := NewScalableBloomFilter(100, 0.01, 1<<40)
+	if got := sb.nextCapacity(sb.filters[0]); got != limit {
+		t.Errorf("expected capacity %d, got %d", limit, got)
+	}
+	sb = NewScalableBloomFilter(100, 0.01, math.MaxInt)
+	if got := sb.nextCapacity(&Bloom{Entries: 1 << 30, Error: 0.01}); got != limit {
+		t.Errorf("expected capacity %d, got %d", limit, got)
+	}
+	sb = NewScalableBloomFilter(100, 0.01, 2)
+	if got := sb.nextCapacity(sb.filters[0]); got != 200 {
+		t.Errorf("expected capacity 200, got %d", got)
+	}
+}
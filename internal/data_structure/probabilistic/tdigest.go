@@ -0,0 +1,328 @@
+package probabilistic
+
+import (
+	"math"
+	"sort"
+)
+
+// tdigestBufferFactor is the size of the buffer of unmerged values relative to the compression
+const tdigestBufferFactor = 5
+
+// MaxTDigestCompression bounds the compression, which sizes the buffer and the centroids
+const MaxTDigestCompression = 100000
+
+// TDigest estimates the quantiles of a stream of values with the merging t-digest algorithm.
+// Values are buffered, then merged into centroids whose size is bounded by the k1 scale
+// function: centroids are small near the tails, so extreme quantiles stay accurate.
+// Compression bounds the number of centroids to about Compression / 2.
+type TDigest struct {
+	Compression  float64
+	centroids    []Centroid
+	buffer       []float64
+	mergedWeight float64
+	min          float64
+	max          float64
+	observations uint64 // number of values added
+	compressions uint64 // number of merges of the buffer
+}
+
+// Centroid is the mean of Weight values
+type Centroid struct {
+	Mean   float64
+	Weight float64
+}
+
+func NewTDigest(compression float64) *TDigest {
+	t := &TDigest{Compression: compression}
+	t.Reset()
+	return t
+}
+
+// Reset empties the digest
+func (t *TDigest) Reset() {
+	t.centroids = nil
+	t.buffer = make([]float64, 0, t.Capacity())
+	t.mergedWeight = 0
+	t.min = math.Inf(1)
+	t.max = math.Inf(-1)
+	t.observations = 0
+	t.compressions = 0
+}
+
+// Capacity returns the size of the buffer of unmerged values
+func (t *TDigest) Capacity() int {
+	return int(math.Ceil(t.Compression)) * tdigestBufferFactor
+}
+
+// Add adds a value
+func (t *TDigest) Add(value float64) {
+	t.buffer = append(t.buffer, value)
+	t.min = math.Min(t.min, value)
+	t.max = math.Max(t.max, value)
+	t.observations++
+	if len(t.buffer) >= t.Capacity() {
+		t.compress()
+	}
+}
+
+// scale is the k1 scale function, mapping a quantile to an index growing fast near the tails
+func (t *TDigest) scale(q float64) float64 {
+	return t.Compression / (2 * math.Pi) * math.Asin(2*q-1)
+}
+
+// inverseScale maps an index back to a quantile
+func (t *TDigest) inverseScale(k float64) float64 {
+	if k >= t.Compression/4 {
+		return 1
+	}
+	return (math.Sin(k*2*math.Pi/t.Compression) + 1) / 2
+}
+
+// merge merges sorted centroids so that each one spans at most one unit of the scale function
+func (t *TDigest) merge(all []Centroid) {
+	total := 0.0
+	for _, c := range all {
+		total += c.Weight
+	}
+	merged := make([]Centroid, 0, int(t.Compression))
+	if len(all) > 0 {
+		cur := all[0]
+		soFar := 0.0
+		limit := total * t.inverseScale(t.scale(0)+1)
+		for _, c := range all[1:] {
+			if soFar+cur.Weight+c.Weight <= limit {
+				cur.Mean += (c.Mean - cur.Mean) * c.Weight / (cur.Weight + c.Weight)
+				cur.Weight += c.Weight
+				continue
+			}
+			merged = append(merged, cur)
+			soFar += cur.Weight
+			limit = total * t.inverseScale(t.scale(soFar/total)+1)
+			cur = c
+		}
+		merged = append(merged, cur)
+	}
+	t.centroids = merged
+	t.mergedWeight = total
+}
+
+// compress merges the buffered values into the centroids
+func (t *TDigest) compress() {
+	if len(t.buffer) == 0 {
+		return
+	}
+	all := make([]Centroid, 0, len(t.centroids)+len(t.buffer))
+	all = append(all, t.centroids...)
+	for _, v := range t.buffer {
+		all = append(all, Centroid{Mean: v, Weight: 1})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Mean < all[j].Mean })
+	t.merge(all)
+	t.buffer = t.buffer[:0]
+	t.compressions++
+}
+
+// Merge adds the values of other digests
+func (t *TDigest) Merge(others ...*TDigest) {
+	t.compress()
+	all := append([]Centroid(nil), t.centroids...)
+	for _, o := range others {
+		o.compress()
+		all = append(all, o.centroids...)
+		t.min = math.Min(t.min, o.min)
+		t.max = math.Max(t.max, o.max)
+		t.observations += o.observations
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Mean < all[j].Mean })
+	t.merge(all)
+	t.compressions++
+}
+
+// Min returns the smallest value, NaN if the digest is empty
+func (t *TDigest) Min() float64 {
+	if t.observations == 0 {
+		return math.NaN()
+	}
+	return t.min
+}
+
+// Max returns the largest value, NaN if the digest is empty
+func (t *TDigest) Max() float64 {
+	if t.observations == 0 {
+		return math.NaN()
+	}
+	return t.max
+}
+
+// Quantile returns an estimate of the value below which a fraction q of the values falls,
+// NaN if the digest is empty
+func (t *TDigest) Quantile(q float64) float64 {
+	t.compress()
+	n := len(t.centroids)
+	switch {
+	case n == 0:
+		return math.NaN()
+	case q <= 0:
+		return t.min
+	case q >= 1:
+		return t.max
+	case n == 1:
+		return t.centroids[0].Mean
+	}
+
+	// Each centroid is centered on its cumulative weight, the tails end on min and max
+	index := q * t.mergedWeight
+	first, last := t.centroids[0], t.centroids[n-1]
+	if index < first.Weight/2 {
+		return t.min + (first.Mean-t.min)*index/(first.Weight/2)
+	}
+	if index > t.mergedWeight-last.Weight/2 {
+		return t.max - (t.max-last.Mean)*(t.mergedWeight-index)/(last.Weight/2)
+	}
+	soFar := first.Weight / 2
+	for i := 0; i < n-1; i++ {
+		dw := (t.centroids[i].Weight + t.centroids[i+1].Weight) / 2
+		if soFar+dw >= index {
+			z := (index - soFar) / dw
+			return t.centroids[i].Mean + (t.centroids[i+1].Mean-t.centroids[i].Mean)*z
+		}
+		soFar += dw
+	}
+	return last.Mean
+}
+
+// CDF returns an estimate of the fraction of the values below value, NaN if the digest is
+// empty
+func (t *TDigest) CDF(value float64) float64 {
+	t.compress()
+	n := len(t.centroids)
+	switch {
+	case n == 0:
+		return math.NaN()
+	case value < t.min:
+		return 0
+	case value > t.max:
+		return 1
+	case t.min == t.max:
+		return 0.5
+	}
+
+	first, last := t.centroids[0], t.centroids[n-1]
+	if value < first.Mean {
+		return first.Weight / 2 * (value - t.min) / (first.Mean - t.min) / t.mergedWeight
+	}
+	if value >= last.Mean {
+		if t.max == last.Mean {
+			return 1 - last.Weight/2/t.mergedWeight
+		}
+		tail := last.Weight / 2 * (value - last.Mean) / (t.max - last.Mean)
+		return (t.mergedWeight - last.Weight/2 + tail) / t.mergedWeight
+	}
+	soFar := first.Weight / 2
+	for i := 0; i < n-1; i++ {
+		left, right := t.centroids[i], t.centroids[i+1]
+		dw := (left.Weight + right.Weight) / 2
+		if value < right.Mean {
+			return (soFar + dw*(value-left.Mean)/(right.Mean-left.Mean)) / t.mergedWeight
+		}
+		soFar += dw
+	}
+	return 1
+}
+
+// TrimmedMean returns the mean of the values between the quantiles low and high, NaN if
+// the digest is empty
+func (t *TDigest) TrimmedMean(low, high float64) float64 {
+	t.compress()
+	from, to := low*t.mergedWeight, high*t.mergedWeight
+	sum, weight, soFar := 0.0, 0.0, 0.0
+	for _, c := range t.centroids {
+		// Part of the centroid inside [from, to]
+		w := math.Min(soFar+c.Weight, to) - math.Max(soFar, from)
+		if w > 0 {
+			sum += c.Mean * w
+			weight += w
+		}
+		soFar += c.Weight
+	}
+	if weight == 0 {
+		return math.NaN()
+	}
+	return sum / weight
+}
+
+// Observations returns the number of values added
+func (t *TDigest) Observations() uint64 {
+	return t.observations
+}
+
+// TDigestInfo describes the internal state of a digest
+type TDigestInfo struct {
+	Capacity       int
+	MergedNodes    int
+	UnmergedNodes  int
+	MergedWeight   float64
+	UnmergedWeight float64
+	Compressions   uint64
+	Bytes          uint64
+}
+
+func (t *TDigest) Info() TDigestInfo {
+	return TDigestInfo{
+		Capacity:       t.Capacity(),
+		MergedNodes:    len(t.centroids),
+		UnmergedNodes:  len(t.buffer),
+		MergedWeight:   t.mergedWeight,
+		UnmergedWeight: float64(len(t.buffer)),
+		Compressions:   t.compressions,
+		Bytes:          uint64(cap(t.centroids)*16 + cap(t.buffer)*8),
+	}
+}
+
+func (t *TDigest) MarshalBinary() ([]byte, error) {
+	e := encoder{}
+	e.float64(t.Compression)
+	e.float64(t.min)
+	e.float64(t.max)
+	e.uint64(t.observations)
+	e.uint64(t.compressions)
+	e.uint64(uint64(len(t.centroids)))
+	for _, c := range t.centroids {
+		e.float64(c.Mean)
+		e.float64(c.Weight)
+	}
+	e.uint64(uint64(len(t.buffer)))
+	for _, v := range t.buffer {
+		e.float64(v)
+	}
+	return e.buf, nil
+}
+
+func (t *TDigest) UnmarshalBinary(data []byte) error {
+	d := decoder{buf: data}
+	compression := d.float64()
+	if d.err != nil || !(compression > 0 && compression <= MaxTDigestCompression) {
+		return ErrInvalidEncoding
+	}
+	res := NewTDigest(compression)
+	res.min, res.max = d.float64(), d.float64()
+	res.observations, res.compressions = d.uint64(), d.uint64()
+	n := d.count(16)
+	for i := 0; i < n && d.err == nil; i++ {
+		c := Centroid{Mean: d.float64(), Weight: d.float64()}
+		res.centroids = append(res.centroids, c)
+		res.mergedWeight += c.Weight
+	}
+	n = d.count(8)
+	if n > res.Capacity() {
+		return ErrInvalidEncoding
+	}
+	for i := 0; i < n && d.err == nil; i++ {
+		res.buffer = append(res.buffer, d.float64())
+	}
+	if err := d.finish(); err != nil {
+		return err
+	}
+	*t = *res
+	return nil
+}
@@ -0,0 +1,84 @@
+package probabilistic
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestTDigestEmpty(t *testing.T) {
+	td := NewTDigest(100)
+	if !math.IsNaN(td.Quantile(0.5)) || !math.IsNaN(td.CDF(1)) || !math.IsNaN(td.Min()) || !math.IsNaN(td.Max()) {
+		t.Errorf("expected NaN for an empty digest")
+	}
+}
+
+func TestTDigestSmall(t *testing.T) {
+	td := NewTDigest(100)
+	for _, v := range []float64{1, 2, 3, 4, 5} {
+		td.Add(v)
+	}
+	if td.Min() != 1 || td.Max() != 5 {
+		t.Errorf("expected min 1 and max 5, got %v and %v", td.Min(), td.Max())
+	}
+	if td.Quantile(0) != 1 || td.Quantile(1) != 5 || td.Quantile(0.5) != 3 {
+		t.Errorf("unexpected quantiles %v %v %v", td.Quantile(0), td.Quantile(0.5), td.Quantile(1))
+	}
+	if td.CDF(0) != 0 || td.CDF(6) != 1 || td.CDF(3) != 0.5 {
+		t.Errorf("unexpected cdf %v %v %v", td.CDF(0), td.CDF(3), td.CDF(6))
+	}
+	if td.TrimmedMean(0.2, 0.8) != 3 {
+		t.Errorf("expected trimmed mean 3, got %v", td.TrimmedMean(0.2, 0.8))
+	}
+}
+
+func TestTDigestAccuracy(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	td := NewTDigest(100)
+	values := make([]float64, 100000)
+	for i := range values {
+		values[i] = rnd.NormFloat64()
+		td.Add(values[i])
+	}
+	sort.Float64s(values)
+
+	if info := td.Info(); info.MergedNodes > 100 || info.Compressions == 0 {
+		t.Errorf("expected at most 100 centroids, got %+v", info)
+	}
+	for _, q := range []float64{0.001, 0.01, 0.1, 0.5, 0.9, 0.99, 0.999} {
+		got := td.Quantile(q)
+		// Compare the ranks, the error of the t-digest is relative to q(1-q)
+		rank := float64(sort.SearchFloat64s(values, got)) / float64(len(values))
+		if math.Abs(rank-q) > 0.005+0.05*q*(1-q) {
+			t.Errorf("quantile %v: got %v with rank %v", q, got, rank)
+		}
+		if cdf := td.CDF(values[int(q*float64(len(values)))]); math.Abs(cdf-q) > 0.005+0.05*q*(1-q) {
+			t.Errorf("cdf at quantile %v: got %v", q, cdf)
+		}
+	}
+	if mean := td.TrimmedMean(0.1, 0.9); math.Abs(mean) > 0.02 {
+		t.Errorf("expected a trimmed mean close to 0, got %v", mean)
+	}
+}
+
+func TestTDigestMerge(t *testing.T) {
+	a, b := NewTDigest(100), NewTDigest(100)
+	for i := 0; i < 1000; i++ {
+		a.Add(float64(i))
+		b.Add(float64(1000 + i))
+	}
+	merged := NewTDigest(100)
+	merged.Merge(a, b)
+	if merged.Observations() != 2000 || merged.Min() != 0 || merged.Max() != 1999 {
+		t.Errorf("unexpected merge: %d values in [%v, %v]", merged.Observations(), merged.Min(), merged.Max())
+	}
+	if median := merged.Quantile(0.5); math.Abs(median-1000) > 20 {
+		t.Errorf("expected a median close to 1000, got %v", median)
+	}
+
+	merged.Reset()
+	if merged.Observations() != 0 || !math.IsNaN(merged.Quantile(0.5)) {
+		t.Errorf("expected an empty digest after reset")
+	}
+}
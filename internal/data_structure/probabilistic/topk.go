@@ -0,0 +1,256 @@
+package probabilistic
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/spaolacci/murmur3"
+)
+
+// decayLookupSize is the number of precomputed powers of the decay
+const decayLookupSize = 256
+
+// MaxTopK bounds K, the heap being searched linearly. MaxTopKBuckets bounds Width * Depth.
+const (
+	MaxTopK        = 1 << 16
+	MaxTopKBuckets = 1 << 25
+)
+
+// TopK keeps track of the K most frequent items with the HeavyKeeper algorithm.
+// Each of the depth rows has width buckets holding a fingerprint and a count. An item
+// increments the buckets it owns in every row, and decays the buckets owned by other items
+// with a probability of decay^count, taking them over once their count reaches 0. The
+// heaviest items are kept in a min-heap of size K.
+type TopK struct {
+	K       int
+	Width   int
+	Depth   int
+	Decay   float64
+	buckets []topKBucket
+	heap    []TopKItem
+	fps     []uint32 // fingerprints of the items of the heap
+	lookup  []float64
+}
+
+type topKBucket struct {
+	fp    uint32
+	count uint64
+}
+
+// TopKItem is an item of the top-k list with its estimated count
+type TopKItem struct {
+	Item  string
+	Count uint64
+}
+
+// ValidTopKSize reports whether k, width and depth are positive and within MaxTopK and
+// MaxTopKBuckets, as NewTopK expects them
+func ValidTopKSize(k, width, depth int) bool {
+	return k > 0 && k <= MaxTopK && width > 0 && depth > 0 && width <= MaxTopKBuckets/depth
+}
+
+func NewTopK(k, width, depth int, decay float64) *TopK {
+	t := &TopK{
+		K:       k,
+		Width:   width,
+		Depth:   depth,
+		Decay:   decay,
+		buckets: make([]topKBucket, width*depth),
+		lookup:  make([]float64, decayLookupSize),
+	}
+	for i := range t.lookup {
+		t.lookup[i] = math.Pow(decay, float64(i))
+	}
+	return t
+}
+
+// calcHash calculates a 32-bit hash for the given item and seed. The hasher is used rather
+// than murmur3.Sum32WithSeed, whose unaligned reads fail the checkptr checks of -race.
+func (t *TopK) calcHash(item string, seed uint32) uint32 {
+	hasher := murmur3.New32WithSeed(seed)
+	hasher.Write([]byte(item))
+	return hasher.Sum32()
+}
+
+func (t *TopK) fingerprint(item string) uint32 {
+	return t.calcHash(item, ABigSeed)
+}
+
+func (t *TopK) bucket(item string, row int) *topKBucket {
+	col := t.calcHash(item, uint32(row)) % uint32(t.Width)
+	return &t.buckets[row*t.Width+int(col)]
+}
+
+func (t *TopK) decayProbability(count uint64) float64 {
+	if count < decayLookupSize {
+		return t.lookup[count]
+	}
+	return t.lookup[decayLookupSize-1]
+}
+
+// heapIndex returns the position of item in the heap, or -1
+func (t *TopK) heapIndex(item string, fp uint32) int {
+	for i := range t.heap {
+		if t.fps[i] == fp && t.heap[i].Item == item {
+			return i
+		}
+	}
+	return -1
+}
+
+func (t *TopK) swap(i, j int) {
+	t.heap[i], t.heap[j] = t.heap[j], t.heap[i]
+	t.fps[i], t.fps[j] = t.fps[j], t.fps[i]
+}
+
+func (t *TopK) siftDown(i int) {
+	for {
+		smallest := i
+		for _, child := range []int{2*i + 1, 2*i + 2} {
+			if child < len(t.heap) && t.heap[child].Count < t.heap[smallest].Count {
+				smallest = child
+			}
+		}
+		if smallest == i {
+			return
+		}
+		t.swap(i, smallest)
+		i = smallest
+	}
+}
+
+func (t *TopK) siftUp(i int) {
+	for i > 0 {
+		parent := (i - 1) / 2
+		if t.heap[parent].Count <= t.heap[i].Count {
+			return
+		}
+		t.swap(i, parent)
+		i = parent
+	}
+}
+
+// IncrBy increases the count of item by increment. Returns the item expelled from the
+// top-k list to make room for item, if any.
+func (t *TopK) IncrBy(item string, increment uint64) (string, bool) {
+	fp := t.fingerprint(item)
+	var maxCount uint64
+	for row := 0; row < t.Depth; row++ {
+		b := t.bucket(item, row)
+		switch {
+		case b.count == 0:
+			b.fp, b.count = fp, increment
+		case b.fp == fp:
+			b.count += increment
+		default:
+			for incr := increment; incr > 0; incr-- {
+				if rand.Float64() < t.decayProbability(b.count) {
+					b.count--
+					if b.count == 0 {
+						b.fp, b.count = fp, incr
+						break
+					}
+				}
+			}
+		}
+		if b.fp == fp && b.count > maxCount {
+			maxCount = b.count
+		}
+	}
+
+	if i := t.heapIndex(item, fp); i >= 0 {
+		// The buckets of item may have been decayed by other items
+		t.heap[i].Count = maxCount
+		t.siftUp(i)
+		t.siftDown(i)
+		return "", false
+	}
+	if maxCount == 0 {
+		return "", false
+	}
+	if len(t.heap) < t.K {
+		t.heap = append(t.heap, TopKItem{Item: item, Count: maxCount})
+		t.fps = append(t.fps, fp)
+		t.siftUp(len(t.heap) - 1)
+		return "", false
+	}
+	if maxCount <= t.heap[0].Count {
+		return "", false
+	}
+	expelled := t.heap[0].Item
+	t.heap[0] = TopKItem{Item: item, Count: maxCount}
+	t.fps[0] = fp
+	t.siftDown(0)
+	return expelled, true
+}
+
+// Query reports whether item is in the top-k list
+func (t *TopK) Query(item string) bool {
+	return t.heapIndex(item, t.fingerprint(item)) >= 0
+}
+
+// Count returns the estimated count of item
+func (t *TopK) Count(item string) uint64 {
+	fp := t.fingerprint(item)
+	var count uint64
+	for row := 0; row < t.Depth; row++ {
+		if b := t.bucket(item, row); b.fp == fp && b.count > count {
+			count = b.count
+		}
+	}
+	return count
+}
+
+// List returns the top-k items from the most to the least frequent
+func (t *TopK) List() []TopKItem {
+	res := make([]TopKItem, len(t.heap))
+	copy(res, t.heap)
+	sort.SliceStable(res, func(i, j int) bool { return res[i].Count > res[j].Count })
+	return res
+}
+
+func (t *TopK) MarshalBinary() ([]byte, error) {
+	e := encoder{}
+	e.uint64(uint64(t.K))
+	e.uint64(uint64(t.Width))
+	e.uint64(uint64(t.Depth))
+	e.float64(t.Decay)
+	for _, b := range t.buckets {
+		e.uint64(uint64(b.fp))
+		e.uint64(b.count)
+	}
+	e.uint64(uint64(len(t.heap)))
+	for _, x := range t.heap {
+		e.bytes([]byte(x.Item))
+		e.uint64(x.Count)
+	}
+	return e.buf, nil
+}
+
+func (t *TopK) UnmarshalBinary(data []byte) error {
+	d := decoder{buf: data}
+	k, width, depth, decay := d.uint64(), d.uint64(), d.uint64(), d.float64()
+	if d.err != nil || k > MaxTopK || width > MaxTopKBuckets || depth > MaxTopKBuckets ||
+		!ValidTopKSize(int(k), int(width), int(depth)) || width > uint64(len(d.buf)/16)/depth {
+		return ErrInvalidEncoding
+	}
+	res := NewTopK(int(k), int(width), int(depth), decay)
+	for i := range res.buckets {
+		res.buckets[i] = topKBucket{fp: uint32(d.uint64()), count: d.uint64()}
+	}
+	n := d.count(16)
+	if uint64(n) > k {
+		return ErrInvalidEncoding
+	}
+	for i := 0; i < n && d.err == nil; i++ {
+		item := string(d.bytes())
+		res.heap = append(res.heap, TopKItem{Item: item, Count: d.uint64()})
+		res.fps = append(res.fps, res.fingerprint(item))
+	}
+	if err := d.finish(); err != nil {
+		return err
+	}
+	*t = *res
+	return nil
+}
@@ -0,0 +1,68 @@
+package probabilistic
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestTopKBasic(t *testing.T) {
+	tk := NewTopK(2, 8, 7, 0.9)
+	tk.IncrBy("a", 5)
+	tk.IncrBy("b", 3)
+	if _, expelled := tk.IncrBy("c", 1); expelled {
+		t.Errorf("did not expect a light item to expel another one")
+	}
+	expelled, ok := tk.IncrBy("c", 10)
+	if !ok || expelled != "b" {
+		t.Errorf("expected 'b' to be expelled, got %q %v", expelled, ok)
+	}
+
+	list := tk.List()
+	if len(list) != 2 || list[0].Item != "c" || list[1].Item != "a" {
+		t.Errorf("unexpected list %v", list)
+	}
+	if !tk.Query("a") || tk.Query("b") {
+		t.Errorf("expected 'a' in the top-k list and 'b' out of it")
+	}
+	if tk.Count("a") != 5 {
+		t.Errorf("expected count 5 for 'a', got %d", tk.Count("a"))
+	}
+}
+
+func TestTopKHeavyHitters(t *testing.T) {
+	rand.Seed(1)
+	tk := NewTopK(10, 100, 5, 0.9)
+	// 10 heavy items among a long tail of light ones
+	for i := 0; i < 20000; i++ {
+		if i%2 == 0 {
+			tk.IncrBy(fmt.Sprintf("heavy-%d", rand.Intn(10)), 1)
+		} else {
+			tk.IncrBy(fmt.Sprintf("light-%d", rand.Intn(5000)), 1)
+		}
+	}
+	list := tk.List()
+	if len(list) != 10 {
+		t.Fatalf("expected 10 items, got %d", len(list))
+	}
+	for i, x := range list {
+		if x.Item[:5] != "heavy" {
+			t.Errorf("expected only heavy items, got %s at %d", x.Item, i)
+		}
+		if i > 0 && x.Count > list[i-1].Count {
+			t.Errorf("expected the list ordered by count, got %v", list)
+		}
+	}
+}
+
+func TestValidTopKSize(t *testing.T) {
+	if !ValidTopKSize(10, 1<<20, 32) || !ValidTopKSize(MaxTopK, 8, 7) {
+		t.Errorf("expected valid sizes")
+	}
+	for _, size := range [][3]int{{0, 8, 7}, {MaxTopK + 1, 8, 7}, {10, 0, 7}, {10, 8, -1}, {10, 1 << 20, 33}, {10, math.MaxInt, 2}} {
+		if ValidTopKSize(size[0], size[1], size[2]) {
+			t.Errorf("expected %v to be invalid", size)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package quick_list
+
+// QuickListNodeSize is the maximum number of elements stored in one node.
+// Like Redis quicklist, a list is a doubly linked list of small arrays, so
+// pushes and pops at both ends are O(1) while keeping good cache locality.
+const QuickListNodeSize = 128
+
+type quickListNode struct {
+	entries []string
+	prev    *quickListNode
+	next    *quickListNode
+}
+
+type QuickList struct {
+	head   *quickListNode
+	tail   *quickListNode
+	length int
+}
+
+func NewQuickList() *QuickList {
+	return &QuickList{}
+}
+
+// Len returns the number of elements in the list
+func (q *QuickList) Len() int {
+	return q.length
+}
+
+// LPush inserts values at the head of the list one after another,
+// so the last value ends up first. Returns the new length.
+func (q *QuickList) LPush(values ...string) int {
+	for _, v := range values {
+		if q.head == nil || len(q.head.entries) >= QuickListNodeSize {
+			node := &quickListNode{next: q.head}
+			if q.head != nil {
+				q.head.prev = node
+			} else {
+				q.tail = node
+			}
+			q.head = node
+		}
+		q.head.entries = append([]string{v}, q.head.entries...)
+		q.length++
+	}
+	return q.length
+}
+
+// RPush appends values at the tail of the list. Returns the new length.
+func (q *QuickList) RPush(values ...string) int {
+	for _, v := range values {
+		if q.tail == nil || len(q.tail.entries) >= QuickListNodeSize {
+			node := &quickListNode{prev: q.tail}
+			if q.tail != nil {
+				q.tail.next = node
+			} else {
+				q.head = node
+			}
+			q.tail = node
+		}
+		q.tail.entries = append(q.tail.entries, v)
+		q.length++
+	}
+	return q.length
+}
+
+// LPop removes and returns the first element of the list
+func (q *QuickList) LPop() (string, bool) {
+	if q.head == nil {
+		return "", false
+	}
+	v := q.head.entries[0]
+	q.head.entries = q.head.entries[1:]
+	q.length--
+	if len(q.head.entries) == 0 {
+		q.unlink(q.head)
+	}
+	return v, true
+}
+
+// RPop removes and returns the last element of the list
+func (q *QuickList) RPop() (string, bool) {
+	if q.tail == nil {
+		return "", false
+	}
+	last := len(q.tail.entries) - 1
+	v := q.tail.entries[last]
+	q.tail.entries = q.tail.entries[:last]
+	q.length--
+	if len(q.tail.entries) == 0 {
+		q.unlink(q.tail)
+	}
+	return v, true
+}
+
+// Index returns the element at index. Negative index counts from the tail (-1 is the last element).
+func (q *QuickList) Index(index int) (string, bool) {
+	if index < 0 {
+		index += q.length
+	}
+	if index < 0 || index >= q.length {
+		return "", false
+	}
+	if index < q.length/2 {
+		for node := q.head; node != nil; node = node.next {
+			if index < len(node.entries) {
+				return node.entries[index], true
+			}
+			index -= len(node.entries)
+		}
+	} else {
+		index = q.length - 1 - index
+		for node := q.tail; node != nil; node = node.prev {
+			if index < len(node.entries) {
+				return node.entries[len(node.entries)-1-index], true
+			}
+			index -= len(node.entries)
+		}
+	}
+	return "", false
+}
+
+// Range returns elements between start and stop (both inclusive).
+// Negative indexes count from the tail, out of range indexes are clamped like LRANGE.
+func (q *QuickList) Range(start, stop int) []string {
+	if start < 0 {
+		start += q.length
+	}
+	if stop < 0 {
+		stop += q.length
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= q.length {
+		stop = q.length - 1
+	}
+	if start > stop || start >= q.length {
+		return []string{}
+	}
+
+	res := make([]string, 0, stop-start+1)
+	pos := 0
+	for node := q.head; node != nil && pos <= stop; node = node.next {
+		n := len(node.entries)
+		if pos+n <= start {
+			pos += n
+			continue
+		}
+		for i, v := range node.entries {
+			if pos+i >= start && pos+i <= stop {
+				res = append(res, v)
+			}
+		}
+		pos += n
+	}
+	return res
+}
+
+func (q *QuickList) unlink(node *quickListNode) {
+	if node.prev != nil {
+		node.prev.next = node.next
+	} else {
+		q.head = node.next
+	}
+	if node.next != nil {
+		node.next.prev = node.prev
+	} else {
+		q.tail = node.prev
+	}
+	node.prev, node.next = nil, nil
+}
@@ -0,0 +1,74 @@
+package quick_list
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuickList_PushPop(t *testing.T) {
+	q := NewQuickList()
+
+	assert.EqualValues(t, 3, q.RPush("a", "b", "c"))
+	assert.EqualValues(t, 5, q.LPush("x", "y")) // y x a b c
+	assert.Equal(t, []string{"y", "x", "a", "b", "c"}, q.Range(0, -1))
+
+	v, ok := q.LPop()
+	assert.True(t, ok)
+	assert.Equal(t, "y", v)
+
+	v, ok = q.RPop()
+	assert.True(t, ok)
+	assert.Equal(t, "c", v)
+	assert.Equal(t, 3, q.Len())
+
+	q.LPop()
+	q.LPop()
+	q.LPop()
+	_, ok = q.LPop()
+	assert.False(t, ok)
+	_, ok = q.RPop()
+	assert.False(t, ok)
+	assert.Equal(t, 0, q.Len())
+}
+
+func TestQuickList_ManyNodes(t *testing.T) {
+	q := NewQuickList()
+	n := QuickListNodeSize*3 + 7
+	for i := 0; i < n; i++ {
+		q.RPush(string(rune('a' + i%26)))
+	}
+	assert.Equal(t, n, q.Len())
+
+	for i := 0; i < n; i++ {
+		v, ok := q.Index(i)
+		assert.True(t, ok)
+		assert.Equal(t, string(rune('a'+i%26)), v)
+	}
+	v, ok := q.Index(-1)
+	assert.True(t, ok)
+	assert.Equal(t, string(rune('a'+(n-1)%26)), v)
+
+	_, ok = q.Index(n)
+	assert.False(t, ok)
+
+	assert.Len(t, q.Range(QuickListNodeSize-2, QuickListNodeSize+2), 5)
+
+	for i := 0; i < n; i++ {
+		_, ok := q.RPop()
+		assert.True(t, ok)
+	}
+	assert.Nil(t, q.head)
+	assert.Nil(t, q.tail)
+}
+
+func TestQuickList_Range(t *testing.T) {
+	q := NewQuickList()
+	q.RPush("a", "b", "c", "d")
+
+	assert.Equal(t, []string{"b", "c"}, q.Range(1, 2))
+	assert.Equal(t, []string{"c", "d"}, q.Range(-2, -1))
+	assert.Equal(t, []string{"a", "b", "c", "d"}, q.Range(-100, 100))
+	assert.Equal(t, []string{}, q.Range(3, 1))
+	assert.Equal(t, []string{}, q.Range(10, 20))
+}
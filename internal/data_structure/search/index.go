@@ -0,0 +1,247 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/spaghetti-lover/multithread-redis/internal/data_structure/sorted_set"
+)
+
+type FieldType string
+
+const (
+	FieldText    FieldType = "TEXT"
+	FieldTag     FieldType = "TAG"
+	FieldNumeric FieldType = "NUMERIC"
+)
+
+// Field is an indexed hash field. Queries refer to it by its alias, which defaults to its name.
+type Field struct {
+	Name     string
+	Alias    string
+	Type     FieldType
+	Sortable bool
+
+	Weight float64 // TEXT: factor applied to the score of the matches in this field
+
+	Separator     byte // TAG: separator of the tags in the value
+	CaseSensitive bool // TAG: tags are lowercased unless case sensitive
+}
+
+// Index is a secondary index over the hashes whose key starts with one of its prefixes
+type Index struct {
+	Name     string
+	Prefixes []string
+	Fields   []*Field
+
+	// docs holds the indexed values of each document, so that they can be removed
+	docs map[string]map[string]string
+	// text maps a field to its terms, each term to the documents containing it and the
+	// positions of the term in the field
+	text map[string]map[string]map[string][]int
+	// tags maps a field to its tags, each tag to the documents having it
+	tags map[string]map[string]map[string]struct{}
+	// numeric holds the documents of a field ordered by value
+	numeric map[string]*sorted_set.SortedSet
+}
+
+func NewIndex(name string, prefixes []string, fields []*Field) *Index {
+	idx := &Index{
+		Name:     name,
+		Prefixes: prefixes,
+		Fields:   fields,
+		docs:     make(map[string]map[string]string),
+		text:     make(map[string]map[string]map[string][]int),
+		tags:     make(map[string]map[string]map[string]struct{}),
+		numeric:  make(map[string]*sorted_set.SortedSet),
+	}
+	for _, f := range fields {
+		switch f.Type {
+		case FieldText:
+			idx.text[f.Alias] = make(map[string]map[string][]int)
+		case FieldTag:
+			idx.tags[f.Alias] = make(map[string]map[string]struct{})
+		case FieldNumeric:
+			idx.numeric[f.Alias], _ = sorted_set.NewSortedSet(sorted_set.IndexConfig{
+				Type:     sorted_set.IndexTypeSkipList,
+				MaxLevel: sorted_set.SkiplistMaxLevel,
+			})
+		}
+	}
+	return idx
+}
+
+// Field returns the field with the given alias
+func (idx *Index) Field(alias string) (*Field, bool) {
+	for _, f := range idx.Fields {
+		if f.Alias == alias {
+			return f, true
+		}
+	}
+	return nil, false
+}
+
+// Matches reports whether the hash stored at key belongs to the index
+func (idx *Index) Matches(key string) bool {
+	if len(idx.Prefixes) == 0 {
+		return true
+	}
+	for _, prefix := range idx.Prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Tokenize splits a text into lowercase terms made of letters, digits and underscores
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+}
+
+// splitTags returns the tags of a TAG field value
+func (f *Field) splitTags(value string) []string {
+	var tags []string
+	for _, tag := range strings.Split(value, string(f.Separator)) {
+		if tag = f.normalizeTag(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+func (f *Field) normalizeTag(tag string) string {
+	tag = strings.TrimSpace(tag)
+	if !f.CaseSensitive {
+		tag = strings.ToLower(tag)
+	}
+	return tag
+}
+
+// Add indexes the fields of the hash stored at key, replacing its previous values
+func (idx *Index) Add(key string, fields map[string]string) {
+	idx.Remove(key)
+	values := make(map[string]string)
+	for _, f := range idx.Fields {
+		value, ok := fields[f.Name]
+		if !ok {
+			continue
+		}
+		switch f.Type {
+		case FieldText:
+			for pos, term := range Tokenize(value) {
+				docs := idx.text[f.Alias][term]
+				if docs == nil {
+					docs = make(map[string][]int)
+					idx.text[f.Alias][term] = docs
+				}
+				docs[key] = append(docs[key], pos)
+			}
+		case FieldTag:
+			for _, tag := range f.splitTags(value) {
+				docs := idx.tags[f.Alias][tag]
+				if docs == nil {
+					docs = make(map[string]struct{})
+					idx.tags[f.Alias][tag] = docs
+				}
+				docs[key] = struct{}{}
+			}
+		case FieldNumeric:
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil || math.IsNaN(n) {
+				// Values that are not numbers are not indexed, nor is NaN which has no
+				// place in the ordering of the scores
+				continue
+			}
+			idx.numeric[f.Alias].Add(n, key)
+		}
+		values[f.Alias] = value
+	}
+	idx.docs[key] = values
+}
+
+// Remove removes the document stored at key from the index
+func (idx *Index) Remove(key string) bool {
+	values, ok := idx.docs[key]
+	if !ok {
+		return false
+	}
+	for alias, value := range values {
+		f, _ := idx.Field(alias)
+		switch f.Type {
+		case FieldText:
+			for _, term := range Tokenize(value) {
+				if docs := idx.text[alias][term]; docs != nil {
+					delete(docs, key)
+					if len(docs) == 0 {
+						delete(idx.text[alias], term)
+					}
+				}
+			}
+		case FieldTag:
+			for _, tag := range f.splitTags(value) {
+				if docs := idx.tags[alias][tag]; docs != nil {
+					delete(docs, key)
+					if len(docs) == 0 {
+						delete(idx.tags[alias], tag)
+					}
+				}
+			}
+		case FieldNumeric:
+			idx.numeric[alias].Remove(key)
+		}
+	}
+	delete(idx.docs, key)
+	return true
+}
+
+// Keys returns the keys of the indexed documents
+func (idx *Index) Keys() []string {
+	keys := make([]string, 0, len(idx.docs))
+	for key := range idx.docs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// NumDocs returns the number of indexed documents
+func (idx *Index) NumDocs() int {
+	return len(idx.docs)
+}
+
+// NumTerms returns the number of distinct terms of the TEXT fields
+func (idx *Index) NumTerms() int {
+	terms := make(map[string]struct{})
+	for _, fieldTerms := range idx.text {
+		for term := range fieldTerms {
+			terms[term] = struct{}{}
+		}
+	}
+	return len(terms)
+}
+
+// NumRecords returns the number of entries of the inverted indexes, one per term or tag
+// of each document field
+func (idx *Index) NumRecords() int {
+	n := 0
+	for _, fieldTerms := range idx.text {
+		for _, docs := range fieldTerms {
+			n += len(docs)
+		}
+	}
+	for _, fieldTags := range idx.tags {
+		for _, docs := range fieldTags {
+			n += len(docs)
+		}
+	}
+	for _, ss := range idx.numeric {
+		n += ss.Len()
+	}
+	return n
+}
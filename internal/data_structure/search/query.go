@@ -0,0 +1,558 @@
+package search
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/spaghetti-lover/multithread-redis/internal/data_structure/sorted_set"
+)
+
+// A query is made of:
+//   - terms, matched in all the TEXT fields: hello, and prefixes: hel*
+//   - phrases, terms following each other: "hello world"
+//   - @field:term, @field:"phrase" or @field:(query) to match TEXT fields only
+//   - @field:{tag | tag} for documents having one of the tags of a TAG field
+//   - @field:[min max] for a range of a NUMERIC field, ( for an exclusive bound, -inf and +inf
+//   - a space between expressions for AND, | for OR, - for NOT and parentheses to group them.
+//     AND binds tighter than OR.
+//   - * alone for all the documents
+
+var ErrSyntax = errors.New("syntax error")
+
+// node is a node of a parsed query, evaluated to the matching documents and their score
+type node interface {
+	eval(idx *Index) map[string]float64
+}
+
+type allNode struct{}
+
+type termNode struct {
+	fields []string
+	term   string
+	prefix bool
+}
+
+type phraseNode struct {
+	fields []string
+	terms  []string
+}
+
+type tagNode struct {
+	field string
+	tags  []string
+}
+
+type numericNode struct {
+	field string
+	r     *sorted_set.ScoreRange
+}
+
+type andNode struct {
+	children []node
+}
+
+type orNode struct {
+	children []node
+}
+
+type notNode struct {
+	child node
+}
+
+// Result is a document matching a query
+type Result struct {
+	Key   string
+	Score float64
+}
+
+// SearchOptions controls the order of the results of Search
+type SearchOptions struct {
+	SortBy string // alias of the field to sort by, by descending score if empty
+	Desc   bool
+}
+
+// Search returns the documents matching query, ordered by descending score then by key, or
+// by the SortBy field, the documents without it coming last
+func (idx *Index) Search(query string, opts SearchOptions) ([]Result, error) {
+	n, err := idx.parseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	var sortField *Field
+	if opts.SortBy != "" {
+		var ok bool
+		if sortField, ok = idx.Field(opts.SortBy); !ok {
+			return nil, fmt.Errorf("Property `%s` not loaded nor in schema", opts.SortBy)
+		}
+	}
+
+	matches := n.eval(idx)
+	results := make([]Result, 0, len(matches))
+	for key, score := range matches {
+		results = append(results, Result{Key: key, Score: score})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if sortField != nil {
+			// The documents without the field come last
+			hasA, hasB := idx.hasField(a.Key, sortField), idx.hasField(b.Key, sortField)
+			if hasA != hasB {
+				return hasA
+			}
+			if c := idx.compareField(sortField, a.Key, b.Key); c != 0 {
+				return (c < 0) != opts.Desc
+			}
+		} else if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		return a.Key < b.Key
+	})
+	return results, nil
+}
+
+func (idx *Index) hasField(key string, f *Field) bool {
+	_, ok := idx.docs[key][f.Alias]
+	return ok
+}
+
+// compareField compares the values of f in two documents having it
+func (idx *Index) compareField(f *Field, a, b string) int {
+	va, vb := idx.docs[a][f.Alias], idx.docs[b][f.Alias]
+	if f.Type == FieldNumeric {
+		x, _ := strconv.ParseFloat(va, 64)
+		y, _ := strconv.ParseFloat(vb, 64)
+		return cmp.Compare(x, y)
+	}
+	return strings.Compare(strings.ToLower(va), strings.ToLower(vb))
+}
+
+func (allNode) eval(idx *Index) map[string]float64 {
+	res := make(map[string]float64, len(idx.docs))
+	for key := range idx.docs {
+		res[key] = 1
+	}
+	return res
+}
+
+// addTermScore adds to res the TF-IDF score of the documents containing a term
+func (idx *Index) addTermScore(res map[string]float64, f *Field, docs map[string][]int, only map[string]struct{}) {
+	idf := math.Log(1 + float64(len(idx.docs))/float64(len(docs)))
+	for key, positions := range docs {
+		if only != nil {
+			if _, ok := only[key]; !ok {
+				continue
+			}
+		}
+		res[key] += float64(len(positions)) * f.Weight * idf
+	}
+}
+
+func (n *termNode) eval(idx *Index) map[string]float64 {
+	res := make(map[string]float64)
+	for _, alias := range n.fields {
+		f, _ := idx.Field(alias)
+		if !n.prefix {
+			if docs := idx.text[alias][n.term]; docs != nil {
+				idx.addTermScore(res, f, docs, nil)
+			}
+			continue
+		}
+		for term, docs := range idx.text[alias] {
+			if strings.HasPrefix(term, n.term) {
+				idx.addTermScore(res, f, docs, nil)
+			}
+		}
+	}
+	return res
+}
+
+func (n *phraseNode) eval(idx *Index) map[string]float64 {
+	res := make(map[string]float64)
+	for _, alias := range n.fields {
+		f, _ := idx.Field(alias)
+		postings := make([]map[string][]int, len(n.terms))
+		for i, term := range n.terms {
+			if postings[i] = idx.text[alias][term]; postings[i] == nil {
+				break
+			}
+		}
+		if postings[len(postings)-1] == nil {
+			continue
+		}
+
+		// Documents where the terms follow each other
+		matching := make(map[string]struct{})
+		for key, positions := range postings[0] {
+			for _, start := range positions {
+				if hasPhraseAt(postings, key, start) {
+					matching[key] = struct{}{}
+					break
+				}
+			}
+		}
+		for _, docs := range postings {
+			idx.addTermScore(res, f, docs, matching)
+		}
+	}
+	return res
+}
+
+// hasPhraseAt reports whether the i-th term of the phrase is at position start+i in key
+func hasPhraseAt(postings []map[string][]int, key string, start int) bool {
+	for i := 1; i < len(postings); i++ {
+		positions, ok := postings[i][key]
+		if !ok {
+			return false
+		}
+		j := sort.SearchInts(positions, start+i)
+		if j == len(positions) || positions[j] != start+i {
+			return false
+		}
+	}
+	return true
+}
+
+func (n *tagNode) eval(idx *Index) map[string]float64 {
+	res := make(map[string]float64)
+	for _, tag := range n.tags {
+		for key := range idx.tags[n.field][tag] {
+			res[key] = 1
+		}
+	}
+	return res
+}
+
+func (n *numericNode) eval(idx *Index) map[string]float64 {
+	res := make(map[string]float64)
+	if n.r.IsEmpty() {
+		return res
+	}
+	for _, item := range idx.numeric[n.field].RangeByScore(n.r, false, 0, -1) {
+		res[item.Member] = 1
+	}
+	return res
+}
+
+func (n *andNode) eval(idx *Index) map[string]float64 {
+	res := n.children[0].eval(idx)
+	for _, child := range n.children[1:] {
+		if len(res) == 0 {
+			break
+		}
+		matches := child.eval(idx)
+		for key, score := range res {
+			if s, ok := matches[key]; ok {
+				res[key] = score + s
+			} else {
+				delete(res, key)
+			}
+		}
+	}
+	return res
+}
+
+func (n *orNode) eval(idx *Index) map[string]float64 {
+	res := make(map[string]float64)
+	for _, child := range n.children {
+		for key, score := range child.eval(idx) {
+			res[key] += score
+		}
+	}
+	return res
+}
+
+func (n *notNode) eval(idx *Index) map[string]float64 {
+	excluded := n.child.eval(idx)
+	res := make(map[string]float64)
+	for key := range idx.docs {
+		if _, ok := excluded[key]; !ok {
+			res[key] = 0
+		}
+	}
+	return res
+}
+
+// maxQueryDepth bounds the nesting of parentheses, negations and field expressions, so that
+// a query can not overflow the stack of the parser
+const maxQueryDepth = 128
+
+// queryParser is a recursive descent parser of queries
+type queryParser struct {
+	idx   *Index
+	s     string
+	pos   int
+	depth int
+}
+
+func (idx *Index) parseQuery(query string) (node, error) {
+	if strings.TrimSpace(query) == "*" {
+		return allNode{}, nil
+	}
+	p := &queryParser{idx: idx, s: query}
+	n, err := p.parseUnion(nil)
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpaces()
+	if p.pos < len(p.s) {
+		return nil, ErrSyntax
+	}
+	return n, nil
+}
+
+func (p *queryParser) skipSpaces() {
+	for p.pos < len(p.s) && p.s[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *queryParser) peek() byte {
+	p.skipSpaces()
+	if p.pos == len(p.s) {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+// textFields returns the TEXT fields matched by the terms outside of a @field: expression
+func (p *queryParser) textFields() []string {
+	var fields []string
+	for _, f := range p.idx.Fields {
+		if f.Type == FieldText {
+			fields = append(fields, f.Alias)
+		}
+	}
+	return fields
+}
+
+// parseUnion parses intersections separated by |. fields restricts the terms to some TEXT
+// fields, nil for all of them.
+func (p *queryParser) parseUnion(fields []string) (node, error) {
+	var children []node
+	for {
+		n, err := p.parseIntersection(fields)
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, n)
+		if p.peek() != '|' {
+			break
+		}
+		p.pos++
+	}
+	if len(children) == 1 {
+		return children[0], nil
+	}
+	return &orNode{children: children}, nil
+}
+
+func (p *queryParser) parseIntersection(fields []string) (node, error) {
+	var children []node
+	for {
+		c := p.peek()
+		if c == 0 || c == '|' || c == ')' {
+			break
+		}
+		n, err := p.parseUnary(fields)
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, n)
+	}
+	switch len(children) {
+	case 0:
+		return nil, ErrSyntax
+	case 1:
+		return children[0], nil
+	}
+	// Evaluate the negations last, so that they filter the other results
+	sort.SliceStable(children, func(i, j int) bool {
+		_, notI := children[i].(*notNode)
+		_, notJ := children[j].(*notNode)
+		return !notI && notJ
+	})
+	return &andNode{children: children}, nil
+}
+
+// nested runs parse one level deeper, failing with a syntax error past maxQueryDepth
+func (p *queryParser) nested(parse func() (node, error)) (node, error) {
+	if p.depth == maxQueryDepth {
+		return nil, ErrSyntax
+	}
+	p.depth++
+	defer func() { p.depth-- }()
+	return parse()
+}
+
+func (p *queryParser) parseUnary(fields []string) (node, error) {
+	if p.peek() == '-' {
+		p.pos++
+		n, err := p.nested(func() (node, error) { return p.parseUnary(fields) })
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{child: n}, nil
+	}
+	return p.parseAtom(fields)
+}
+
+func (p *queryParser) parseAtom(fields []string) (node, error) {
+	switch p.peek() {
+	case '(':
+		p.pos++
+		n, err := p.nested(func() (node, error) { return p.parseUnion(fields) })
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ')' {
+			return nil, ErrSyntax
+		}
+		p.pos++
+		return n, nil
+	case '@':
+		p.pos++
+		return p.nested(p.parseFieldExpr)
+	case '"':
+		return p.parsePhrase(fields)
+	default:
+		return p.parseTerm(fields)
+	}
+}
+
+// parseFieldExpr parses the expression following @
+func (p *queryParser) parseFieldExpr() (node, error) {
+	start := p.pos
+	for p.pos < len(p.s) && p.s[p.pos] != ':' {
+		p.pos++
+	}
+	if p.pos == len(p.s) {
+		return nil, ErrSyntax
+	}
+	alias := p.s[start:p.pos]
+	p.pos++
+	f, ok := p.idx.Field(alias)
+	if !ok {
+		return nil, fmt.Errorf("Unknown field `%s`", alias)
+	}
+
+	switch f.Type {
+	case FieldTag:
+		if p.peek() != '{' {
+			return nil, ErrSyntax
+		}
+		return p.parseTags(f)
+	case FieldNumeric:
+		if p.peek() != '[' {
+			return nil, ErrSyntax
+		}
+		return p.parseRange(f)
+	default:
+		return p.parseAtom([]string{f.Alias})
+	}
+}
+
+// parseTags parses {tag | tag ...}, with \ escaping the next character
+func (p *queryParser) parseTags(f *Field) (node, error) {
+	p.pos++
+	n := &tagNode{field: f.Alias}
+	var tag strings.Builder
+	for ; p.pos < len(p.s); p.pos++ {
+		switch c := p.s[p.pos]; c {
+		case '\\':
+			if p.pos++; p.pos < len(p.s) {
+				tag.WriteByte(p.s[p.pos])
+			}
+		case '|', '}':
+			if t := f.normalizeTag(tag.String()); t != "" {
+				n.tags = append(n.tags, t)
+			}
+			tag.Reset()
+			if c == '}' {
+				p.pos++
+				if len(n.tags) == 0 {
+					return nil, ErrSyntax
+				}
+				return n, nil
+			}
+		default:
+			tag.WriteByte(c)
+		}
+	}
+	return nil, ErrSyntax
+}
+
+// parseRange parses [min max]
+func (p *queryParser) parseRange(f *Field) (node, error) {
+	p.pos++
+	end := strings.IndexByte(p.s[p.pos:], ']')
+	if end < 0 {
+		return nil, ErrSyntax
+	}
+	bounds := strings.FieldsFunc(p.s[p.pos:p.pos+end], func(r rune) bool { return r == ' ' || r == ',' })
+	p.pos += end + 1
+	if len(bounds) != 2 {
+		return nil, ErrSyntax
+	}
+	r, err := sorted_set.ParseScoreRange(bounds[0], bounds[1])
+	if err != nil {
+		return nil, fmt.Errorf("Bad lower or upper range in numeric filter")
+	}
+	return &numericNode{field: f.Alias, r: r}, nil
+}
+
+func (p *queryParser) parsePhrase(fields []string) (node, error) {
+	p.pos++
+	end := strings.IndexByte(p.s[p.pos:], '"')
+	if end < 0 {
+		return nil, ErrSyntax
+	}
+	terms := Tokenize(p.s[p.pos : p.pos+end])
+	p.pos += end + 1
+	if fields == nil {
+		fields = p.textFields()
+	}
+	switch len(terms) {
+	case 0:
+		return nil, ErrSyntax
+	case 1:
+		return &termNode{fields: fields, term: terms[0]}, nil
+	}
+	return &phraseNode{fields: fields, terms: terms}, nil
+}
+
+// parseTerm parses a term, a prefix if it ends with *. \ escapes the next character.
+func (p *queryParser) parseTerm(fields []string) (node, error) {
+	var term strings.Builder
+	prefix := false
+loop:
+	for ; p.pos < len(p.s); p.pos++ {
+		c := p.s[p.pos]
+		switch {
+		case c == '\\':
+			if p.pos++; p.pos < len(p.s) {
+				term.WriteByte(p.s[p.pos])
+			}
+		case c == '*':
+			prefix = true
+			p.pos++
+			break loop
+		case c >= 0x80 || c == '_' || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c)):
+			term.WriteByte(c)
+		default:
+			break loop
+		}
+	}
+	if term.Len() == 0 {
+		return nil, ErrSyntax
+	}
+	if fields == nil {
+		fields = p.textFields()
+	}
+	return &termNode{fields: fields, term: strings.ToLower(term.String()), prefix: prefix}, nil
+}
@@ -0,0 +1,132 @@
+package search
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestIndex() *Index {
+	idx := NewIndex("idx", []string{"doc:"}, []*Field{
+		{Name: "title", Alias: "title", Type: FieldText, Weight: 1},
+		{Name: "body", Alias: "body", Type: FieldText, Weight: 1},
+		{Name: "tags", Alias: "tags", Type: FieldTag, Separator: ','},
+		{Name: "price", Alias: "price", Type: FieldNumeric},
+	})
+	idx.Add("doc:1", map[string]string{"title": "Hello world", "body": "the quick brown fox", "tags": "news, Tech", "price": "10"})
+	idx.Add("doc:2", map[string]string{"title": "Hello there", "body": "world peace", "tags": "news", "price": "25.5"})
+	idx.Add("doc:3", map[string]string{"title": "Goodbye", "body": "brown world hello", "tags": "sport", "price": "100"})
+	idx.Add("doc:4", map[string]string{"title": "Helicopter", "price": "not a number"})
+	return idx
+}
+
+func keys(t *testing.T, idx *Index, query string, opts SearchOptions) []string {
+	results, err := idx.Search(query, opts)
+	require.NoError(t, err, query)
+	res := []string{}
+	for _, r := range results {
+		res = append(res, r.Key)
+	}
+	return res
+}
+
+func TestIndexMatches(t *testing.T) {
+	idx := newTestIndex()
+	assert.True(t, idx.Matches("doc:9"))
+	assert.False(t, idx.Matches("user:1"))
+	assert.True(t, NewIndex("all", nil, nil).Matches("anything"))
+	assert.Equal(t, []string{"hello", "wörld", "a_b", "42"}, Tokenize("Hello, WÖRLD! a_b-42"))
+}
+
+func TestSearchQueries(t *testing.T) {
+	idx := newTestIndex()
+	byPrice := SearchOptions{SortBy: "price"}
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"*", []string{"doc:1", "doc:2", "doc:3", "doc:4"}},
+		{"hello", []string{"doc:1", "doc:2", "doc:3"}},
+		{"HELLO world", []string{"doc:1", "doc:2", "doc:3"}},
+		{"@title:hello", []string{"doc:1", "doc:2"}},
+		{"@title:hello @body:world", []string{"doc:2"}},
+		{"@title:(hello | goodbye)", []string{"doc:1", "doc:2", "doc:3"}},
+		{"hel*", []string{"doc:1", "doc:2", "doc:3", "doc:4"}},
+		{`"hello world"`, []string{"doc:1"}},
+		{`"world hello"`, []string{"doc:3"}},
+		{`@body:"quick fox"`, []string{}},
+		{"hello -@title:there", []string{"doc:1", "doc:3"}},
+		{"-hello", []string{"doc:4"}},
+		{"@tags:{news}", []string{"doc:1", "doc:2"}},
+		{"@tags:{tech | sport}", []string{"doc:1", "doc:3"}},
+		{"@price:[10 25.5]", []string{"doc:1", "doc:2"}},
+		{"@price:[(10 +inf]", []string{"doc:2", "doc:3"}},
+		{"@price:[-inf (10]", []string{}},
+		{"@tags:{news} @price:[20 inf]", []string{"doc:2"}},
+		{"goodbye | @tags:{tech}", []string{"doc:1", "doc:3"}},
+		{"brown hello | there", []string{"doc:1", "doc:2", "doc:3"}},
+		{"missing", []string{}},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, keys(t, idx, tt.query, byPrice), tt.query)
+	}
+
+	for _, query := range []string{"(hello", "@nope:x", "@tags:news", "@price:10", "@price:[a b]", "@tags:{}", ")", `"unterminated`} {
+		_, err := idx.Search(query, SearchOptions{})
+		assert.Error(t, err, query)
+	}
+
+	// Nesting is bounded rather than limited by the stack
+	nested := strings.Repeat("(", 100) + "hello" + strings.Repeat(")", 100)
+	assert.Equal(t, []string{"doc:1", "doc:2", "doc:3"}, keys(t, idx, nested, byPrice))
+	for _, query := range []string{
+		strings.Repeat("(", 200) + "hello" + strings.Repeat(")", 200),
+		strings.Repeat("-", 200) + "hello",
+		strings.Repeat("@title:", 200) + "hello",
+		strings.Repeat("(", 3000000),
+	} {
+		_, err := idx.Search(query, SearchOptions{})
+		assert.Equal(t, ErrSyntax, err, query[:10])
+	}
+
+	// NaN is not indexed as a number
+	idx.Add("doc:5", map[string]string{"title": "Nothing", "price": "NaN"})
+	assert.Equal(t, []string{"doc:1", "doc:2", "doc:3"}, keys(t, idx, "@price:[-inf +inf]", byPrice))
+}
+
+func TestSearchOrder(t *testing.T) {
+	idx := newTestIndex()
+
+	// doc:1 has the rarest term in its title and body
+	assert.Equal(t, []string{"doc:1", "doc:3", "doc:2"}, keys(t, idx, "hello | brown | fox", SearchOptions{}))
+
+	// The documents without a numeric value come last in both orders
+	assert.Equal(t, []string{"doc:1", "doc:2", "doc:3", "doc:4"}, keys(t, idx, "*", SearchOptions{SortBy: "price"}))
+	assert.Equal(t, []string{"doc:3", "doc:2", "doc:1", "doc:4"}, keys(t, idx, "*", SearchOptions{SortBy: "price", Desc: true}))
+
+	_, err := idx.Search("*", SearchOptions{SortBy: "nope"})
+	assert.Error(t, err)
+}
+
+func TestIndexUpdate(t *testing.T) {
+	idx := newTestIndex()
+	assert.Equal(t, 4, idx.NumDocs())
+
+	idx.Add("doc:1", map[string]string{"title": "Replaced", "tags": "sport", "price": "1"})
+	assert.Equal(t, []string{"doc:3"}, keys(t, idx, "brown", SearchOptions{}))
+	assert.Equal(t, []string{"doc:1", "doc:3"}, keys(t, idx, "@tags:{sport}", SearchOptions{SortBy: "price"}))
+	assert.Equal(t, []string{"doc:1"}, keys(t, idx, "@price:[0 5]", SearchOptions{}))
+
+	assert.True(t, idx.Remove("doc:1"))
+	assert.False(t, idx.Remove("doc:1"))
+	assert.Equal(t, []string{}, keys(t, idx, "replaced", SearchOptions{}))
+	assert.Equal(t, []string{"doc:2", "doc:3", "doc:4"}, idx.Keys())
+
+	for _, key := range idx.Keys() {
+		idx.Remove(key)
+	}
+	assert.Equal(t, 0, idx.NumTerms())
+	assert.Equal(t, 0, idx.NumRecords())
+}
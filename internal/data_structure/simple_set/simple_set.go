@@ -1,28 +1,149 @@
 package simple_set
 
+import (
+	"math/rand"
+	"strconv"
+
+	"github.com/spaghetti-lover/multithread-redis/internal/data_structure/intset"
+	"github.com/spaghetti-lover/multithread-redis/internal/data_structure/listpack"
+)
+
+const (
+	EncodingIntset    = "intset"
+	EncodingListpack  = "listpack"
+	EncodingHashTable = "hashtable"
+)
+
+// SimpleSet is a set of strings.
+// A set of integers is stored in an intset while it has at most maxIntsetEntries members.
+// Other small sets are stored in a listpack, and converted to a Go map once they have more
+// than maxListpackEntries members or a member longer than maxListpackValue bytes.
+// Conversions are one way, like in Redis.
 type SimpleSet struct {
-	key  string
-	dict map[string]struct{}
+	key                string
+	is                 *intset.IntSet
+	lp                 *listpack.Listpack
+	dict               map[string]struct{}
+	maxIntsetEntries   int
+	maxListpackEntries int
+	maxListpackValue   int
 }
 
-func NewSimpleSet(key string) *SimpleSet {
+func NewSimpleSet(key string, maxIntsetEntries, maxListpackEntries, maxListpackValue int) *SimpleSet {
 	return &SimpleSet{
-		key:  key,
-		dict: make(map[string]struct{}),
+		key:                key,
+		is:                 intset.New(),
+		maxIntsetEntries:   maxIntsetEntries,
+		maxListpackEntries: maxListpackEntries,
+		maxListpackValue:   maxListpackValue,
+	}
+}
+
+// Encoding returns the current encoding, "intset", "listpack" or "hashtable"
+func (s *SimpleSet) Encoding() string {
+	switch {
+	case s.is != nil:
+		return EncodingIntset
+	case s.lp != nil:
+		return EncodingListpack
 	}
+	return EncodingHashTable
+}
+
+// parseInt returns the integer represented by member. Members such as "007" or "+1"
+// are not integers, they would not be given back unchanged.
+func parseInt(member string) (int64, bool) {
+	v, err := strconv.ParseInt(member, 10, 64)
+	if err != nil || strconv.FormatInt(v, 10) != member {
+		return 0, false
+	}
+	return v, true
+}
+
+func (s *SimpleSet) convertToListpack() {
+	s.lp = listpack.New()
+	for _, v := range s.is.Values() {
+		s.lp.Append(strconv.FormatInt(v, 10))
+	}
+	s.is = nil
+}
+
+func (s *SimpleSet) convertToHashTable() {
+	members := s.Members()
+	s.dict = make(map[string]struct{}, len(members))
+	for _, m := range members {
+		s.dict[m] = struct{}{}
+	}
+	s.is = nil
+	s.lp = nil
+}
+
+// add adds a single member, converting the encoding when needed
+func (s *SimpleSet) add(member string) bool {
+	if s.is != nil {
+		if v, ok := parseInt(member); ok {
+			if !s.is.Add(v) {
+				return false
+			}
+			if s.is.Len() > s.maxIntsetEntries {
+				s.convertToHashTable()
+			}
+			return true
+		}
+		if s.is.Len() < s.maxListpackEntries && len(member) <= s.maxListpackValue {
+			s.convertToListpack()
+		} else {
+			s.convertToHashTable()
+		}
+	}
+
+	if s.lp != nil {
+		if s.lp.Find(0, member, 0) >= 0 {
+			return false
+		}
+		if s.lp.Len() < s.maxListpackEntries && len(member) <= s.maxListpackValue {
+			s.lp.Append(member)
+			return true
+		}
+		s.convertToHashTable()
+	}
+
+	if _, exist := s.dict[member]; exist {
+		return false
+	}
+	s.dict[member] = struct{}{}
+	return true
+}
+
+// rem removes a single member
+func (s *SimpleSet) rem(member string) bool {
+	switch {
+	case s.is != nil:
+		v, ok := parseInt(member)
+		return ok && s.is.Remove(v)
+	case s.lp != nil:
+		pos := s.lp.Find(0, member, 0)
+		if pos < 0 {
+			return false
+		}
+		s.lp.Delete(pos, 1)
+		return true
+	}
+	if _, exist := s.dict[member]; !exist {
+		return false
+	}
+	delete(s.dict, member)
+	return true
 }
 
 // SADD
 func (s *SimpleSet) Add(members ...string) int {
 	added := 0
-
 	for _, m := range members {
-		if _, exist := s.dict[m]; !exist {
-			s.dict[m] = struct{}{}
+		if s.add(m) {
 			added += 1
 		}
 	}
-
 	return added
 }
 
@@ -30,8 +151,7 @@ func (s *SimpleSet) Add(members ...string) int {
 func (s *SimpleSet) Rem(members ...string) int {
 	removed := 0
 	for _, m := range members {
-		if _, exist := s.dict[m]; exist {
-			delete(s.dict, m)
+		if s.rem(m) {
 			removed += 1
 		}
 	}
@@ -40,7 +160,16 @@ func (s *SimpleSet) Rem(members ...string) int {
 
 // SISMEMBER
 func (s *SimpleSet) IsMember(member string) int {
-	_, exist := s.dict[member]
+	exist := false
+	switch {
+	case s.is != nil:
+		v, ok := parseInt(member)
+		exist = ok && s.is.Contains(v)
+	case s.lp != nil:
+		exist = s.lp.Find(0, member, 0) >= 0
+	default:
+		_, exist = s.dict[member]
+	}
 
 	if exist {
 		return 1
@@ -50,11 +179,88 @@ func (s *SimpleSet) IsMember(member string) int {
 
 // SMEMBERS
 func (s *SimpleSet) Members() []string {
-	m := make([]string, 0, len(s.dict))
+	switch {
+	case s.is != nil:
+		m := make([]string, 0, s.is.Len())
+		for _, v := range s.is.Values() {
+			m = append(m, strconv.FormatInt(v, 10))
+		}
+		return m
+	case s.lp != nil:
+		return s.lp.Entries()
+	}
 
+	m := make([]string, 0, len(s.dict))
 	for k := range s.dict {
 		m = append(m, k)
 	}
-
 	return m
 }
+
+// SCARD
+func (s *SimpleSet) Card() int {
+	switch {
+	case s.is != nil:
+		return s.is.Len()
+	case s.lp != nil:
+		return s.lp.Len()
+	}
+	return len(s.dict)
+}
+
+// RandomMembers returns count distinct random members, or all of them if count is larger
+// than the set. With a negative count, -count members are returned and may repeat.
+func (s *SimpleSet) RandomMembers(count int) []string {
+	if count < 0 {
+		members := s.Members()
+		// count comes from the client: neither negate it, which overflows, nor size the
+		// result from it
+		var res []string
+		for i := 0; i > count && len(members) > 0; i-- {
+			res = append(res, members[rand.Intn(len(members))])
+		}
+		return res
+	}
+	if s.dict != nil && count < len(s.dict) {
+		return s.sampleDict(count)
+	}
+
+	// Small encodings and sets returned whole: shuffle only the count first members
+	members := s.Members()
+	count = min(count, len(members))
+	for i := 0; i < count; i++ {
+		j := i + rand.Intn(len(members)-i)
+		members[i], members[j] = members[j], members[i]
+	}
+	return members[:count]
+}
+
+// sampleDict picks count < len(dict) distinct members of a hash table by reservoir
+// sampling, without copying the members
+func (s *SimpleSet) sampleDict(count int) []string {
+	res := make([]string, 0, count)
+	i := 0
+	for member := range s.dict {
+		if i < count {
+			res = append(res, member)
+		} else if j := rand.Intn(i + 1); j < count {
+			res[j] = member
+		}
+		i++
+	}
+	// The reservoir keeps its first members in iteration order
+	rand.Shuffle(len(res), func(i, j int) {
+		res[i], res[j] = res[j], res[i]
+	})
+	return res
+}
+
+// SPOP
+func (s *SimpleSet) Pop(count int) []string {
+	if count < 0 {
+		return nil
+	}
+	members := s.RandomMembers(count)
+	s.Rem(members...)
+	return members
+}
@@ -1,13 +1,14 @@
 package simple_set
 
 import (
+	"fmt"
 	"reflect"
 	"sort"
 	"testing"
 )
 
 func TestSimpleSet(t *testing.T) {
-	set := NewSimpleSet("test")
+	set := NewSimpleSet("test", 512, 128, 64)
 
 	// --- Test SADD ---
 	added := set.Add("a", "b", "c", "a") // "a" bị duplicate
@@ -40,3 +41,113 @@ func TestSimpleSet(t *testing.T) {
 		t.Errorf("expected a to be removed")
 	}
 }
+
+func TestSimpleSet_RandomMembers(t *testing.T) {
+	set := NewSimpleSet("test", 512, 128, 64)
+	set.Add("a", "b", "c", "d", "e")
+
+	if got := set.RandomMembers(3); len(got) != 3 {
+		t.Errorf("expected 3 members, got %v", got)
+	}
+	got := set.RandomMembers(10)
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, []string{"a", "b", "c", "d", "e"}) {
+		t.Errorf("expected all members, got %v", got)
+	}
+	// Negative counts may repeat members
+	got = set.RandomMembers(-20)
+	if len(got) != 20 {
+		t.Errorf("expected 20 members, got %d", len(got))
+	}
+	for _, m := range got {
+		if set.IsMember(m) != 1 {
+			t.Errorf("unexpected member %s", m)
+		}
+	}
+
+	// A hash table is sampled without copying its members
+	set = NewSimpleSet("test", 4, 4, 64)
+	for i := 0; i < 100; i++ {
+		set.Add(fmt.Sprintf("m%d", i))
+	}
+	got = set.RandomMembers(30)
+	seen := make(map[string]bool)
+	for _, m := range got {
+		if set.IsMember(m) != 1 || seen[m] {
+			t.Errorf("unexpected or repeated member %s", m)
+		}
+		seen[m] = true
+	}
+	if len(seen) != 30 {
+		t.Errorf("expected 30 members, got %d", len(seen))
+	}
+}
+
+func TestSimpleSet_Pop(t *testing.T) {
+	set := NewSimpleSet("test", 512, 128, 64)
+	set.Add("a", "b", "c")
+
+	popped := set.Pop(2)
+	if len(popped) != 2 || set.Card() != 1 {
+		t.Errorf("expected 2 popped and 1 left, got %v and %d", popped, set.Card())
+	}
+	for _, m := range popped {
+		if set.IsMember(m) != 0 {
+			t.Errorf("expected %s to be removed", m)
+		}
+	}
+	set.Pop(5)
+	if set.Card() != 0 {
+		t.Errorf("expected empty set, got %d", set.Card())
+	}
+}
+
+func TestSimpleSet_Encoding(t *testing.T) {
+	set := NewSimpleSet("test", 4, 3, 8)
+	set.Add("3", "-1", "2")
+	if set.Encoding() != EncodingIntset {
+		t.Errorf("expected intset, got %s", set.Encoding())
+	}
+	if !reflect.DeepEqual(set.Members(), []string{"-1", "2", "3"}) {
+		t.Errorf("expected sorted integers, got %v", set.Members())
+	}
+	// "02" is not the canonical form of an integer
+	if set.IsMember("02") != 0 {
+		t.Errorf("expected 02 not to be member")
+	}
+
+	// A string member converts the intset to a listpack
+	set.Rem("3")
+	set.Add("a")
+	if set.Encoding() != EncodingListpack {
+		t.Errorf("expected listpack, got %s", set.Encoding())
+	}
+	if set.IsMember("2") != 1 || set.IsMember("a") != 1 || set.Card() != 3 {
+		t.Errorf("unexpected members %v", set.Members())
+	}
+
+	// Too many members
+	set.Add("b")
+	if set.Encoding() != EncodingHashTable {
+		t.Errorf("expected hashtable, got %s", set.Encoding())
+	}
+	members := set.Members()
+	sort.Strings(members)
+	if !reflect.DeepEqual(members, []string{"-1", "2", "a", "b"}) {
+		t.Errorf("unexpected members %v", members)
+	}
+
+	// Too many integers
+	set = NewSimpleSet("test", 4, 3, 8)
+	set.Add("1", "2", "3", "4", "5")
+	if set.Encoding() != EncodingHashTable || set.Card() != 5 {
+		t.Errorf("expected hashtable with 5 members, got %s with %d", set.Encoding(), set.Card())
+	}
+
+	// Member too long
+	set = NewSimpleSet("test", 4, 3, 8)
+	set.Add("abcdefghi")
+	if set.Encoding() != EncodingHashTable || set.IsMember("abcdefghi") != 1 {
+		t.Errorf("expected hashtable, got %s", set.Encoding())
+	}
+}
@@ -1,6 +1,9 @@
 package sorted_set
 
 import (
+	"fmt"
+	"math"
+	"math/rand"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -248,3 +251,282 @@ func TestSortedSet_Integration(t *testing.T) {
 		assert.EqualValues(t, expectedRank, rank)
 	}
 }
+
+func TestSortedSet_PopMinMax(t *testing.T) {
+	ss, err := NewSortedSetWithBTree(4)
+	assert.NoError(t, err)
+	for i := 1; i <= 10; i++ {
+		ss.Add(float64(i), fmt.Sprintf("m%02d", i))
+	}
+
+	items := ss.PopMin(3)
+	assert.Len(t, items, 3)
+	for i, item := range items {
+		assert.EqualValues(t, i+1, item.Score)
+	}
+
+	items = ss.PopMax(2)
+	assert.Len(t, items, 2)
+	assert.EqualValues(t, 10, items[0].Score)
+	assert.EqualValues(t, 9, items[1].Score)
+
+	assert.Equal(t, 5, ss.Len())
+	assert.EqualValues(t, 0, ss.GetRank("m04"))
+}
+
+func TestSortedSet_GetRange(t *testing.T) {
+	ss, err := NewSortedSetWithBTree(4)
+	assert.NoError(t, err)
+	for i := 1; i <= 50; i++ {
+		ss.Add(float64(i/2), fmt.Sprintf("m%02d", i))
+	}
+
+	items := ss.Index.GetRange(10, 12)
+	assert.Len(t, items, 6)
+	for i, item := range items {
+		assert.EqualValues(t, 10+i/2, item.Score)
+		assert.Equal(t, fmt.Sprintf("m%02d", 20+i), item.Member)
+	}
+	assert.Empty(t, ss.Index.GetRange(100, 200))
+	assert.Len(t, ss.Index.GetRange(-1, 0), 1)
+}
+
+func TestSortedSet_RangeByScore(t *testing.T) {
+	ss, err := NewSortedSetWithBTree(4)
+	assert.NoError(t, err)
+	// Many members with the same score spread over several leaves
+	for i := 0; i < 60; i++ {
+		ss.Add(float64(i%3), fmt.Sprintf("m%02d", i))
+	}
+	for i := 0; i < 60; i += 2 {
+		assert.EqualValues(t, 1, ss.Remove(fmt.Sprintf("m%02d", i)))
+	}
+	assert.Equal(t, 30, ss.Len())
+
+	r, err := ParseScoreRange("(0", "+inf")
+	assert.NoError(t, err)
+	assert.Equal(t, 20, ss.Count(r))
+	items := ss.RangeByScore(r, true, 1, 3)
+	assert.Len(t, items, 3)
+	assert.Equal(t, "m53", items[0].Member)
+	assert.EqualValues(t, 2, items[0].Score)
+
+	r, _ = ParseScoreRange("1", "(1")
+	assert.Equal(t, 0, ss.Count(r))
+
+	items = ss.RangeByRank(0, 2, true)
+	assert.Equal(t, "m59", items[0].Member)
+	assert.Equal(t, "m47", items[2].Member)
+	assert.Equal(t, 29, ss.GetRevRank("m03"))
+}
+
+func TestSortedSet_RangeByLex(t *testing.T) {
+	ss, err := NewSortedSetWithBTree(4)
+	assert.NoError(t, err)
+	for _, m := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+		ss.Add(0, m)
+	}
+	r, err := ParseLexRange("[b", "(e")
+	assert.NoError(t, err)
+	items := ss.RangeByLex(r, false, 0, -1)
+	assert.Len(t, items, 3)
+	assert.Equal(t, "b", items[0].Member)
+	assert.Equal(t, "d", items[2].Member)
+
+	// LIMIT stops at the end of the range, and skips the offset from either end
+	assert.Equal(t, []*Item{{Member: "c"}, {Member: "d"}}, ss.RangeByLex(r, false, 1, 5))
+	assert.Equal(t, []*Item{{Member: "c"}, {Member: "b"}}, ss.RangeByLex(r, true, 1, -1))
+	assert.Empty(t, ss.RangeByLex(r, false, 3, 1))
+	assert.Empty(t, ss.RangeByLex(r, true, math.MaxInt, 1))
+	assert.Empty(t, ss.RangeByLex(r, false, -1, 1))
+
+	r, _ = ParseLexRange("-", "+")
+	assert.Equal(t, 7, ss.LexCount(r))
+	r, _ = ParseLexRange("+", "-")
+	assert.Equal(t, 0, ss.LexCount(r))
+
+	_, err = ParseLexRange("b", "+")
+	assert.Equal(t, ErrInvalidLexRange, err)
+}
+
+func TestSortedSet_RemoveRange(t *testing.T) {
+	ss, err := NewSortedSetWithBTree(4)
+	assert.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		ss.Add(float64(i/10), fmt.Sprintf("m%02d", i))
+	}
+
+	r, _ := ParseScoreRange("2", "(5")
+	assert.Equal(t, 30, ss.RemoveRangeByScore(r))
+	assert.Equal(t, 70, ss.Len())
+	_, exists := ss.GetScore("m25")
+	assert.False(t, exists)
+
+	assert.Equal(t, 20, ss.RemoveRangeByRank(10, 29))
+	assert.Equal(t, 50, ss.Len())
+	assert.Equal(t, "m70", ss.Index.GetByRank(20).Member)
+	for rank, item := range ss.RangeByRank(0, ss.Len()-1, false) {
+		assert.Equal(t, rank, ss.GetRank(item.Member))
+	}
+}
+
+func TestBTreeIndex_PopMinMax(t *testing.T) {
+	index := NewBTreeIndex(4)
+	for i := 0; i < 200; i++ {
+		index.Add(float64(i%50), fmt.Sprintf("m%03d", i))
+	}
+
+	prev := index.PopMin()
+	for i := 1; i < 100; i++ {
+		item := index.PopMin()
+		assert.True(t, prev.CompareTo(item) < 0)
+		prev = item
+	}
+	prev = index.PopMax()
+	for i := 1; i < 100; i++ {
+		item := index.PopMax()
+		assert.True(t, prev.CompareTo(item) > 0)
+		prev = item
+	}
+	assert.Nil(t, index.PopMin())
+	assert.Nil(t, index.PopMax())
+}
+
+// checkCounts verifies that the count of every node is the number of items in its subtree
+func checkCounts(t *testing.T, node *BTreeNode) int {
+	if node.IsLeaf {
+		assert.Equal(t, len(node.Items), node.Count)
+		return len(node.Items)
+	}
+	total := 0
+	for _, child := range node.Children {
+		assert.Same(t, node, child.Parent)
+		total += checkCounts(t, child)
+	}
+	assert.Equal(t, total, node.Count)
+	return total
+}
+
+func TestBTreeIndex_SubtreeCounts(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, degree := range []int{3, 4, 5, 8} {
+		ss, err := NewSortedSetWithBTree(degree)
+		assert.NoError(t, err)
+		index := ss.Index.(*BTreeIndex)
+		for i := 0; i < 3000; i++ {
+			member := fmt.Sprintf("m%d", rnd.Intn(500))
+			switch rnd.Intn(4) {
+			case 0:
+				ss.Remove(member)
+			case 1:
+				ss.PopMin(1)
+			default:
+				ss.Add(float64(rnd.Intn(50)), member)
+			}
+		}
+		assert.Equal(t, ss.Len(), checkCounts(t, index.Root))
+
+		items := index.GetRangeByRank(0, ss.Len()-1)
+		assert.Len(t, items, ss.Len())
+		for rank, item := range items {
+			assert.Same(t, item, index.GetByRank(rank))
+			assert.Equal(t, rank, ss.GetRank(item.Member))
+			if rank > 0 {
+				assert.True(t, items[rank-1].CompareTo(item) < 0)
+			}
+		}
+		assert.Nil(t, index.GetByRank(ss.Len()))
+	}
+}
+
+// checkShape verifies that the leaves are at the same depth, that the nodes but the root
+// are at least half full and that the leaves are linked in order
+func checkShape(t *testing.T, index *BTreeIndex) {
+	minItems := (index.Degree - 1) / 2
+	depth := -1
+	var leaves []*BTreeNode
+	var walk func(node *BTreeNode, level int)
+	walk = func(node *BTreeNode, level int) {
+		if node != index.Root {
+			assert.GreaterOrEqual(t, len(node.Items), minItems)
+		}
+		if node.IsLeaf {
+			if depth == -1 {
+				depth = level
+			}
+			assert.Equal(t, depth, level)
+			leaves = append(leaves, node)
+			return
+		}
+		assert.Len(t, node.Children, len(node.Items)+1)
+		for _, child := range node.Children {
+			walk(child, level+1)
+		}
+	}
+	walk(index.Root, 0)
+	for i, leaf := range leaves {
+		if i+1 < len(leaves) {
+			assert.Same(t, leaves[i+1], leaf.Next)
+		} else {
+			assert.Nil(t, leaf.Next)
+		}
+	}
+}
+
+func TestBTreeIndex_DeleteRankRange(t *testing.T) {
+	rnd := rand.New(rand.NewSource(3))
+	for _, degree := range []int{3, 4, 5, 8, 32} {
+		for round := 0; round < 20; round++ {
+			index, model := randomIndex(rnd, func() OrderedIndex { return NewBTreeIndex(degree) }, 1500)
+			btree := index.(*BTreeIndex)
+			for len(model.items) > 0 {
+				start := rnd.Intn(len(model.items))
+				end := start + rnd.Intn(min(len(model.items)-start, 1+rnd.Intn(200)))
+				assertItems(t, model.items[start:end+1], index.DeleteRangeByRank(start, end))
+				model.items = append(model.items[:start], model.items[end+1:]...)
+
+				assert.Equal(t, len(model.items), checkCounts(t, btree.Root))
+				checkShape(t, btree)
+				assertItems(t, model.items, collect(index.IterateFromRank(0, false)))
+				if rnd.Intn(3) == 0 {
+					member := fmt.Sprintf("n%d", rnd.Intn(1000))
+					model.set(index, float64(rnd.Intn(40)), member)
+				}
+			}
+			assert.Equal(t, 0, index.Count())
+		}
+	}
+}
+
+const benchmarkMembers = 100000
+
+func newBenchmarkIndex(b *testing.B, index OrderedIndex) *SortedSet {
+	ss := &SortedSet{Index: index, MemberScore: make(map[string]float64)}
+	for i := 0; i < benchmarkMembers; i++ {
+		ss.Add(float64(i%1000), fmt.Sprintf("member:%d", i))
+	}
+	b.ResetTimer()
+	return ss
+}
+
+func BenchmarkBTreeIndex_GetRank(b *testing.B) {
+	ss := newBenchmarkIndex(b, NewBTreeIndex(32))
+	for i := 0; i < b.N; i++ {
+		ss.GetRank(fmt.Sprintf("member:%d", i%benchmarkMembers))
+	}
+}
+
+func BenchmarkBTreeIndex_GetByRank(b *testing.B) {
+	ss := newBenchmarkIndex(b, NewBTreeIndex(32))
+	for i := 0; i < b.N; i++ {
+		ss.Index.GetByRank(i % benchmarkMembers)
+	}
+}
+
+func BenchmarkBTreeIndex_GetRangeByRank(b *testing.B) {
+	ss := newBenchmarkIndex(b, NewBTreeIndex(32))
+	for i := 0; i < b.N; i++ {
+		start := i % (benchmarkMembers - 10)
+		ss.Index.GetRangeByRank(start, start+9)
+	}
+}
@@ -1,5 +1,7 @@
 package sorted_set
 
+import "slices"
+
 // BTreeNode represents a node in the B+ Tree
 type BTreeNode struct {
 	Items    []*Item
@@ -7,6 +9,8 @@ type BTreeNode struct {
 	IsLeaf   bool
 	Parent   *BTreeNode
 	Next     *BTreeNode
+	// Count is the number of items in the leaves of the subtree, for rank queries
+	Count int
 }
 
 // BTreeIndex implements OrderedIndex using B+ Tree
@@ -31,14 +35,7 @@ func (t *BTreeIndex) Add(score float64, member string) int {
 	}
 
 	// Find the correct leaf to insert into
-	node := t.Root
-	for !node.IsLeaf {
-		i := 0
-		for i < len(node.Items) && score >= node.Items[i].Score {
-			i++
-		}
-		node = node.Children[i]
-	}
+	node := t.findLeaf(item)
 
 	// Check if the member already exists in the leaf node
 	for i, existingItem := range node.Items {
@@ -54,6 +51,7 @@ func (t *BTreeIndex) Add(score float64, member string) int {
 		i++
 	}
 	node.Items = append(node.Items[:i], append([]*Item{item}, node.Items[i:]...)...)
+	addCount(node, 1)
 
 	// Split the node if it's over capacity
 	if len(node.Items) > t.Degree-1 {
@@ -62,24 +60,32 @@ func (t *BTreeIndex) Add(score float64, member string) int {
 	return 1 // Added new item
 }
 
-func (t *BTreeIndex) RemoveByScore(score float64, member string) int {
-	// Optimized removal with known score
-
-	// Find the correct leaf node using score (O(log n))
+// findLeaf returns the leaf where item is or would be stored. Separators are compared on
+// both score and member, items with the same score may be spread over several leaves.
+func (t *BTreeIndex) findLeaf(item *Item) *BTreeNode {
 	node := t.Root
 	for !node.IsLeaf {
 		i := 0
-		for i < len(node.Items) && score >= node.Items[i].Score {
+		for i < len(node.Items) && item.CompareTo(node.Items[i]) >= 0 {
 			i++
 		}
 		node = node.Children[i]
 	}
+	return node
+}
+
+func (t *BTreeIndex) RemoveByScore(score float64, member string) int {
+	// Optimized removal with known score
+
+	// Find the correct leaf node using score (O(log n))
+	node := t.findLeaf(&Item{Score: score, Member: member})
 
 	// Find and remove the item in the leaf node
 	for i, item := range node.Items {
 		if item.Member == member && item.Score == score {
 			// Remove the item
 			node.Items = append(node.Items[:i], node.Items[i+1:]...)
+			addCount(node, -1)
 
 			// Handle underflow if necessary
 			if len(node.Items) < (t.Degree-1)/2 && node.Parent != nil {
@@ -93,6 +99,146 @@ func (t *BTreeIndex) RemoveByScore(score float64, member string) int {
 	return 0 // Item not found
 }
 
+func (t *BTreeIndex) PopMin() *Item {
+	node := t.Root
+	for !node.IsLeaf {
+		node = node.Children[0]
+	}
+	if len(node.Items) == 0 {
+		return nil
+	}
+	item := node.Items[0]
+	t.RemoveByScore(item.Score, item.Member)
+	return item
+}
+
+func (t *BTreeIndex) PopMax() *Item {
+	node := t.Root
+	for !node.IsLeaf {
+		node = node.Children[len(node.Children)-1]
+	}
+	if len(node.Items) == 0 {
+		return nil
+	}
+	item := node.Items[len(node.Items)-1]
+	t.RemoveByScore(item.Score, item.Member)
+	return item
+}
+
+// removeItems removes items, which are consecutive from the rank of the first one, and
+// returns them, O(log N + M)
+func (t *BTreeIndex) removeItems(items []*Item) []*Item {
+	if len(items) == 0 {
+		return items
+	}
+	start := t.GetRankByScore(items[0].Score, items[0].Member)
+	t.deleteRankRange(start, start+len(items)-1)
+	return items
+}
+
+func (t *BTreeIndex) DeleteRangeByScore(r *ScoreRange) []*Item {
+	return t.removeItems(t.GetRangeByScore(r))
+}
+
+func (t *BTreeIndex) DeleteRangeByLex(r *LexRange) []*Item {
+	return t.removeItems(t.GetRangeByLex(r))
+}
+
+func (t *BTreeIndex) DeleteRangeByRank(start, end int) []*Item {
+	return t.removeItems(t.GetRangeByRank(start, end))
+}
+
+// deleteRankRange removes the items with start <= rank <= end. The subtrees inside the range
+// are unlinked whole and the leaves on its edges are cut in one descent, then the nodes left
+// underflowing along the two edges are rebalanced.
+func (t *BTreeIndex) deleteRankRange(start, end int) {
+	if start == 0 && end == t.Root.Count-1 {
+		t.Clear()
+		return
+	}
+	prev, _ := t.findByRank(start - 1)
+	next, _ := t.findByRank(end + 1)
+	t.cutRange(t.Root, start, end)
+	if prev != nil && prev != next {
+		prev.Next = next
+	}
+	if start > 0 {
+		t.rebalanceAt(start - 1)
+	}
+	if start < t.Root.Count {
+		t.rebalanceAt(start)
+	}
+}
+
+// cutRange removes the items with start <= rank <= end from the subtree of node, ranks
+// being relative to the subtree. A separator between two remaining children is the one on
+// the left of the right child, which is still lower than or equal to all its items.
+func (t *BTreeIndex) cutRange(node *BTreeNode, start, end int) {
+	node.Count -= end - start + 1
+	if node.IsLeaf {
+		node.Items = slices.Delete(node.Items, start, end+1)
+		return
+	}
+	children := node.Children[:0]
+	items := node.Items[:0]
+	offset := 0
+	for i, child := range node.Children {
+		lo, hi := offset, offset+child.Count-1
+		offset += child.Count
+		if lo >= start && hi <= end {
+			continue
+		}
+		if hi >= start && lo <= end {
+			t.cutRange(child, max(start, lo)-lo, min(end, hi)-lo)
+		}
+		if len(children) > 0 {
+			items = append(items, node.Items[i-1])
+		}
+		children = append(children, child)
+	}
+	clear(node.Children[len(children):])
+	clear(node.Items[len(items):])
+	node.Children, node.Items = children, items
+}
+
+// rebalanceAt fixes the underflowing nodes on the path to the leaf holding rank, the
+// topmost first so that the node fixed always has a sibling
+func (t *BTreeIndex) rebalanceAt(rank int) {
+	minItems := (t.Degree - 1) / 2
+	for {
+		for !t.Root.IsLeaf && len(t.Root.Children) == 1 {
+			t.Root = t.Root.Children[0]
+			t.Root.Parent = nil
+		}
+		var underflow *BTreeNode
+		node, _ := t.findByRank(rank)
+		for ; node != nil && node.Parent != nil; node = node.Parent {
+			if len(node.Items) < minItems {
+				underflow = node
+			}
+		}
+		if underflow == nil {
+			return
+		}
+		t.handleUnderflow(underflow)
+	}
+}
+
+// addCount adds delta to the count of node and of all its ancestors
+func addCount(node *BTreeNode, delta int) {
+	for ; node != nil; node = node.Parent {
+		node.Count += delta
+	}
+}
+
+// sumCounts recomputes the count of an internal node from its children
+func sumCounts(node *BTreeNode) {
+	node.Count = 0
+	for _, child := range node.Children {
+		node.Count += child.Count
+	}
+}
+
 func (t *BTreeIndex) handleUnderflow(node *BTreeNode) {
 	// Root node doesn't need to handle underflow
 	if node.Parent == nil {
@@ -156,6 +302,8 @@ func (t *BTreeIndex) borrowFromLeftSibling(node *BTreeNode, leftSibling *BTreeNo
 
 		// Insert borrowed item at the beginning of current node
 		node.Items = append([]*Item{borrowedItem}, node.Items...)
+		leftSibling.Count--
+		node.Count++
 
 		// Update separator in parent (first item of current node)
 		parent.Items[separatorIndex] = node.Items[0]
@@ -173,6 +321,8 @@ func (t *BTreeIndex) borrowFromLeftSibling(node *BTreeNode, leftSibling *BTreeNo
 		node.Items = append([]*Item{separatorItem}, node.Items...)
 		node.Children = append([]*BTreeNode{borrowedChild}, node.Children...)
 		borrowedChild.Parent = node
+		leftSibling.Count -= borrowedChild.Count
+		node.Count += borrowedChild.Count
 
 		// Update separator in parent
 		parent.Items[separatorIndex] = borrowedItem
@@ -189,6 +339,8 @@ func (t *BTreeIndex) borrowFromRightSibling(node *BTreeNode, rightSibling *BTree
 
 		// Insert borrowed item at the end of current node
 		node.Items = append(node.Items, borrowedItem)
+		rightSibling.Count--
+		node.Count++
 
 		// Update separator in parent (first item of right sibling)
 		if len(rightSibling.Items) > 0 {
@@ -208,6 +360,8 @@ func (t *BTreeIndex) borrowFromRightSibling(node *BTreeNode, rightSibling *BTree
 		node.Items = append(node.Items, separatorItem)
 		node.Children = append(node.Children, borrowedChild)
 		borrowedChild.Parent = node
+		rightSibling.Count -= borrowedChild.Count
+		node.Count += borrowedChild.Count
 
 		// Update separator in parent
 		parent.Items[separatorIndex] = borrowedItem
@@ -221,6 +375,7 @@ func (t *BTreeIndex) mergeWithLeftSibling(node *BTreeNode, leftSibling *BTreeNod
 		// Merge all items from current node to left sibling
 		leftSibling.Items = append(leftSibling.Items, node.Items...)
 		leftSibling.Next = node.Next
+		leftSibling.Count += node.Count
 
 		// Remove separator from parent and current node from children
 		parent.Items = append(parent.Items[:separatorIndex], parent.Items[separatorIndex+1:]...)
@@ -233,6 +388,7 @@ func (t *BTreeIndex) mergeWithLeftSibling(node *BTreeNode, leftSibling *BTreeNod
 		leftSibling.Items = append(leftSibling.Items, separatorItem)
 		leftSibling.Items = append(leftSibling.Items, node.Items...)
 		leftSibling.Children = append(leftSibling.Children, node.Children...)
+		leftSibling.Count += node.Count
 
 		// Update parent pointers
 		for _, child := range node.Children {
@@ -261,6 +417,7 @@ func (t *BTreeIndex) mergeWithRightSibling(node *BTreeNode, rightSibling *BTreeN
 		// Merge all items from right sibling to current node
 		node.Items = append(node.Items, rightSibling.Items...)
 		node.Next = rightSibling.Next
+		node.Count += rightSibling.Count
 
 		// Remove separator from parent and right sibling from children
 		parent.Items = append(parent.Items[:separatorIndex], parent.Items[separatorIndex+1:]...)
@@ -273,6 +430,7 @@ func (t *BTreeIndex) mergeWithRightSibling(node *BTreeNode, rightSibling *BTreeN
 		node.Items = append(node.Items, separatorItem)
 		node.Items = append(node.Items, rightSibling.Items...)
 		node.Children = append(node.Children, rightSibling.Children...)
+		node.Count += rightSibling.Count
 
 		// Update parent pointers
 		for _, child := range rightSibling.Children {
@@ -294,6 +452,8 @@ func (t *BTreeIndex) mergeWithRightSibling(node *BTreeNode, rightSibling *BTreeN
 	}
 }
 
+// GetRank walks the leaves as the score of the member is unknown, O(N).
+// GetRankByScore is logarithmic.
 func (t *BTreeIndex) GetRank(member string) int {
 	rank := 0
 
@@ -317,40 +477,154 @@ func (t *BTreeIndex) GetRank(member string) int {
 	return -1
 }
 
-func (t *BTreeIndex) GetByRank(rank int) *Item {
-	if rank < 0 {
-		return nil
+// GetRankByScore descends to the leaf of the item, adding the counts of the subtrees on its
+// left, O(log N)
+func (t *BTreeIndex) GetRankByScore(score float64, member string) int {
+	item := &Item{Score: score, Member: member}
+	rank := 0
+	node := t.Root
+	for !node.IsLeaf {
+		i := 0
+		for i < len(node.Items) && item.CompareTo(node.Items[i]) >= 0 {
+			rank += node.Children[i].Count
+			i++
+		}
+		node = node.Children[i]
+	}
+	for i, existing := range node.Items {
+		if existing.Member == member && existing.Score == score {
+			return rank + i
+		}
 	}
+	return -1
+}
 
-	currentRank := 0
+// countBefore returns the number of items of the prefix where pred holds, O(log N).
+// When pred holds for a separator, it holds for the whole subtree on its left.
+func (t *BTreeIndex) countBefore(pred seekPredicate) int {
+	count := 0
 	node := t.Root
+	for !node.IsLeaf {
+		i := 0
+		for i < len(node.Items) && pred(node.Items[i].Score, node.Items[i].Member) {
+			count += node.Children[i].Count
+			i++
+		}
+		node = node.Children[i]
+	}
+	for _, item := range node.Items {
+		if !pred(item.Score, item.Member) {
+			break
+		}
+		count++
+	}
+	return count
+}
 
-	// Find the first leaf node
+// findByRank returns the leaf holding the item at rank and the index of the item in it
+func (t *BTreeIndex) findByRank(rank int) (*BTreeNode, int) {
+	if rank < 0 || rank >= t.Root.Count {
+		return nil, 0
+	}
+	node := t.Root
 	for !node.IsLeaf {
-		node = node.Children[0]
+		i := 0
+		for i < len(node.Children)-1 && rank >= node.Children[i].Count {
+			rank -= node.Children[i].Count
+			i++
+		}
+		node = node.Children[i]
 	}
+	return node, rank
+}
 
-	// Traverse leaf nodes
-	for node != nil {
-		for _, item := range node.Items {
-			if currentRank == rank {
-				return item
-			}
-			currentRank++
+func (t *BTreeIndex) First() *Item {
+	return t.GetByRank(0)
+}
+
+func (t *BTreeIndex) Last() *Item {
+	return t.GetByRank(t.Root.Count - 1)
+}
+
+// btreeIterator walks the leaves, going back to the previous leaf by rank as leaves are
+// only linked forward
+type btreeIterator struct {
+	t       *BTreeIndex
+	node    *BTreeNode
+	i       int
+	rank    int
+	reverse bool
+}
+
+func (it *btreeIterator) Valid() bool {
+	return it.node != nil
+}
+
+func (it *btreeIterator) Item() *Item {
+	return it.node.Items[it.i]
+}
+
+func (it *btreeIterator) Rank() int {
+	return it.rank
+}
+
+func (it *btreeIterator) Next() {
+	if it.reverse {
+		it.rank--
+		it.i--
+		if it.i < 0 {
+			it.node, it.i = it.t.findByRank(it.rank)
 		}
-		node = node.Next
+		return
+	}
+	it.rank++
+	it.i++
+	for it.node != nil && it.i >= len(it.node.Items) {
+		it.node, it.i = it.node.Next, 0
+	}
+}
+
+func (t *BTreeIndex) IterateFromRank(rank int, reverse bool) Iterator {
+	node, i := t.findByRank(rank)
+	return &btreeIterator{t: t, node: node, i: i, rank: rank, reverse: reverse}
+}
+
+func (t *BTreeIndex) IterateFromScore(score float64, exclusive bool, reverse bool) Iterator {
+	rank := t.countBefore(seekScore(score, exclusive, reverse))
+	if reverse {
+		rank--
+	}
+	return t.IterateFromRank(rank, reverse)
+}
+
+func (t *BTreeIndex) IterateFromLex(bound LexBound, reverse bool) Iterator {
+	rank := t.countBefore(seekLex(bound, reverse))
+	if reverse {
+		rank--
 	}
+	return t.IterateFromRank(rank, reverse)
+}
 
-	return nil
+func (t *BTreeIndex) GetByRank(rank int) *Item {
+	node, i := t.findByRank(rank)
+	if node == nil {
+		return nil
+	}
+	return node.Items[i]
 }
 
 func (t *BTreeIndex) GetRange(min, max float64) []*Item {
 	var result []*Item
 	node := t.Root
 
-	// Find the first leaf node
+	// Find the leftmost leaf that may hold min (O(log n)). Items with a score equal to a
+	// separator may be on both sides of it, so go left on equality.
 	for !node.IsLeaf {
-		node = node.Children[0]
+		i := 0
+		for i < len(node.Items) && min > node.Items[i].Score {
+			i++
+		}
+		node = node.Children[i]
 	}
 
 	// Traverse leaf nodes and collect items in range
@@ -369,53 +643,81 @@ func (t *BTreeIndex) GetRange(min, max float64) []*Item {
 	return result
 }
 
-func (t *BTreeIndex) GetRangeByRank(start, end int) []*Item {
+func (t *BTreeIndex) GetRangeByScore(r *ScoreRange) []*Item {
 	var result []*Item
-	if start < 0 || end < start {
+	if r.IsEmpty() {
 		return result
 	}
-
-	currentRank := 0
 	node := t.Root
-
-	// Find the first leaf node
 	for !node.IsLeaf {
-		node = node.Children[0]
+		i := 0
+		for i < len(node.Items) && r.Min > node.Items[i].Score {
+			i++
+		}
+		node = node.Children[i]
 	}
 
-	// Traverse leaf nodes
 	for node != nil {
 		for _, item := range node.Items {
-			if currentRank >= start && currentRank <= end {
-				result = append(result, item)
-			}
-			if currentRank > end {
+			if !r.LteMax(item.Score) {
 				return result
 			}
-			currentRank++
+			if r.GteMin(item.Score) {
+				result = append(result, item)
+			}
 		}
 		node = node.Next
 	}
-
 	return result
 }
 
-func (t *BTreeIndex) Count() int {
-	count := 0
+func (t *BTreeIndex) GetRangeByLex(r *LexRange) []*Item {
+	var result []*Item
+	if r.IsEmpty() {
+		return result
+	}
+	// The items on the left of a separator lower than the minimum are out of the range
 	node := t.Root
-
-	// Find the first leaf node
 	for !node.IsLeaf {
-		node = node.Children[0]
+		i := 0
+		for i < len(node.Items) && r.Min.Inf == 0 && node.Items[i].Member <= r.Min.Value {
+			i++
+		}
+		node = node.Children[i]
 	}
 
-	// Count items in all leaf nodes
 	for node != nil {
-		count += len(node.Items)
+		for _, item := range node.Items {
+			if !r.LteMax(item.Member) {
+				return result
+			}
+			if r.GteMin(item.Member) {
+				result = append(result, item)
+			}
+		}
 		node = node.Next
 	}
+	return result
+}
 
-	return count
+func (t *BTreeIndex) GetRangeByRank(start, end int) []*Item {
+	var result []*Item
+	if start < 0 || end < start {
+		return result
+	}
+	node, i := t.findByRank(start)
+	for rank := start; node != nil && rank <= end; rank++ {
+		result = append(result, node.Items[i])
+		i++
+		if i == len(node.Items) {
+			node, i = node.Next, 0
+		}
+	}
+	return result
+}
+
+func (t *BTreeIndex) Count() int {
+	return t.Root.Count
 }
 
 func (t *BTreeIndex) Clear() {
@@ -448,6 +750,8 @@ func (t *BTreeIndex) splitLeaf(node *BTreeNode) {
 	newLeaf.Items = append(newLeaf.Items, node.Items[medianIndex:]...)
 	node.Items = node.Items[:medianIndex]
 	node.Next = newLeaf
+	newLeaf.Count = len(newLeaf.Items)
+	node.Count = len(node.Items)
 
 	parent := node.Parent
 	promotedItem := newLeaf.Items[0]
@@ -487,6 +791,8 @@ func (t *BTreeIndex) splitInternal(node *BTreeNode) {
 	for _, child := range newInternal.Children {
 		child.Parent = newInternal
 	}
+	sumCounts(node)
+	sumCounts(newInternal)
 
 	parent := node.Parent
 	childIndex := 0
@@ -507,7 +813,7 @@ func (t *BTreeIndex) splitInternal(node *BTreeNode) {
 
 func (t *BTreeIndex) splitRoot() {
 	oldRoot := t.Root
-	newRoot := &BTreeNode{}
+	newRoot := &BTreeNode{Count: oldRoot.Count}
 
 	t.Root = newRoot
 	oldRoot.Parent = newRoot
@@ -0,0 +1,255 @@
+package sorted_set
+
+import (
+	"strconv"
+
+	"github.com/spaghetti-lover/multithread-redis/internal/data_structure/listpack"
+)
+
+// ListpackIndex is an OrderedIndex storing the items of a small sorted set in a listpack,
+// as member, score, member, score, ... in ascending order.
+// Every operation is O(N), which is cheaper than a tree for a few dozen items and saves
+// one allocation per item.
+type ListpackIndex struct {
+	lp *listpack.Listpack
+}
+
+func NewListpackIndex() *ListpackIndex {
+	return &ListpackIndex{lp: listpack.New()}
+}
+
+// Bytes returns the size of the encoded items in bytes
+func (l *ListpackIndex) Bytes() int {
+	return l.lp.Bytes()
+}
+
+// get decodes the item at offset pos and returns the offset of the next one
+func (l *ListpackIndex) get(pos int) (*Item, int) {
+	member, next := l.lp.Get(pos)
+	score, next := l.lp.Get(next)
+	v, _ := strconv.ParseFloat(score, 64)
+	return &Item{Score: v, Member: member}, next
+}
+
+// items decodes all the items in ascending order
+func (l *ListpackIndex) items() []*Item {
+	res := make([]*Item, 0, l.Count())
+	for pos := 0; pos < l.lp.End(); {
+		var item *Item
+		item, pos = l.get(pos)
+		res = append(res, item)
+	}
+	return res
+}
+
+// find returns the offset and rank of the first item for which stop holds, or End() and
+// Count() if there is none
+func (l *ListpackIndex) find(stop func(item *Item) bool) (int, int) {
+	rank := 0
+	for pos := 0; pos < l.lp.End(); rank++ {
+		item, next := l.get(pos)
+		if stop(item) {
+			return pos, rank
+		}
+		pos = next
+	}
+	return l.lp.End(), rank
+}
+
+// Score returns the score of member
+func (l *ListpackIndex) Score(member string) (float64, bool) {
+	pos := l.lp.Find(0, member, 1)
+	if pos < 0 {
+		return 0, false
+	}
+	score, _ := l.lp.Get(l.lp.Next(pos))
+	v, _ := strconv.ParseFloat(score, 64)
+	return v, true
+}
+
+func (l *ListpackIndex) Add(score float64, member string) int {
+	item := &Item{Score: score, Member: member}
+	pos, _ := l.find(func(other *Item) bool { return other.CompareTo(item) >= 0 })
+	if pos < l.lp.End() {
+		if other, _ := l.get(pos); other.CompareTo(item) == 0 {
+			return 0
+		}
+	}
+	l.lp.Insert(pos, member, strconv.FormatFloat(score, 'g', -1, 64))
+	return 1
+}
+
+func (l *ListpackIndex) Count() int {
+	return l.lp.Len() / 2
+}
+
+func (l *ListpackIndex) First() *Item {
+	return l.GetByRank(0)
+}
+
+func (l *ListpackIndex) Last() *Item {
+	return l.GetByRank(l.Count() - 1)
+}
+
+func (l *ListpackIndex) GetRank(member string) int {
+	pos, rank := l.find(func(item *Item) bool { return item.Member == member })
+	if pos == l.lp.End() {
+		return -1
+	}
+	return rank
+}
+
+func (l *ListpackIndex) GetRankByScore(score float64, member string) int {
+	pos, rank := l.find(func(item *Item) bool { return item.Score == score && item.Member == member })
+	if pos == l.lp.End() {
+		return -1
+	}
+	return rank
+}
+
+func (l *ListpackIndex) GetByRank(rank int) *Item {
+	if rank < 0 || rank >= l.Count() {
+		return nil
+	}
+	item, _ := l.get(l.lp.Seek(2 * rank))
+	return item
+}
+
+// filter returns the items for which keep holds, in ascending order
+func (l *ListpackIndex) filter(keep func(item *Item) bool) []*Item {
+	var res []*Item
+	for _, item := range l.items() {
+		if keep(item) {
+			res = append(res, item)
+		}
+	}
+	return res
+}
+
+func (l *ListpackIndex) GetRange(min, max float64) []*Item {
+	return l.filter(func(item *Item) bool { return item.Score >= min && item.Score <= max })
+}
+
+func (l *ListpackIndex) GetRangeByRank(start, end int) []*Item {
+	items := l.items()
+	if start < 0 || end < start || start >= len(items) {
+		return nil
+	}
+	return items[start:min(end+1, len(items))]
+}
+
+func (l *ListpackIndex) GetRangeByScore(r *ScoreRange) []*Item {
+	return l.filter(func(item *Item) bool { return r.Contains(item.Score) })
+}
+
+func (l *ListpackIndex) GetRangeByLex(r *LexRange) []*Item {
+	return l.filter(func(item *Item) bool { return r.Contains(item.Member) })
+}
+
+func (l *ListpackIndex) IterateFromRank(rank int, reverse bool) Iterator {
+	return &sliceIterator{items: l.items(), i: rank, reverse: reverse}
+}
+
+// iterateFrom returns an iterator on the first item after the prefix for which before
+// holds, or on the last item of the prefix with reverse
+func (l *ListpackIndex) iterateFrom(before seekPredicate, reverse bool) Iterator {
+	_, rank := l.find(func(item *Item) bool { return !before(item.Score, item.Member) })
+	if reverse {
+		rank--
+	}
+	return l.IterateFromRank(rank, reverse)
+}
+
+func (l *ListpackIndex) IterateFromScore(score float64, exclusive bool, reverse bool) Iterator {
+	return l.iterateFrom(seekScore(score, exclusive, reverse), reverse)
+}
+
+func (l *ListpackIndex) IterateFromLex(bound LexBound, reverse bool) Iterator {
+	return l.iterateFrom(seekLex(bound, reverse), reverse)
+}
+
+func (l *ListpackIndex) RemoveByScore(score float64, member string) int {
+	pos, _ := l.find(func(item *Item) bool { return item.Score == score && item.Member == member })
+	if pos == l.lp.End() {
+		return 0
+	}
+	l.lp.Delete(pos, 2)
+	return 1
+}
+
+func (l *ListpackIndex) PopMin() *Item {
+	return l.popRank(0)
+}
+
+func (l *ListpackIndex) PopMax() *Item {
+	return l.popRank(l.Count() - 1)
+}
+
+func (l *ListpackIndex) popRank(rank int) *Item {
+	item := l.GetByRank(rank)
+	if item != nil {
+		l.lp.Delete(l.lp.Seek(2*rank), 2)
+	}
+	return item
+}
+
+// deleteMatching removes the items for which keep holds. They must be consecutive.
+func (l *ListpackIndex) deleteMatching(keep func(item *Item) bool) []*Item {
+	pos, _ := l.find(keep)
+	var res []*Item
+	for end := pos; end < l.lp.End(); {
+		item, next := l.get(end)
+		if !keep(item) {
+			break
+		}
+		res = append(res, item)
+		end = next
+	}
+	if len(res) > 0 {
+		l.lp.Delete(pos, 2*len(res))
+	}
+	return res
+}
+
+func (l *ListpackIndex) DeleteRangeByScore(r *ScoreRange) []*Item {
+	return l.deleteMatching(func(item *Item) bool { return r.Contains(item.Score) })
+}
+
+func (l *ListpackIndex) DeleteRangeByLex(r *LexRange) []*Item {
+	return l.deleteMatching(func(item *Item) bool { return r.Contains(item.Member) })
+}
+
+func (l *ListpackIndex) DeleteRangeByRank(start, end int) []*Item {
+	items := l.GetRangeByRank(start, end)
+	if len(items) > 0 {
+		l.lp.Delete(l.lp.Seek(2*start), 2*len(items))
+	}
+	return items
+}
+
+// sliceIterator walks items decoded in ascending order
+type sliceIterator struct {
+	items   []*Item
+	i       int
+	reverse bool
+}
+
+func (it *sliceIterator) Valid() bool {
+	return it.i >= 0 && it.i < len(it.items)
+}
+
+func (it *sliceIterator) Item() *Item {
+	return it.items[it.i]
+}
+
+func (it *sliceIterator) Rank() int {
+	return it.i
+}
+
+func (it *sliceIterator) Next() {
+	if it.reverse {
+		it.i--
+	} else {
+		it.i++
+	}
+}
@@ -0,0 +1,66 @@
+package sorted_set
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newListpackSortedSet(t *testing.T) *SortedSet {
+	ss, err := NewSortedSet(IndexConfig{Type: IndexTypeBTree, Degree: 4, MaxListpackEntries: 4, MaxListpackValue: 8})
+	assert.NoError(t, err)
+	return ss
+}
+
+func TestSortedSet_ListpackEncoding(t *testing.T) {
+	ss := newListpackSortedSet(t)
+	assert.Equal(t, 1, ss.Add(3, "c"))
+	assert.Equal(t, 1, ss.Add(1, "a"))
+	assert.Equal(t, 1, ss.Add(math.Inf(1), "z"))
+	assert.Equal(t, 0, ss.Add(2, "c"))
+	assert.Equal(t, EncodingListpack, ss.Encoding())
+	assert.Nil(t, ss.MemberScore)
+
+	score, ok := ss.GetScore("c")
+	assert.True(t, ok)
+	assert.Equal(t, 2.0, score)
+	score, _ = ss.GetScore("z")
+	assert.True(t, math.IsInf(score, 1))
+	assert.Equal(t, 1, ss.GetRank("c"))
+	assert.Equal(t, 0, ss.GetRevRank("z"))
+	assert.Equal(t, map[string]float64{"a": 1, "c": 2, "z": math.Inf(1)}, ss.Scores())
+
+	assert.Equal(t, 1, ss.Remove("a"))
+	assert.Equal(t, 0, ss.Remove("a"))
+	assert.Equal(t, 2, ss.Len())
+}
+
+func TestSortedSet_ListpackConversion(t *testing.T) {
+	// Too many members
+	ss := newListpackSortedSet(t)
+	for i, member := range []string{"a", "b", "c", "d"} {
+		ss.Add(float64(i), member)
+	}
+	assert.Equal(t, EncodingListpack, ss.Encoding())
+	ss.Add(4, "e")
+	assert.Equal(t, string(IndexTypeBTree), ss.Encoding())
+	assert.Equal(t, 5, ss.Len())
+	assert.Equal(t, 5, len(ss.MemberScore))
+	assert.Equal(t, 4, ss.GetRank("e"))
+	assert.Equal(t, "a", ss.PopMin(1)[0].Member)
+
+	// Member too long
+	ss = newListpackSortedSet(t)
+	ss.Add(1, "short")
+	ss.Add(2, strings.Repeat("x", 9))
+	assert.Equal(t, string(IndexTypeBTree), ss.Encoding())
+	score, ok := ss.GetScore("short")
+	assert.True(t, ok)
+	assert.Equal(t, 1.0, score)
+
+	// The listpack can be disabled
+	ss, _ = NewSortedSetWithBTree(4)
+	assert.Equal(t, string(IndexTypeBTree), ss.Encoding())
+}
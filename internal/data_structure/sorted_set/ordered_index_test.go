@@ -0,0 +1,220 @@
+package sorted_set
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Conformance suite run against every OrderedIndex backend
+
+var indexBackends = map[string]func() OrderedIndex{
+	"btree-3":  func() OrderedIndex { return NewBTreeIndex(3) },
+	"btree-4":  func() OrderedIndex { return NewBTreeIndex(4) },
+	"btree-16": func() OrderedIndex { return NewBTreeIndex(16) },
+	"skiplist": func() OrderedIndex { return NewSkipListIndex(SkiplistMaxLevel) },
+	"listpack": func() OrderedIndex { return NewListpackIndex() },
+}
+
+// indexModel is a sorted slice of the items expected in the index
+type indexModel struct {
+	items []*Item
+}
+
+func (m *indexModel) find(member string) int {
+	for i, item := range m.items {
+		if item.Member == member {
+			return i
+		}
+	}
+	return -1
+}
+
+// set adds or updates a member in both the model and the index
+func (m *indexModel) set(index OrderedIndex, score float64, member string) {
+	if i := m.find(member); i >= 0 {
+		index.RemoveByScore(m.items[i].Score, member)
+		m.items = append(m.items[:i], m.items[i+1:]...)
+	}
+	index.Add(score, member)
+	m.items = append(m.items, &Item{Score: score, Member: member})
+	sort.Slice(m.items, func(i, j int) bool { return m.items[i].CompareTo(m.items[j]) < 0 })
+}
+
+func (m *indexModel) remove(index OrderedIndex, member string) {
+	if i := m.find(member); i >= 0 {
+		index.RemoveByScore(m.items[i].Score, member)
+		m.items = append(m.items[:i], m.items[i+1:]...)
+	}
+}
+
+// filter returns the items of the model for which keep holds
+func (m *indexModel) filter(keep func(item *Item) bool) []*Item {
+	var res []*Item
+	for _, item := range m.items {
+		if keep(item) {
+			res = append(res, item)
+		}
+	}
+	return res
+}
+
+func assertItems(t *testing.T, expected, actual []*Item, msgAndArgs ...interface{}) {
+	assert.Equal(t, len(expected), len(actual), msgAndArgs...)
+	for i := 0; i < len(expected) && i < len(actual); i++ {
+		assert.Equal(t, *expected[i], *actual[i], msgAndArgs...)
+	}
+}
+
+// collect walks an iterator to the end
+func collect(it Iterator) []*Item {
+	var res []*Item
+	for ; it.Valid(); it.Next() {
+		res = append(res, it.Item())
+	}
+	return res
+}
+
+func randomIndex(rnd *rand.Rand, newIndex func() OrderedIndex, ops int) (OrderedIndex, *indexModel) {
+	index := newIndex()
+	model := &indexModel{}
+	for i := 0; i < ops; i++ {
+		member := fmt.Sprintf("m%03d", rnd.Intn(300))
+		if rnd.Intn(4) == 0 {
+			model.remove(index, member)
+		} else {
+			model.set(index, float64(rnd.Intn(40)), member)
+		}
+	}
+	return index, model
+}
+
+func TestOrderedIndex_Conformance(t *testing.T) {
+	for name, newIndex := range indexBackends {
+		t.Run(name, func(t *testing.T) {
+			rnd := rand.New(rand.NewSource(7))
+			index, model := randomIndex(rnd, newIndex, 2000)
+			n := len(model.items)
+
+			assert.Equal(t, n, index.Count())
+			assert.Equal(t, *model.items[0], *index.First())
+			assert.Equal(t, *model.items[n-1], *index.Last())
+
+			for rank, item := range model.items {
+				assert.Equal(t, *item, *index.GetByRank(rank))
+				assert.Equal(t, rank, index.GetRankByScore(item.Score, item.Member))
+			}
+			assert.Nil(t, index.GetByRank(n))
+			assert.Equal(t, -1, index.GetRankByScore(1000, "missing"))
+
+			// Full walks in both directions
+			assertItems(t, model.items, collect(index.IterateFromRank(0, false)))
+			reversed := make([]*Item, n)
+			for i, item := range model.items {
+				reversed[n-1-i] = item
+			}
+			assertItems(t, reversed, collect(index.IterateFromRank(n-1, true)))
+			assert.False(t, index.IterateFromRank(n, false).Valid())
+
+			for i := 0; i < 100; i++ {
+				start := rnd.Intn(n)
+				end := start + rnd.Intn(20)
+				assertItems(t, model.items[start:min(end+1, n)], index.GetRangeByRank(start, end))
+
+				r := &ScoreRange{Min: float64(rnd.Intn(42) - 1), Max: float64(rnd.Intn(42) - 1), MinEx: rnd.Intn(2) == 0, MaxEx: rnd.Intn(2) == 0}
+				expected := model.filter(func(item *Item) bool { return r.Contains(item.Score) })
+				assertItems(t, expected, index.GetRangeByScore(r), "range %+v", r)
+
+				// The iterators start on the bounds of the range
+				it := index.IterateFromScore(r.Min, r.MinEx, false)
+				after := model.filter(func(item *Item) bool { return r.GteMin(item.Score) })
+				assert.Equal(t, len(after) > 0, it.Valid())
+				if len(after) > 0 {
+					assert.Equal(t, *after[0], *it.Item())
+					assert.Equal(t, n-len(after), it.Rank())
+				}
+				it = index.IterateFromScore(r.Max, r.MaxEx, true)
+				before := model.filter(func(item *Item) bool { return r.LteMax(item.Score) })
+				assert.Equal(t, len(before) > 0, it.Valid())
+				if len(before) > 0 {
+					assert.Equal(t, *before[len(before)-1], *it.Item())
+					assert.Equal(t, len(before)-1, it.Rank())
+				}
+			}
+		})
+	}
+}
+
+func TestOrderedIndex_Lex(t *testing.T) {
+	for name, newIndex := range indexBackends {
+		t.Run(name, func(t *testing.T) {
+			index := newIndex()
+			model := &indexModel{}
+			for i := 0; i < 200; i += 2 {
+				model.set(index, 0, fmt.Sprintf("m%03d", i))
+			}
+			bounds := []string{"-", "+", "[m000", "(m000", "[m051", "(m052", "[m198", "(m198", "[m300", "[a"}
+			for _, min := range bounds {
+				for _, max := range bounds {
+					r, err := ParseLexRange(min, max)
+					assert.NoError(t, err)
+					expected := model.filter(func(item *Item) bool { return r.Contains(item.Member) })
+					assertItems(t, expected, index.GetRangeByLex(r), "range %s %s", min, max)
+
+					it := index.IterateFromLex(r.Min, false)
+					var got []*Item
+					for ; it.Valid() && r.LteMax(it.Item().Member); it.Next() {
+						got = append(got, it.Item())
+					}
+					assertItems(t, expected, got, "forward %s %s", min, max)
+
+					it = index.IterateFromLex(r.Max, true)
+					got = got[:0]
+					for ; it.Valid() && r.GteMin(it.Item().Member); it.Next() {
+						got = append([]*Item{it.Item()}, got...)
+					}
+					assertItems(t, expected, got, "reverse %s %s", min, max)
+				}
+			}
+		})
+	}
+}
+
+func TestOrderedIndex_DeleteRange(t *testing.T) {
+	for name, newIndex := range indexBackends {
+		t.Run(name, func(t *testing.T) {
+			rnd := rand.New(rand.NewSource(11))
+			index, model := randomIndex(rnd, newIndex, 1500)
+
+			r := &ScoreRange{Min: 10, Max: 20, MaxEx: true}
+			expected := model.filter(func(item *Item) bool { return r.Contains(item.Score) })
+			assertItems(t, expected, index.DeleteRangeByScore(r))
+			model.items = model.filter(func(item *Item) bool { return !r.Contains(item.Score) })
+
+			expected = model.items[5:25]
+			assertItems(t, expected, index.DeleteRangeByRank(5, 24))
+			model.items = append(model.items[:5], model.items[25:]...)
+
+			assert.Equal(t, len(model.items), index.Count())
+			assertItems(t, model.items, collect(index.IterateFromRank(0, false)))
+			for rank, item := range model.items {
+				assert.Equal(t, rank, index.GetRankByScore(item.Score, item.Member))
+			}
+
+			for len(model.items) > 0 {
+				assert.Equal(t, *model.items[0], *index.PopMin())
+				model.items = model.items[1:]
+				if len(model.items) > 0 {
+					assert.Equal(t, *model.items[len(model.items)-1], *index.PopMax())
+					model.items = model.items[:len(model.items)-1]
+				}
+			}
+			assert.Equal(t, 0, index.Count())
+			assert.Nil(t, index.First())
+			assert.Nil(t, index.Last())
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package sorted_set
+
+import (
+	"errors"
+	"math"
+	"strconv"
+)
+
+var (
+	ErrInvalidScoreRange = errors.New("min or max is not a float")
+	ErrInvalidLexRange   = errors.New("min or max not valid string range item")
+)
+
+// ScoreRange is a range of scores, each bound may be exclusive
+type ScoreRange struct {
+	Min   float64
+	Max   float64
+	MinEx bool
+	MaxEx bool
+}
+
+// GteMin reports whether score is above the lower bound of the range
+func (r *ScoreRange) GteMin(score float64) bool {
+	if r.MinEx {
+		return score > r.Min
+	}
+	return score >= r.Min
+}
+
+// LteMax reports whether score is below the upper bound of the range
+func (r *ScoreRange) LteMax(score float64) bool {
+	if r.MaxEx {
+		return score < r.Max
+	}
+	return score <= r.Max
+}
+
+func (r *ScoreRange) Contains(score float64) bool {
+	return r.GteMin(score) && r.LteMax(score)
+}
+
+// IsEmpty reports whether no score can be inside the range
+func (r *ScoreRange) IsEmpty() bool {
+	return r.Min > r.Max || (r.Min == r.Max && (r.MinEx || r.MaxEx))
+}
+
+// parseScoreBound parses a score optionally prefixed by '(' for an exclusive bound.
+// -inf and +inf are accepted.
+func parseScoreBound(s string) (float64, bool, error) {
+	exclusive := false
+	if len(s) > 0 && s[0] == '(' {
+		exclusive = true
+		s = s[1:]
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(v) {
+		return 0, false, ErrInvalidScoreRange
+	}
+	return v, exclusive, nil
+}
+
+// ParseScoreRange parses the min and max arguments of ZRANGEBYSCORE-like commands
+func ParseScoreRange(min, max string) (*ScoreRange, error) {
+	r := &ScoreRange{}
+	var err error
+	if r.Min, r.MinEx, err = parseScoreBound(min); err != nil {
+		return nil, err
+	}
+	if r.Max, r.MaxEx, err = parseScoreBound(max); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// LexBound is a bound of a LexRange: a member, or Inf = -1 for "-" and Inf = 1 for "+"
+type LexBound struct {
+	Value     string
+	Exclusive bool
+	Inf       int
+}
+
+// LexRange is a range of members, used when all the members have the same score
+type LexRange struct {
+	Min LexBound
+	Max LexBound
+}
+
+// GteMin reports whether member is above the lower bound of the range
+func (r *LexRange) GteMin(member string) bool {
+	switch r.Min.Inf {
+	case -1:
+		return true
+	case 1:
+		return false
+	}
+	if r.Min.Exclusive {
+		return member > r.Min.Value
+	}
+	return member >= r.Min.Value
+}
+
+// LteMax reports whether member is below the upper bound of the range
+func (r *LexRange) LteMax(member string) bool {
+	switch r.Max.Inf {
+	case -1:
+		return false
+	case 1:
+		return true
+	}
+	if r.Max.Exclusive {
+		return member < r.Max.Value
+	}
+	return member <= r.Max.Value
+}
+
+func (r *LexRange) Contains(member string) bool {
+	return r.GteMin(member) && r.LteMax(member)
+}
+
+// IsEmpty reports whether no member can be inside the range
+func (r *LexRange) IsEmpty() bool {
+	if r.Min.Inf == 1 || r.Max.Inf == -1 {
+		return true
+	}
+	if r.Min.Inf == -1 || r.Max.Inf == 1 {
+		return false
+	}
+	return r.Min.Value > r.Max.Value ||
+		(r.Min.Value == r.Max.Value && (r.Min.Exclusive || r.Max.Exclusive))
+}
+
+func parseLexBound(s string) (LexBound, error) {
+	if s == "-" {
+		return LexBound{Inf: -1}, nil
+	}
+	if s == "+" {
+		return LexBound{Inf: 1}, nil
+	}
+	if len(s) == 0 || (s[0] != '(' && s[0] != '[') {
+		return LexBound{}, ErrInvalidLexRange
+	}
+	return LexBound{Value: s[1:], Exclusive: s[0] == '('}, nil
+}
+
+// ParseLexRange parses the min and max arguments of ZRANGEBYLEX-like commands:
+// "-", "+", "[member" for an inclusive bound or "(member" for an exclusive one
+func ParseLexRange(min, max string) (*LexRange, error) {
+	minBound, err := parseLexBound(min)
+	if err != nil {
+		return nil, err
+	}
+	maxBound, err := parseLexBound(max)
+	if err != nil {
+		return nil, err
+	}
+	return &LexRange{Min: minBound, Max: maxBound}, nil
+}
+
+// seekPredicate holds for a prefix of the ordered items
+type seekPredicate func(score float64, member string) bool
+
+// seekScore returns the predicate holding for the items before an iterator seeded by
+// IterateFromScore
+func seekScore(score float64, exclusive bool, reverse bool) seekPredicate {
+	// The reverse iterator starts on the last item of the prefix
+	if exclusive != reverse {
+		return func(s float64, _ string) bool { return s <= score }
+	}
+	return func(s float64, _ string) bool { return s < score }
+}
+
+// seekLex is like seekScore for IterateFromLex
+func seekLex(bound LexBound, reverse bool) seekPredicate {
+	switch bound.Inf {
+	case -1:
+		return func(float64, string) bool { return false }
+	case 1:
+		return func(float64, string) bool { return true }
+	}
+	if bound.Exclusive != reverse {
+		return func(_ float64, member string) bool { return member <= bound.Value }
+	}
+	return func(_ float64, member string) bool { return member < bound.Value }
+}
@@ -85,8 +85,206 @@ func (sl *SkipListIndex) GetRank(member string) int {
 	return -1
 }
 
+// GetRankByScore implements OrderedIndex.GetRankByScore using the spans, O(log N)
+func (sl *SkipListIndex) GetRankByScore(score float64, member string) int {
+	rank := sl.getRankByScoreAndMember(score, member)
+	if rank == 0 {
+		return -1
+	}
+	return int(rank - 1)
+}
+
+// GetByRank implements OrderedIndex.GetByRank using the spans, O(log N)
+func (sl *SkipListIndex) GetByRank(rank int) *Item {
+	if rank < 0 || rank >= int(sl.length) {
+		return nil
+	}
+	node := sl.getNodeByRank(uint32(rank + 1))
+	if node == nil {
+		return nil
+	}
+	return &Item{Score: node.score, Member: node.ele}
+}
+
+// GetRange implements OrderedIndex.GetRange, O(log N + M) for M items in the range
+func (sl *SkipListIndex) GetRange(min, max float64) []*Item {
+	var result []*Item
+	x := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && x.levels[i].forward.score < min {
+			x = x.levels[i].forward
+		}
+	}
+	for x = x.levels[0].forward; x != nil && x.score <= max; x = x.levels[0].forward {
+		result = append(result, &Item{Score: x.score, Member: x.ele})
+	}
+	return result
+}
+
+// GetRangeByRank implements OrderedIndex.GetRangeByRank
+func (sl *SkipListIndex) GetRangeByRank(start, end int) []*Item {
+	var result []*Item
+	if start < 0 || end < start || start >= int(sl.length) {
+		return result
+	}
+	x := sl.getNodeByRank(uint32(start + 1))
+	for rank := start; x != nil && rank <= end; rank++ {
+		result = append(result, &Item{Score: x.score, Member: x.ele})
+		x = x.levels[0].forward
+	}
+	return result
+}
+
+// GetRangeByScore implements OrderedIndex.GetRangeByScore
+func (sl *SkipListIndex) GetRangeByScore(r *ScoreRange) []*Item {
+	var result []*Item
+	if r.IsEmpty() {
+		return result
+	}
+	x := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && !r.GteMin(x.levels[i].forward.score) {
+			x = x.levels[i].forward
+		}
+	}
+	for x = x.levels[0].forward; x != nil && r.LteMax(x.score); x = x.levels[0].forward {
+		result = append(result, &Item{Score: x.score, Member: x.ele})
+	}
+	return result
+}
+
+// GetRangeByLex implements OrderedIndex.GetRangeByLex
+func (sl *SkipListIndex) GetRangeByLex(r *LexRange) []*Item {
+	var result []*Item
+	if r.IsEmpty() {
+		return result
+	}
+	x := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && !r.GteMin(x.levels[i].forward.ele) {
+			x = x.levels[i].forward
+		}
+	}
+	for x = x.levels[0].forward; x != nil && r.LteMax(x.ele); x = x.levels[0].forward {
+		result = append(result, &Item{Score: x.score, Member: x.ele})
+	}
+	return result
+}
+
+// Count implements OrderedIndex.Count
+func (sl *SkipListIndex) Count() int {
+	return int(sl.length)
+}
+
+// First implements OrderedIndex.First
+func (sl *SkipListIndex) First() *Item {
+	x := sl.head.levels[0].forward
+	if x == nil {
+		return nil
+	}
+	return &Item{Score: x.score, Member: x.ele}
+}
+
+// Last implements OrderedIndex.Last
+func (sl *SkipListIndex) Last() *Item {
+	if sl.tail == nil {
+		return nil
+	}
+	return &Item{Score: sl.tail.score, Member: sl.tail.ele}
+}
+
+// skiplistIterator follows the forward pointers of level 0, or the backward pointers in reverse
+type skiplistIterator struct {
+	node    *SkiplistNode
+	rank    int
+	reverse bool
+}
+
+func (it *skiplistIterator) Valid() bool {
+	return it.node != nil
+}
+
+func (it *skiplistIterator) Item() *Item {
+	return &Item{Score: it.node.score, Member: it.node.ele}
+}
+
+func (it *skiplistIterator) Rank() int {
+	return it.rank
+}
+
+func (it *skiplistIterator) Next() {
+	if it.reverse {
+		it.node = it.node.backward
+		it.rank--
+		return
+	}
+	it.node = it.node.levels[0].forward
+	it.rank++
+}
+
+// IterateFromRank implements OrderedIndex.IterateFromRank
+func (sl *SkipListIndex) IterateFromRank(rank int, reverse bool) Iterator {
+	it := &skiplistIterator{rank: rank, reverse: reverse}
+	if rank >= 0 && rank < int(sl.length) {
+		it.node = sl.getNodeByRank(uint32(rank + 1))
+	}
+	return it
+}
+
+// IterateFromScore implements OrderedIndex.IterateFromScore
+func (sl *SkipListIndex) IterateFromScore(score float64, exclusive bool, reverse bool) Iterator {
+	return sl.iterateFrom(seekScore(score, exclusive, reverse), reverse)
+}
+
+// IterateFromLex implements OrderedIndex.IterateFromLex
+func (sl *SkipListIndex) IterateFromLex(bound LexBound, reverse bool) Iterator {
+	return sl.iterateFrom(seekLex(bound, reverse), reverse)
+}
+
+// iterateFrom starts after the prefix where pred holds, or on its last node in reverse
+func (sl *SkipListIndex) iterateFrom(pred seekPredicate, reverse bool) Iterator {
+	x, rank := sl.seek(pred)
+	if reverse {
+		if x == sl.head {
+			return &skiplistIterator{rank: -1, reverse: true}
+		}
+		return &skiplistIterator{node: x, rank: int(rank) - 1, reverse: true}
+	}
+	return &skiplistIterator{node: x.levels[0].forward, rank: int(rank)}
+}
+
 // Private helper methods
 
+// seek returns the last node of the prefix where pred holds (the head if it is empty) and
+// its 1-based rank, accumulating the spans from the top level down
+func (sl *SkipListIndex) seek(pred seekPredicate) (*SkiplistNode, uint32) {
+	x := sl.head
+	var rank uint32 = 0
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && pred(x.levels[i].forward.score, x.levels[i].forward.ele) {
+			rank += x.levels[i].span
+			x = x.levels[i].forward
+		}
+	}
+	return x, rank
+}
+
+// getNodeByRank finds the node at 1-based rank by accumulating spans from the top level down
+func (sl *SkipListIndex) getNodeByRank(rank uint32) *SkiplistNode {
+	x := sl.head
+	var traversed uint32 = 0
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && traversed+x.levels[i].span <= rank {
+			traversed += x.levels[i].span
+			x = x.levels[i].forward
+		}
+		if traversed == rank {
+			return x
+		}
+	}
+	return nil
+}
+
 func (sl *SkipListIndex) findMember(member string) *SkiplistNode {
 	x := sl.head.levels[0].forward
 	for x != nil {
@@ -240,3 +438,94 @@ func (sl *SkipListIndex) RemoveByScore(score float64, member string) int {
 	}
 	return 0
 }
+
+// PopMin implements OrderedIndex.PopMin
+func (sl *SkipListIndex) PopMin() *Item {
+	x := sl.head.levels[0].forward
+	if x == nil {
+		return nil
+	}
+	sl.deleteByNode(x)
+	return &Item{Score: x.score, Member: x.ele}
+}
+
+// PopMax implements OrderedIndex.PopMax
+func (sl *SkipListIndex) PopMax() *Item {
+	x := sl.tail
+	if x == nil {
+		return nil
+	}
+	sl.deleteByNode(x)
+	return &Item{Score: x.score, Member: x.ele}
+}
+
+// DeleteRangeByScore implements OrderedIndex.DeleteRangeByScore, O(log N + M)
+func (sl *SkipListIndex) DeleteRangeByScore(r *ScoreRange) []*Item {
+	if r.IsEmpty() {
+		return nil
+	}
+	update := [SkiplistMaxLevel]*SkiplistNode{}
+	x := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && !r.GteMin(x.levels[i].forward.score) {
+			x = x.levels[i].forward
+		}
+		update[i] = x
+	}
+	return sl.deleteWhile(x.levels[0].forward, update, func(n *SkiplistNode) bool {
+		return r.LteMax(n.score)
+	})
+}
+
+// DeleteRangeByLex implements OrderedIndex.DeleteRangeByLex, O(log N + M)
+func (sl *SkipListIndex) DeleteRangeByLex(r *LexRange) []*Item {
+	if r.IsEmpty() {
+		return nil
+	}
+	update := [SkiplistMaxLevel]*SkiplistNode{}
+	x := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && !r.GteMin(x.levels[i].forward.ele) {
+			x = x.levels[i].forward
+		}
+		update[i] = x
+	}
+	return sl.deleteWhile(x.levels[0].forward, update, func(n *SkiplistNode) bool {
+		return r.LteMax(n.ele)
+	})
+}
+
+// DeleteRangeByRank implements OrderedIndex.DeleteRangeByRank, O(log N + M)
+func (sl *SkipListIndex) DeleteRangeByRank(start, end int) []*Item {
+	if start < 0 || end < start {
+		return nil
+	}
+	update := [SkiplistMaxLevel]*SkiplistNode{}
+	x := sl.head
+	var traversed uint32 = 0
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && traversed+x.levels[i].span <= uint32(start) {
+			traversed += x.levels[i].span
+			x = x.levels[i].forward
+		}
+		update[i] = x
+	}
+	rank := start
+	return sl.deleteWhile(x.levels[0].forward, update, func(n *SkiplistNode) bool {
+		rank++
+		return rank <= end+1
+	})
+}
+
+// deleteWhile deletes the nodes from x while inRange holds. update holds the predecessors
+// of x at each level, they stay the predecessors of the next node after each deletion.
+func (sl *SkipListIndex) deleteWhile(x *SkiplistNode, update [SkiplistMaxLevel]*SkiplistNode, inRange func(*SkiplistNode) bool) []*Item {
+	var removed []*Item
+	for x != nil && inRange(x) {
+		next := x.levels[0].forward
+		sl.deleteNode(x, update)
+		removed = append(removed, &Item{Score: x.score, Member: x.ele})
+		x = next
+	}
+	return removed
+}
@@ -1,6 +1,7 @@
 package sorted_set
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -157,3 +158,142 @@ func TestSkipListIndex_Integration(t *testing.T) {
 		assert.EqualValues(t, expectedRank, rank)
 	}
 }
+
+func TestSkipListIndex_GetByRank(t *testing.T) {
+	skiplist := NewSkipListIndex(16)
+	for i := 0; i < 100; i++ {
+		skiplist.Add(float64(100-i), fmt.Sprintf("m%03d", 100-i))
+	}
+
+	for rank := 0; rank < 100; rank++ {
+		item := skiplist.GetByRank(rank)
+		assert.NotNil(t, item)
+		assert.EqualValues(t, rank+1, item.Score)
+		assert.Equal(t, fmt.Sprintf("m%03d", rank+1), item.Member)
+	}
+
+	assert.Nil(t, skiplist.GetByRank(-1))
+	assert.Nil(t, skiplist.GetByRank(100))
+}
+
+func TestSkipListIndex_PopMinMax(t *testing.T) {
+	ss, err := NewSortedSet(IndexConfig{Type: IndexTypeSkipList})
+	assert.NoError(t, err)
+	ss.Add(3, "c")
+	ss.Add(1, "a")
+	ss.Add(2, "b")
+	ss.Add(4, "d")
+
+	items := ss.PopMin(2)
+	assert.Len(t, items, 2)
+	assert.Equal(t, "a", items[0].Member)
+	assert.Equal(t, "b", items[1].Member)
+
+	items = ss.PopMax(5)
+	assert.Len(t, items, 2)
+	assert.Equal(t, "d", items[0].Member)
+	assert.Equal(t, "c", items[1].Member)
+	assert.Equal(t, 0, ss.Len())
+}
+
+func TestSkipListIndex_GetRange(t *testing.T) {
+	skiplist := NewSkipListIndex(16)
+	for i := 1; i <= 50; i++ {
+		skiplist.Add(float64(i/2), fmt.Sprintf("m%02d", i))
+	}
+
+	items := skiplist.GetRange(10, 12)
+	assert.Len(t, items, 6)
+	for i, item := range items {
+		assert.EqualValues(t, 10+i/2, item.Score)
+		assert.Equal(t, fmt.Sprintf("m%02d", 20+i), item.Member)
+	}
+	assert.Empty(t, skiplist.GetRange(100, 200))
+	assert.Len(t, skiplist.GetRange(-1, 0), 1)
+}
+
+func TestSkipListIndex_RangeByScoreAndLex(t *testing.T) {
+	ss, err := NewSortedSet(IndexConfig{Type: IndexTypeSkipList})
+	assert.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		ss.Add(float64(i), fmt.Sprintf("m%d", i))
+	}
+
+	r, err := ParseScoreRange("(2", "5")
+	assert.NoError(t, err)
+	items := ss.RangeByScore(r, false, 0, -1)
+	assert.Len(t, items, 3)
+	assert.Equal(t, "m3", items[0].Member)
+	assert.Equal(t, 3, ss.Count(r))
+
+	items = ss.RangeByRank(1, 3, false)
+	assert.Len(t, items, 3)
+	assert.Equal(t, "m1", items[0].Member)
+	items = ss.RangeByRank(0, 1, true)
+	assert.Equal(t, "m9", items[0].Member)
+	assert.Equal(t, "m8", items[1].Member)
+
+	lex, _ := NewSortedSet(IndexConfig{Type: IndexTypeSkipList})
+	for _, m := range []string{"a", "b", "c", "d"} {
+		lex.Add(0, m)
+	}
+	lr, err := ParseLexRange("(a", "[c")
+	assert.NoError(t, err)
+	items = lex.RangeByLex(lr, true, 0, -1)
+	assert.Len(t, items, 2)
+	assert.Equal(t, "c", items[0].Member)
+	assert.Equal(t, 2, lex.LexCount(lr))
+}
+
+func TestSkipListIndex_RemoveRange(t *testing.T) {
+	ss, err := NewSortedSet(IndexConfig{Type: IndexTypeSkipList})
+	assert.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		ss.Add(float64(i), fmt.Sprintf("m%02d", i))
+	}
+
+	r, _ := ParseScoreRange("(10", "20")
+	assert.Equal(t, 10, ss.RemoveRangeByScore(r))
+	assert.Equal(t, 90, ss.Len())
+	assert.Equal(t, 11, ss.GetRank("m21"))
+
+	assert.Equal(t, 5, ss.RemoveRangeByRank(0, 4))
+	assert.Equal(t, "m05", ss.Index.GetByRank(0).Member)
+	assert.Equal(t, 85, ss.Len())
+
+	// The spans stay consistent after the deletions
+	for rank := 0; rank < ss.Len(); rank++ {
+		item := ss.Index.GetByRank(rank)
+		assert.Equal(t, rank, ss.GetRank(item.Member))
+	}
+
+	lex, _ := NewSortedSet(IndexConfig{Type: IndexTypeSkipList})
+	for _, m := range []string{"a", "b", "c", "d"} {
+		lex.Add(0, m)
+	}
+	lr, _ := ParseLexRange("(a", "+")
+	assert.Equal(t, 3, lex.RemoveRangeByLex(lr))
+	assert.Equal(t, 1, lex.Len())
+}
+
+func BenchmarkSkipListIndex_GetRank(b *testing.B) {
+	ss := newBenchmarkIndex(b, NewSkipListIndex(SkiplistMaxLevel))
+	for i := 0; i < b.N; i++ {
+		ss.GetRank(fmt.Sprintf("member:%d", i%benchmarkMembers))
+	}
+}
+
+func BenchmarkSkipListIndex_GetByRank(b *testing.B) {
+	ss := newBenchmarkIndex(b, NewSkipListIndex(SkiplistMaxLevel))
+	for i := 0; i < b.N; i++ {
+		ss.Index.GetByRank(i % benchmarkMembers)
+	}
+}
+
+func BenchmarkSkipListIndex_GetRangeByRank(b *testing.B) {
+	ss := newBenchmarkIndex(b, NewSkipListIndex(SkiplistMaxLevel))
+	for i := 0; i < b.N; i++ {
+		start := i % (benchmarkMembers - 10)
+		ss.Index.GetRangeByRank(start, start+9)
+	}
+}
@@ -1,8 +1,16 @@
 package sorted_set
 
+const EncodingListpack = "listpack"
+
+// SortedSet is a set of members ordered by score.
+// With a listpack limit in its configuration, a small sorted set is stored in a
+// ListpackIndex without MemberScore, and converted to the configured index once it has
+// more than MaxListpackEntries members or a member longer than MaxListpackValue bytes.
+// The conversion is one way, like in Redis.
 type SortedSet struct {
 	Index       OrderedIndex
 	MemberScore map[string]float64
+	config      IndexConfig
 }
 
 // NewSortedSet creates a new SortedSet with the specified index configuration
@@ -11,10 +19,14 @@ func NewSortedSet(config IndexConfig) (*SortedSet, error) {
 	if err != nil {
 		return nil, err
 	}
+	if config.MaxListpackEntries > 0 {
+		return &SortedSet{Index: NewListpackIndex(), config: config}, nil
+	}
 
 	return &SortedSet{
 		Index:       index,
 		MemberScore: make(map[string]float64),
+		config:      config,
 	}, nil
 }
 
@@ -26,12 +38,36 @@ func NewSortedSetWithBTree(degree int) (*SortedSet, error) {
 	})
 }
 
+// Encoding returns "listpack" for a small sorted set, else the type of its index
+func (ss *SortedSet) Encoding() string {
+	if ss.MemberScore == nil {
+		return EncodingListpack
+	}
+	return string(ss.config.Type)
+}
+
+// convertIndex moves the items of the listpack to the configured index
+func (ss *SortedSet) convertIndex() {
+	// The configuration was validated by NewSortedSet
+	index, _ := NewOrderedIndex(ss.config)
+	ss.MemberScore = make(map[string]float64, ss.Index.Count())
+	for it := ss.Index.IterateFromRank(0, false); it.Valid(); it.Next() {
+		item := it.Item()
+		index.Add(item.Score, item.Member)
+		ss.MemberScore[item.Member] = item.Score
+	}
+	ss.Index = index
+}
+
 func (ss *SortedSet) Add(score float64, member string) int {
 	if member == "" {
 		return 0
 	}
+	if ss.MemberScore == nil && len(member) > ss.config.MaxListpackValue {
+		ss.convertIndex()
+	}
 
-	oldScore, exists := ss.MemberScore[member]
+	oldScore, exists := ss.GetScore(member)
 	if exists {
 		if oldScore == score {
 			return 0 // No change needed
@@ -39,24 +75,60 @@ func (ss *SortedSet) Add(score float64, member string) int {
 		// Remove old entry using score (efficient)
 		ss.Index.RemoveByScore(oldScore, member)
 		ss.Index.Add(score, member)
-		ss.MemberScore[member] = score
+		if ss.MemberScore != nil {
+			ss.MemberScore[member] = score
+		}
 		return 0
 	}
 
 	result := ss.Index.Add(score, member)
-	if result == 1 {
+	if ss.MemberScore == nil {
+		if ss.Index.Count() > ss.config.MaxListpackEntries {
+			ss.convertIndex()
+		}
+	} else if result == 1 {
 		ss.MemberScore[member] = score
 	}
 	return result
 }
 
 func (ss *SortedSet) GetScore(member string) (float64, bool) {
+	if ss.MemberScore == nil {
+		return ss.Index.(*ListpackIndex).Score(member)
+	}
 	score, exists := ss.MemberScore[member]
 	return score, exists
 }
 
+// Scores returns the score of every member. The returned map must not be modified.
+func (ss *SortedSet) Scores() map[string]float64 {
+	if ss.MemberScore != nil {
+		return ss.MemberScore
+	}
+	scores := make(map[string]float64, ss.Index.Count())
+	for it := ss.Index.IterateFromRank(0, false); it.Valid(); it.Next() {
+		scores[it.Item().Member] = it.Item().Score
+	}
+	return scores
+}
+
+// GetRank returns the rank of a member, -1 if the member is not found
 func (ss *SortedSet) GetRank(member string) int {
-	return ss.Index.GetRank(member)
+	score, exists := ss.GetScore(member)
+	if !exists {
+		return -1
+	}
+	return ss.Index.GetRankByScore(score, member)
+}
+
+// GetRevRank returns the rank of a member with the scores ordered from high to low,
+// -1 if the member is not found
+func (ss *SortedSet) GetRevRank(member string) int {
+	rank := ss.GetRank(member)
+	if rank < 0 {
+		return -1
+	}
+	return ss.Len() - 1 - rank
 }
 
 func (ss *SortedSet) Remove(member string) int {
@@ -64,7 +136,7 @@ func (ss *SortedSet) Remove(member string) int {
 		return 0
 	}
 
-	score, exists := ss.MemberScore[member]
+	score, exists := ss.GetScore(member)
 	if !exists {
 		return 0
 	}
@@ -76,3 +148,156 @@ func (ss *SortedSet) Remove(member string) int {
 	}
 	return result
 }
+
+// Len returns the number of members in the sorted set
+func (ss *SortedSet) Len() int {
+	return ss.Index.Count()
+}
+
+// PopMin removes and returns up to count members with the lowest scores, in ascending order
+func (ss *SortedSet) PopMin(count int) []*Item {
+	var res []*Item
+	for i := 0; i < count && ss.Len() > 0; i++ {
+		item := ss.Index.PopMin()
+		if item == nil {
+			break
+		}
+		delete(ss.MemberScore, item.Member)
+		res = append(res, item)
+	}
+	return res
+}
+
+// PopMax removes and returns up to count members with the highest scores, in descending order
+func (ss *SortedSet) PopMax(count int) []*Item {
+	var res []*Item
+	for i := 0; i < count && ss.Len() > 0; i++ {
+		item := ss.Index.PopMax()
+		if item == nil {
+			break
+		}
+		delete(ss.MemberScore, item.Member)
+		res = append(res, item)
+	}
+	return res
+}
+
+// RangeByRank returns the members with start <= rank <= end. Ranks must already be
+// normalized to [0, Len()). With reverse, ranks are counted from the highest score.
+func (ss *SortedSet) RangeByRank(start, end int, reverse bool) []*Item {
+	if !reverse {
+		return ss.Index.GetRangeByRank(start, end)
+	}
+	n := ss.Len()
+	items := ss.Index.GetRangeByRank(n-1-end, n-1-start)
+	reverseItems(items)
+	return items
+}
+
+// RangeByScore returns the members with a score inside r, skipping offset of them and
+// returning at most count (count < 0 means no limit). With reverse, they are ordered
+// from the highest score. It costs O(log N + count) whatever the size of the range.
+func (ss *SortedSet) RangeByScore(r *ScoreRange, reverse bool, offset, count int) []*Item {
+	if r.IsEmpty() {
+		return nil
+	}
+	it := ss.Index.IterateFromScore(r.Min, r.MinEx, false)
+	if reverse {
+		it = ss.Index.IterateFromScore(r.Max, r.MaxEx, true)
+	}
+	return ss.limitRange(it, reverse, offset, count, func(item *Item) bool { return r.Contains(item.Score) })
+}
+
+// RangeByLex is like RangeByScore for a range of members with the same score
+func (ss *SortedSet) RangeByLex(r *LexRange, reverse bool, offset, count int) []*Item {
+	if r.IsEmpty() {
+		return nil
+	}
+	it := ss.Index.IterateFromLex(r.Min, false)
+	if reverse {
+		it = ss.Index.IterateFromLex(r.Max, true)
+	}
+	return ss.limitRange(it, reverse, offset, count, func(item *Item) bool { return r.Contains(item.Member) })
+}
+
+// Count returns the number of members with a score inside r, O(log N)
+func (ss *SortedSet) Count(r *ScoreRange) int {
+	if r.IsEmpty() {
+		return 0
+	}
+	first := ss.Index.IterateFromScore(r.Min, r.MinEx, false)
+	last := ss.Index.IterateFromScore(r.Max, r.MaxEx, true)
+	return countBetween(first, last)
+}
+
+// LexCount returns the number of members inside r, O(log N)
+func (ss *SortedSet) LexCount(r *LexRange) int {
+	if r.IsEmpty() {
+		return 0
+	}
+	first := ss.Index.IterateFromLex(r.Min, false)
+	last := ss.Index.IterateFromLex(r.Max, true)
+	return countBetween(first, last)
+}
+
+// countBetween returns the number of items from first to last included
+func countBetween(first, last Iterator) int {
+	if !first.Valid() || !last.Valid() || first.Rank() > last.Rank() {
+		return 0
+	}
+	return last.Rank() - first.Rank() + 1
+}
+
+// RemoveRangeByScore removes the members with a score inside r and returns how many
+func (ss *SortedSet) RemoveRangeByScore(r *ScoreRange) int {
+	return ss.forgetItems(ss.Index.DeleteRangeByScore(r))
+}
+
+// RemoveRangeByLex removes the members inside r and returns how many
+func (ss *SortedSet) RemoveRangeByLex(r *LexRange) int {
+	return ss.forgetItems(ss.Index.DeleteRangeByLex(r))
+}
+
+// RemoveRangeByRank removes the members with start <= rank <= end and returns how many.
+// Ranks must already be normalized to [0, Len()).
+func (ss *SortedSet) RemoveRangeByRank(start, end int) int {
+	return ss.forgetItems(ss.Index.DeleteRangeByRank(start, end))
+}
+
+// forgetItems drops the scores of items removed from the index
+func (ss *SortedSet) forgetItems(items []*Item) int {
+	for _, item := range items {
+		delete(ss.MemberScore, item.Member)
+	}
+	return len(items)
+}
+
+func reverseItems(items []*Item) {
+	for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+		items[i], items[j] = items[j], items[i]
+	}
+}
+
+// limitRange applies LIMIT offset count to the items from it while they are inside the range.
+// The offset is skipped by rank rather than by walking the items.
+func (ss *SortedSet) limitRange(it Iterator, reverse bool, offset, count int, inside func(*Item) bool) []*Item {
+	if !it.Valid() || offset < 0 {
+		return nil
+	}
+	if offset > 0 {
+		rank := it.Rank() + offset
+		if reverse {
+			rank = it.Rank() - offset
+		}
+		it = ss.Index.IterateFromRank(rank, reverse)
+	}
+	var res []*Item
+	for ; it.Valid() && (count < 0 || len(res) < count); it.Next() {
+		item := it.Item()
+		if !inside(item) {
+			break
+		}
+		res = append(res, item)
+	}
+	return res
+}
@@ -1,18 +1,93 @@
 package sorted_set
 
-// OrderedIndex defines the interface for ordered data structures
+// OrderedIndex defines the interface for ordered data structures.
+// Items are ordered by score, then by member.
 type OrderedIndex interface {
 	// Add adds an item with score and member
 	// Returns 1 if new item added, 0 if item updated
 	Add(score float64, member string) int
 
+	// Count returns the number of items
+	Count() int
+
+	// First returns the item with the lowest score, nil if the index is empty
+	First() *Item
+
+	// Last returns the item with the highest score, nil if the index is empty
+	Last() *Item
+
 	// GetRank returns the rank (0-based index) of a member
 	// Return -1 if member not found
 	GetRank(member string) int
 
+	// GetRankByScore returns the rank of a member with a known score in O(log N)
+	// Return -1 if member not found
+	GetRankByScore(score float64, member string) int
+
+	// GetByRank returns the item at the given rank (0-based index)
+	// Return nil if rank is out of range
+	GetByRank(rank int) *Item
+
+	// GetRange returns the items with min <= score <= max in ascending order
+	GetRange(min, max float64) []*Item
+
+	// GetRangeByRank returns the items with start <= rank <= end in ascending order
+	GetRangeByRank(start, end int) []*Item
+
+	// GetRangeByScore returns the items with a score inside r in ascending order
+	GetRangeByScore(r *ScoreRange) []*Item
+
+	// GetRangeByLex returns the items with a member inside r in ascending order.
+	// All the items are expected to have the same score.
+	GetRangeByLex(r *LexRange) []*Item
+
+	// IterateFromRank returns an iterator starting at the item at rank
+	IterateFromRank(rank int, reverse bool) Iterator
+
+	// IterateFromScore returns an iterator starting at the first item with a score above
+	// score, or the last one below it with reverse. score itself is skipped if exclusive.
+	IterateFromScore(score float64, exclusive bool, reverse bool) Iterator
+
+	// IterateFromLex is like IterateFromScore for a bound on the members.
+	// All the items are expected to have the same score.
+	IterateFromLex(bound LexBound, reverse bool) Iterator
+
 	// RemoveByScore removes an item by its score and member with O(log N) complexity.
 	// Returns 1 if member was removed, 0 if not found
 	RemoveByScore(score float64, member string) int
+
+	// PopMin removes and returns the item with the lowest score in O(log N),
+	// nil if the index is empty
+	PopMin() *Item
+
+	// PopMax removes and returns the item with the highest score in O(log N),
+	// nil if the index is empty
+	PopMax() *Item
+
+	// DeleteRangeByScore removes the items with a score inside r and returns them
+	DeleteRangeByScore(r *ScoreRange) []*Item
+
+	// DeleteRangeByLex removes the items with a member inside r and returns them
+	DeleteRangeByLex(r *LexRange) []*Item
+
+	// DeleteRangeByRank removes the items with start <= rank <= end and returns them
+	DeleteRangeByRank(start, end int) []*Item
+}
+
+// Iterator walks the items of an OrderedIndex in ascending order, or descending in reverse.
+// The index must not be modified while iterating.
+type Iterator interface {
+	// Valid reports whether the iterator is on an item
+	Valid() bool
+
+	// Item returns the current item
+	Item() *Item
+
+	// Rank returns the rank of the current item
+	Rank() int
+
+	// Next moves to the following item
+	Next()
 }
 
 // IndexType represents the type of index to create
@@ -28,4 +103,9 @@ type IndexConfig struct {
 	Type     IndexType
 	Degree   int // For B+ Tree
 	MaxLevel int // For Skip List
+
+	// Small sorted sets are stored in a listpack while they have at most
+	// MaxListpackEntries members of at most MaxListpackValue bytes. 0 disables the listpack.
+	MaxListpackEntries int
+	MaxListpackValue   int
 }
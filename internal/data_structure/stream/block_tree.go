@@ -0,0 +1,262 @@
+package stream
+
+// blockTreeDegree is the maximum number of children of an internal node and of blocks in a leaf
+const blockTreeDegree = 32
+
+// blockTreeNode is a node of the B+ tree indexing the entry blocks of a stream by master ID.
+// keys[i] is the smallest master ID under children[i] (internal node) or the master ID of
+// blocks[i] (leaf). Leaves are linked so ranges are scanned in both directions.
+type blockTreeNode struct {
+	isLeaf   bool
+	keys     []StreamID
+	children []*blockTreeNode
+	blocks   []*entryBlock
+	parent   *blockTreeNode
+	prev     *blockTreeNode
+	next     *blockTreeNode
+}
+
+// blockTree is a B+ tree of entry blocks. Entries are appended in ID order, so blocks are
+// almost always inserted at the right end. Deleting a block never rebalances the tree:
+// trimming removes blocks from the left and empty nodes are simply unlinked.
+type blockTree struct {
+	root   *blockTreeNode
+	blocks int
+}
+
+// blockPos is the position of a block: a leaf and an index in its blocks
+type blockPos struct {
+	leaf  *blockTreeNode
+	index int
+}
+
+func newBlockTree() *blockTree {
+	return &blockTree{root: &blockTreeNode{isLeaf: true}}
+}
+
+func (t *blockTree) len() int {
+	return t.blocks
+}
+
+// nodes returns the number of nodes of the tree
+func (t *blockTree) nodes() int {
+	count := 0
+	level := []*blockTreeNode{t.root}
+	for len(level) > 0 {
+		count += len(level)
+		var next []*blockTreeNode
+		for _, node := range level {
+			next = append(next, node.children...)
+		}
+		level = next
+	}
+	return count
+}
+
+// childIndex returns the index of the last key smaller or equal to id, 0 if there is none
+func childIndex(keys []StreamID, id StreamID) int {
+	lo, hi := 0, len(keys)
+	for lo < hi {
+		mid := (lo + hi) / 2
+		if id.Less(keys[mid]) {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+	if lo == 0 {
+		return 0
+	}
+	return lo - 1
+}
+
+// seek returns the position of the block that may contain id: the last block whose master ID
+// is smaller or equal to id, or the first block if id is smaller than every master ID.
+func (t *blockTree) seek(id StreamID) (blockPos, bool) {
+	node := t.root
+	for !node.isLeaf {
+		node = node.children[childIndex(node.keys, id)]
+	}
+	if len(node.blocks) == 0 {
+		return blockPos{}, false
+	}
+	return blockPos{leaf: node, index: childIndex(node.keys, id)}, true
+}
+
+func (t *blockTree) first() (blockPos, bool) {
+	node := t.root
+	for !node.isLeaf {
+		node = node.children[0]
+	}
+	if len(node.blocks) == 0 {
+		return blockPos{}, false
+	}
+	return blockPos{leaf: node, index: 0}, true
+}
+
+func (t *blockTree) last() (blockPos, bool) {
+	node := t.root
+	for !node.isLeaf {
+		node = node.children[len(node.children)-1]
+	}
+	if len(node.blocks) == 0 {
+		return blockPos{}, false
+	}
+	return blockPos{leaf: node, index: len(node.blocks) - 1}, true
+}
+
+func (p blockPos) block() *entryBlock {
+	return p.leaf.blocks[p.index]
+}
+
+func (p blockPos) next() (blockPos, bool) {
+	if p.index+1 < len(p.leaf.blocks) {
+		return blockPos{leaf: p.leaf, index: p.index + 1}, true
+	}
+	if p.leaf.next == nil {
+		return blockPos{}, false
+	}
+	return blockPos{leaf: p.leaf.next, index: 0}, true
+}
+
+func (p blockPos) prev() (blockPos, bool) {
+	if p.index > 0 {
+		return blockPos{leaf: p.leaf, index: p.index - 1}, true
+	}
+	if p.leaf.prev == nil {
+		return blockPos{}, false
+	}
+	return blockPos{leaf: p.leaf.prev, index: len(p.leaf.prev.blocks) - 1}, true
+}
+
+// insert adds a block keyed by its master ID
+func (t *blockTree) insert(b *entryBlock) {
+	node := t.root
+	for !node.isLeaf {
+		node = node.children[childIndex(node.keys, b.masterID)]
+	}
+	i := 0
+	for i < len(node.keys) && !b.masterID.Less(node.keys[i]) {
+		i++
+	}
+	node.keys = append(node.keys[:i], append([]StreamID{b.masterID}, node.keys[i:]...)...)
+	node.blocks = append(node.blocks[:i], append([]*entryBlock{b}, node.blocks[i:]...)...)
+	t.blocks++
+	if i == 0 {
+		t.updateMinKey(node)
+	}
+	if len(node.keys) > blockTreeDegree {
+		t.split(node)
+	}
+}
+
+// updateMinKey propagates the smallest key of node to its ancestors
+func (t *blockTree) updateMinKey(node *blockTreeNode) {
+	for node.parent != nil && len(node.keys) > 0 {
+		parent := node.parent
+		i := indexOfChild(parent, node)
+		if parent.keys[i] == node.keys[0] {
+			return
+		}
+		parent.keys[i] = node.keys[0]
+		if i != 0 {
+			return
+		}
+		node = parent
+	}
+}
+
+func indexOfChild(parent, child *blockTreeNode) int {
+	for i, c := range parent.children {
+		if c == child {
+			return i
+		}
+	}
+	return -1
+}
+
+func (t *blockTree) split(node *blockTreeNode) {
+	mid := len(node.keys) / 2
+	sibling := &blockTreeNode{
+		isLeaf: node.isLeaf,
+		parent: node.parent,
+	}
+	sibling.keys = append(sibling.keys, node.keys[mid:]...)
+	node.keys = node.keys[:mid:mid]
+	if node.isLeaf {
+		sibling.blocks = append(sibling.blocks, node.blocks[mid:]...)
+		node.blocks = node.blocks[:mid:mid]
+		sibling.next = node.next
+		sibling.prev = node
+		if node.next != nil {
+			node.next.prev = sibling
+		}
+		node.next = sibling
+	} else {
+		sibling.children = append(sibling.children, node.children[mid:]...)
+		node.children = node.children[:mid:mid]
+		for _, child := range sibling.children {
+			child.parent = sibling
+		}
+	}
+
+	parent := node.parent
+	if parent == nil {
+		parent = &blockTreeNode{
+			keys:     []StreamID{node.keys[0]},
+			children: []*blockTreeNode{node},
+		}
+		node.parent = parent
+		sibling.parent = parent
+		t.root = parent
+	}
+	i := indexOfChild(parent, node) + 1
+	parent.keys = append(parent.keys[:i], append([]StreamID{sibling.keys[0]}, parent.keys[i:]...)...)
+	parent.children = append(parent.children[:i], append([]*blockTreeNode{sibling}, parent.children[i:]...)...)
+	if len(parent.keys) > blockTreeDegree {
+		t.split(parent)
+	}
+}
+
+// remove deletes the block at p
+func (t *blockTree) remove(p blockPos) {
+	node := p.leaf
+	node.keys = append(node.keys[:p.index], node.keys[p.index+1:]...)
+	node.blocks = append(node.blocks[:p.index], node.blocks[p.index+1:]...)
+	t.blocks--
+
+	if len(node.keys) > 0 {
+		if p.index == 0 {
+			t.updateMinKey(node)
+		}
+		return
+	}
+
+	// Unlink the empty leaf, then remove empty ancestors
+	if node.prev != nil {
+		node.prev.next = node.next
+	}
+	if node.next != nil {
+		node.next.prev = node.prev
+	}
+	for node.parent != nil && len(node.keys) == 0 {
+		parent := node.parent
+		i := indexOfChild(parent, node)
+		parent.keys = append(parent.keys[:i], parent.keys[i+1:]...)
+		parent.children = append(parent.children[:i], parent.children[i+1:]...)
+		if len(parent.keys) > 0 && i == 0 {
+			t.updateMinKey(parent)
+		}
+		node = parent
+	}
+
+	if len(t.root.keys) == 0 {
+		t.root = &blockTreeNode{isLeaf: true}
+		return
+	}
+	// Shrink the tree while the root has a single child
+	for !t.root.isLeaf && len(t.root.children) == 1 {
+		t.root = t.root.children[0]
+		t.root.parent = nil
+	}
+}
@@ -0,0 +1,441 @@
+package stream
+
+import (
+	"errors"
+	"sort"
+)
+
+var ErrGroupExists = errors.New("Consumer Group name already exists")
+
+// EntriesReadUnknown is the entries read counter of a group when it can not be computed,
+// because entries were deleted between the first entry of the stream and the group last ID
+const EntriesReadUnknown int64 = -1
+
+// PendingEntry is an entry delivered to a consumer and not acknowledged yet
+type PendingEntry struct {
+	ID            StreamID
+	Consumer      *Consumer
+	DeliveryTime  int64 // unix ms of the last delivery
+	DeliveryCount uint64
+}
+
+// Consumer is a member of a consumer group with its own pending entries list
+type Consumer struct {
+	Name       string
+	SeenTime   int64 // unix ms of the last interaction
+	ActiveTime int64 // unix ms of the last read or claim that returned entries, -1 if none
+	pel        *pendingList
+}
+
+func (c *Consumer) PendingLen() int {
+	return c.pel.len()
+}
+
+// ConsumerGroup tracks the entries delivered to its consumers: LastID is the last entry
+// delivered, and every delivered entry stays in the group PEL (and in the PEL of the consumer
+// owning it) until it is acknowledged.
+type ConsumerGroup struct {
+	Name        string
+	LastID      StreamID
+	EntriesRead int64
+	pel         *pendingList
+	consumers   map[string]*Consumer
+}
+
+// pendingList is a pending entries list ordered by ID
+type pendingList struct {
+	ids     []StreamID
+	entries map[StreamID]*PendingEntry
+}
+
+func newPendingList() *pendingList {
+	return &pendingList{entries: make(map[StreamID]*PendingEntry)}
+}
+
+func (l *pendingList) len() int {
+	return len(l.ids)
+}
+
+func (l *pendingList) get(id StreamID) (*PendingEntry, bool) {
+	e, exist := l.entries[id]
+	return e, exist
+}
+
+// search returns the index of the first ID greater or equal to id
+func (l *pendingList) search(id StreamID) int {
+	return sort.Search(len(l.ids), func(i int) bool {
+		return !l.ids[i].Less(id)
+	})
+}
+
+func (l *pendingList) add(e *PendingEntry) {
+	if _, exist := l.entries[e.ID]; exist {
+		l.entries[e.ID] = e
+		return
+	}
+	l.entries[e.ID] = e
+	// Entries are mostly delivered in ID order
+	if len(l.ids) == 0 || l.ids[len(l.ids)-1].Less(e.ID) {
+		l.ids = append(l.ids, e.ID)
+		return
+	}
+	i := l.search(e.ID)
+	l.ids = append(l.ids, StreamID{})
+	copy(l.ids[i+1:], l.ids[i:])
+	l.ids[i] = e.ID
+}
+
+func (l *pendingList) remove(id StreamID) bool {
+	if _, exist := l.entries[id]; !exist {
+		return false
+	}
+	delete(l.entries, id)
+	i := l.search(id)
+	l.ids = append(l.ids[:i], l.ids[i+1:]...)
+	return true
+}
+
+// rangeFrom returns the entries with start <= ID <= end, at most count of them (count <= 0 means all)
+func (l *pendingList) rangeFrom(start, end StreamID, count int) []*PendingEntry {
+	res := make([]*PendingEntry, 0)
+	for i := l.search(start); i < len(l.ids); i++ {
+		if end.Less(l.ids[i]) || (count > 0 && len(res) >= count) {
+			break
+		}
+		res = append(res, l.entries[l.ids[i]])
+	}
+	return res
+}
+
+func newConsumerGroup(name string, lastID StreamID, entriesRead int64) *ConsumerGroup {
+	return &ConsumerGroup{
+		Name:        name,
+		LastID:      lastID,
+		EntriesRead: entriesRead,
+		pel:         newPendingList(),
+		consumers:   make(map[string]*Consumer),
+	}
+}
+
+func (g *ConsumerGroup) Consumer(name string) (*Consumer, bool) {
+	c, exist := g.consumers[name]
+	return c, exist
+}
+
+// Consumers returns the consumers sorted by name
+func (g *ConsumerGroup) Consumers() []*Consumer {
+	res := make([]*Consumer, 0, len(g.consumers))
+	for _, c := range g.consumers {
+		res = append(res, c)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+	return res
+}
+
+// CreateConsumer returns the consumer called name, creating it if needed. created tells
+// whether it was created.
+func (g *ConsumerGroup) CreateConsumer(name string, nowMs int64) (c *Consumer, created bool) {
+	if c, exist := g.consumers[name]; exist {
+		return c, false
+	}
+	c = &Consumer{
+		Name:       name,
+		SeenTime:   nowMs,
+		ActiveTime: -1,
+		pel:        newPendingList(),
+	}
+	g.consumers[name] = c
+	return c, true
+}
+
+// DeleteConsumer deletes a consumer and its pending entries. Returns the number of pending
+// entries it had, and false if there is no such consumer.
+func (g *ConsumerGroup) DeleteConsumer(name string) (int, bool) {
+	c, exist := g.consumers[name]
+	if !exist {
+		return 0, false
+	}
+	pending := c.pel.len()
+	for _, id := range c.pel.ids {
+		g.pel.remove(id)
+	}
+	delete(g.consumers, name)
+	return pending, true
+}
+
+func (g *ConsumerGroup) PendingLen() int {
+	return g.pel.len()
+}
+
+// PendingRange returns the pending entries with start <= ID <= end idle for at least minIdle ms,
+// at most count of them (count <= 0 means all). If consumer is not nil only its entries are returned.
+func (g *ConsumerGroup) PendingRange(start, end StreamID, count int, consumer *Consumer, minIdle int64, nowMs int64) []*PendingEntry {
+	pel := g.pel
+	if consumer != nil {
+		pel = consumer.pel
+	}
+	if minIdle <= 0 {
+		return pel.rangeFrom(start, end, count)
+	}
+	res := make([]*PendingEntry, 0)
+	for _, e := range pel.rangeFrom(start, end, 0) {
+		if count > 0 && len(res) >= count {
+			break
+		}
+		if nowMs-e.DeliveryTime >= minIdle {
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+// Ack removes id from the pending entries. Returns false if it was not pending.
+func (g *ConsumerGroup) Ack(id StreamID) bool {
+	e, exist := g.pel.get(id)
+	if !exist {
+		return false
+	}
+	g.pel.remove(id)
+	e.Consumer.pel.remove(id)
+	return true
+}
+
+// deliver adds id to the pending entries of consumer, moving it from its previous owner
+func (g *ConsumerGroup) deliver(id StreamID, consumer *Consumer, nowMs int64) *PendingEntry {
+	e, exist := g.pel.get(id)
+	if !exist {
+		e = &PendingEntry{ID: id}
+		g.pel.add(e)
+	} else if e.Consumer != consumer {
+		e.Consumer.pel.remove(id)
+	}
+	e.Consumer = consumer
+	e.DeliveryTime = nowMs
+	consumer.pel.add(e)
+	return e
+}
+
+// ClaimOptions are the options of XCLAIM
+type ClaimOptions struct {
+	DeliveryTime int64 // unix ms to set as the last delivery time
+	RetryCount   int64 // delivery count to set, negative to increment it
+	Force        bool  // create the pending entry if the entry exists but is not pending
+	JustID       bool  // do not increment the delivery count
+}
+
+func (s *Stream) Group(name string) (*ConsumerGroup, bool) {
+	g, exist := s.groups[name]
+	return g, exist
+}
+
+// Groups returns the consumer groups sorted by name
+func (s *Stream) Groups() []*ConsumerGroup {
+	res := make([]*ConsumerGroup, 0, len(s.groups))
+	for _, g := range s.groups {
+		res = append(res, g)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+	return res
+}
+
+// CreateGroup creates a consumer group that will deliver the entries after lastID.
+// A negative entriesRead is estimated from the stream.
+func (s *Stream) CreateGroup(name string, lastID StreamID, entriesRead int64) (*ConsumerGroup, error) {
+	if _, exist := s.groups[name]; exist {
+		return nil, ErrGroupExists
+	}
+	if entriesRead < 0 {
+		entriesRead = s.estimateEntriesRead(lastID)
+	}
+	if s.groups == nil {
+		s.groups = make(map[string]*ConsumerGroup)
+	}
+	g := newConsumerGroup(name, lastID, entriesRead)
+	s.groups[name] = g
+	return g, nil
+}
+
+// SetGroupID sets the last delivered ID of a group. A negative entriesRead is estimated from the stream.
+func (s *Stream) SetGroupID(g *ConsumerGroup, lastID StreamID, entriesRead int64) {
+	if entriesRead < 0 {
+		entriesRead = s.estimateEntriesRead(lastID)
+	}
+	g.LastID = lastID
+	g.EntriesRead = entriesRead
+}
+
+func (s *Stream) DestroyGroup(name string) bool {
+	if _, exist := s.groups[name]; !exist {
+		return false
+	}
+	delete(s.groups, name)
+	return true
+}
+
+// Get returns the entry with id
+func (s *Stream) Get(id StreamID) (Entry, bool) {
+	entries := s.Range(id, id, 1, false)
+	if len(entries) == 0 {
+		return Entry{}, false
+	}
+	return entries[0], true
+}
+
+// hasTombstonesAfter tells whether entries with an ID greater or equal to start were deleted
+func (s *Stream) hasTombstonesAfter(start StreamID) bool {
+	if s.length == 0 || s.maxDeletedID == MinID {
+		return false
+	}
+	return !s.maxDeletedID.Less(start)
+}
+
+// estimateEntriesRead returns the number of entries added up to id, the counter of a group
+// whose last delivered ID is id. It is only known if no entry was deleted in the middle of the stream.
+func (s *Stream) estimateEntriesRead(id StreamID) int64 {
+	added := int64(s.entriesAdded)
+	if added == 0 {
+		return 0
+	}
+	if s.length == 0 && !s.lastID.Less(id) {
+		return added
+	}
+	switch id.Compare(s.lastID) {
+	case 0:
+		return added
+	case 1:
+		return EntriesReadUnknown
+	}
+	first, _ := s.First()
+	if s.maxDeletedID == MinID || s.maxDeletedID.Less(first.ID) {
+		// No fragmentation between the first entry and the end of the stream
+		switch id.Compare(first.ID) {
+		case -1:
+			return added - int64(s.length)
+		case 0:
+			return added - int64(s.length) + 1
+		}
+	}
+	return EntriesReadUnknown
+}
+
+// Lag returns the number of entries not delivered to the group yet. ok is false when it
+// can not be computed because of deleted entries.
+func (s *Stream) Lag(g *ConsumerGroup) (lag int64, ok bool) {
+	if s.entriesAdded == 0 || !g.LastID.Less(s.lastID) {
+		return 0, true
+	}
+	if s.hasTombstonesAfter(g.LastID) || g.EntriesRead == EntriesReadUnknown {
+		return 0, false
+	}
+	return int64(s.entriesAdded) - g.EntriesRead, true
+}
+
+// ReadGroup delivers to consumer the entries never delivered to the group, at most count of
+// them (count <= 0 means no limit). Unless noAck, they are added to the pending entries.
+func (s *Stream) ReadGroup(g *ConsumerGroup, consumer *Consumer, count int, noAck bool, nowMs int64) []Entry {
+	consumer.SeenTime = nowMs
+	start, ok := g.LastID.Incr()
+	if !ok {
+		return nil
+	}
+	entries := s.Range(start, MaxID, count, false)
+	for _, e := range entries {
+		if g.EntriesRead != EntriesReadUnknown && !s.hasTombstonesAfter(g.LastID) {
+			g.EntriesRead++
+		} else {
+			g.EntriesRead = s.estimateEntriesRead(e.ID)
+		}
+		g.LastID = e.ID
+		if !noAck {
+			pe := g.deliver(e.ID, consumer, nowMs)
+			pe.DeliveryCount = 1
+		}
+	}
+	if len(entries) > 0 {
+		consumer.ActiveTime = nowMs
+	}
+	return entries
+}
+
+// ReadGroupHistory delivers again the pending entries of consumer with an ID greater than
+// after, at most count of them. Entries deleted from the stream are returned with nil Fields.
+func (s *Stream) ReadGroupHistory(g *ConsumerGroup, consumer *Consumer, after StreamID, count int, nowMs int64) []Entry {
+	consumer.SeenTime = nowMs
+	start, ok := after.Incr()
+	if !ok {
+		return nil
+	}
+	res := make([]Entry, 0)
+	for _, pe := range consumer.pel.rangeFrom(start, MaxID, count) {
+		e, exist := s.Get(pe.ID)
+		if !exist {
+			e = Entry{ID: pe.ID}
+		}
+		pe.DeliveryTime = nowMs
+		pe.DeliveryCount++
+		res = append(res, e)
+	}
+	return res
+}
+
+// Claim transfers the pending entry id to consumer if it has been idle for at least minIdle ms.
+// deleted is true when the entry no longer exists in the stream, it is then removed from the PEL.
+func (s *Stream) Claim(g *ConsumerGroup, consumer *Consumer, id StreamID, minIdle int64, nowMs int64, opts ClaimOptions) (entry Entry, claimed bool, deleted bool) {
+	pe, pending := g.pel.get(id)
+	e, exist := s.Get(id)
+	if !pending {
+		if !opts.Force || !exist {
+			return Entry{}, false, false
+		}
+		pe = g.deliver(id, consumer, nowMs)
+	} else {
+		if minIdle > 0 && nowMs-pe.DeliveryTime < minIdle {
+			return Entry{}, false, false
+		}
+		if !exist {
+			g.Ack(id)
+			return Entry{}, false, true
+		}
+		g.deliver(id, consumer, nowMs)
+	}
+
+	pe.DeliveryTime = opts.DeliveryTime
+	if opts.RetryCount >= 0 {
+		pe.DeliveryCount = uint64(opts.RetryCount)
+	} else if !opts.JustID {
+		pe.DeliveryCount++
+	}
+	consumer.ActiveTime = nowMs
+	return e, true, false
+}
+
+// AutoClaim claims the pending entries idle for at least minIdle ms, scanning the PEL from start.
+// It claims at most count entries and looks at most at 10 * count entries. next is the ID to
+// start from in the next call, 0-0 when the whole PEL was scanned. The pending entries of
+// deleted entries are removed from the PEL and returned in deleted.
+func (s *Stream) AutoClaim(g *ConsumerGroup, consumer *Consumer, minIdle int64, start StreamID, count int, justID bool, nowMs int64) (next StreamID, claimed []Entry, deleted []StreamID) {
+	consumer.SeenTime = nowMs
+	claimed = make([]Entry, 0)
+	deleted = make([]StreamID, 0)
+	attempts := 10 * count
+
+	candidates := g.pel.rangeFrom(start, MaxID, attempts+1)
+	for i, pe := range candidates {
+		if i == attempts || len(claimed) == count {
+			return pe.ID, claimed, deleted
+		}
+		opts := ClaimOptions{DeliveryTime: nowMs, RetryCount: -1, JustID: justID}
+		e, ok, gone := s.Claim(g, consumer, pe.ID, minIdle, nowMs, opts)
+		switch {
+		case gone:
+			deleted = append(deleted, pe.ID)
+		case ok:
+			claimed = append(claimed, e)
+		}
+	}
+	return MinID, claimed, deleted
+}
@@ -0,0 +1,153 @@
+package stream
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func pendingIDs(entries []*PendingEntry) []uint64 {
+	res := make([]uint64, 0, len(entries))
+	for _, e := range entries {
+		res = append(res, e.ID.Ms)
+	}
+	return res
+}
+
+func TestConsumerGroup_ReadAck(t *testing.T) {
+	s := newTestStream(t, 10, 4)
+	g, err := s.CreateGroup("g", MinID, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), g.EntriesRead)
+	_, err = s.CreateGroup("g", MinID, -1)
+	assert.ErrorIs(t, err, ErrGroupExists)
+
+	alice, created := g.CreateConsumer("alice", 1000)
+	assert.True(t, created)
+	bob, _ := g.CreateConsumer("bob", 1000)
+
+	assert.Equal(t, []uint64{1, 2, 3}, ids(s.ReadGroup(g, alice, 3, false, 1000)))
+	assert.Equal(t, []uint64{4, 5}, ids(s.ReadGroup(g, bob, 2, false, 1000)))
+	assert.Equal(t, StreamID{Ms: 5}, g.LastID)
+	assert.Equal(t, int64(5), g.EntriesRead)
+	lag, ok := s.Lag(g)
+	assert.True(t, ok)
+	assert.Equal(t, int64(5), lag)
+
+	assert.Equal(t, 5, g.PendingLen())
+	assert.Equal(t, 3, alice.PendingLen())
+	assert.Equal(t, int64(1000), alice.ActiveTime)
+
+	assert.True(t, g.Ack(StreamID{Ms: 2}))
+	assert.False(t, g.Ack(StreamID{Ms: 2}))
+	assert.Equal(t, []uint64{1, 3}, pendingIDs(g.PendingRange(MinID, MaxID, 0, alice, 0, 1000)))
+	assert.Equal(t, []uint64{1, 3, 4, 5}, pendingIDs(g.PendingRange(MinID, MaxID, 0, nil, 0, 1000)))
+	assert.Equal(t, []uint64{3, 4}, pendingIDs(g.PendingRange(StreamID{Ms: 2}, MaxID, 2, nil, 0, 1000)))
+
+	// Reading the history delivers the entry again
+	history := s.ReadGroupHistory(g, alice, MinID, 0, 2000)
+	assert.Equal(t, []uint64{1, 3}, ids(history))
+	pe, _ := g.pel.get(StreamID{Ms: 1})
+	assert.Equal(t, uint64(2), pe.DeliveryCount)
+	assert.Equal(t, int64(2000), pe.DeliveryTime)
+
+	// Deleted entries are returned without fields
+	s.Delete(StreamID{Ms: 3})
+	history = s.ReadGroupHistory(g, alice, StreamID{Ms: 1}, 0, 2000)
+	assert.Equal(t, []Entry{{ID: StreamID{Ms: 3}}}, history)
+
+	// No acknowledgement needed with noAck
+	assert.Len(t, s.ReadGroup(g, bob, 0, true, 3000), 5)
+	assert.Equal(t, 2, bob.PendingLen())
+	assert.Empty(t, s.ReadGroup(g, bob, 0, false, 3000))
+
+	pending, ok := g.DeleteConsumer("bob")
+	assert.True(t, ok)
+	assert.Equal(t, 2, pending)
+	assert.Equal(t, 2, g.PendingLen())
+	assert.Len(t, g.Consumers(), 1)
+}
+
+func TestConsumerGroup_Claim(t *testing.T) {
+	s := newTestStream(t, 10, 4)
+	g, _ := s.CreateGroup("g", MinID, -1)
+	alice, _ := g.CreateConsumer("alice", 0)
+	bob, _ := g.CreateConsumer("bob", 0)
+	s.ReadGroup(g, alice, 4, false, 1000)
+
+	opts := ClaimOptions{DeliveryTime: 1500, RetryCount: -1}
+	// Not idle for long enough
+	_, claimed, _ := s.Claim(g, bob, StreamID{Ms: 1}, 1000, 1500, opts)
+	assert.False(t, claimed)
+
+	e, claimed, _ := s.Claim(g, bob, StreamID{Ms: 1}, 100, 1500, opts)
+	assert.True(t, claimed)
+	assert.Equal(t, uint64(1), e.ID.Ms)
+	assert.Equal(t, 1, bob.PendingLen())
+	assert.Equal(t, 3, alice.PendingLen())
+	pe, _ := g.pel.get(StreamID{Ms: 1})
+	assert.Equal(t, bob, pe.Consumer)
+	assert.Equal(t, uint64(2), pe.DeliveryCount)
+
+	// Not pending: only claimed with Force
+	_, claimed, _ = s.Claim(g, bob, StreamID{Ms: 8}, 0, 1500, opts)
+	assert.False(t, claimed)
+	opts.Force = true
+	_, claimed, _ = s.Claim(g, bob, StreamID{Ms: 8}, 0, 1500, opts)
+	assert.True(t, claimed)
+
+	// Claiming a deleted entry drops it from the PEL
+	s.Delete(StreamID{Ms: 2})
+	_, claimed, deleted := s.Claim(g, bob, StreamID{Ms: 2}, 0, 1500, opts)
+	assert.False(t, claimed)
+	assert.True(t, deleted)
+	assert.Equal(t, []uint64{1, 3, 4, 8}, pendingIDs(g.PendingRange(MinID, MaxID, 0, nil, 0, 1500)))
+}
+
+func TestConsumerGroup_AutoClaim(t *testing.T) {
+	s := newTestStream(t, 10, 4)
+	g, _ := s.CreateGroup("g", MinID, -1)
+	alice, _ := g.CreateConsumer("alice", 0)
+	bob, _ := g.CreateConsumer("bob", 0)
+	s.ReadGroup(g, alice, 0, false, 1000)
+	s.Delete(StreamID{Ms: 2})
+
+	next, claimed, deleted := s.AutoClaim(g, bob, 500, MinID, 3, false, 2000)
+	assert.Equal(t, []uint64{1, 3, 4}, ids(claimed))
+	assert.Equal(t, []StreamID{{Ms: 2}}, deleted)
+	assert.Equal(t, StreamID{Ms: 5}, next)
+
+	next, claimed, _ = s.AutoClaim(g, bob, 500, next, 100, true, 2000)
+	assert.Len(t, claimed, 6)
+	assert.Equal(t, MinID, next)
+	assert.Equal(t, 9, bob.PendingLen())
+	assert.Equal(t, 0, alice.PendingLen())
+}
+
+func TestStream_GroupEntriesRead(t *testing.T) {
+	s := newTestStream(t, 10, 4)
+	g, _ := s.CreateGroup("g", s.LastID(), -1)
+	assert.Equal(t, int64(10), g.EntriesRead)
+	lag, ok := s.Lag(g)
+	assert.True(t, ok)
+	assert.Equal(t, int64(0), lag)
+
+	// Only known from the first entry of the stream
+	s.SetGroupID(g, StreamID{Ms: 5}, -1)
+	assert.Equal(t, EntriesReadUnknown, g.EntriesRead)
+	s.SetGroupID(g, MinID, -1)
+	assert.Equal(t, int64(0), g.EntriesRead)
+
+	// A deletion after the last delivered ID makes the lag unknown
+	s.SetGroupID(g, StreamID{Ms: 5}, 5)
+	lag, ok = s.Lag(g)
+	assert.True(t, ok)
+	assert.Equal(t, int64(5), lag)
+	s.Delete(StreamID{Ms: 7})
+	_, ok = s.Lag(g)
+	assert.False(t, ok)
+
+	assert.True(t, s.DestroyGroup("g"))
+	assert.False(t, s.DestroyGroup("g"))
+	assert.Empty(t, s.Groups())
+}
@@ -0,0 +1,138 @@
+package stream
+
+import (
+	"strconv"
+
+	"github.com/spaghetti-lover/multithread-redis/internal/data_structure/listpack"
+)
+
+const (
+	entryFlagLive    = "0"
+	entryFlagDeleted = "1"
+)
+
+// Entry is a stream entry: an ID and its field/value pairs interleaved
+type Entry struct {
+	ID     StreamID
+	Fields []string
+}
+
+// entryBlock stores consecutive entries of a stream in a listpack, like a Redis stream node.
+// Each entry is encoded as: flag, ms delta from masterID, seq, number of fields, fields and values.
+// Deleted entries are only flagged and skipped, the block is dropped once all of them are deleted.
+type entryBlock struct {
+	masterID StreamID
+	lastID   StreamID
+	lp       *listpack.Listpack
+	count    int // live entries
+	total    int // live and deleted entries
+}
+
+func newEntryBlock(masterID StreamID) *entryBlock {
+	return &entryBlock{
+		masterID: masterID,
+		lp:       listpack.New(),
+	}
+}
+
+func (b *entryBlock) append(id StreamID, fields []string) {
+	b.lp.Append(entryFlagLive,
+		strconv.FormatUint(id.Ms-b.masterID.Ms, 10),
+		strconv.FormatUint(id.Seq, 10),
+		strconv.Itoa(len(fields)/2))
+	b.lp.Append(fields...)
+	b.lastID = id
+	b.count++
+	b.total++
+}
+
+// blockCursor decodes the entries of a block one by one
+type blockCursor struct {
+	block *entryBlock
+	pos   int
+}
+
+// next decodes the entry at the cursor. flagPos is the offset of its flag, to delete it.
+func (c *blockCursor) next() (entry Entry, flagPos int, deleted bool, ok bool) {
+	lp := c.block.lp
+	if c.pos >= lp.End() {
+		return Entry{}, 0, false, false
+	}
+	flagPos = c.pos
+	flag, pos := lp.Get(c.pos)
+	msDelta, pos := lp.Get(pos)
+	seq, pos := lp.Get(pos)
+	numFields, pos := lp.Get(pos)
+
+	ms, _ := strconv.ParseUint(msDelta, 10, 64)
+	entry.ID.Ms = c.block.masterID.Ms + ms
+	entry.ID.Seq, _ = strconv.ParseUint(seq, 10, 64)
+	n, _ := strconv.Atoi(numFields)
+
+	deleted = flag == entryFlagDeleted
+	if !deleted {
+		entry.Fields = make([]string, 0, 2*n)
+	}
+	for i := 0; i < 2*n; i++ {
+		var v string
+		v, pos = lp.Get(pos)
+		if !deleted {
+			entry.Fields = append(entry.Fields, v)
+		}
+	}
+	c.pos = pos
+	return entry, flagPos, deleted, true
+}
+
+// entries returns the live entries of the block in ascending order
+func (b *entryBlock) entries() []Entry {
+	res := make([]Entry, 0, b.count)
+	c := &blockCursor{block: b}
+	for {
+		e, _, deleted, ok := c.next()
+		if !ok {
+			return res
+		}
+		if !deleted {
+			res = append(res, e)
+		}
+	}
+}
+
+// delete flags the entry with id as deleted. Returns false if it is not a live entry of the block.
+func (b *entryBlock) delete(id StreamID) bool {
+	c := &blockCursor{block: b}
+	for {
+		e, flagPos, deleted, ok := c.next()
+		if !ok || id.Less(e.ID) {
+			return false
+		}
+		if e.ID == id {
+			if deleted {
+				return false
+			}
+			b.lp.Replace(flagPos, entryFlagDeleted)
+			b.count--
+			return true
+		}
+	}
+}
+
+// trimBefore deletes the live entries with an ID smaller than id, at most limit of them
+// (limit < 0 means no limit). Returns the number of deleted entries.
+func (b *entryBlock) trimBefore(id StreamID, limit int) int {
+	removed := 0
+	c := &blockCursor{block: b}
+	for limit < 0 || removed < limit {
+		e, flagPos, deleted, ok := c.next()
+		if !ok || !e.ID.Less(id) {
+			break
+		}
+		if !deleted {
+			b.lp.Replace(flagPos, entryFlagDeleted)
+			b.count--
+			removed++
+		}
+	}
+	return removed
+}
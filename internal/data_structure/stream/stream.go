@@ -0,0 +1,294 @@
+package stream
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrIDTooSmall = errors.New("The ID specified in XADD is equal or smaller than the target stream top item")
+	ErrIDZero     = errors.New("The ID specified in XADD must be greater than 0-0")
+	ErrExhausted  = errors.New("The stream has exhausted the last possible ID, unable to add more items")
+)
+
+// Stream is an append-only log of entries ordered by ID.
+// Entries are packed in listpack blocks of at most maxNodeEntries entries and maxNodeBytes bytes,
+// the blocks being indexed by their first (master) ID in a B+ tree, like the radix tree of Redis.
+type Stream struct {
+	tree           *blockTree
+	length         int
+	lastID         StreamID
+	maxDeletedID   StreamID
+	entriesAdded   uint64
+	maxNodeEntries int
+	maxNodeBytes   int
+	groups         map[string]*ConsumerGroup
+}
+
+func NewStream(maxNodeEntries int, maxNodeBytes int) *Stream {
+	return &Stream{
+		tree:           newBlockTree(),
+		maxNodeEntries: maxNodeEntries,
+		maxNodeBytes:   maxNodeBytes,
+	}
+}
+
+func (s *Stream) Len() int {
+	return s.length
+}
+
+// LastID returns the ID of the last entry ever added, even if it was deleted since
+func (s *Stream) LastID() StreamID {
+	return s.lastID
+}
+
+// MaxDeletedID returns the greatest ID deleted with Delete
+func (s *Stream) MaxDeletedID() StreamID {
+	return s.maxDeletedID
+}
+
+// EntriesAdded returns the number of entries added over the lifetime of the stream
+func (s *Stream) EntriesAdded() uint64 {
+	return s.entriesAdded
+}
+
+// Blocks returns the number of entry blocks
+func (s *Stream) Blocks() int {
+	return s.tree.len()
+}
+
+// TreeNodes returns the number of nodes of the tree indexing the blocks
+func (s *Stream) TreeNodes() int {
+	return s.tree.nodes()
+}
+
+// ResolveID returns the ID of a new entry from the XADD argument: "*" generates it from nowMs,
+// "ms-*" generates the sequence number only, otherwise the ID is explicit.
+func (s *Stream) ResolveID(arg string, nowMs uint64) (StreamID, error) {
+	if arg == "*" {
+		if nowMs > s.lastID.Ms {
+			return StreamID{Ms: nowMs, Seq: 0}, nil
+		}
+		id, ok := s.lastID.Incr()
+		if !ok {
+			return StreamID{}, ErrExhausted
+		}
+		return id, nil
+	}
+
+	if msPart, found := strings.CutSuffix(arg, "-*"); found {
+		ms, err := strconv.ParseUint(msPart, 10, 64)
+		if err != nil {
+			return StreamID{}, ErrInvalidID
+		}
+		switch {
+		case ms < s.lastID.Ms:
+			return StreamID{}, ErrIDTooSmall
+		case ms == s.lastID.Ms && s.entriesAdded > 0:
+			id, ok := s.lastID.Incr()
+			if !ok || id.Ms != ms {
+				return StreamID{}, ErrIDTooSmall
+			}
+			return id, nil
+		case ms == 0:
+			return StreamID{Ms: 0, Seq: 1}, nil
+		}
+		return StreamID{Ms: ms, Seq: 0}, nil
+	}
+
+	id, err := ParseID(arg, 0)
+	if err != nil {
+		return StreamID{}, err
+	}
+	if id == MinID {
+		return StreamID{}, ErrIDZero
+	}
+	if !s.lastID.Less(id) {
+		return StreamID{}, ErrIDTooSmall
+	}
+	return id, nil
+}
+
+// Add appends an entry. id must be greater than LastID, fields holds field/value pairs.
+func (s *Stream) Add(id StreamID, fields []string) error {
+	if !s.lastID.Less(id) {
+		return ErrIDTooSmall
+	}
+	var b *entryBlock
+	if pos, ok := s.tree.last(); ok {
+		b = pos.block()
+		if b.total >= s.maxNodeEntries || b.lp.Bytes() >= s.maxNodeBytes {
+			b = nil
+		}
+	}
+	if b == nil {
+		b = newEntryBlock(id)
+		s.tree.insert(b)
+	}
+	b.append(id, fields)
+	s.length++
+	s.entriesAdded++
+	s.lastID = id
+	return nil
+}
+
+// First returns the first entry of the stream
+func (s *Stream) First() (Entry, bool) {
+	entries := s.Range(MinID, MaxID, 1, false)
+	if len(entries) == 0 {
+		return Entry{}, false
+	}
+	return entries[0], true
+}
+
+// Last returns the last entry of the stream
+func (s *Stream) Last() (Entry, bool) {
+	entries := s.Range(MinID, MaxID, 1, true)
+	if len(entries) == 0 {
+		return Entry{}, false
+	}
+	return entries[0], true
+}
+
+// Range returns the entries with start <= ID <= end, at most count of them (count <= 0 means all).
+// In reverse the entries are returned from end to start.
+func (s *Stream) Range(start, end StreamID, count int, reverse bool) []Entry {
+	res := make([]Entry, 0)
+	if end.Less(start) {
+		return res
+	}
+	full := func() bool {
+		return count > 0 && len(res) >= count
+	}
+
+	if !reverse {
+		pos, ok := s.tree.seek(start)
+		for ; ok && !full(); pos, ok = pos.next() {
+			b := pos.block()
+			if end.Less(b.masterID) {
+				break
+			}
+			if b.lastID.Less(start) {
+				continue
+			}
+			for _, e := range b.entries() {
+				if e.ID.Less(start) {
+					continue
+				}
+				if end.Less(e.ID) || full() {
+					break
+				}
+				res = append(res, e)
+			}
+		}
+		return res
+	}
+
+	pos, ok := s.tree.seek(end)
+	for ; ok && !full(); pos, ok = pos.prev() {
+		b := pos.block()
+		if b.lastID.Less(start) {
+			break
+		}
+		if end.Less(b.masterID) {
+			continue
+		}
+		entries := b.entries()
+		for i := len(entries) - 1; i >= 0; i-- {
+			e := entries[i]
+			if end.Less(e.ID) {
+				continue
+			}
+			if e.ID.Less(start) || full() {
+				break
+			}
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+// Delete removes the entry with id. Returns false if there is no such entry.
+func (s *Stream) Delete(id StreamID) bool {
+	pos, ok := s.tree.seek(id)
+	if !ok {
+		return false
+	}
+	b := pos.block()
+	if !b.delete(id) {
+		return false
+	}
+	s.length--
+	if s.maxDeletedID.Less(id) {
+		s.maxDeletedID = id
+	}
+	if b.count == 0 {
+		s.tree.remove(pos)
+	}
+	return true
+}
+
+// TrimMaxLen removes the oldest entries until at most maxLen are left.
+// See trim for approx and limit. Returns the number of removed entries.
+func (s *Stream) TrimMaxLen(maxLen int, approx bool, limit int) int {
+	return s.trim(func(b *entryBlock) (bool, int) {
+		excess := s.length - maxLen
+		if excess <= 0 {
+			return false, 0
+		}
+		return excess >= b.count, excess
+	}, MaxID, approx, limit)
+}
+
+// TrimMinID removes the entries with an ID smaller than minID.
+// See trim for approx and limit. Returns the number of removed entries.
+func (s *Stream) TrimMinID(minID StreamID, approx bool, limit int) int {
+	return s.trim(func(b *entryBlock) (bool, int) {
+		if !b.masterID.Less(minID) {
+			return false, 0
+		}
+		return b.lastID.Less(minID), -1
+	}, minID, approx, limit)
+}
+
+// trim removes entries from the head of the stream. check tells whether a block can be removed
+// as a whole and, if not, how many of its entries before ID before can be removed (-1 for all).
+// With approx only whole blocks are removed, which is much cheaper, so the stream may keep a few
+// more entries than asked. limit caps the number of removed entries, a negative limit means none.
+func (s *Stream) trim(check func(b *entryBlock) (whole bool, n int), before StreamID, approx bool, limit int) int {
+	removed := 0
+	for {
+		pos, ok := s.tree.first()
+		if !ok {
+			return removed
+		}
+		b := pos.block()
+		whole, n := check(b)
+		if n == 0 && !whole {
+			return removed
+		}
+		if whole {
+			if limit >= 0 && removed+b.count > limit {
+				return removed
+			}
+			s.tree.remove(pos)
+			s.length -= b.count
+			removed += b.count
+			continue
+		}
+		if approx {
+			return removed
+		}
+		if limit >= 0 && (n < 0 || n > limit-removed) {
+			n = limit - removed
+		}
+		deleted := b.trimBefore(before, n)
+		s.length -= deleted
+		removed += deleted
+		if b.count == 0 {
+			s.tree.remove(pos)
+		}
+		return removed
+	}
+}
@@ -0,0 +1,121 @@
+package stream
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidID = errors.New("Invalid stream ID specified as stream command argument")
+
+// StreamID identifies an entry: milliseconds time and a sequence number for entries
+// added in the same millisecond
+type StreamID struct {
+	Ms  uint64
+	Seq uint64
+}
+
+var MinID = StreamID{Ms: 0, Seq: 0}
+var MaxID = StreamID{Ms: math.MaxUint64, Seq: math.MaxUint64}
+
+func (id StreamID) String() string {
+	return fmt.Sprintf("%d-%d", id.Ms, id.Seq)
+}
+
+func (id StreamID) Compare(other StreamID) int {
+	switch {
+	case id.Ms < other.Ms:
+		return -1
+	case id.Ms > other.Ms:
+		return 1
+	case id.Seq < other.Seq:
+		return -1
+	case id.Seq > other.Seq:
+		return 1
+	}
+	return 0
+}
+
+func (id StreamID) Less(other StreamID) bool {
+	return id.Compare(other) < 0
+}
+
+// Incr returns the smallest ID greater than id. ok is false if id is MaxID.
+func (id StreamID) Incr() (StreamID, bool) {
+	if id.Seq == math.MaxUint64 {
+		if id.Ms == math.MaxUint64 {
+			return id, false
+		}
+		return StreamID{Ms: id.Ms + 1, Seq: 0}, true
+	}
+	return StreamID{Ms: id.Ms, Seq: id.Seq + 1}, true
+}
+
+// Decr returns the greatest ID smaller than id. ok is false if id is MinID.
+func (id StreamID) Decr() (StreamID, bool) {
+	if id.Seq == 0 {
+		if id.Ms == 0 {
+			return id, false
+		}
+		return StreamID{Ms: id.Ms - 1, Seq: math.MaxUint64}, true
+	}
+	return StreamID{Ms: id.Ms, Seq: id.Seq - 1}, true
+}
+
+// ParseID parses "ms-seq" or "ms". When seq is missing it is set to missingSeq,
+// so a range start can use 0 and a range end math.MaxUint64.
+func ParseID(s string, missingSeq uint64) (StreamID, error) {
+	msPart, seqPart, hasSeq := strings.Cut(s, "-")
+	ms, err := strconv.ParseUint(msPart, 10, 64)
+	if err != nil {
+		return StreamID{}, ErrInvalidID
+	}
+	if !hasSeq {
+		return StreamID{Ms: ms, Seq: missingSeq}, nil
+	}
+	seq, err := strconv.ParseUint(seqPart, 10, 64)
+	if err != nil {
+		return StreamID{}, ErrInvalidID
+	}
+	return StreamID{Ms: ms, Seq: seq}, nil
+}
+
+// ParseRangeID parses a range boundary of XRANGE/XREVRANGE: "-", "+", an ID, an incomplete
+// ID ("ms") or an exclusive ID prefixed with "(". isStart tells which end of the range it is.
+func ParseRangeID(s string, isStart bool) (StreamID, error) {
+	switch s {
+	case "-":
+		return MinID, nil
+	case "+":
+		return MaxID, nil
+	}
+
+	var missingSeq uint64 = 0
+	if !isStart {
+		missingSeq = math.MaxUint64
+	}
+	exclusive := strings.HasPrefix(s, "(")
+	if exclusive {
+		s = s[1:]
+	}
+	id, err := ParseID(s, missingSeq)
+	if err != nil {
+		return StreamID{}, err
+	}
+	if !exclusive {
+		return id, nil
+	}
+
+	var ok bool
+	if isStart {
+		id, ok = id.Incr()
+	} else {
+		id, ok = id.Decr()
+	}
+	if !ok {
+		return StreamID{}, errors.New("invalid start or end ID for an exclusive range")
+	}
+	return id, nil
+}
@@ -0,0 +1,178 @@
+package stream
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestStream(t *testing.T, n int, blockEntries int) *Stream {
+	s := NewStream(blockEntries, 4096)
+	for i := 1; i <= n; i++ {
+		err := s.Add(StreamID{Ms: uint64(i), Seq: 0}, []string{"f", fmt.Sprint(i)})
+		assert.NoError(t, err)
+	}
+	return s
+}
+
+func ids(entries []Entry) []uint64 {
+	res := make([]uint64, 0, len(entries))
+	for _, e := range entries {
+		res = append(res, e.ID.Ms)
+	}
+	return res
+}
+
+func TestParseID(t *testing.T) {
+	id, err := ParseID("1526919030474-55", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, StreamID{Ms: 1526919030474, Seq: 55}, id)
+	assert.Equal(t, "1526919030474-55", id.String())
+
+	id, err = ParseID("12", math.MaxUint64)
+	assert.NoError(t, err)
+	assert.Equal(t, StreamID{Ms: 12, Seq: math.MaxUint64}, id)
+
+	_, err = ParseID("abc", 0)
+	assert.ErrorIs(t, err, ErrInvalidID)
+	_, err = ParseID("1-x", 0)
+	assert.ErrorIs(t, err, ErrInvalidID)
+
+	id, err = ParseRangeID("(5-3", true)
+	assert.NoError(t, err)
+	assert.Equal(t, StreamID{Ms: 5, Seq: 4}, id)
+	id, err = ParseRangeID("(5-0", false)
+	assert.NoError(t, err)
+	assert.Equal(t, StreamID{Ms: 4, Seq: math.MaxUint64}, id)
+	_, err = ParseRangeID("(0-0", false)
+	assert.Error(t, err)
+}
+
+func TestStream_ResolveID(t *testing.T) {
+	s := NewStream(100, 4096)
+	id, err := s.ResolveID("0-*", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, StreamID{Ms: 0, Seq: 1}, id)
+	_, err = s.ResolveID("0-0", 0)
+	assert.ErrorIs(t, err, ErrIDZero)
+
+	assert.NoError(t, s.Add(StreamID{Ms: 5, Seq: 1}, []string{"f", "v"}))
+	id, err = s.ResolveID("5-*", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, StreamID{Ms: 5, Seq: 2}, id)
+	_, err = s.ResolveID("4-*", 0)
+	assert.ErrorIs(t, err, ErrIDTooSmall)
+	_, err = s.ResolveID("5-1", 0)
+	assert.ErrorIs(t, err, ErrIDTooSmall)
+
+	// The clock went backwards: keep increasing the last ID
+	id, err = s.ResolveID("*", 3)
+	assert.NoError(t, err)
+	assert.Equal(t, StreamID{Ms: 5, Seq: 2}, id)
+	id, err = s.ResolveID("*", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, StreamID{Ms: 10, Seq: 0}, id)
+}
+
+func TestStream_AddRange(t *testing.T) {
+	// Small blocks so that the block tree has several levels
+	s := newTestStream(t, 5000, 3)
+	assert.Equal(t, 5000, s.Len())
+	assert.Equal(t, 1667, s.Blocks())
+	assert.Equal(t, StreamID{Ms: 5000}, s.LastID())
+	assert.ErrorIs(t, s.Add(StreamID{Ms: 5000}, []string{"f", "v"}), ErrIDTooSmall)
+
+	entries := s.Range(MinID, MaxID, 0, false)
+	assert.Len(t, entries, 5000)
+	for i, e := range entries {
+		assert.Equal(t, uint64(i+1), e.ID.Ms)
+		assert.Equal(t, []string{"f", fmt.Sprint(i + 1)}, e.Fields)
+	}
+
+	assert.Equal(t, []uint64{100, 101, 102}, ids(s.Range(StreamID{Ms: 100}, StreamID{Ms: 102}, 0, false)))
+	assert.Equal(t, []uint64{102, 101, 100}, ids(s.Range(StreamID{Ms: 100}, StreamID{Ms: 102}, 0, true)))
+	assert.Equal(t, []uint64{4000, 4001}, ids(s.Range(StreamID{Ms: 4000}, MaxID, 2, false)))
+	assert.Equal(t, []uint64{5000, 4999}, ids(s.Range(MinID, MaxID, 2, true)))
+	assert.Empty(t, s.Range(StreamID{Ms: 10}, StreamID{Ms: 9}, 0, false))
+	assert.Empty(t, s.Range(StreamID{Ms: 6000}, MaxID, 0, false))
+
+	first, ok := s.First()
+	assert.True(t, ok)
+	assert.Equal(t, uint64(1), first.ID.Ms)
+	last, ok := s.Last()
+	assert.True(t, ok)
+	assert.Equal(t, uint64(5000), last.ID.Ms)
+}
+
+func TestStream_Delete(t *testing.T) {
+	s := newTestStream(t, 1000, 4)
+
+	assert.True(t, s.Delete(StreamID{Ms: 10}))
+	assert.False(t, s.Delete(StreamID{Ms: 10}))
+	assert.False(t, s.Delete(StreamID{Ms: 2000}))
+	assert.Equal(t, 999, s.Len())
+	assert.Equal(t, []uint64{9, 11}, ids(s.Range(StreamID{Ms: 9}, StreamID{Ms: 11}, 0, false)))
+	assert.Equal(t, StreamID{Ms: 10}, s.MaxDeletedID())
+
+	// Deleting every entry of a block drops the block
+	blocks := s.Blocks()
+	for ms := uint64(1); ms <= 4; ms++ {
+		assert.True(t, s.Delete(StreamID{Ms: ms}))
+	}
+	assert.Equal(t, blocks-1, s.Blocks())
+	first, _ := s.First()
+	assert.Equal(t, uint64(5), first.ID.Ms)
+
+	for ms := uint64(5); ms <= 1000; ms++ {
+		s.Delete(StreamID{Ms: ms})
+	}
+	assert.Equal(t, 0, s.Len())
+	assert.Equal(t, 0, s.Blocks())
+	_, ok := s.First()
+	assert.False(t, ok)
+
+	// The last ID is kept, new entries must still be greater
+	assert.ErrorIs(t, s.Add(StreamID{Ms: 1000}, []string{"f", "v"}), ErrIDTooSmall)
+	assert.NoError(t, s.Add(StreamID{Ms: 1001}, []string{"f", "v"}))
+	assert.Equal(t, 1, s.Len())
+}
+
+func TestStream_TrimMaxLen(t *testing.T) {
+	s := newTestStream(t, 100, 10)
+	assert.Equal(t, 5, s.TrimMaxLen(95, false, -1))
+	assert.Equal(t, 95, s.Len())
+	first, _ := s.First()
+	assert.Equal(t, uint64(6), first.ID.Ms)
+
+	// Approximate trimming only removes whole blocks
+	assert.Equal(t, 5, s.TrimMaxLen(83, true, -1))
+	assert.Equal(t, 90, s.Len())
+	first, _ = s.First()
+	assert.Equal(t, uint64(11), first.ID.Ms)
+
+	// Limit stops before a block that does not fit
+	assert.Equal(t, 10, s.TrimMaxLen(0, true, 15))
+	assert.Equal(t, 80, s.Len())
+
+	assert.Equal(t, 0, s.TrimMaxLen(100, false, -1))
+	assert.Equal(t, 80, s.TrimMaxLen(0, false, -1))
+	assert.Equal(t, 0, s.Len())
+	assert.Equal(t, 0, s.Blocks())
+}
+
+func TestStream_TrimMinID(t *testing.T) {
+	s := newTestStream(t, 100, 10)
+	assert.Equal(t, 14, s.TrimMinID(StreamID{Ms: 15}, false, -1))
+	first, _ := s.First()
+	assert.Equal(t, uint64(15), first.ID.Ms)
+
+	// Approximate trimming keeps the block holding 31..35
+	assert.Equal(t, 16, s.TrimMinID(StreamID{Ms: 35}, true, -1))
+	first, _ = s.First()
+	assert.Equal(t, uint64(31), first.ID.Ms)
+
+	assert.Equal(t, 0, s.TrimMinID(StreamID{Ms: 5}, false, -1))
+	assert.Equal(t, 70, s.Len())
+}
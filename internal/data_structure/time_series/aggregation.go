@@ -0,0 +1,132 @@
+package time_series
+
+import (
+	"math"
+	"strings"
+)
+
+// Aggregation reduces the values of the samples of a bucket to one value
+type Aggregation string
+
+const (
+	AggAvg   Aggregation = "avg"
+	AggSum   Aggregation = "sum"
+	AggMin   Aggregation = "min"
+	AggMax   Aggregation = "max"
+	AggCount Aggregation = "count"
+	AggFirst Aggregation = "first"
+	AggLast  Aggregation = "last"
+)
+
+func ParseAggregation(s string) (Aggregation, bool) {
+	a := Aggregation(strings.ToLower(s))
+	switch a {
+	case AggAvg, AggSum, AggMin, AggMax, AggCount, AggFirst, AggLast:
+		return a, true
+	}
+	return "", false
+}
+
+// reduce aggregates the values of a bucket, ordered by timestamp
+func (a Aggregation) reduce(values []float64) float64 {
+	switch a {
+	case AggCount:
+		return float64(len(values))
+	case AggFirst:
+		return values[0]
+	case AggLast:
+		return values[len(values)-1]
+	case AggMin:
+		res := math.Inf(1)
+		for _, v := range values {
+			res = min(res, v)
+		}
+		return res
+	case AggMax:
+		res := math.Inf(-1)
+		for _, v := range values {
+			res = max(res, v)
+		}
+		return res
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	if a == AggAvg {
+		return sum / float64(len(values))
+	}
+	return sum
+}
+
+// BucketStart returns the start of the bucket holding timestamp, buckets of duration
+// milliseconds starting at align
+func BucketStart(timestamp, duration, align int64) int64 {
+	offset := (timestamp - align) % duration
+	if offset < 0 {
+		offset += duration
+	}
+	return timestamp - offset
+}
+
+// Aggregate groups samples ordered by timestamp into buckets and returns a sample per
+// non-empty bucket, timestamped with the start of the bucket
+func Aggregate(samples []Sample, a Aggregation, duration, align int64) []Sample {
+	var res []Sample
+	var values []float64
+	var bucket int64
+	for _, s := range samples {
+		b := BucketStart(s.Timestamp, duration, align)
+		if len(values) > 0 && b != bucket {
+			res = append(res, Sample{bucket, a.reduce(values)})
+			values = values[:0]
+		}
+		bucket = b
+		values = append(values, s.Value)
+	}
+	if len(values) > 0 {
+		res = append(res, Sample{bucket, a.reduce(values)})
+	}
+	return res
+}
+
+// Rule is a compaction rule, which downsamples a source series into a destination series
+// by aggregating the samples of each bucket of the source
+type Rule struct {
+	DestKey        string
+	Aggregation    Aggregation
+	BucketDuration int64
+	AlignTimestamp int64
+
+	// current is the start of the open bucket, whose aggregate is written to the
+	// destination once a sample of a later bucket is added
+	current int64
+	open    bool
+}
+
+func (r *Rule) BucketStart(timestamp int64) int64 {
+	return BucketStart(timestamp, r.BucketDuration, r.AlignTimestamp)
+}
+
+// OpenBucket returns the start of the bucket not yet written to the destination
+func (r *Rule) OpenBucket() (int64, bool) {
+	return r.current, r.open
+}
+
+// Update records that a sample of the source was added or updated at timestamp and
+// returns the start of the bucket whose aggregate must be written to the destination:
+// the open bucket when a later bucket opens, or the bucket of a late sample.
+func (r *Rule) Update(timestamp int64) (int64, bool) {
+	b := r.BucketStart(timestamp)
+	switch {
+	case !r.open:
+		r.current, r.open = b, true
+	case b > r.current:
+		closed := r.current
+		r.current = b
+		return closed, true
+	case b < r.current:
+		return b, true
+	}
+	return 0, false
+}
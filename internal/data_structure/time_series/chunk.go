@@ -0,0 +1,191 @@
+package time_series
+
+import (
+	"math"
+	"math/bits"
+)
+
+// bitWriter appends bits to a byte slice, most significant bit first
+type bitWriter struct {
+	buf []byte
+	n   uint // number of bits written
+}
+
+func (w *bitWriter) writeBit(bit bool) {
+	if w.n%8 == 0 {
+		w.buf = append(w.buf, 0)
+	}
+	if bit {
+		w.buf[len(w.buf)-1] |= 1 << (7 - w.n%8)
+	}
+	w.n++
+}
+
+// writeBits writes the nbits lowest bits of v
+func (w *bitWriter) writeBits(v uint64, nbits int) {
+	for i := nbits - 1; i >= 0; i-- {
+		w.writeBit(v>>i&1 == 1)
+	}
+}
+
+type bitReader struct {
+	buf []byte
+	pos uint
+}
+
+func (r *bitReader) readBit() bool {
+	bit := r.buf[r.pos/8]>>(7-r.pos%8)&1 == 1
+	r.pos++
+	return bit
+}
+
+func (r *bitReader) readBits(nbits int) uint64 {
+	var v uint64
+	for i := 0; i < nbits; i++ {
+		v <<= 1
+		if r.readBit() {
+			v |= 1
+		}
+	}
+	return v
+}
+
+// dodBuckets are the sizes in bits of the encodings of a non-zero delta of delta. The i-th
+// encoding is introduced by i+1 bits set to 1 and a 0 bit, the last one by 4 bits set to 1.
+// A zero delta of delta is a single 0 bit.
+var dodBuckets = []int{7, 9, 12, 64}
+
+// chunk holds consecutive samples compressed as in Gorilla: a timestamp is encoded as the
+// difference between its delta to the previous timestamp and the previous delta, which is
+// 0 for regular intervals, and a value as its XOR with the previous value, which has few
+// meaningful bits for close values.
+type chunk struct {
+	bits  bitWriter
+	count int
+	first int64
+	last  int64
+
+	lastValue float64
+	delta     int64
+	// leading and trailing are the numbers of zero bits around the meaningful bits of the
+	// last XOR written with a window, -1 before the first one
+	leading  int
+	trailing int
+}
+
+func newChunk() *chunk {
+	return &chunk{leading: -1}
+}
+
+// size returns the size of the compressed samples in bytes
+func (c *chunk) size() int {
+	return len(c.bits.buf)
+}
+
+// append adds a sample more recent than the last one
+func (c *chunk) append(s Sample) {
+	if c.count == 0 {
+		c.first = s.Timestamp
+		c.bits.writeBits(uint64(s.Timestamp), 64)
+		c.bits.writeBits(math.Float64bits(s.Value), 64)
+	} else {
+		delta := s.Timestamp - c.last
+		c.writeDod(delta - c.delta)
+		c.delta = delta
+		c.writeXor(math.Float64bits(s.Value) ^ math.Float64bits(c.lastValue))
+	}
+	c.last = s.Timestamp
+	c.lastValue = s.Value
+	c.count++
+}
+
+func (c *chunk) writeDod(dod int64) {
+	if dod == 0 {
+		c.bits.writeBit(false)
+		return
+	}
+	for _, size := range dodBuckets {
+		c.bits.writeBit(true)
+		if size == 64 {
+			c.bits.writeBits(uint64(dod), 64)
+			return
+		}
+		if limit := int64(1) << (size - 1); dod >= -limit && dod < limit {
+			c.bits.writeBit(false)
+			c.bits.writeBits(uint64(dod), size)
+			return
+		}
+	}
+}
+
+func (c *chunk) writeXor(xor uint64) {
+	if xor == 0 {
+		c.bits.writeBit(false)
+		return
+	}
+	c.bits.writeBit(true)
+	leading, trailing := bits.LeadingZeros64(xor), bits.TrailingZeros64(xor)
+	if c.leading >= 0 && leading >= c.leading && trailing >= c.trailing {
+		// The meaningful bits fit in the previous window
+		c.bits.writeBit(false)
+		c.bits.writeBits(xor>>c.trailing, 64-c.leading-c.trailing)
+		return
+	}
+	c.bits.writeBit(true)
+	meaningful := 64 - leading - trailing
+	c.bits.writeBits(uint64(leading), 6)
+	c.bits.writeBits(uint64(meaningful%64), 6) // 64 meaningful bits are written as 0
+	c.bits.writeBits(xor>>trailing, meaningful)
+	c.leading, c.trailing = leading, trailing
+}
+
+// samples decodes the samples of the chunk
+func (c *chunk) samples() []Sample {
+	r := bitReader{buf: c.bits.buf}
+	res := make([]Sample, 0, c.count)
+	var ts, delta int64
+	var value uint64
+	leading, trailing := 0, 0
+	for i := 0; i < c.count; i++ {
+		if i == 0 {
+			ts = int64(r.readBits(64))
+			value = r.readBits(64)
+		} else {
+			delta += readDod(&r)
+			ts += delta
+			if r.readBit() {
+				if r.readBit() {
+					leading = int(r.readBits(6))
+					meaningful := int(r.readBits(6))
+					if meaningful == 0 {
+						meaningful = 64
+					}
+					trailing = 64 - leading - meaningful
+				}
+				value ^= r.readBits(64-leading-trailing) << trailing
+			}
+		}
+		res = append(res, Sample{Timestamp: ts, Value: math.Float64frombits(value)})
+	}
+	return res
+}
+
+func readDod(r *bitReader) int64 {
+	if !r.readBit() {
+		return 0
+	}
+	for _, size := range dodBuckets {
+		if size == 64 {
+			return int64(r.readBits(64))
+		}
+		if !r.readBit() {
+			v := int64(r.readBits(size))
+			// Sign extension
+			if v >= 1<<(size-1) {
+				v -= 1 << size
+			}
+			return v
+		}
+	}
+	return 0
+}
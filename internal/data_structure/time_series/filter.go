@@ -0,0 +1,62 @@
+package time_series
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrInvalidFilter = errors.New("invalid filter")
+
+// LabelFilter selects series by the value of a label:
+//
+//	label=value      the label has the value
+//	label!=value     the label does not have the value
+//	label=(v1,v2)    the label has one of the values
+//	label!=(v1,v2)   the label has none of the values
+//	label=           the series does not have the label
+//	label!=          the series has the label
+type LabelFilter struct {
+	Label  string
+	Values []string
+	Negate bool
+}
+
+func ParseLabelFilter(s string) (*LabelFilter, error) {
+	i := strings.IndexByte(s, '=')
+	if i <= 0 {
+		return nil, ErrInvalidFilter
+	}
+	f := &LabelFilter{Label: s[:i]}
+	if s[i-1] == '!' {
+		f.Negate = true
+		f.Label = s[:i-1]
+	}
+	if f.Label == "" {
+		return nil, ErrInvalidFilter
+	}
+	value := s[i+1:]
+	if strings.HasPrefix(value, "(") && strings.HasSuffix(value, ")") {
+		for _, v := range strings.Split(value[1:len(value)-1], ",") {
+			f.Values = append(f.Values, strings.TrimSpace(v))
+		}
+	} else {
+		f.Values = []string{value}
+	}
+	return f, nil
+}
+
+// Positive reports whether the filter only matches series having the label
+func (f *LabelFilter) Positive() bool {
+	return !f.Negate && !(len(f.Values) == 1 && f.Values[0] == "")
+}
+
+// Match reports whether the series matches the filter, a missing label having an empty value
+func (f *LabelFilter) Match(s *Series) bool {
+	value, _ := s.Label(f.Label)
+	for _, v := range f.Values {
+		if v == value {
+			return !f.Negate
+		}
+	}
+	return f.Negate
+}
@@ -0,0 +1,245 @@
+package time_series
+
+import (
+	"errors"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// DuplicatePolicy decides what happens when a sample is added at the timestamp of an
+// existing sample
+type DuplicatePolicy string
+
+const (
+	PolicyBlock DuplicatePolicy = "BLOCK"
+	PolicyFirst DuplicatePolicy = "FIRST"
+	PolicyLast  DuplicatePolicy = "LAST"
+	PolicyMin   DuplicatePolicy = "MIN"
+	PolicyMax   DuplicatePolicy = "MAX"
+	PolicySum   DuplicatePolicy = "SUM"
+)
+
+var (
+	ErrDuplicate = errors.New("duplicate sample with BLOCK policy")
+	ErrTooOld    = errors.New("timestamp is older than retention")
+)
+
+func ParseDuplicatePolicy(s string) (DuplicatePolicy, bool) {
+	p := DuplicatePolicy(strings.ToUpper(s))
+	switch p {
+	case PolicyBlock, PolicyFirst, PolicyLast, PolicyMin, PolicyMax, PolicySum:
+		return p, true
+	}
+	return "", false
+}
+
+// apply returns the value kept when value is added at the timestamp of a sample of value old
+func (p DuplicatePolicy) apply(old, value float64) (float64, error) {
+	switch p {
+	case PolicyFirst:
+		return old, nil
+	case PolicyLast:
+		return value, nil
+	case PolicyMin:
+		return min(old, value), nil
+	case PolicyMax:
+		return max(old, value), nil
+	case PolicySum:
+		return old + value, nil
+	}
+	return 0, ErrDuplicate
+}
+
+type Sample struct {
+	Timestamp int64
+	Value     float64
+}
+
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Series is a sequence of samples ordered by timestamp, stored in compressed chunks
+type Series struct {
+	// Retention is the maximum age of the samples relative to the last one in
+	// milliseconds, 0 to keep all the samples
+	Retention int64
+	// ChunkSize is the size in bytes from which a new chunk is started
+	ChunkSize       int
+	DuplicatePolicy DuplicatePolicy
+	Labels          []Label
+	// Rules holds the compaction rules whose source is the series
+	Rules []*Rule
+	// SourceKey is the key of the series compacted into this one, empty if there is none
+	SourceKey string
+
+	chunks []*chunk
+	count  int
+}
+
+func NewSeries(retention int64, chunkSize int, policy DuplicatePolicy, labels []Label) *Series {
+	return &Series{
+		Retention:       retention,
+		ChunkSize:       chunkSize,
+		DuplicatePolicy: policy,
+		Labels:          labels,
+	}
+}
+
+// Label returns the value of a label
+func (s *Series) Label(name string) (string, bool) {
+	for _, l := range s.Labels {
+		if l.Name == name {
+			return l.Value, true
+		}
+	}
+	return "", false
+}
+
+// Len returns the number of samples
+func (s *Series) Len() int {
+	return s.count
+}
+
+func (s *Series) NumChunks() int {
+	return len(s.chunks)
+}
+
+// MemoryUsage returns the size of the compressed samples in bytes
+func (s *Series) MemoryUsage() int {
+	size := 0
+	for _, c := range s.chunks {
+		size += c.size()
+	}
+	return size
+}
+
+// First returns the oldest sample
+func (s *Series) First() (Sample, bool) {
+	if len(s.chunks) == 0 {
+		return Sample{}, false
+	}
+	c := s.chunks[0]
+	return c.samples()[0], true
+}
+
+// Last returns the most recent sample
+func (s *Series) Last() (Sample, bool) {
+	if len(s.chunks) == 0 {
+		return Sample{}, false
+	}
+	c := s.chunks[len(s.chunks)-1]
+	return Sample{Timestamp: c.last, Value: c.lastValue}, true
+}
+
+// Add adds a sample. A sample older than the last one is inserted in its chunk, policy
+// deciding the value kept if a sample exists at the same timestamp.
+func (s *Series) Add(timestamp int64, value float64, policy DuplicatePolicy) error {
+	if last, ok := s.Last(); ok && timestamp <= last.Timestamp {
+		if s.Retention > 0 && timestamp < last.Timestamp-s.Retention {
+			return ErrTooOld
+		}
+		return s.upsert(timestamp, value, policy)
+	}
+	if len(s.chunks) == 0 || s.chunks[len(s.chunks)-1].size() >= s.ChunkSize {
+		s.chunks = append(s.chunks, newChunk())
+	}
+	s.chunks[len(s.chunks)-1].append(Sample{timestamp, value})
+	s.count++
+	s.trim()
+	return nil
+}
+
+// upsert decompresses the chunk holding timestamp to insert or update the sample
+func (s *Series) upsert(timestamp int64, value float64, policy DuplicatePolicy) error {
+	i := sort.Search(len(s.chunks), func(i int) bool { return s.chunks[i].first > timestamp }) - 1
+	i = max(i, 0)
+	samples := s.chunks[i].samples()
+	j := sort.Search(len(samples), func(j int) bool { return samples[j].Timestamp >= timestamp })
+	if j < len(samples) && samples[j].Timestamp == timestamp {
+		v, err := policy.apply(samples[j].Value, value)
+		if err != nil {
+			return err
+		}
+		samples[j].Value = v
+	} else {
+		samples = slices.Insert(samples, j, Sample{timestamp, value})
+		s.count++
+	}
+	s.chunks = slices.Replace(s.chunks, i, i+1, s.compress(samples)...)
+	return nil
+}
+
+// compress stores samples in chunks
+func (s *Series) compress(samples []Sample) []*chunk {
+	chunks := []*chunk{newChunk()}
+	for _, sample := range samples {
+		if chunks[len(chunks)-1].size() >= s.ChunkSize {
+			chunks = append(chunks, newChunk())
+		}
+		chunks[len(chunks)-1].append(sample)
+	}
+	return chunks
+}
+
+// trim drops the chunks whose samples are all older than the retention
+func (s *Series) trim() {
+	last, ok := s.Last()
+	if s.Retention == 0 || !ok {
+		return
+	}
+	n := 0
+	for n < len(s.chunks)-1 && s.chunks[n].last < last.Timestamp-s.Retention {
+		s.count -= s.chunks[n].count
+		n++
+	}
+	s.chunks = s.chunks[n:]
+}
+
+// Range returns the samples whose timestamp is between from and to inclusive, ignoring the
+// samples older than the retention
+func (s *Series) Range(from, to int64) []Sample {
+	if last, ok := s.Last(); ok && s.Retention > 0 {
+		from = max(from, last.Timestamp-s.Retention)
+	}
+	var res []Sample
+	for _, c := range s.chunks {
+		if c.last < from {
+			continue
+		}
+		if c.first > to {
+			break
+		}
+		for _, sample := range c.samples() {
+			if sample.Timestamp >= from && sample.Timestamp <= to {
+				res = append(res, sample)
+			}
+		}
+	}
+	return res
+}
+
+// Delete deletes the samples whose timestamp is between from and to inclusive and returns
+// their number
+func (s *Series) Delete(from, to int64) int {
+	deleted := 0
+	chunks := make([]*chunk, 0, len(s.chunks))
+	for _, c := range s.chunks {
+		if c.last < from || c.first > to {
+			chunks = append(chunks, c)
+			continue
+		}
+		samples := slices.DeleteFunc(c.samples(), func(sample Sample) bool {
+			return sample.Timestamp >= from && sample.Timestamp <= to
+		})
+		deleted += c.count - len(samples)
+		if len(samples) > 0 {
+			chunks = append(chunks, s.compress(samples)...)
+		}
+	}
+	s.chunks = chunks
+	s.count -= deleted
+	return deleted
+}
@@ -0,0 +1,177 @@
+package time_series
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChunkRoundTrip(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	var samples []Sample
+	ts := int64(-5000)
+	value := 20.0
+	for i := 0; i < 2000; i++ {
+		switch i % 4 {
+		case 0:
+			ts += 1000 // regular interval
+		case 1:
+			ts += rnd.Int63n(5000) + 1
+		case 2:
+			ts += rnd.Int63n(1 << 40)
+		default:
+			ts++
+		}
+		switch i % 3 {
+		case 0:
+			value += rnd.Float64() - 0.5
+		case 1:
+			value = math.Round(value)
+		}
+		samples = append(samples, Sample{ts, value})
+	}
+	samples = append(samples, Sample{ts + 1, math.Inf(-1)}, Sample{ts + 2, 0}, Sample{ts + 3, -0.0})
+
+	c := newChunk()
+	for _, s := range samples {
+		c.append(s)
+	}
+	assert.Equal(t, samples, c.samples())
+
+	// Regular metrics take a few bits per sample instead of 16 bytes
+	c = newChunk()
+	for i := 0; i < 1000; i++ {
+		c.append(Sample{int64(i) * 1000, float64(20 + i%3)})
+	}
+	assert.Less(t, c.size(), 1000)
+}
+
+func TestSeriesAdd(t *testing.T) {
+	s := NewSeries(0, 64, PolicyBlock, nil)
+	for ts := int64(10); ts <= 1000; ts += 10 {
+		require.NoError(t, s.Add(ts, float64(ts), s.DuplicatePolicy))
+	}
+	assert.Equal(t, 100, s.Len())
+	assert.Greater(t, s.NumChunks(), 1)
+
+	// Out of order samples are inserted in their chunk
+	require.NoError(t, s.Add(15, 1.5, PolicyBlock))
+	require.NoError(t, s.Add(5, 0.5, PolicyBlock))
+	assert.Equal(t, []Sample{{5, 0.5}, {10, 10}, {15, 1.5}, {20, 20}}, s.Range(0, 20))
+
+	assert.ErrorIs(t, s.Add(10, 1, PolicyBlock), ErrDuplicate)
+	policies := []struct {
+		policy   DuplicatePolicy
+		value    float64
+		expected float64
+	}{
+		{PolicyFirst, 1, 10},
+		{PolicyMin, 3, 3},
+		{PolicyMax, 2, 3},
+		{PolicySum, 4, 7},
+		{PolicyLast, 8, 8},
+	}
+	for _, p := range policies {
+		require.NoError(t, s.Add(10, p.value, p.policy))
+		assert.Equal(t, []Sample{{10, p.expected}}, s.Range(10, 10), p.policy)
+	}
+	assert.Equal(t, 102, s.Len())
+
+	first, _ := s.First()
+	last, _ := s.Last()
+	assert.Equal(t, Sample{5, 0.5}, first)
+	assert.Equal(t, Sample{1000, 1000}, last)
+
+	assert.Equal(t, 3, s.Delete(0, 15))
+	assert.Equal(t, 11, s.Delete(500, 600))
+	assert.Equal(t, 0, s.Delete(2000, 3000))
+	assert.Equal(t, 88, s.Len())
+	assert.Len(t, s.Range(0, math.MaxInt64), 88)
+	assert.Equal(t, []Sample{{490, 490}, {610, 610}}, s.Range(485, 615))
+}
+
+func TestSeriesRetention(t *testing.T) {
+	s := NewSeries(100, 32, PolicyLast, nil)
+	for ts := int64(0); ts <= 1000; ts += 10 {
+		require.NoError(t, s.Add(ts, 1, PolicyLast))
+	}
+	assert.Len(t, s.Range(0, 1000), 11)
+	assert.Less(t, s.Len(), 101)
+	assert.ErrorIs(t, s.Add(850, 1, PolicyLast), ErrTooOld)
+	assert.NoError(t, s.Add(905, 1, PolicyLast))
+}
+
+func TestAggregate(t *testing.T) {
+	samples := []Sample{{0, 1}, {5, 3}, {9, 2}, {10, 10}, {25, -1}, {29, 5}}
+	tests := []struct {
+		aggregation Aggregation
+		expected    []Sample
+	}{
+		{AggAvg, []Sample{{0, 2}, {10, 10}, {20, 2}}},
+		{AggSum, []Sample{{0, 6}, {10, 10}, {20, 4}}},
+		{AggMin, []Sample{{0, 1}, {10, 10}, {20, -1}}},
+		{AggMax, []Sample{{0, 3}, {10, 10}, {20, 5}}},
+		{AggCount, []Sample{{0, 3}, {10, 1}, {20, 2}}},
+		{AggFirst, []Sample{{0, 1}, {10, 10}, {20, -1}}},
+		{AggLast, []Sample{{0, 2}, {10, 10}, {20, 5}}},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, Aggregate(samples, tt.aggregation, 10, 0), tt.aggregation)
+	}
+	assert.Equal(t, []Sample{{-5, 1}, {5, 3}, {25, 2}}, Aggregate(samples, AggCount, 10, 5))
+	assert.Equal(t, int64(-10), BucketStart(-1, 10, 0))
+
+	_, ok := ParseAggregation("AVG")
+	assert.True(t, ok)
+	_, ok = ParseAggregation("median")
+	assert.False(t, ok)
+}
+
+func TestRuleUpdate(t *testing.T) {
+	r := &Rule{BucketDuration: 10}
+	_, closed := r.Update(3)
+	assert.False(t, closed)
+	_, closed = r.Update(7)
+	assert.False(t, closed)
+	b, closed := r.Update(12)
+	assert.True(t, closed)
+	assert.Equal(t, int64(0), b)
+	// A late sample updates its bucket
+	b, closed = r.Update(5)
+	assert.True(t, closed)
+	assert.Equal(t, int64(0), b)
+	current, _ := r.OpenBucket()
+	assert.Equal(t, int64(10), current)
+}
+
+func TestLabelFilter(t *testing.T) {
+	s := NewSeries(0, 64, PolicyBlock, []Label{{"sensor", "temp"}, {"room", "kitchen"}})
+	tests := []struct {
+		filter   string
+		expected bool
+	}{
+		{"sensor=temp", true},
+		{"sensor!=temp", false},
+		{"room=(hall,kitchen)", true},
+		{"room!=(hall,kitchen)", false},
+		{"floor=", true},
+		{"floor!=", false},
+		{"room!=", true},
+		{"sensor=humidity", false},
+	}
+	for _, tt := range tests {
+		f, err := ParseLabelFilter(tt.filter)
+		require.NoError(t, err, tt.filter)
+		assert.Equal(t, tt.expected, f.Match(s), tt.filter)
+	}
+	f, _ := ParseLabelFilter("floor=")
+	assert.False(t, f.Positive())
+
+	for _, text := range []string{"sensor", "=temp", "!=temp"} {
+		_, err := ParseLabelFilter(text)
+		assert.Error(t, err, text)
+	}
+}
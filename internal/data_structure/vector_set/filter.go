@@ -0,0 +1,530 @@
+package vector_set
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/spaghetti-lover/multithread-redis/internal/data_structure/json_doc"
+)
+
+// Filter is a boolean expression over the JSON attributes of an element, such as
+//
+//	.year >= 1980 and .genre in ["drama", "comedy"] and not (.rating < 7)
+//
+// Selectors start with a dot and read a field of the attributes, nested fields being
+// separated by dots. The operators are, from the lowest precedence: or (||), and (&&),
+// not (!), the comparisons == != < <= > >= and in, + and -, then * / and %. Literals are
+// numbers, strings quoted with ' or ", true, false, null and arrays. An element missing a
+// selected field does not match.
+type Filter struct {
+	root expr
+}
+
+type expr interface {
+	eval(attrs *json_doc.Object) (any, bool)
+}
+
+type literal struct{ value any }
+
+type selector struct{ path []string }
+
+type arrayExpr struct{ items []expr }
+
+type unaryExpr struct {
+	op      string
+	operand expr
+}
+
+type binaryExpr struct {
+	op          string
+	left, right expr
+}
+
+func ParseFilter(text string) (*Filter, error) {
+	p := &filterParser{text: text}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, p.errorf("unexpected %q", p.tok.text)
+	}
+	return &Filter{root: root}, nil
+}
+
+// Match evaluates the filter against the attributes of an element
+func (f *Filter) Match(attrs *json_doc.Object) bool {
+	v, ok := f.root.eval(attrs)
+	return ok && truthy(v)
+}
+
+// normalize converts the numbers of the attributes to float64
+func normalize(v any) any {
+	switch v := v.(type) {
+	case int64:
+		return float64(v)
+	case *json_doc.Array:
+		items := make([]any, len(v.Items))
+		for i, item := range v.Items {
+			items[i] = normalize(item)
+		}
+		return items
+	}
+	return v
+}
+
+func truthy(v any) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	case string:
+		return v != ""
+	case []any:
+		return len(v) > 0
+	case nil:
+		return false
+	}
+	return true
+}
+
+func equal(a, b any) bool {
+	switch a := a.(type) {
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !equal(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case *json_doc.Object:
+		return false
+	}
+	if _, ok := b.(*json_doc.Object); ok {
+		return false
+	}
+	if _, ok := b.([]any); ok {
+		return false
+	}
+	return a == b
+}
+
+func (e *literal) eval(*json_doc.Object) (any, bool) {
+	return e.value, true
+}
+
+func (e *selector) eval(attrs *json_doc.Object) (any, bool) {
+	var v any = attrs
+	for _, key := range e.path {
+		obj, ok := v.(*json_doc.Object)
+		if !ok {
+			return nil, false
+		}
+		if v, ok = obj.Get(key); !ok {
+			return nil, false
+		}
+	}
+	return normalize(v), true
+}
+
+func (e *arrayExpr) eval(attrs *json_doc.Object) (any, bool) {
+	items := make([]any, len(e.items))
+	for i, item := range e.items {
+		v, ok := item.eval(attrs)
+		if !ok {
+			return nil, false
+		}
+		items[i] = v
+	}
+	return items, true
+}
+
+func (e *unaryExpr) eval(attrs *json_doc.Object) (any, bool) {
+	v, ok := e.operand.eval(attrs)
+	if !ok {
+		return nil, false
+	}
+	if e.op == "-" {
+		n, ok := v.(float64)
+		return -n, ok
+	}
+	return !truthy(v), true
+}
+
+func (e *binaryExpr) eval(attrs *json_doc.Object) (any, bool) {
+	left, ok := e.left.eval(attrs)
+	if !ok {
+		return nil, false
+	}
+	// and / or short-circuit
+	switch e.op {
+	case "and":
+		if !truthy(left) {
+			return false, true
+		}
+	case "or":
+		if truthy(left) {
+			return true, true
+		}
+	}
+	right, ok := e.right.eval(attrs)
+	if !ok {
+		return nil, false
+	}
+
+	switch e.op {
+	case "and", "or":
+		return truthy(right), true
+	case "==":
+		return equal(left, right), true
+	case "!=":
+		return !equal(left, right), true
+	case "in":
+		switch r := right.(type) {
+		case []any:
+			for _, item := range r {
+				if equal(left, item) {
+					return true, true
+				}
+			}
+			return false, true
+		case string:
+			l, ok := left.(string)
+			return ok && strings.Contains(r, l), ok
+		}
+		return nil, false
+	}
+
+	if l, ok := left.(string); ok {
+		r, ok := right.(string)
+		if !ok {
+			return nil, false
+		}
+		switch e.op {
+		case "<":
+			return l < r, true
+		case "<=":
+			return l <= r, true
+		case ">":
+			return l > r, true
+		case ">=":
+			return l >= r, true
+		case "+":
+			return l + r, true
+		}
+		return nil, false
+	}
+
+	l, ok1 := left.(float64)
+	r, ok2 := right.(float64)
+	if !ok1 || !ok2 {
+		return nil, false
+	}
+	switch e.op {
+	case "<":
+		return l < r, true
+	case "<=":
+		return l <= r, true
+	case ">":
+		return l > r, true
+	case ">=":
+		return l >= r, true
+	case "+":
+		return l + r, true
+	case "-":
+		return l - r, true
+	case "*":
+		return l * r, true
+	case "/":
+		return l / r, r != 0
+	case "%":
+		return math.Mod(l, r), r != 0
+	}
+	return nil, false
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokString
+	tokSelector
+	tokIdent
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	// value holds the number of a tokNumber, the unquoted string of a tokString
+	value any
+}
+
+// maxFilterDepth bounds the nesting of parentheses, arrays and unary operators, so that a
+// FILTER expression can not overflow the stack of the parser
+const maxFilterDepth = 128
+
+type filterParser struct {
+	text  string
+	pos   int
+	tok   token
+	depth int
+}
+
+func (p *filterParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("syntax error in FILTER expression: "+format, args...)
+}
+
+// nested runs parse one level deeper, failing with a syntax error past maxFilterDepth
+func (p *filterParser) nested(parse func() (expr, error)) (expr, error) {
+	if p.depth == maxFilterDepth {
+		return nil, p.errorf("expression nested too deeply")
+	}
+	p.depth++
+	defer func() { p.depth-- }()
+	return parse()
+}
+
+// operators holds the operators tokens, longest first
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "+", "-", "*", "/", "%", "(", ")", "[", "]", ","}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// next reads the next token
+func (p *filterParser) next() error {
+	for p.pos < len(p.text) && (p.text[p.pos] == ' ' || p.text[p.pos] == '\t') {
+		p.pos++
+	}
+	if p.pos >= len(p.text) {
+		p.tok = token{kind: tokEOF, text: "end of expression"}
+		return nil
+	}
+	start := p.pos
+	c := p.text[p.pos]
+	switch {
+	case c == '.':
+		p.pos++
+		for p.pos < len(p.text) && (isIdentByte(p.text[p.pos]) || p.text[p.pos] == '.') {
+			p.pos++
+		}
+		text := p.text[start:p.pos]
+		if text == "." || strings.HasSuffix(text, ".") || strings.Contains(text, "..") {
+			return p.errorf("invalid selector %q", text)
+		}
+		p.tok = token{kind: tokSelector, text: text}
+	case c >= '0' && c <= '9':
+		for p.pos < len(p.text) && (isIdentByte(p.text[p.pos]) || p.text[p.pos] == '.' ||
+			(p.text[p.pos] == '-' || p.text[p.pos] == '+') && (p.text[p.pos-1] == 'e' || p.text[p.pos-1] == 'E')) {
+			p.pos++
+		}
+		text := p.text[start:p.pos]
+		n, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return p.errorf("invalid number %q", text)
+		}
+		p.tok = token{kind: tokNumber, text: text, value: n}
+	case c == '"' || c == '\'':
+		var b strings.Builder
+		for p.pos++; ; p.pos++ {
+			if p.pos >= len(p.text) {
+				return p.errorf("unterminated string")
+			}
+			ch := p.text[p.pos]
+			if ch == c {
+				p.pos++
+				break
+			}
+			if ch == '\\' && p.pos+1 < len(p.text) {
+				p.pos++
+				ch = p.text[p.pos]
+			}
+			b.WriteByte(ch)
+		}
+		p.tok = token{kind: tokString, text: p.text[start:p.pos], value: b.String()}
+	case isIdentByte(c):
+		for p.pos < len(p.text) && isIdentByte(p.text[p.pos]) {
+			p.pos++
+		}
+		p.tok = token{kind: tokIdent, text: strings.ToLower(p.text[start:p.pos])}
+	default:
+		for _, op := range operators {
+			if strings.HasPrefix(p.text[p.pos:], op) {
+				p.pos += len(op)
+				p.tok = token{kind: tokOp, text: op}
+				return nil
+			}
+		}
+		return p.errorf("unexpected character %q", c)
+	}
+	return nil
+}
+
+func (p *filterParser) isOp(op string) bool {
+	return p.tok.kind == tokOp && p.tok.text == op
+}
+
+// accept consumes the current token if it is one of the given operators or keywords
+func (p *filterParser) accept(ops ...string) (string, bool, error) {
+	if p.tok.kind != tokOp && p.tok.kind != tokIdent {
+		return "", false, nil
+	}
+	for _, op := range ops {
+		if p.tok.text == op {
+			return op, true, p.next()
+		}
+	}
+	return "", false, nil
+}
+
+func (p *filterParser) parseOr() (expr, error) {
+	return p.parseBinary(p.parseAnd, map[string]string{"or": "or", "||": "or"})
+}
+
+func (p *filterParser) parseAnd() (expr, error) {
+	return p.parseBinary(p.parseNot, map[string]string{"and": "and", "&&": "and"})
+}
+
+func (p *filterParser) parseNot() (expr, error) {
+	if _, ok, err := p.accept("not", "!"); err != nil {
+		return nil, err
+	} else if ok {
+		operand, err := p.nested(p.parseNot)
+		if err != nil {
+			return nil, err
+		}
+		return &unaryExpr{op: "not", operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *filterParser) parseComparison() (expr, error) {
+	left, err := p.parseAdd()
+	if err != nil {
+		return nil, err
+	}
+	op, ok, err := p.accept("==", "!=", "<", "<=", ">", ">=", "in")
+	if err != nil || !ok {
+		return left, err
+	}
+	right, err := p.parseAdd()
+	if err != nil {
+		return nil, err
+	}
+	return &binaryExpr{op: op, left: left, right: right}, nil
+}
+
+func (p *filterParser) parseAdd() (expr, error) {
+	return p.parseBinary(p.parseMul, map[string]string{"+": "+", "-": "-"})
+}
+
+func (p *filterParser) parseMul() (expr, error) {
+	return p.parseBinary(p.parseUnary, map[string]string{"*": "*", "/": "/", "%": "%"})
+}
+
+// parseBinary parses left-associative operators, ops mapping the tokens to the operators
+func (p *filterParser) parseBinary(operand func() (expr, error), ops map[string]string) (expr, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, isOp := ops[p.tok.text]
+		if !isOp || (p.tok.kind != tokOp && p.tok.kind != tokIdent) {
+			return left, nil
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: op, left: left, right: right}
+	}
+}
+
+func (p *filterParser) parseUnary() (expr, error) {
+	if _, ok, err := p.accept("-"); err != nil {
+		return nil, err
+	} else if ok {
+		operand, err := p.nested(p.parseUnary)
+		if err != nil {
+			return nil, err
+		}
+		return &unaryExpr{op: "-", operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *filterParser) parsePrimary() (expr, error) {
+	tok := p.tok
+	switch tok.kind {
+	case tokNumber, tokString:
+		return &literal{tok.value}, p.next()
+	case tokSelector:
+		return &selector{strings.Split(tok.text[1:], ".")}, p.next()
+	case tokIdent:
+		switch tok.text {
+		case "true":
+			return &literal{true}, p.next()
+		case "false":
+			return &literal{false}, p.next()
+		case "null":
+			return &literal{nil}, p.next()
+		}
+	case tokOp:
+		switch tok.text {
+		case "(":
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			e, err := p.nested(p.parseOr)
+			if err != nil {
+				return nil, err
+			}
+			if !p.isOp(")") {
+				return nil, p.errorf("expected ')'")
+			}
+			return e, p.next()
+		case "[":
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			arr := &arrayExpr{}
+			for !p.isOp("]") {
+				item, err := p.nested(p.parseOr)
+				if err != nil {
+					return nil, err
+				}
+				arr.items = append(arr.items, item)
+				if _, ok, err := p.accept(","); err != nil {
+					return nil, err
+				} else if !ok && !p.isOp("]") {
+					return nil, p.errorf("expected ',' or ']'")
+				}
+			}
+			return arr, p.next()
+		}
+	}
+	return nil, p.errorf("unexpected %s", describe(tok))
+}
+
+func describe(tok token) string {
+	if tok.kind == tokEOF {
+		return tok.text
+	}
+	return strconv.Quote(tok.text)
+}
@@ -0,0 +1,249 @@
+package vector_set
+
+import (
+	"container/heap"
+	"math"
+	"slices"
+)
+
+// hnswMaxLevel bounds the layers of the HNSW graph
+const hnswMaxLevel = 16
+
+type candidate struct {
+	node *node
+	dist float32
+}
+
+// candidateHeap is a heap of candidates, the closest on top, or the farthest if farthest is set
+type candidateHeap struct {
+	items    []candidate
+	farthest bool
+}
+
+// closer orders the candidates by distance, then by name so that results are deterministic
+func (h *candidateHeap) closer(a, b candidate) bool {
+	if a.dist != b.dist {
+		return a.dist < b.dist
+	}
+	return a.node.name < b.node.name
+}
+
+func (h *candidateHeap) Len() int { return len(h.items) }
+func (h *candidateHeap) Less(i, j int) bool {
+	if h.farthest {
+		return h.closer(h.items[j], h.items[i])
+	}
+	return h.closer(h.items[i], h.items[j])
+}
+func (h *candidateHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *candidateHeap) Push(x any)    { h.items = append(h.items, x.(candidate)) }
+func (h *candidateHeap) Pop() any {
+	last := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return last
+}
+
+func (h *candidateHeap) push(c candidate) { heap.Push(h, c) }
+func (h *candidateHeap) pop() candidate   { return heap.Pop(h).(candidate) }
+func (h *candidateHeap) fix()             { heap.Fix(h, 0) }
+
+// sorted returns the candidates closest first
+func (h *candidateHeap) sorted() []candidate {
+	res := slices.Clone(h.items)
+	slices.SortFunc(res, func(a, b candidate) int {
+		if h.closer(a, b) {
+			return -1
+		}
+		return 1
+	})
+	return res
+}
+
+// maxLinks returns the number of neighbours of a node in a layer
+func (s *Set) maxLinks(layer int) int {
+	if layer == 0 {
+		return 2 * s.M
+	}
+	return s.M
+}
+
+// randomLevel draws the top layer of a new node from an exponential distribution, each
+// layer having about M times fewer nodes than the one below
+func (s *Set) randomLevel() int {
+	level := int(-math.Log(1-s.rnd.Float64()) / math.Log(float64(s.M)))
+	return min(level, hnswMaxLevel)
+}
+
+// searchLayer walks a layer of the graph from the entry candidates and returns the ef
+// closest nodes accepted by match, closest first. The walk stops after visiting maxVisits
+// nodes if maxVisits is not 0.
+func (s *Set) searchLayer(query []float32, entry []candidate, ef int, layer int, match func(n *node) bool, maxVisits int) []candidate {
+	visited := make(map[*node]struct{})
+	candidates := &candidateHeap{}
+	results := &candidateHeap{farthest: true}
+	for _, c := range entry {
+		visited[c.node] = struct{}{}
+		candidates.push(c)
+		if match == nil || match(c.node) {
+			results.push(c)
+		}
+	}
+	for results.Len() > ef {
+		results.pop()
+	}
+
+walk:
+	for candidates.Len() > 0 {
+		c := candidates.pop()
+		if results.Len() >= ef && c.dist > results.items[0].dist {
+			break
+		}
+		for _, nb := range c.node.links[layer] {
+			if _, ok := visited[nb]; ok {
+				continue
+			}
+			if maxVisits > 0 && len(visited) >= maxVisits {
+				break walk
+			}
+			visited[nb] = struct{}{}
+			d := s.Metric.distance(query, nb.vector)
+			if results.Len() >= ef && d >= results.items[0].dist {
+				continue
+			}
+			candidates.push(candidate{nb, d})
+			if match == nil || match(nb) {
+				results.push(candidate{nb, d})
+				if results.Len() > ef {
+					results.pop()
+				}
+			}
+		}
+	}
+	return results.sorted()
+}
+
+// selectNeighbors picks up to m neighbours for a node at vector among candidates with the
+// HNSW heuristic: a candidate closer to an already selected neighbour than to the node is
+// skipped, so that the links point in diverse directions. Skipped candidates fill the
+// remaining slots.
+func (s *Set) selectNeighbors(vector []float32, candidates []*node, m int) []*node {
+	sorted := make([]candidate, len(candidates))
+	for i, c := range candidates {
+		sorted[i] = candidate{c, s.Metric.distance(vector, c.vector)}
+	}
+	sorted = (&candidateHeap{items: sorted}).sorted()
+
+	selected := make([]*node, 0, m)
+	var skipped []*node
+	for _, c := range sorted {
+		if len(selected) >= m {
+			break
+		}
+		diverse := true
+		for _, r := range selected {
+			if s.Metric.distance(c.node.vector, r.vector) < c.dist {
+				diverse = false
+				break
+			}
+		}
+		if diverse {
+			selected = append(selected, c.node)
+		} else {
+			skipped = append(skipped, c.node)
+		}
+	}
+	for _, c := range skipped {
+		if len(selected) >= m {
+			break
+		}
+		selected = append(selected, c)
+	}
+	return selected
+}
+
+func removeLink(links []*node, n *node) []*node {
+	res := make([]*node, 0, len(links))
+	for _, l := range links {
+		if l != n {
+			res = append(res, l)
+		}
+	}
+	return res
+}
+
+// relink replaces the neighbours of n in a layer, keeping the links bidirectional.
+// A new neighbour having too many links drops its worst ones.
+func (s *Set) relink(n *node, layer int, neighbors []*node) {
+	old := n.links[layer]
+	n.links[layer] = neighbors
+	for _, x := range old {
+		if !slices.Contains(neighbors, x) {
+			x.links[layer] = removeLink(x.links[layer], n)
+		}
+	}
+	for _, x := range neighbors {
+		if slices.Contains(old, x) {
+			continue
+		}
+		x.links[layer] = append(x.links[layer], n)
+		if len(x.links[layer]) > s.maxLinks(layer) {
+			s.relink(x, layer, s.selectNeighbors(x.vector, x.links[layer], s.maxLinks(layer)))
+		}
+	}
+}
+
+// insert links a new node into the graph
+func (s *Set) insert(n *node) {
+	level := s.randomLevel()
+	n.links = make([][]*node, level+1)
+	if s.entry == nil {
+		s.entry, s.maxLevel = n, level
+		return
+	}
+
+	entry := []candidate{{s.entry, s.Metric.distance(n.vector, s.entry.vector)}}
+	for layer := s.maxLevel; layer > level; layer-- {
+		entry = s.searchLayer(n.vector, entry, 1, layer, nil, 0)
+	}
+	for layer := min(level, s.maxLevel); layer >= 0; layer-- {
+		found := s.searchLayer(n.vector, entry, s.EfConstruction, layer, nil, 0)
+		candidates := make([]*node, len(found))
+		for i, c := range found {
+			candidates[i] = c.node
+		}
+		s.relink(n, layer, s.selectNeighbors(n.vector, candidates, s.M))
+		entry = found
+	}
+	if level > s.maxLevel {
+		s.entry, s.maxLevel = n, level
+	}
+}
+
+// unlink removes a node from the graph, then links each of its former neighbours to the
+// best of its remaining neighbours and of the other former neighbours of the node
+func (s *Set) unlink(n *node) {
+	for layer, neighbors := range n.links {
+		for _, nb := range neighbors {
+			nb.links[layer] = removeLink(nb.links[layer], n)
+		}
+		for _, nb := range neighbors {
+			candidates := slices.Clone(nb.links[layer])
+			for _, c := range neighbors {
+				if c != nb && !slices.Contains(candidates, c) {
+					candidates = append(candidates, c)
+				}
+			}
+			s.relink(nb, layer, s.selectNeighbors(nb.vector, candidates, s.maxLinks(layer)))
+		}
+	}
+
+	if s.entry != n {
+		return
+	}
+	s.entry, s.maxLevel = nil, 0
+	for _, x := range s.nodes {
+		if s.entry == nil || len(x.links)-1 > s.maxLevel {
+			s.entry, s.maxLevel = x, len(x.links)-1
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package vector_set
+
+import "math"
+
+// Metric is the measure of the distance between two vectors
+type Metric string
+
+const (
+	MetricCosine Metric = "COSINE"
+	MetricL2     Metric = "L2"
+	MetricIP     Metric = "IP"
+)
+
+func dot(a, b []float32) float32 {
+	var sum float32
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+func norm(v []float32) float32 {
+	return float32(math.Sqrt(float64(dot(v, v))))
+}
+
+// distance returns a value that is lower for closer vectors. Vectors are normalized for the
+// cosine metric, so that the cosine similarity is their inner product.
+func (m Metric) distance(a, b []float32) float32 {
+	switch m {
+	case MetricL2:
+		var sum float32
+		for i := range a {
+			d := a[i] - b[i]
+			sum += d * d
+		}
+		return sum
+	case MetricIP:
+		return -dot(a, b)
+	default:
+		return 1 - dot(a, b)
+	}
+}
+
+// score converts a distance to the score reported to the user: the cosine similarity
+// mapped to [0, 1], the Euclidean distance or the inner product
+func (m Metric) score(distance float32) float64 {
+	switch m {
+	case MetricL2:
+		return math.Sqrt(float64(distance))
+	case MetricIP:
+		return float64(-distance)
+	default:
+		return 1 - float64(distance)/2
+	}
+}
@@ -0,0 +1,258 @@
+package vector_set
+
+import (
+	"errors"
+	"math/rand/v2"
+	"slices"
+
+	"github.com/spaghetti-lover/multithread-redis/internal/data_structure/json_doc"
+)
+
+// IndexType is the way a set finds the nearest neighbours of a vector
+type IndexType string
+
+const (
+	// IndexFlat compares the query with every vector: exact but linear
+	IndexFlat IndexType = "FLAT"
+	// IndexHNSW searches a hierarchical navigable small world graph: approximate but logarithmic
+	IndexHNSW IndexType = "HNSW"
+)
+
+// filterEfFactor is the default number of nodes visited per requested result when a search
+// with a filter walks the HNSW graph
+const filterEfFactor = 100
+
+var ErrInvalidAttributes = errors.New("attributes must be a JSON object")
+
+type node struct {
+	name   string
+	vector []float32
+	// norm is the norm of the vector as added, the stored vector being normalized for the
+	// cosine metric
+	norm       float32
+	attributes string
+	attrValue  *json_doc.Object
+	// links holds the neighbours of the node in each layer of the HNSW graph, links being
+	// bidirectional
+	links [][]*node
+}
+
+// Set is a set of named vectors of the same dimension supporting nearest-neighbour queries
+type Set struct {
+	Dim    int
+	Metric Metric
+	Index  IndexType
+	// M is the number of neighbours of a node in the upper layers of the HNSW graph,
+	// the bottom layer having twice as many
+	M int
+	// EfConstruction is the number of candidates considered when linking a new node
+	EfConstruction int
+
+	nodes    map[string]*node
+	entry    *node
+	maxLevel int
+	rnd      *rand.Rand
+}
+
+// Result is a vector found by Search, the score depending on the metric
+type Result struct {
+	Name  string
+	Score float64
+}
+
+type SearchOptions struct {
+	Count int
+	// Ef is the number of candidates kept while walking the HNSW graph, at least Count
+	Ef     int
+	Filter *Filter
+	// FilterEf bounds the number of nodes visited when walking the HNSW graph with a
+	// filter, Count * 100 if 0
+	FilterEf int
+	// Exact compares the query with every vector even if the set has an HNSW index
+	Exact bool
+}
+
+func New(dim int, metric Metric, index IndexType, m, efConstruction int) *Set {
+	return &Set{
+		Dim:            dim,
+		Metric:         metric,
+		Index:          index,
+		M:              m,
+		EfConstruction: efConstruction,
+		nodes:          make(map[string]*node),
+		rnd:            rand.New(rand.NewPCG(uint64(dim), uint64(m))),
+	}
+}
+
+func (s *Set) Len() int {
+	return len(s.nodes)
+}
+
+func (s *Set) Exists(name string) bool {
+	_, ok := s.nodes[name]
+	return ok
+}
+
+// MaxLevel returns the top layer of the HNSW graph
+func (s *Set) MaxLevel() int {
+	return s.maxLevel
+}
+
+// prepare returns a copy of vector in the form used for the distances and its norm
+func (s *Set) prepare(vector []float32) ([]float32, float32) {
+	v := slices.Clone(vector)
+	if s.Metric != MetricCosine {
+		return v, 1
+	}
+	n := norm(v)
+	if n > 0 {
+		for i := range v {
+			v[i] /= n
+		}
+	}
+	return v, n
+}
+
+// Add adds a vector, or replaces the vector of an existing element keeping its attributes.
+// Returns whether the element was added.
+func (s *Set) Add(name string, vector []float32) bool {
+	n := &node{name: name}
+	n.vector, n.norm = s.prepare(vector)
+	old, exist := s.nodes[name]
+	if exist {
+		n.attributes, n.attrValue = old.attributes, old.attrValue
+		s.Remove(name)
+	}
+	s.nodes[name] = n
+	if s.Index == IndexHNSW {
+		s.insert(n)
+	}
+	return !exist
+}
+
+// Remove removes an element and returns whether it existed
+func (s *Set) Remove(name string) bool {
+	n, exist := s.nodes[name]
+	if !exist {
+		return false
+	}
+	delete(s.nodes, name)
+	if s.Index == IndexHNSW {
+		s.unlink(n)
+	}
+	return true
+}
+
+// Vector returns the vector of an element
+func (s *Set) Vector(name string) ([]float32, bool) {
+	n, exist := s.nodes[name]
+	if !exist {
+		return nil, false
+	}
+	v := make([]float32, len(n.vector))
+	for i, x := range n.vector {
+		v[i] = x * n.norm
+	}
+	return v, true
+}
+
+// Attributes returns the JSON attributes of an element, empty if it has none
+func (s *Set) Attributes(name string) (string, bool) {
+	n, exist := s.nodes[name]
+	if !exist {
+		return "", false
+	}
+	return n.attributes, true
+}
+
+// SetAttributes replaces the attributes of an element with a JSON object, an empty string
+// removing them. Returns whether the element exists.
+func (s *Set) SetAttributes(name string, attributes string) (bool, error) {
+	n, exist := s.nodes[name]
+	if !exist {
+		return false, nil
+	}
+	if attributes == "" {
+		n.attributes, n.attrValue = "", nil
+		return true, nil
+	}
+	obj, err := ParseAttributes(attributes)
+	if err != nil {
+		return true, err
+	}
+	n.attributes, n.attrValue = attributes, obj
+	return true, nil
+}
+
+// ParseAttributes parses the attributes of an element, which must be a JSON object
+func ParseAttributes(attributes string) (*json_doc.Object, error) {
+	v, err := json_doc.Parse(attributes)
+	if err != nil {
+		return nil, ErrInvalidAttributes
+	}
+	obj, ok := v.(*json_doc.Object)
+	if !ok {
+		return nil, ErrInvalidAttributes
+	}
+	return obj, nil
+}
+
+// Search returns the Count elements closest to query, closest first. Elements without
+// attributes never match a filter.
+func (s *Set) Search(query []float32, opts SearchOptions) []Result {
+	q, _ := s.prepare(query)
+	var match func(n *node) bool
+	if opts.Filter != nil {
+		match = func(n *node) bool {
+			return n.attrValue != nil && opts.Filter.Match(n.attrValue)
+		}
+	}
+
+	var found []candidate
+	if s.Index == IndexFlat || opts.Exact || s.entry == nil {
+		found = s.bruteForce(q, opts.Count, match)
+	} else {
+		entry := []candidate{{s.entry, s.Metric.distance(q, s.entry.vector)}}
+		for lc := s.maxLevel; lc > 0; lc-- {
+			entry = s.searchLayer(q, entry, 1, lc, nil, 0)
+		}
+		maxVisits := 0
+		if match != nil {
+			maxVisits = opts.FilterEf
+			if maxVisits == 0 {
+				maxVisits = opts.Count * filterEfFactor
+			}
+		}
+		found = s.searchLayer(q, entry, max(opts.Ef, opts.Count), 0, match, maxVisits)
+	}
+
+	if len(found) > opts.Count {
+		found = found[:opts.Count]
+	}
+	res := make([]Result, len(found))
+	for i, c := range found {
+		res[i] = Result{Name: c.node.name, Score: s.Metric.score(c.dist)}
+	}
+	return res
+}
+
+// bruteForce compares query with every element
+func (s *Set) bruteForce(query []float32, count int, match func(n *node) bool) []candidate {
+	if count <= 0 {
+		return nil
+	}
+	results := &candidateHeap{farthest: true}
+	for _, n := range s.nodes {
+		if match != nil && !match(n) {
+			continue
+		}
+		d := s.Metric.distance(query, n.vector)
+		if results.Len() < count {
+			results.push(candidate{n, d})
+		} else if results.closer(candidate{n, d}, results.items[0]) {
+			results.items[0] = candidate{n, d}
+			results.fix()
+		}
+	}
+	return results.sorted()
+}
@@ -0,0 +1,186 @@
+package vector_set
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func names(results []Result) []string {
+	res := []string{}
+	for _, r := range results {
+		res = append(res, r.Name)
+	}
+	return res
+}
+
+func randomVector(rnd *rand.Rand, dim int) []float32 {
+	v := make([]float32, dim)
+	for i := range v {
+		v[i] = rnd.Float32()*2 - 1
+	}
+	return v
+}
+
+func TestMetrics(t *testing.T) {
+	for _, index := range []IndexType{IndexFlat, IndexHNSW} {
+		s := New(2, MetricCosine, index, 16, 200)
+		s.Add("right", []float32{10, 0})
+		s.Add("up", []float32{0, 1})
+		s.Add("left", []float32{-2, 0})
+		res := s.Search([]float32{1, 0.1}, SearchOptions{Count: 3})
+		assert.Equal(t, []string{"right", "up", "left"}, names(res), index)
+		assert.InDelta(t, 1, res[0].Score, 0.01)
+		assert.InDelta(t, 0, res[2].Score, 0.01)
+
+		s = New(2, MetricL2, index, 16, 200)
+		s.Add("a", []float32{0, 0})
+		s.Add("b", []float32{3, 4})
+		res = s.Search([]float32{3, 4}, SearchOptions{Count: 2})
+		assert.Equal(t, []string{"b", "a"}, names(res), index)
+		assert.InDelta(t, 5, res[1].Score, 1e-6)
+
+		s = New(2, MetricIP, index, 16, 200)
+		s.Add("small", []float32{1, 1})
+		s.Add("large", []float32{2, 2})
+		res = s.Search([]float32{1, 1}, SearchOptions{Count: 1})
+		assert.Equal(t, []string{"large"}, names(res), index)
+		assert.InDelta(t, 4, res[0].Score, 1e-6)
+	}
+}
+
+func TestSetElements(t *testing.T) {
+	s := New(3, MetricCosine, IndexHNSW, 4, 50)
+	assert.True(t, s.Add("a", []float32{1, 2, 2}))
+	assert.False(t, s.Add("a", []float32{0, 3, 4}))
+	assert.Equal(t, 1, s.Len())
+
+	v, ok := s.Vector("a")
+	require.True(t, ok)
+	assert.InDeltaSlice(t, []float32{0, 3, 4}, v, 1e-6)
+
+	ok, err := s.SetAttributes("a", `{"year": 1999}`)
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	_, err = s.SetAttributes("a", `[1]`)
+	assert.ErrorIs(t, err, ErrInvalidAttributes)
+	ok, _ = s.SetAttributes("missing", `{}`)
+	assert.False(t, ok)
+
+	// Replacing the vector keeps the attributes
+	s.Add("a", []float32{1, 1, 1})
+	attrs, _ := s.Attributes("a")
+	assert.Equal(t, `{"year": 1999}`, attrs)
+
+	assert.True(t, s.Remove("a"))
+	assert.False(t, s.Remove("a"))
+	assert.Empty(t, s.Search([]float32{1, 1, 1}, SearchOptions{Count: 5}))
+}
+
+func TestHNSWRecall(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	const dim, n, count = 16, 2000, 10
+	hnsw := New(dim, MetricL2, IndexHNSW, 16, 100)
+	flat := New(dim, MetricL2, IndexFlat, 16, 100)
+	for i := 0; i < n; i++ {
+		v := randomVector(rnd, dim)
+		hnsw.Add(strconv.Itoa(i), v)
+		flat.Add(strconv.Itoa(i), v)
+	}
+	// Remove a tenth of the vectors to check that the graph stays connected
+	for i := 0; i < n; i += 10 {
+		hnsw.Remove(strconv.Itoa(i))
+		flat.Remove(strconv.Itoa(i))
+	}
+
+	found, total := 0, 0
+	for q := 0; q < 50; q++ {
+		query := randomVector(rnd, dim)
+		exact := names(flat.Search(query, SearchOptions{Count: count}))
+		assert.Equal(t, exact, names(hnsw.Search(query, SearchOptions{Count: count, Exact: true})))
+		approx := names(hnsw.Search(query, SearchOptions{Count: count, Ef: 100}))
+		for _, name := range approx {
+			for _, e := range exact {
+				if name == e {
+					found++
+				}
+			}
+		}
+		total += count
+	}
+	recall := float64(found) / float64(total)
+	assert.Greater(t, recall, 0.9, "recall %v", recall)
+}
+
+func TestSearchFilter(t *testing.T) {
+	for _, index := range []IndexType{IndexFlat, IndexHNSW} {
+		s := New(1, MetricL2, index, 4, 50)
+		for i := 0; i < 100; i++ {
+			name := strconv.Itoa(i)
+			s.Add(name, []float32{float32(i)})
+			if i%10 != 0 {
+				s.SetAttributes(name, `{"n": `+name+`, "even": `+strconv.FormatBool(i%2 == 0)+`}`)
+			}
+		}
+		f, err := ParseFilter(".even and .n >= 20")
+		require.NoError(t, err)
+		res := s.Search([]float32{0}, SearchOptions{Count: 3, Filter: f})
+		assert.Equal(t, []string{"22", "24", "26"}, names(res), index)
+	}
+}
+
+func TestFilterExpressions(t *testing.T) {
+	s := New(1, MetricL2, IndexFlat, 4, 50)
+	s.Add("a", []float32{0})
+	s.SetAttributes("a", `{"year": 1984, "genre": "drama", "tags": ["a", "b"], "meta": {"rating": 7.5}, "ok": true}`)
+	attrs := s.nodes["a"].attrValue
+
+	tests := []struct {
+		filter   string
+		expected bool
+	}{
+		{".year == 1984", true},
+		{".year > 1990 || .genre == 'drama'", true},
+		{".year > 1990 or .genre == \"action\"", false},
+		{"not .year > 1990", true},
+		{"!(.ok)", false},
+		{".genre in ['drama', 'comedy']", true},
+		{"'b' in .tags", true},
+		{"'ram' in .genre", true},
+		{".meta.rating >= 7 and .meta.rating < 8", true},
+		{"(.year - 1900) * 2 % 100 == 68", true},
+		{"-.year < 0", true},
+		{".missing == 1", false},
+		{"not .missing", false},
+		{".genre > 1", false},
+		{".tags == ['a', 'b']", true},
+	}
+	for _, tt := range tests {
+		f, err := ParseFilter(tt.filter)
+		require.NoError(t, err, tt.filter)
+		assert.Equal(t, tt.expected, f.Match(attrs), tt.filter)
+	}
+
+	for _, text := range []string{"", ".year ==", "(.year", ".year 1", "..a", ".a.", "'open", "1.2.3", "[1, 2", "#"} {
+		_, err := ParseFilter(text)
+		assert.Error(t, err, text)
+	}
+
+	// Nesting is bounded rather than limited by the stack
+	_, err := ParseFilter(strings.Repeat("(", 100) + ".ok" + strings.Repeat(")", 100))
+	assert.NoError(t, err)
+	for _, text := range []string{
+		strings.Repeat("(", 200) + ".ok" + strings.Repeat(")", 200),
+		strings.Repeat("[", 200) + strings.Repeat("]", 200),
+		strings.Repeat("!", 200) + ".ok",
+		strings.Repeat("-", 200) + "1",
+		strings.Repeat("(", 3000000),
+	} {
+		_, err := ParseFilter(text)
+		assert.EqualError(t, err, "syntax error in FILTER expression: expression nested too deeply", text[:10])
+	}
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"errors"
 	"io"
 	"log"
 	"net"
@@ -22,7 +23,50 @@ type IOHandler struct {
 	ioMultiplexer iomux.IOMultiplexer
 	mu            sync.Mutex
 	server        *Server
-	conns         map[int]net.Conn
+	conns         map[int]*clientConn
+}
+
+// clientConn is a connection monitored by an I/O handler.
+// Replies are written by a dedicated goroutine in the order the commands were read, so the
+// event loop never waits on a worker: a blocking command (BLPOP, ...) can stay unanswered
+// for a long time without holding up the other connections of the handler.
+type clientConn struct {
+	conn net.Conn
+	mu   sync.Mutex
+	// ReplyCh of the dispatched tasks, in order. The queue is unbounded so a client
+	// pipelining many commands never makes the event loop wait for its writer.
+	pending []chan []byte
+	ready   chan struct{} // signaled when a reply channel is queued
+	closed  chan struct{}
+	// query holds the data read and not decoded yet. Only the event loop uses it.
+	query []byte
+	// queued is closed once the last task read is queued to its worker, nil if it already is.
+	// Only the event loop uses it.
+	queued <-chan struct{}
+}
+
+// queueReply hands the reply channel of a dispatched task over to the connection's writer
+func (c *clientConn) queueReply(replyCh chan []byte) {
+	c.mu.Lock()
+	c.pending = append(c.pending, replyCh)
+	c.mu.Unlock()
+	select {
+	case c.ready <- struct{}{}:
+	default:
+	}
+}
+
+// nextReply pops the oldest queued reply channel
+func (c *clientConn) nextReply() (chan []byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.pending) == 0 {
+		return nil, false
+	}
+	replyCh := c.pending[0]
+	c.pending[0] = nil
+	c.pending = c.pending[1:]
+	return replyCh, true
 }
 
 func NewIOHandler(id int, server *Server) (*IOHandler, error) {
@@ -35,7 +79,7 @@ func NewIOHandler(id int, server *Server) (*IOHandler, error) {
 		id:            id,
 		ioMultiplexer: multiplexer,
 		server:        server,
-		conns:         make(map[int]net.Conn), // map from fd to corresponding connection
+		conns:         make(map[int]*clientConn), // map from fd to corresponding connection
 	}, nil
 }
 
@@ -55,7 +99,13 @@ func (h *IOHandler) AddConn(conn net.Conn) error {
 		connFd = int(fd)
 		log.Printf("I/O Handler %d is monitoring fd %d", h.id, connFd)
 		// Store the connection object so it's not garbage collected
-		h.conns[connFd] = conn
+		c := &clientConn{
+			conn:   conn,
+			ready:  make(chan struct{}, 1),
+			closed: make(chan struct{}),
+		}
+		h.conns[connFd] = c
+		go h.writeReplies(connFd, c)
 		// Add to epoll
 		h.ioMultiplexer.Monitor(iomux.Event{
 			Fd: connFd,
@@ -70,12 +120,43 @@ func (h *IOHandler) closeConn(fd int) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	if conn, ok := h.conns[fd]; ok {
-		conn.Close()
+	if c, ok := h.conns[fd]; ok {
+		close(c.closed)
+		c.conn.Close()
 		delete(h.conns, fd)
 	}
 }
 
+// writeReplies waits for the replies of the connection's tasks one by one and writes them back
+func (h *IOHandler) writeReplies(fd int, c *clientConn) {
+	for {
+		replyCh, ok := c.nextReply()
+		if !ok {
+			select {
+			case <-c.closed:
+				return
+			case <-c.ready:
+			}
+			continue
+		}
+		select {
+		case <-c.closed:
+			return
+		case res, ok := <-replyCh:
+			if !ok {
+				// queued after the last reply of a connection to close
+				h.closeConn(fd)
+				return
+			}
+			if _, err := c.conn.Write(res); err != nil {
+				log.Printf("Write error on fd %d: %v", fd, err)
+				h.closeConn(fd)
+				return
+			}
+		}
+	}
+}
+
 func (h *IOHandler) Run() {
 	log.Printf("I/O Handler %d started", h.id)
 	for {
@@ -89,7 +170,7 @@ func (h *IOHandler) Run() {
 			connFd := event.Fd
 
 			h.mu.Lock()
-			conn, ok := h.conns[connFd]
+			c, ok := h.conns[connFd]
 			h.mu.Unlock()
 
 			if !ok {
@@ -97,8 +178,8 @@ func (h *IOHandler) Run() {
 				continue
 			}
 
-			cmd, err := readCommandConn(conn)
-			if err != nil {
+			cmds, err := readCommands(c.conn.Read, &c.query)
+			if err != nil && !errors.Is(err, core.ErrProtocol) {
 				if err == io.EOF || err == syscall.ECONNRESET {
 					//log.Printf("Client disconnected (fd: %d)", connFd)
 				} else {
@@ -108,15 +189,31 @@ func (h *IOHandler) Run() {
 				continue
 			}
 
-			replyCh := make(chan []byte, 1)
-			task := &core.Task{
-				Command: cmd,
-				ReplyCh: replyCh,
+			for _, cmd := range cmds {
+				replyCh := make(chan []byte, 1)
+				task := &core.Task{
+					Command:    cmd,
+					ReplyCh:    replyCh,
+					ConnClosed: c.closed,
+				}
+				// dispatch the command to the corresponding Worker
+				c.queued = h.server.dispatchAfter(c.queued, task)
+				// hand the reply over to the connection's writer instead of waiting for it here
+				c.queueReply(replyCh)
+			}
+
+			if err != nil {
+				// stop reading the connection, the writer closes it after the error reply
+				if err := h.ioMultiplexer.Unmonitor(iomux.Event{Fd: connFd, Op: iomux.OpRead}); err != nil {
+					log.Printf("Can not unmonitor fd %d: %v", connFd, err)
+				}
+				replyCh := make(chan []byte, 1)
+				replyCh <- protocolErrorReply(err)
+				c.queueReply(replyCh)
+				closeCh := make(chan []byte)
+				close(closeCh)
+				c.queueReply(closeCh)
 			}
-			// dispatch the command to the corresponding Worker
-			h.server.dispatch(task)
-			res := <-replyCh
-			syscall.Write(connFd, res)
 		}
 	}
 }
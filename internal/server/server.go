@@ -2,6 +2,8 @@ package server
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"hash/fnv"
 	"io"
 	"log"
@@ -9,6 +11,7 @@ import (
 	"net"
 	"os"
 	"runtime"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"syscall"
@@ -39,18 +42,116 @@ func (s *Server) getPartitionID(key string) int {
 	return int(hasher.Sum32()) % s.numWorkers
 }
 
-func (s *Server) dispatch(task *core.Task) {
+// multiKeys returns all the keys of commands that touch more than one key
+func multiKeys(cmd *core.Command) []string {
+	switch cmd.Cmd {
+	case "BLPOP", "BRPOP", "BZPOPMIN", "BZPOPMAX":
+		if len(cmd.Args) > 1 {
+			return cmd.Args[:len(cmd.Args)-1]
+		}
+	case "LMOVE", "BLMOVE":
+		if len(cmd.Args) > 1 {
+			return cmd.Args[:2]
+		}
+	case "ZMPOP":
+		if len(cmd.Args) == 0 {
+			return nil
+		}
+		if numKeys, err := strconv.Atoi(cmd.Args[0]); err == nil && numKeys > 0 && numKeys < len(cmd.Args) {
+			return cmd.Args[1 : 1+numKeys]
+		}
+	}
+	return nil
+}
+
+// dispatch sends the task to the worker owning its keys. It returns nil once the task is
+// queued, or a channel closed once it is when the task first waits for data of other workers.
+func (s *Server) dispatch(task *core.Task) <-chan struct{} {
+	if owner, inputs, ok := core.ZsetOpKeys(task.Command); ok {
+		return s.dispatchZsetOp(task, owner, inputs)
+	}
+
+	// Keys of a multi-key command must be owned by the same worker, otherwise a
+	// blocked client would wait on a worker that never sees the pushes to its keys.
+	keys := multiKeys(task.Command)
+	for _, key := range keys {
+		if s.getPartitionID(key) != s.getPartitionID(keys[0]) {
+			task.ReplyCh <- core.Encode(errors.New("CROSSSLOT Keys in request don't hash to the same worker"), false)
+			return nil
+		}
+	}
+
 	// Commands like PING etc., don't have a key.
 	// We can send them to any worker.
 	var key string
 	var workerID int
-	if len(task.Command.Args) > 0 {
+	if len(keys) > 0 {
+		// The first argument of ZMPOP is not a key
+		workerID = s.getPartitionID(keys[0])
+	} else if len(task.Command.Args) > 0 {
 		key = task.Command.Args[0]
 		workerID = s.getPartitionID(key)
 	} else {
 		workerID = rand.Intn(s.numWorkers)
 	}
 	s.workers[workerID].TaskCh <- task
+	return nil
+}
+
+// dispatchAfter dispatches the task once prev, returned by the dispatch of the previous task
+// of the connection, is closed, so the commands of a connection run in the order they were
+// sent. It never waits: a task behind a pending dispatch is dispatched from a goroutine.
+func (s *Server) dispatchAfter(prev <-chan struct{}, task *core.Task) <-chan struct{} {
+	if prev != nil {
+		select {
+		case <-prev:
+		default:
+			done := make(chan struct{})
+			go func() {
+				<-prev
+				if queued := s.dispatch(task); queued != nil {
+					<-queued
+				}
+				close(done)
+			}()
+			return done
+		}
+	}
+	return s.dispatch(task)
+}
+
+// dispatchZsetOp sends a sorted set operation (ZUNIONSTORE, ...) to the worker owning its
+// destination or first input key, together with copies of the inputs owned by other workers.
+// The copies are requested from all the workers at once and gathered by a goroutine, so the
+// I/O handler does not wait for busy workers. The operation is not atomic across workers.
+func (s *Server) dispatchZsetOp(task *core.Task, owner string, inputs []string) <-chan struct{} {
+	ownerID := s.getPartitionID(owner)
+	remote := make(map[int][]string)
+	for _, key := range inputs {
+		if id := s.getPartitionID(key); id != ownerID {
+			remote[id] = append(remote[id], key)
+		}
+	}
+	if len(remote) == 0 {
+		s.workers[ownerID].TaskCh <- task
+		return nil
+	}
+	snapshots := make([]<-chan map[string]map[string]float64, 0, len(remote))
+	for id, keys := range remote {
+		snapshots = append(snapshots, s.workers[id].SnapshotZsets(keys))
+	}
+	done := make(chan struct{})
+	go func() {
+		task.Inputs = make(map[string]map[string]float64)
+		for _, snapshot := range snapshots {
+			for key, scores := range <-snapshot {
+				task.Inputs[key] = scores
+			}
+		}
+		s.workers[ownerID].TaskCh <- task
+		close(done)
+	}()
+	return done
 }
 
 func NewServer() *Server {
@@ -109,27 +210,51 @@ func (s *Server) Stop() {
 
 var serverStatus int32 = constant.ServerStatusIdle
 
-func readCommand(fd int) (*core.Command, error) {
-	var buf = make([]byte, 512)
-	n, err := syscall.Read(fd, buf)
+// readCommands reads the data available from a connection and returns the commands it
+// completes. query holds the data of the connection not decoded yet: a command split over
+// several reads is decoded once its last byte arrives. It fails with core.ErrProtocol when
+// the data is malformed or a command exceeds constant.MaxQueryBufferSize, after returning
+// the commands before it.
+func readCommands(read func([]byte) (int, error), query *[]byte) ([]*core.Command, error) {
+	buf := make([]byte, constant.ReadBufferSize)
+	n, err := read(buf)
 	if err != nil {
 		return nil, err
 	}
 	if n == 0 {
 		return nil, io.EOF
 	}
-	return core.ParseCmd(buf)
-}
-
-func readCommandConn(conn net.Conn) (*core.Command, error) {
-	var buf = make([]byte, 512)
-	// Use the Read method from the net.Conn interface
-	n, err := conn.Read(buf)
-	if err != nil {
-		return nil, err // This will properly handle io.EOF
+	data := append(*query, buf[:n]...)
+	var cmds []*core.Command
+	pos := 0
+	for pos < len(data) {
+		cmd, size, err := core.DecodeCmd(data[pos:])
+		if errors.Is(err, core.ErrIncomplete) {
+			break
+		}
+		if err != nil {
+			return cmds, err
+		}
+		cmds = append(cmds, cmd)
+		pos += size
+	}
+	if len(data)-pos > constant.MaxQueryBufferSize {
+		return cmds, fmt.Errorf("%w: too big command", core.ErrProtocol)
 	}
-	return core.ParseCmd(buf[:n])
+	switch {
+	case pos == len(data):
+		*query = nil
+	case pos > 0:
+		*query = append([]byte(nil), data[pos:]...)
+	default:
+		*query = data
+	}
+	return cmds, nil
+}
 
+// protocolErrorReply is the reply sent before closing a connection sending malformed data
+func protocolErrorReply(err error) []byte {
+	return core.Encode(errors.New("(error) ERR "+err.Error()), false)
 }
 
 // func respond(data string, fd int) error {
@@ -177,6 +302,8 @@ func RunIoMultiplexingServer(wg *sync.WaitGroup) {
 	}
 
 	var events = make([]iomux.Event, config.MaxConnection)
+	// data read from each connection and not decoded yet
+	var queries = make(map[int]*[]byte)
 	var lastActiveExpireExecTime = time.Now()
 
 	for atomic.LoadInt32(&serverStatus) != constant.ServerStatusShutdown {
@@ -191,6 +318,9 @@ func RunIoMultiplexingServer(wg *sync.WaitGroup) {
 			atomic.SwapInt32(&serverStatus, constant.ServerStatusIdle)
 			lastActiveExpireExecTime = time.Now() // Idle
 		}
+		// reply to the blocked clients (BLPOP, ...) whose timeout has passed
+		core.ExpireBlockedClients()
+
 		// wait for file descriptors in the monitoring list to be ready for I/O
 		// it is a blocking call.
 		events, err = ioMultiplexer.Wait()
@@ -215,6 +345,7 @@ func RunIoMultiplexingServer(wg *sync.WaitGroup) {
 					continue
 				}
 				log.Printf("set up a new connection")
+				queries[connFd] = new([]byte)
 				// ask epoll to monitor this connection
 				if err = ioMultiplexer.Monitor(iomux.Event{
 					Fd: connFd,
@@ -227,36 +358,40 @@ func RunIoMultiplexingServer(wg *sync.WaitGroup) {
 					return
 				}
 				// handle data from an existing connection
-				// read the command, execute it and write back the response.
-				cmd, err := readCommand(events[i].Fd)
-				if err != nil {
+				// read the commands, execute them and write back the responses.
+				fd := events[i].Fd
+				cmds, err := readCommands(func(buf []byte) (int, error) {
+					return syscall.Read(fd, buf)
+				}, queries[fd])
+				if err != nil && !errors.Is(err, core.ErrProtocol) {
 					if err == io.EOF || err == syscall.ECONNRESET {
 						log.Println("client disconnected: ", err)
-
-						err = ioMultiplexer.Unmonitor(iomux.Event{
-							Fd: events[i].Fd,
-							Op: iomux.OpRead,
-						})
-						if err != nil {
-							log.Println("Can not unmonitor: ", err)
-						}
-						_ = syscall.Close(events[i].Fd)
+					} else {
+						log.Println("read error:", err)
 						continue
 					}
-					log.Println("read error:", err)
-					continue
 				}
-				if err = core.ExecuteAndResponse(cmd, events[i].Fd); err != nil {
-					log.Println("err write: ", err)
+				for _, cmd := range cmds {
+					if werr := core.ExecuteAndResponse(cmd, fd); werr != nil {
+						log.Println("err write: ", werr)
+						err = werr
+						break
+					}
+				}
+				if errors.Is(err, core.ErrProtocol) {
+					_, _ = syscall.Write(fd, protocolErrorReply(err))
+				}
+				if err != nil {
+					core.UnblockClient(fd)
+					delete(queries, fd)
 
-					err = ioMultiplexer.Unmonitor(iomux.Event{
-						Fd: events[i].Fd,
+					if err = ioMultiplexer.Unmonitor(iomux.Event{
+						Fd: fd,
 						Op: iomux.OpRead,
-					})
-					if err != nil {
+					}); err != nil {
 						log.Println("Can not unmonitor: ", err)
 					}
-					_ = syscall.Close(events[i].Fd)
+					_ = syscall.Close(fd)
 				}
 			}
 		}
@@ -0,0 +1,139 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/spaghetti-lover/multithread-redis/internal/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// encodeCommand encodes a command as a client sends it
+func encodeCommand(args ...string) []byte {
+	res := []byte(fmt.Sprintf("*%d\r\n", len(args)))
+	for _, arg := range args {
+		res = append(res, core.Encode(arg, false)...)
+	}
+	return res
+}
+
+// segmentedReader serves data in segments of at most size bytes, as a socket may
+type segmentedReader struct {
+	data []byte
+	size int
+}
+
+func (r *segmentedReader) Read(buf []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(buf, r.data[:min(r.size, len(r.data))])
+	r.data = r.data[n:]
+	return n, nil
+}
+
+// readAll decodes the commands of data read in segments of size bytes
+func readAll(t *testing.T, data []byte, size int) []*core.Command {
+	r := &segmentedReader{data: data, size: size}
+	var query []byte
+	var cmds []*core.Command
+	for len(r.data) > 0 {
+		read, err := readCommands(r.Read, &query)
+		require.NoError(t, err)
+		cmds = append(cmds, read...)
+	}
+	assert.Empty(t, query)
+	return cmds
+}
+
+// execute runs a command on the single-threaded executor and decodes its reply
+func execute(t *testing.T, cmd *core.Command) interface{} {
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	defer r.Close()
+	replies := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(r)
+		replies <- data
+	}()
+	require.NoError(t, core.ExecuteAndResponse(cmd, int(w.Fd())))
+	w.Close()
+	res, err := core.Decode(<-replies)
+	require.NoError(t, err)
+	return res
+}
+
+func executeArgs(t *testing.T, args ...string) interface{} {
+	cmds := readAll(t, encodeCommand(args...), 512)
+	require.Len(t, cmds, 1)
+	return execute(t, cmds[0])
+}
+
+// dumpAndLoad copies a sketch with SCANDUMP and LOADCHUNK, the LOADCHUNK commands being
+// pipelined and read in segments smaller than the chunks
+func dumpAndLoad(t *testing.T, prefix, src, dst string) int {
+	var pipeline []byte
+	iter := "0"
+	for {
+		res := executeArgs(t, prefix+".SCANDUMP", src, iter).([]interface{})
+		next := strconv.FormatInt(res[0].(int64), 10)
+		if next == "0" {
+			break
+		}
+		pipeline = append(pipeline, encodeCommand(prefix+".LOADCHUNK", dst, next, res[1].(string))...)
+		iter = next
+	}
+	cmds := readAll(t, pipeline, 1000)
+	for _, cmd := range cmds {
+		require.Equal(t, "OK", execute(t, cmd))
+	}
+	return len(cmds)
+}
+
+func TestScanDumpLoadChunk(t *testing.T) {
+	require.Equal(t, "OK", executeArgs(t, "BF.RESERVE", "dump:bf", "0.001", "100000"))
+	for i := 0; i < 1000; i++ {
+		executeArgs(t, "BF.ADD", "dump:bf", "item"+strconv.Itoa(i))
+	}
+	// A header and several chunks of 64KB
+	assert.Greater(t, dumpAndLoad(t, "BF", "dump:bf", "dump:bf2"), 3)
+	for i := 0; i < 1000; i++ {
+		require.Equal(t, int64(1), executeArgs(t, "BF.EXISTS", "dump:bf2", "item"+strconv.Itoa(i)))
+	}
+	assert.Equal(t, executeArgs(t, "BF.INFO", "dump:bf"), executeArgs(t, "BF.INFO", "dump:bf2"))
+
+	require.Equal(t, "OK", executeArgs(t, "CMS.INITBYDIM", "dump:cms", "20000", "5"))
+	require.Equal(t, []interface{}{"7"}, executeArgs(t, "CMS.INCRBY", "dump:cms", "a", "7"))
+	assert.Greater(t, dumpAndLoad(t, "CMS", "dump:cms", "dump:cms2"), 2)
+	assert.Equal(t, []interface{}{"7", "0"}, executeArgs(t, "CMS.QUERY", "dump:cms2", "a", "b"))
+}
+
+func TestReadCommands(t *testing.T) {
+	// Pipelined and inline commands
+	data := append(encodeCommand("SET", "k", "v"), "PING\r\n\r\n"...)
+	data = append(data, encodeCommand("GET", "k")...)
+	cmds := readAll(t, data, 3)
+	require.Len(t, cmds, 3)
+	assert.Equal(t, &core.Command{Cmd: "SET", Args: []string{"k", "v"}}, cmds[0])
+	assert.Equal(t, &core.Command{Cmd: "PING", Args: []string{}}, cmds[1])
+	assert.Equal(t, &core.Command{Cmd: "GET", Args: []string{"k"}}, cmds[2])
+
+	// Malformed data gets a protocol error, after the commands before it
+	for _, data := range []string{
+		"*1\r\n$99999999999\r\n",
+		"*1\r\n$-2\r\n",
+		"*1\r\n$x\r\n",
+		"*1\r\n$2\r\nabc\r\n",
+		"*99999999999\r\n",
+		"*1\r\n?\r\n",
+	} {
+		query := encodeCommand("PING")
+		cmds, err := readCommands((&segmentedReader{data: []byte(data), size: 512}).Read, &query)
+		assert.ErrorIs(t, err, core.ErrProtocol, data)
+		assert.Len(t, cmds, 1, data)
+	}
+}